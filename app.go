@@ -2,23 +2,75 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
+	"log/slog"
+	"net/http"
 	"path/filepath"
 	goruntime "runtime"
+	"sync"
 
+	"penguin-tunes/pkg/analysis"
 	cfg "penguin-tunes/pkg/config"
 	"penguin-tunes/pkg/indexer"
+	"penguin-tunes/pkg/instance"
+	"penguin-tunes/pkg/lifecycle"
+	"penguin-tunes/pkg/logging"
+	"penguin-tunes/pkg/lyrics"
+	"penguin-tunes/pkg/media"
+	"penguin-tunes/pkg/mpd"
+	"penguin-tunes/pkg/mpris"
+	"penguin-tunes/pkg/organizer"
+	"penguin-tunes/pkg/player"
+	"penguin-tunes/pkg/playlist"
+	"penguin-tunes/pkg/stats"
+	"penguin-tunes/pkg/waveform"
 
 	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 // App struct
 type App struct {
-	ctx        context.Context
+	ctx context.Context
+	// log is the app's logger; logs holds every component's once started
+	log  *slog.Logger
+	logs *logging.Logs
+	// jobs runs background work that quitting cancels and waits for
+	jobs *lifecycle.Group
+	// dirLock makes this instance the writer of the data dir; nil when
+	// another process holds it and the library was opened read-only
+	dirLock    *cfg.Lock
 	cfgManager *cfg.Manager
 	idx        *indexer.Index
+	watcherMtx sync.Mutex
 	watcher    *indexer.Watcher
+	player     *player.Player
+	mpris      *mpris.Server
+	playlists  *playlist.Store
+	stats      *stats.Store
+	tracker    *stats.Tracker
+	journal    *organizer.Journal
+	analyzer   *analysis.Runner
+	waveforms  *waveform.Cache
+	lyrics     *lyrics.Follower
+	// subsonic serves the API for remote players while enabled
+	subsonicMtx sync.Mutex
+	subsonic    *http.Server
+	subsonicCfg cfg.SubsonicConfig
+	// mpd serves MPD clients while enabled
+	mpdMtx sync.Mutex
+	mpd    *mpd.Server
+	mpdCfg cfg.MPDConfig
+	// instance receives the command lines of later launches; launchArgs is this one's
+	instance   *instance.Server
+	launchArgs []string
+	// scans runs library scans one at a time; nil while read-only
+	scans *indexer.ScanCoordinator
+	// media streams tracks to the frontend's audio element
+	media *media.Handler
+	// smartResults caches evaluated smart playlist track IDs to detect changes
+	smartMtx     sync.Mutex
+	smartResults map[string][]string
 }
 
 // wailsEmitter adapts Wails runtime to indexer.EventEmitter
@@ -28,54 +80,157 @@ func (w wailsEmitter) Emit(ctx context.Context, event string, data any) {
 	wailsruntime.EventsEmit(ctx, event, data)
 }
 
+// appEmitter lets App react to index changes reported by the watcher before forwarding them
+type appEmitter struct{ a *App }
+
+func (e appEmitter) Emit(ctx context.Context, event string, data any) {
+	if event == "index-updated" {
+		e.a.onIndexUpdated()
+		e.a.analyzeLibrary()
+	}
+	wailsruntime.EventsEmit(ctx, event, data)
+}
+
 // NewApp creates a new App application struct
 func NewApp() *App {
-	return &App{}
+	a := &App{log: slog.Default()}
+	a.media = media.New(a.mediaTrack)
+	return a
 }
 
 // startup is called when the app starts. The context is saved
 // so we can call the runtime methods
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
-	// Initialize config manager
-	cm, err := cfg.NewManager()
+	a.player = player.New(ctx, wailsEmitter{})
+	appDir, err := cfg.DefaultDir()
+	if err == nil {
+		a.startLogging(appDir)
+	}
+	a.startJobs(ctx)
+	a.startMPRIS()
 	if err != nil {
-		fmt.Printf("config manager error: %v\n", err)
+		a.log.Error("config dir failed", "err", err)
+		return
+	}
+	// Only one process writes the data dir; the others get a read-only view
+	lock, err := cfg.LockDir(appDir)
+	if err != nil && !errors.Is(err, cfg.ErrLocked) {
+		a.log.Error("lock failed", "dir", appDir, "err", err)
 		return
 	}
+	var cm *cfg.Manager
+	if lock != nil {
+		a.dirLock = lock
+		a.jobs.OnShutdown("lock", lock.Unlock)
+		// only the writer knows no write is in progress
+		removed, err := lock.RemoveStaleTemp()
+		if err != nil {
+			a.log.Warn("remove stale temp files failed", "err", err)
+		}
+		if len(removed) > 0 {
+			a.log.Info("removed stale temp files", "files", removed)
+		}
+		cm, err = cfg.NewManagerAt(appDir)
+	} else {
+		a.log.Warn("opening the library read-only", "err", err)
+		cm, err = cfg.OpenManagerReadOnly(appDir)
+	}
+	if err != nil {
+		a.log.Error("config manager failed", "err", err)
+		return
+	}
+	cm.SetLogger(a.logger("config"))
+	a.applyLogLevels(cm.GetConfig().Logging)
 	a.cfgManager = cm
+	a.media.SetLogger(a.logger("media"))
+	a.media.SetDSP(cm.GetConfig().DSP)
 	// determine index path
-	cfgDir, _ := os.UserConfigDir()
-	appDir := filepath.Join(cfgDir, "PenguinTunes")
 	idxPath := filepath.Join(appDir, "index.json")
 	a.idx = indexer.NewIndex(idxPath, appDir)
+	a.idx.SetLogger(a.logger("indexer"))
 	if err := a.idx.LoadFromFile(); err != nil {
-		fmt.Printf("load index error: %v\n", err)
+		a.log.Error("load index failed", "err", err)
 	}
-	// Watcher
-	wa, err := indexer.NewWatcher(a.ctx, a.idx, a.cfgManager, wailsEmitter{})
-	if err != nil {
-		fmt.Printf("watcher error: %v\n", err)
-	} else {
-		a.watcher = wa
-		stop, _ := wa.Start()
-		// for now, stop is not saved; we'll keep it running until app exit
-		_ = stop
+	a.idx.SetReadOnly(cm.ReadOnly())
+	if !cm.ReadOnly() {
+		// saves in-place changes no job or watcher has saved yet
+		a.jobs.OnShutdown("index", a.idx.SaveToFile)
 	}
+	a.playlists = playlist.NewStoreAtBase(appDir)
+	if err := a.playlists.LoadFromFile(); err != nil {
+		a.log.Error("load playlists failed", "err", err)
+	}
+	a.playlists.SetReadOnly(cm.ReadOnly())
+	a.startStats(appDir)
+	a.startOrganizer(appDir)
+	a.startAnalysis(appDir)
+	a.startLyrics()
+	a.startSubsonic()
+	a.startMPD()
+	a.jobs.OnShutdown("servers", a.stopServers)
+	a.startInstance()
+	if cm.ReadOnly() {
+		// scans and the watcher would only fail to save
+		a.emitIndexUpdated()
+		return
+	}
+	a.startWatcher()
+	a.jobs.OnShutdown("watcher", a.stopWatcher)
+	a.startScans()
 	// Start initial scan in background
 	// Emit current index (if any) so frontend can display it instantly
-	wailsruntime.EventsEmit(a.ctx, "index-updated", a.idx.GetAll())
-	go func() {
-		cfg := cm.GetConfig()
-		if len(cfg.SrcDirs) > 0 {
-			// scan
-			if err := indexer.ScanDirs(cfg.SrcDirs, a.idx, goruntime.NumCPU()); err != nil {
-				fmt.Printf("scan error: %v\n", err)
+	a.emitIndexUpdated()
+	a.startScan(cm.GetConfig().SrcDirs)
+}
+
+// scanDirs rescans dirs, imports playlist files found there if enabled and
+// notifies the frontend. The scan coordinator runs it, one scan at a time.
+func (a *App) scanDirs(ctx context.Context, dirs []string) error {
+	var found []string
+	opts := a.scanOptions()
+	opts.Concurrency = goruntime.NumCPU()
+	if a.cfgManager.GetConfig().AutoImportPlaylists {
+		opts.OnFile = func(path string) {
+			if playlist.IsPlaylistFile(path) {
+				found = append(found, path)
 			}
-			// emit event to frontend
-			wailsruntime.EventsEmit(a.ctx, "index-updated", a.idx.GetAll())
 		}
-	}()
+	}
+	err := indexer.ScanDirsContext(ctx, dirs, a.idx, opts)
+	if errors.Is(err, context.Canceled) {
+		// what was read is saved; the rest waits for the next scan
+		return err
+	}
+	// playlists are resolved after the scan so they can see every track
+	a.autoImportPlaylists(found)
+	// emit event to frontend
+	a.emitIndexUpdated()
+	a.pruneWaveforms()
+	a.analyzeLibrary()
+	return err
+}
+
+// scanOptions builds the metadata options shared by scans and tag edits from the config
+func (a *App) scanOptions() indexer.ScanOptions {
+	opts, err := indexer.ConfigScanOptions(a.cfgManager.GetConfig())
+	if err != nil {
+		a.log.Warn("bad path template", "err", err)
+	}
+	return opts
+}
+
+// emitIndexUpdated sends the full track list to the frontend after index changes
+func (a *App) emitIndexUpdated() {
+	a.onIndexUpdated()
+	wailsruntime.EventsEmit(a.ctx, "index-updated", a.idx.GetAll())
+}
+
+// onIndexUpdated brings state that depends on the index in line with it
+func (a *App) onIndexUpdated() {
+	a.reconcilePlaylists()
+	a.refreshSmartPlaylists()
+	a.notifyMPD()
 }
 
 // GetConfig returns current configuration
@@ -91,29 +246,24 @@ func (a *App) SaveConfig(cfg cfg.Config) error {
 	if a.cfgManager == nil {
 		return fmt.Errorf("not initialized")
 	}
-	if err := a.cfgManager.SaveConfig(cfg); err != nil {
+	if _, err := indexer.ParsePathTemplates(cfg.PathTemplates); err != nil {
 		return err
 	}
-	// When srcDirs change, restart scan and watchers
-	go func() {
-		// Kick off a scan
-		if a.idx != nil {
-			if err := indexer.ScanDirs(cfg.SrcDirs, a.idx, goruntime.NumCPU()); err != nil {
-				fmt.Printf("scan error: %v\n", err)
-			}
-			wailsruntime.EventsEmit(a.ctx, "index-updated", a.idx.GetAll())
-		}
-	}()
-	// restart watchers to pick new srcDirs
-	if a.watcher != nil {
-		_ = a.watcher.Close()
+	if err := checkSubsonic(cfg.Subsonic); err != nil {
+		return err
 	}
-	// create new watcher and add watches
-	wa, err := indexer.NewWatcher(a.ctx, a.idx, a.cfgManager, wailsEmitter{})
-	if err == nil {
-		a.watcher = wa
-		_, _ = wa.Start()
+	if err := checkLogging(cfg.Logging); err != nil {
+		return err
+	}
+	old := a.cfgManager.GetConfig()
+	if err := a.cfgManager.SaveConfig(cfg); err != nil {
+		return err
 	}
+	cur := a.cfgManager.GetConfig()
+	a.applyLogLevels(cur.Logging)
+	a.startSubsonic()
+	a.startMPD()
+	a.rescanChanged(old, cur)
 	return nil
 }
 
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+
+	"penguin-tunes/pkg/analysis"
+	"penguin-tunes/pkg/indexer"
+	"penguin-tunes/pkg/waveform"
+
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// startAnalysis sets up the background pass that fingerprints decoded audio,
+// fills the waveform cache and, when enabled, estimates tempo and key
+func (a *App) startAnalysis(appDir string) {
+	seconds := a.cfgManager.GetConfig().FingerprintSeconds
+	a.waveforms = waveform.NewCacheAtBase(appDir)
+	if a.cfgManager.ReadOnly() {
+		// the cache and fingerprints are filled by the process that holds the data dir
+		a.waveforms.SetReadOnly(true)
+		return
+	}
+	a.analyzer = analysis.NewRunner(a.idx,
+		analysis.NewFingerprintTask(seconds),
+		analysis.NewWaveformTask(a.waveforms),
+		analysis.NewTempoKeyTask(func() bool { return a.cfgManager.GetConfig().AnalyzeTempoKey }),
+	)
+	a.analyzer.SetLogger(a.logger("analysis"))
+	a.analyzer.OnProgress = func(p analysis.Progress) {
+		wailsruntime.EventsEmit(a.ctx, "analysis-progress", p)
+	}
+}
+
+// analyzeLibrary runs the analysis pass in the background over tracks that still need it
+func (a *App) analyzeLibrary() {
+	if a.analyzer == nil {
+		return
+	}
+	// a pass already running picks up the new work itself
+	a.jobs.Go("analysis", a.analyzer.Run)
+}
+
+// FindSimilar lists fingerprinted tracks that sound like the given one, best
+// match first; threshold 0 uses the default
+func (a *App) FindSimilar(id string, threshold float64) ([]indexer.SimilarTrack, error) {
+	if a.idx == nil {
+		return nil, fmt.Errorf("index not initialized")
+	}
+	return a.idx.FindSimilar(id, threshold)
+}
+
+// pruneWaveforms drops the cached peaks of tracks that left the library
+func (a *App) pruneWaveforms() {
+	if a.waveforms == nil {
+		return
+	}
+	keep := make(map[string]bool)
+	for _, t := range a.idx.GetAll() {
+		keep[t.ID] = true
+	}
+	if err := a.waveforms.Prune(keep); err != nil {
+		a.log.Error("prune waveforms failed", "err", err)
+	}
+}
+
+// GetWaveform returns the seek bar peaks of a track, or nil while the
+// background analysis hasn't reached it or can't decode it
+func (a *App) GetWaveform(id string) (*waveform.Peaks, error) {
+	if a.idx == nil || a.waveforms == nil {
+		return nil, fmt.Errorf("index not initialized")
+	}
+	t := a.idx.GetByID(id)
+	if t == nil {
+		return nil, fmt.Errorf("track %s not found", id)
+	}
+	st, err := waveform.StampOf(t.Path)
+	if err != nil {
+		return nil, err
+	}
+	return a.waveforms.Get(id, st)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+
+	cfg "penguin-tunes/pkg/config"
+	"penguin-tunes/pkg/dsp"
+
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// dspProbeRate is used to validate DSP settings before they are persisted
+const dspProbeRate = 48000
+
+// GetEQPresets returns built-in presets followed by user presets
+func (a *App) GetEQPresets() ([]cfg.EQPreset, error) {
+	if a.cfgManager == nil {
+		return nil, fmt.Errorf("config manager not initialized")
+	}
+	return append(dsp.BuiltinPresets(), a.cfgManager.GetConfig().DSP.Presets...), nil
+}
+
+// SaveEQPreset stores a user preset, replacing one with the same name
+func (a *App) SaveEQPreset(p cfg.EQPreset) error {
+	if a.cfgManager == nil {
+		return fmt.Errorf("not initialized")
+	}
+	if p.Name == "" {
+		return fmt.Errorf("preset name is required")
+	}
+	if dsp.IsBuiltinPreset(p.Name) {
+		return fmt.Errorf("preset %q is built-in", p.Name)
+	}
+	if _, err := dsp.NewEqualizer(p.Bands, dspProbeRate); err != nil {
+		return err
+	}
+	c := a.cfgManager.GetConfig()
+	replaced := false
+	for i := range c.DSP.Presets {
+		if c.DSP.Presets[i].Name == p.Name {
+			c.DSP.Presets[i] = p
+			replaced = true
+		}
+	}
+	if !replaced {
+		c.DSP.Presets = append(c.DSP.Presets, p)
+	}
+	return a.cfgManager.SaveConfig(c)
+}
+
+// DeleteEQPreset removes a user preset
+func (a *App) DeleteEQPreset(name string) error {
+	if a.cfgManager == nil {
+		return fmt.Errorf("not initialized")
+	}
+	if dsp.IsBuiltinPreset(name) {
+		return fmt.Errorf("preset %q is built-in", name)
+	}
+	c := a.cfgManager.GetConfig()
+	n := make([]cfg.EQPreset, 0, len(c.DSP.Presets))
+	for _, p := range c.DSP.Presets {
+		if p.Name == name {
+			continue
+		}
+		n = append(n, p)
+	}
+	c.DSP.Presets = n
+	return a.cfgManager.SaveConfig(c)
+}
+
+// ApplyEQPreset copies a preset's bands and preamp into the active DSP settings
+func (a *App) ApplyEQPreset(name string) error {
+	if a.cfgManager == nil {
+		return fmt.Errorf("not initialized")
+	}
+	c := a.cfgManager.GetConfig()
+	p, ok := dsp.FindPreset(name, c.DSP.Presets)
+	if !ok {
+		return fmt.Errorf("preset %q not found", name)
+	}
+	c.DSP.Preset = p.Name
+	c.DSP.Bands = p.Bands
+	c.DSP.Preamp = p.Preamp
+	if err := a.cfgManager.SaveConfig(c); err != nil {
+		return err
+	}
+	a.dspChanged()
+	return nil
+}
+
+// SetDSPConfig updates bands, preamp, balance and bypass flags; user presets are kept as stored
+func (a *App) SetDSPConfig(d cfg.DSPConfig) error {
+	if a.cfgManager == nil {
+		return fmt.Errorf("not initialized")
+	}
+	if _, err := dsp.FromConfig(d, dspProbeRate); err != nil {
+		return err
+	}
+	c := a.cfgManager.GetConfig()
+	d.Presets = c.DSP.Presets
+	c.DSP = d
+	// saved directly on the manager: DSP changes must not trigger a rescan like SaveConfig does
+	if err := a.cfgManager.SaveConfig(c); err != nil {
+		return err
+	}
+	a.dspChanged()
+	return nil
+}
+
+// GetDSPGraph returns the active DSP settings as gains and filter
+// coefficients for sampleRate, for the frontend to build its Web Audio graph
+// from for sources the media handler doesn't process; it is rebuilt on every
+// dsp-changed event
+func (a *App) GetDSPGraph(sampleRate float64) (dsp.Graph, error) {
+	if a.cfgManager == nil {
+		return dsp.Graph{}, fmt.Errorf("not initialized")
+	}
+	ch, err := dsp.FromConfig(a.cfgManager.GetConfig().DSP, sampleRate)
+	if err != nil {
+		return dsp.Graph{}, err
+	}
+	return ch.Graph(sampleRate), nil
+}
+
+// dspChanged applies new settings to the tracks being streamed and tells
+// the frontend to fetch the graph again for the others
+func (a *App) dspChanged() {
+	a.media.SetDSP(a.cfgManager.GetConfig().DSP)
+	wailsruntime.EventsEmit(a.ctx, "dsp-changed", nil)
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"fmt"
+
+	"penguin-tunes/pkg/indexer"
+)
+
+// FindDuplicates groups library tracks that are copies of the same song, each
+// with a suggested copy to keep
+func (a *App) FindDuplicates(opts indexer.DuplicateOptions) ([]indexer.DuplicateGroup, error) {
+	if a.idx == nil {
+		return nil, fmt.Errorf("index not initialized")
+	}
+	return a.idx.FindDuplicates(opts)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"penguin-tunes/pkg/instance"
+)
+
+// claimInstance makes this process the running instance. When another one
+// is running, args are forwarded to it and ok is false: this launch is done.
+// srv is nil when the socket can't be used; the app then runs unguarded.
+func claimInstance(args []string) (srv *instance.Server, ok bool) {
+	sock, err := instance.SocketPath()
+	if err != nil {
+		slog.Warn("single instance unavailable", "err", err)
+		return nil, true
+	}
+	srv, err = instance.Listen(sock)
+	if errors.Is(err, instance.ErrRunning) {
+		dir, _ := os.Getwd()
+		if err := instance.Send(sock, instance.Request{Dir: dir, Args: args}); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return nil, false
+	}
+	if err != nil {
+		slog.Warn("single instance unavailable", "err", err)
+		return nil, true
+	}
+	return srv, true
+}
+
+// startInstance handles the command line of this launch and of later ones
+func (a *App) startInstance() {
+	if len(a.launchArgs) > 0 {
+		dir, _ := os.Getwd()
+		if err := a.handleLaunch(instance.Request{Dir: dir, Args: a.launchArgs}); err != nil {
+			a.log.Warn("command line failed", "err", err)
+		}
+	}
+	if a.instance != nil {
+		a.jobs.OnShutdown("instance", a.instance.Close)
+		go a.instance.Serve(a.handleLaunch)
+	}
+}
+
+// launch is a parsed command line
+type launch struct {
+	addDirs []string
+	paths   []string
+	enqueue bool
+}
+
+// parseLaunch reads "[--enqueue] [--add-dir DIR]... [PATH]..." with paths
+// made absolute against the launch's working dir
+func parseLaunch(req instance.Request) (launch, error) {
+	var l launch
+	abs := func(p string) string {
+		if strings.HasPrefix(p, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				p = filepath.Join(home, p[2:])
+			}
+		}
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(req.Dir, p)
+		}
+		return filepath.Clean(p)
+	}
+	args := req.Args
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			for _, p := range args[i+1:] {
+				l.paths = append(l.paths, abs(p))
+			}
+			return l, nil
+		case arg == "--enqueue":
+			l.enqueue = true
+		case arg == "--add-dir":
+			if i+1 >= len(args) {
+				return l, fmt.Errorf("--add-dir needs a directory")
+			}
+			i++
+			l.addDirs = append(l.addDirs, abs(args[i]))
+		case strings.HasPrefix(arg, "--add-dir="):
+			l.addDirs = append(l.addDirs, abs(strings.TrimPrefix(arg, "--add-dir=")))
+		case strings.HasPrefix(arg, "file://"):
+			// file managers may pass URIs
+			if u, err := url.Parse(arg); err == nil {
+				l.paths = append(l.paths, filepath.Clean(u.Path))
+			}
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			return l, fmt.Errorf("unknown option %s", arg)
+		default:
+			l.paths = append(l.paths, abs(arg))
+		}
+	}
+	return l, nil
+}
+
+// handleLaunch adds the requested source dirs and plays, or enqueues, the
+// given files, folders and playlists. It brings the window to the front.
+func (a *App) handleLaunch(req instance.Request) error {
+	wailsruntime.WindowUnminimise(a.ctx)
+	wailsruntime.WindowShow(a.ctx)
+	l, err := parseLaunch(req)
+	if err != nil {
+		return err
+	}
+	for _, dir := range l.addDirs {
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+		if err := a.AddSrcDir(dir); err != nil {
+			return err
+		}
+	}
+	if len(l.paths) == 0 {
+		return nil
+	}
+	if a.player == nil || a.idx == nil {
+		return fmt.Errorf("not initialized")
+	}
+	tracks, failed := a.openPaths(l.paths)
+	if len(tracks) > 0 {
+		if l.enqueue {
+			a.player.Enqueue(tracks...)
+		} else if err := a.player.SetQueue(tracks, 0); err != nil {
+			return err
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("could not open %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"penguin-tunes/pkg/indexer"
+	"penguin-tunes/pkg/lifecycle"
+)
+
+// shutdownTimeout bounds how long quitting waits for scans and analysis to stop
+const shutdownTimeout = 5 * time.Second
+
+// startJobs creates the group that background work runs in. Hooks added
+// during startup run in reverse at shutdown, so what started first is
+// released last.
+func (a *App) startJobs(ctx context.Context) {
+	a.jobs = lifecycle.New(ctx)
+	a.jobs.SetLogger(a.logger("lifecycle"))
+	a.jobs.OnShutdown("logs", func() error {
+		if a.logs == nil {
+			return nil
+		}
+		return a.logs.Close()
+	})
+}
+
+// shutdown is called when the app quits: it cancels scans and analysis,
+// waits for them to save what they did, stops the watcher with its pending
+// save and releases the data dir
+func (a *App) shutdown(ctx context.Context) {
+	if a.jobs == nil {
+		return
+	}
+	a.log.Info("shutting down")
+	a.jobs.Shutdown(shutdownTimeout)
+}
+
+// startWatcher (re)starts watching the source dirs. The old watcher saves
+// its pending changes before the new one starts.
+func (a *App) startWatcher() {
+	a.watcherMtx.Lock()
+	defer a.watcherMtx.Unlock()
+	if a.watcher != nil {
+		a.watcher.Close()
+		a.watcher = nil
+	}
+	wa, err := indexer.NewWatcher(a.ctx, a.idx, a.cfgManager, appEmitter{a})
+	if err != nil {
+		a.log.Error("watcher failed", "err", err)
+		return
+	}
+	wa.SetLogger(a.logger("watcher"))
+	if _, err := wa.Start(); err != nil {
+		a.log.Error("watcher failed", "err", err)
+		return
+	}
+	a.watcher = wa
+}
+
+// stopWatcher stops watching, saving pending changes
+func (a *App) stopWatcher() error {
+	a.watcherMtx.Lock()
+	defer a.watcherMtx.Unlock()
+	if a.watcher == nil {
+		return nil
+	}
+	err := a.watcher.Close()
+	a.watcher = nil
+	return err
+}
+
+// stopServers stops the Subsonic and MPD servers so no client reaches the
+// library while it is saved
+func (a *App) stopServers() error {
+	var errs []error
+	a.subsonicMtx.Lock()
+	if a.subsonic != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		errs = append(errs, a.subsonic.Shutdown(ctx))
+		cancel()
+		a.subsonic = nil
+	}
+	a.subsonicMtx.Unlock()
+	a.mpdMtx.Lock()
+	if a.mpd != nil {
+		errs = append(errs, a.mpd.Close())
+		a.mpd = nil
+	}
+	a.mpdMtx.Unlock()
+	return errors.Join(errs...)
+}
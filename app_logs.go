@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	cfg "penguin-tunes/pkg/config"
+	"penguin-tunes/pkg/logging"
+)
+
+// startLogging sends every component's records to rotating files in
+// appDir/logs and to stderr; until it runs they go to slog's default
+func (a *App) startLogging(appDir string) {
+	logs, err := logging.Open(filepath.Join(appDir, "logs"), logging.Options{Echo: os.Stderr})
+	if err != nil {
+		a.log.Error("open logs failed", "err", err)
+		return
+	}
+	a.logs = logs
+	a.log = logs.Logger("app")
+	// packages without an injected logger land in the app's
+	slog.SetDefault(a.log)
+}
+
+// logger returns the logger of a component
+func (a *App) logger(component string) *slog.Logger {
+	if a.logs == nil {
+		return a.log.With("component", component)
+	}
+	return a.logs.Logger(component)
+}
+
+// applyLogLevels sets the component levels from the config
+func (a *App) applyLogLevels(c cfg.LoggingConfig) {
+	if a.logs == nil {
+		return
+	}
+	if err := a.logs.SetLevels(c.Level, c.Components); err != nil {
+		a.log.Warn("bad log levels", "err", err)
+	}
+}
+
+// checkLogging validates the log levels of a config about to be saved
+func checkLogging(c cfg.LoggingConfig) error {
+	if _, err := logging.ParseLevel(c.Level); err != nil {
+		return fmt.Errorf("logging: %w", err)
+	}
+	for comp, lvl := range c.Components {
+		if _, err := logging.ParseLevel(lvl); err != nil {
+			return fmt.Errorf("logging %s: %w", comp, err)
+		}
+	}
+	return nil
+}
+
+// GetRecentLogs returns up to limit of the latest log entries, oldest first,
+// at or above level ("" means all) and from component ("" means all)
+func (a *App) GetRecentLogs(limit int, level string, component string) ([]logging.Entry, error) {
+	if a.logs == nil {
+		return nil, fmt.Errorf("logs not initialized")
+	}
+	min := slog.LevelDebug
+	if level != "" {
+		lvl, err := logging.ParseLevel(level)
+		if err != nil {
+			return nil, err
+		}
+		min = lvl
+	}
+	return a.logs.Recent(limit, min, component), nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+
+	"penguin-tunes/pkg/indexer"
+	"penguin-tunes/pkg/lyrics"
+	"penguin-tunes/pkg/player"
+
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// LyricsLine is sent with "lyrics-line" when playback reaches another line;
+// Index is -1 before the first line
+type LyricsLine struct {
+	TrackID string `json:"trackId"`
+	Index   int    `json:"index"`
+}
+
+// startLyrics follows the player so the showing lyrics line can be announced
+func (a *App) startLyrics() {
+	a.lyrics = lyrics.NewFollower()
+	a.player.Subscribe(a.followLyrics)
+}
+
+// followLyrics loads the lyrics of each new track and emits "lyrics-line" as
+// the position crosses line boundaries
+func (a *App) followLyrics(ev player.Event) {
+	st := ev.Status
+	var cur *indexer.Track
+	if st.Current >= 0 && st.Current < len(st.Queue) {
+		cur = st.Queue[st.Current]
+	}
+	switch ev.Kind {
+	case player.EventTrack, player.EventSeeked, player.EventPosition:
+	default:
+		return
+	}
+	if cur == nil {
+		a.lyrics.Set("", nil)
+		return
+	}
+	if cur.ID != a.lyrics.Track() {
+		l, err := trackLyrics(cur)
+		if err != nil {
+			a.log.Warn("load lyrics failed", "err", err)
+		}
+		a.lyrics.Set(cur.ID, l)
+	}
+	if line, changed := a.lyrics.Update(st.Position); changed {
+		wailsruntime.EventsEmit(a.ctx, "lyrics-line", LyricsLine{TrackID: cur.ID, Index: line})
+	}
+}
+
+// GetLyrics returns the lyrics of a track, or nil when it has none
+func (a *App) GetLyrics(id string) (*lyrics.Lyrics, error) {
+	if a.idx == nil {
+		return nil, fmt.Errorf("index not initialized")
+	}
+	t := a.trackByID(id)
+	if t == nil {
+		return nil, fmt.Errorf("track %s not found", id)
+	}
+	return trackLyrics(t)
+}
+
+// trackLyrics loads the lyrics of t; those of a file cut by a CUE sheet
+// don't belong to any one of its tracks
+func trackLyrics(t *indexer.Track) (*lyrics.Lyrics, error) {
+	if t.Virtual() {
+		return nil, nil
+	}
+	return lyrics.Load(t.Path)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+
+	"penguin-tunes/pkg/indexer"
+	"penguin-tunes/pkg/media"
+)
+
+// mediaTrack finds a track the audio element may load, nil before startup
+func (a *App) mediaTrack(id string) *indexer.Track {
+	if a.idx == nil {
+		return nil
+	}
+	return a.trackByID(id)
+}
+
+// GetMediaSource returns where the audio element loads a track from,
+// starting from seconds into its file. Processed sources already went
+// through the DSP chain; the frontend applies it to the others.
+func (a *App) GetMediaSource(id string, from float64) (media.Source, error) {
+	t := a.mediaTrack(id)
+	if t == nil {
+		return media.Source{}, fmt.Errorf("track %s not found", id)
+	}
+	return media.SourceFor(t, from), nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"net"
+
+	"penguin-tunes/pkg/mpd"
+)
+
+// startMPD (re)starts the MPD protocol server to match the config, stopping
+// it when disabled. A read-only instance leaves serving to the one holding
+// the data dir.
+func (a *App) startMPD() {
+	if a.cfgManager.ReadOnly() {
+		return
+	}
+	a.mpdMtx.Lock()
+	defer a.mpdMtx.Unlock()
+	c := a.cfgManager.GetConfig().MPD
+	if a.mpd != nil {
+		if c == a.mpdCfg {
+			return
+		}
+		_ = a.mpd.Close()
+		a.mpd = nil
+	}
+	a.mpdCfg = c
+	if !c.Enabled {
+		return
+	}
+	addr := c.Address
+	if addr == "" {
+		addr = mpd.DefaultAddress
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		a.log.Error("mpd listen failed", "err", err)
+		return
+	}
+	srv := mpd.New(a.idx, a.player, mpd.Options{
+		Roots:    func() []string { return a.cfgManager.GetConfig().SrcDirs },
+		Password: c.Password,
+		OnUpdate: func() { a.startScan(a.cfgManager.GetConfig().SrcDirs) },
+	})
+	a.mpd = srv
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, net.ErrClosed) {
+			a.log.Error("mpd server failed", "err", err)
+		}
+	}()
+}
+
+// notifyMPD wakes MPD clients waiting for database changes
+func (a *App) notifyMPD() {
+	a.mpdMtx.Lock()
+	defer a.mpdMtx.Unlock()
+	if a.mpd != nil {
+		a.mpd.DatabaseChanged()
+	}
+}
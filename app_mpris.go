@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/godbus/dbus/v5"
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"penguin-tunes/pkg/mpris"
+)
+
+// startMPRIS publishes the player on the session bus; desktops without D-Bus just skip it
+func (a *App) startMPRIS() {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		a.log.Info("mpris: session bus unavailable", "err", err)
+		return
+	}
+	srv, err := mpris.New(conn, a.player, mpris.Options{
+		Identity:     "PenguinTunes",
+		DesktopEntry: "penguin-tunes",
+		OnRaise:      func() { wailsruntime.WindowShow(a.ctx) },
+		OnQuit:       func() { wailsruntime.Quit(a.ctx) },
+	})
+	if err != nil {
+		a.log.Error("mpris failed", "err", err)
+		conn.Close()
+		return
+	}
+	a.mpris = srv
+	// hooks run in reverse, so the server releases its name before the bus goes
+	a.jobs.OnShutdown("mpris bus", conn.Close)
+	a.jobs.OnShutdown("mpris", srv.Close)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"penguin-tunes/pkg/indexer"
+	"penguin-tunes/pkg/playlist"
+)
+
+// openPaths turns files, folders and playlist files into tracks to queue.
+// Folders are expanded in natural order. Library files give their indexed
+// tracks; others are read as external tracks that are never indexed.
+// failed lists the paths that gave nothing, with the reason.
+func (a *App) openPaths(paths []string) (tracks []*indexer.Track, failed []string) {
+	opts := a.scanOptions()
+	file := func(p string) error {
+		if ts := a.idx.FileTracks(p); len(ts) > 0 {
+			tracks = append(tracks, ts...)
+			return nil
+		}
+		ts, err := a.idx.ReadExternal(p, opts)
+		if err != nil {
+			return err
+		}
+		tracks = append(tracks, ts...)
+		return nil
+	}
+	for _, p := range paths {
+		fi, err := os.Stat(p)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", p, err))
+			continue
+		}
+		switch {
+		case fi.IsDir():
+			files, err := indexer.AudioFiles(p)
+			if err != nil {
+				failed = append(failed, fmt.Sprintf("%s: %v", p, err))
+			}
+			if len(files) == 0 && err == nil {
+				failed = append(failed, fmt.Sprintf("%s: no audio files", p))
+			}
+			for _, f := range files {
+				if err := file(f); err != nil {
+					failed = append(failed, fmt.Sprintf("%s: %v", f, err))
+				}
+			}
+		case playlist.IsPlaylistFile(p):
+			entries, err := playlist.ReadPaths(p)
+			if err != nil {
+				failed = append(failed, fmt.Sprintf("%s: %v", p, err))
+				continue
+			}
+			for _, e := range entries {
+				if err := file(e); err != nil {
+					failed = append(failed, fmt.Sprintf("%s: %v", e, err))
+				}
+			}
+		case indexer.IsAudioFile(p):
+			if err := file(p); err != nil {
+				failed = append(failed, fmt.Sprintf("%s: %v", p, err))
+			}
+		default:
+			failed = append(failed, fmt.Sprintf("%s: not an audio or playlist file", p))
+		}
+	}
+	return tracks, failed
+}
+
+// trackByID finds a library track, or an external one in the queue. Only
+// bindings that play or read tracks use it; see tracksByID.
+func (a *App) trackByID(id string) *indexer.Track {
+	if t := a.idx.GetByID(id); t != nil {
+		return t
+	}
+	if a.player == nil {
+		return nil
+	}
+	for _, t := range a.player.Status().Queue {
+		if t.External && t.ID == id {
+			return t
+		}
+	}
+	return nil
+}
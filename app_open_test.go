@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cfg "penguin-tunes/pkg/config"
+	"penguin-tunes/pkg/indexer"
+	"penguin-tunes/pkg/player"
+	"penguin-tunes/pkg/tagwriter"
+)
+
+func TestExternalTracksStayOutOfTheIndex(t *testing.T) {
+	base := t.TempDir()
+	cm, err := cfg.NewManagerAt(base)
+	if err != nil {
+		t.Fatalf("NewManagerAt: %v", err)
+	}
+	a := &App{
+		log:        slog.Default(),
+		cfgManager: cm,
+		idx:        indexer.NewIndexAtBase(base),
+		player:     player.New(context.Background(), nil),
+	}
+	ext := filepath.Join(t.TempDir(), "opened.mp3")
+	if err := os.WriteFile(ext, []byte("dummy"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	tracks, failed := a.openPaths([]string{ext})
+	if len(tracks) != 1 || len(failed) != 0 || !tracks[0].External {
+		t.Fatalf("expected one external track, got %+v %v", tracks, failed)
+	}
+	id := tracks[0].ID
+	// opened files are queued as the launch handler does
+	if err := a.player.SetQueue(tracks, 0); err != nil {
+		t.Fatalf("SetQueue: %v", err)
+	}
+	if err := a.PlayTracks([]string{id}, 0); err != nil {
+		t.Fatalf("a queued external track must play again: %v", err)
+	}
+
+	title := "Changed"
+	if err := a.EditTags([]string{id}, tagwriter.Update{Title: &title}); err == nil {
+		t.Fatalf("expected tag edits of an external track to be refused")
+	}
+	if _, err := a.PlanOrganize([]string{id}, "", t.TempDir()); err == nil {
+		t.Fatalf("expected organizing an external track to be refused")
+	}
+	if got := a.idx.GetAll(); len(got) != 0 {
+		t.Fatalf("expected the index to stay empty, got %+v", got)
+	}
+	if _, err := os.Stat(filepath.Join(base, "index.json")); !os.IsNotExist(err) {
+		t.Fatalf("expected no index written, got %v", err)
+	}
+}
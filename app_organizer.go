@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+
+	"penguin-tunes/pkg/indexer"
+	"penguin-tunes/pkg/organizer"
+)
+
+// startOrganizer loads the undo journal of the library organizer
+func (a *App) startOrganizer(appDir string) {
+	a.journal = organizer.NewJournalAtBase(appDir)
+	if err := a.journal.LoadFromFile(); err != nil {
+		a.log.Error("load organizer journal failed", "err", err)
+	}
+	a.journal.SetReadOnly(a.cfgManager.ReadOnly())
+}
+
+// PlanOrganize shows where the given tracks (all when ids is empty) would move
+// under template, without touching any file. An empty template uses the default
+// and an empty dest keeps tracks inside the library folder they are in.
+func (a *App) PlanOrganize(ids []string, template string, dest string) (*organizer.Plan, error) {
+	if a.idx == nil || a.cfgManager == nil || a.journal == nil {
+		return nil, fmt.Errorf("index not initialized")
+	}
+	var tracks []*indexer.Track
+	if len(ids) == 0 {
+		tracks = a.idx.GetAll()
+	} else {
+		var err error
+		if tracks, err = a.tracksByID(ids); err != nil {
+			return nil, err
+		}
+	}
+	if template == "" {
+		template = organizer.DefaultTemplate
+	}
+	return organizer.NewPlan(tracks, organizer.Options{
+		Template: template,
+		Root:     dest,
+		Roots:    a.cfgManager.GetConfig().SrcDirs,
+	})
+}
+
+// ApplyOrganize moves the files as PlanOrganize describes and returns how many
+// files were moved. The plan is worked out again so it reflects the disk as it is now.
+func (a *App) ApplyOrganize(ids []string, template string, dest string) (int, error) {
+	plan, err := a.PlanOrganize(ids, template, dest)
+	if err != nil {
+		return 0, err
+	}
+	n, err := organizer.Apply(plan, a.idx, a.journal)
+	a.afterOrganize(n)
+	return n, err
+}
+
+// UndoOrganize moves the files of the most recent organize back and returns how many moved
+func (a *App) UndoOrganize() (int, error) {
+	if a.idx == nil || a.journal == nil {
+		return 0, fmt.Errorf("index not initialized")
+	}
+	n, err := organizer.Undo(a.idx, a.journal)
+	a.afterOrganize(n)
+	return n, err
+}
+
+// CanUndoOrganize reports whether an organize can be undone
+func (a *App) CanUndoOrganize() bool {
+	if a.journal == nil {
+		return false
+	}
+	_, ok := a.journal.Last()
+	return ok
+}
+
+// afterOrganize persists moved index entries and tells the frontend.
+// Playlists need no update: their entries refer to tracks by ID, which a
+// move keeps.
+func (a *App) afterOrganize(moved int) {
+	if moved == 0 {
+		return
+	}
+	if err := a.idx.SaveToFile(); err != nil {
+		a.log.Error("index save failed", "err", err)
+	}
+	a.emitIndexUpdated()
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+
+	"penguin-tunes/pkg/indexer"
+	"penguin-tunes/pkg/player"
+)
+
+// GetPlayerStatus returns queue and transport state
+func (a *App) GetPlayerStatus() (player.Status, error) {
+	if a.player == nil {
+		return player.Status{}, fmt.Errorf("player not initialized")
+	}
+	return a.player.Status(), nil
+}
+
+// PlayTracks replaces the queue with the given track IDs and starts at index start
+func (a *App) PlayTracks(ids []string, start int) error {
+	if a.player == nil {
+		return fmt.Errorf("player not initialized")
+	}
+	tracks, err := a.queueTracksByID(ids)
+	if err != nil {
+		return err
+	}
+	return a.player.SetQueue(tracks, start)
+}
+
+// EnqueueTracks appends the given track IDs to the queue
+func (a *App) EnqueueTracks(ids []string) error {
+	if a.player == nil {
+		return fmt.Errorf("player not initialized")
+	}
+	tracks, err := a.queueTracksByID(ids)
+	if err != nil {
+		return err
+	}
+	a.player.Enqueue(tracks...)
+	return nil
+}
+
+// PlayQueueIndex jumps to entry i of the queue
+func (a *App) PlayQueueIndex(i int) error {
+	if a.player == nil {
+		return fmt.Errorf("player not initialized")
+	}
+	return a.player.PlayIndex(i)
+}
+
+// ClearQueue empties the queue and stops playback
+func (a *App) ClearQueue() {
+	if a.player != nil {
+		a.player.Clear()
+	}
+}
+
+// Play resumes playback
+func (a *App) Play() {
+	if a.player != nil {
+		a.player.Play()
+	}
+}
+
+// Pause pauses playback
+func (a *App) Pause() {
+	if a.player != nil {
+		a.player.Pause()
+	}
+}
+
+// PlayPause toggles playback
+func (a *App) PlayPause() {
+	if a.player != nil {
+		a.player.PlayPause()
+	}
+}
+
+// Stop stops playback
+func (a *App) Stop() {
+	if a.player != nil {
+		a.player.Stop()
+	}
+}
+
+// Next skips to the next queue entry
+func (a *App) Next() {
+	if a.player != nil {
+		a.player.Next()
+	}
+}
+
+// Previous restarts the track or goes back one entry
+func (a *App) Previous() {
+	if a.player != nil {
+		a.player.Previous()
+	}
+}
+
+// Seek moves playback to pos seconds
+func (a *App) Seek(pos float64) {
+	if a.player != nil {
+		a.player.Seek(pos)
+	}
+}
+
+// SetVolume sets the output volume in range [0, 1]
+func (a *App) SetVolume(v float64) {
+	if a.player != nil {
+		a.player.SetVolume(v)
+	}
+}
+
+// ReportProgress is called by the frontend audio element with position and
+// duration in seconds within the file; tracks cut by a CUE sheet start at the
+// status Offset
+func (a *App) ReportProgress(pos, duration float64) {
+	if a.player != nil {
+		a.player.UpdatePosition(pos, duration)
+	}
+}
+
+// TrackEnded is called by the frontend when the current track finished playing
+func (a *App) TrackEnded() {
+	if a.player != nil {
+		a.player.TrackEnded()
+	}
+}
+
+// tracksByID returns library tracks. Tracks of files opened from outside the
+// library are refused: they must not reach anything that writes the index.
+func (a *App) tracksByID(ids []string) ([]*indexer.Track, error) {
+	if a.idx == nil {
+		return nil, fmt.Errorf("index not initialized")
+	}
+	tracks := make([]*indexer.Track, 0, len(ids))
+	for _, id := range ids {
+		t := a.idx.GetByID(id)
+		if t == nil {
+			if a.trackByID(id) != nil {
+				return nil, fmt.Errorf("track %s is not in the library", id)
+			}
+			return nil, fmt.Errorf("track %s not found", id)
+		}
+		tracks = append(tracks, t)
+	}
+	return tracks, nil
+}
+
+// queueTracksByID returns tracks to play: library ones or external ones
+// already in the queue
+func (a *App) queueTracksByID(ids []string) ([]*indexer.Track, error) {
+	if a.idx == nil {
+		return nil, fmt.Errorf("index not initialized")
+	}
+	tracks := make([]*indexer.Track, 0, len(ids))
+	for _, id := range ids {
+		t := a.trackByID(id)
+		if t == nil {
+			return nil, fmt.Errorf("track %s not found", id)
+		}
+		tracks = append(tracks, t)
+	}
+	return tracks, nil
+}
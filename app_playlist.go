@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+
+	"penguin-tunes/pkg/indexer"
+	"penguin-tunes/pkg/playlist"
+
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// GetPlaylists returns all playlists sorted by name
+func (a *App) GetPlaylists() ([]playlist.Playlist, error) {
+	if a.playlists == nil {
+		return nil, fmt.Errorf("playlists not initialized")
+	}
+	return a.playlists.List(), nil
+}
+
+// GetPlaylistItems returns a playlist's entries with their tracks; missing entries have no track
+func (a *App) GetPlaylistItems(id string) ([]playlist.Item, error) {
+	if a.playlists == nil || a.idx == nil {
+		return nil, fmt.Errorf("playlists not initialized")
+	}
+	p, err := a.playlists.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	return playlist.Resolve(p, a.idx), nil
+}
+
+// CreatePlaylist creates an empty playlist
+func (a *App) CreatePlaylist(name string) (playlist.Playlist, error) {
+	if a.playlists == nil {
+		return playlist.Playlist{}, fmt.Errorf("playlists not initialized")
+	}
+	p, err := a.playlists.Create(name)
+	if err != nil {
+		return playlist.Playlist{}, err
+	}
+	a.emitPlaylistsUpdated()
+	return p, nil
+}
+
+// RenamePlaylist renames a playlist
+func (a *App) RenamePlaylist(id, name string) error {
+	return a.changePlaylists(func(s *playlist.Store) error { return s.Rename(id, name) })
+}
+
+// DeletePlaylist removes a playlist
+func (a *App) DeletePlaylist(id string) error {
+	return a.changePlaylists(func(s *playlist.Store) error { return s.Delete(id) })
+}
+
+// AddToPlaylist inserts tracks at position at; pass -1 to append
+func (a *App) AddToPlaylist(id string, trackIDs []string, at int) error {
+	tracks, err := a.tracksByID(trackIDs)
+	if err != nil {
+		return err
+	}
+	return a.changePlaylists(func(s *playlist.Store) error { return s.Add(id, tracks, at) })
+}
+
+// RemoveFromPlaylist removes the entries at the given positions
+func (a *App) RemoveFromPlaylist(id string, positions []int) error {
+	return a.changePlaylists(func(s *playlist.Store) error { return s.Remove(id, positions) })
+}
+
+// MovePlaylistEntry moves the entry at from to position to
+func (a *App) MovePlaylistEntry(id string, from, to int) error {
+	return a.changePlaylists(func(s *playlist.Store) error { return s.Move(id, from, to) })
+}
+
+// PlayPlaylist queues the available tracks of a playlist, starting at entry start
+func (a *App) PlayPlaylist(id string, start int) error {
+	items, err := a.GetPlaylistItems(id)
+	if err != nil {
+		return err
+	}
+	if a.player == nil {
+		return fmt.Errorf("player not initialized")
+	}
+	tracks := make([]*indexer.Track, 0, len(items))
+	first := 0
+	for _, it := range items {
+		if it.Track == nil {
+			continue
+		}
+		if it.Position < start {
+			first = len(tracks) + 1
+		}
+		tracks = append(tracks, it.Track)
+	}
+	if first >= len(tracks) {
+		first = 0
+	}
+	return a.player.SetQueue(tracks, first)
+}
+
+// ImportPlaylist imports an M3U/M3U8/PLS/XSPF file and reports entries that matched no indexed track
+func (a *App) ImportPlaylist(path string) (playlist.ImportResult, error) {
+	if a.playlists == nil || a.idx == nil {
+		return playlist.ImportResult{}, fmt.Errorf("playlists not initialized")
+	}
+	res, err := a.playlists.Import(path, a.idx)
+	if err != nil {
+		return playlist.ImportResult{}, err
+	}
+	a.emitPlaylistsUpdated()
+	return res, nil
+}
+
+// ExportPlaylist writes a playlist to path in the format given by its extension,
+// with track locations relative to the playlist file or absolute
+func (a *App) ExportPlaylist(id, path string, relative bool) error {
+	if a.playlists == nil || a.idx == nil {
+		return fmt.Errorf("playlists not initialized")
+	}
+	return a.playlists.Export(id, path, relative, a.idx)
+}
+
+// autoImportPlaylists imports new or changed playlist files found during a scan
+func (a *App) autoImportPlaylists(paths []string) {
+	if a.playlists == nil || len(paths) == 0 {
+		return
+	}
+	changed := false
+	for _, p := range paths {
+		res, wrote, err := a.playlists.ImportOrUpdate(p, a.idx)
+		if err != nil {
+			a.log.Warn("playlist import failed", "path", p, "err", err)
+			continue
+		}
+		if len(res.Unresolved) > 0 {
+			a.log.Info("playlist imported with unresolved entries", "path", p, "unresolved", len(res.Unresolved))
+		}
+		changed = changed || wrote
+	}
+	if changed {
+		a.emitPlaylistsUpdated()
+	}
+}
+
+func (a *App) changePlaylists(fn func(s *playlist.Store) error) error {
+	if a.playlists == nil {
+		return fmt.Errorf("playlists not initialized")
+	}
+	if err := fn(a.playlists); err != nil {
+		return err
+	}
+	a.emitPlaylistsUpdated()
+	return nil
+}
+
+func (a *App) emitPlaylistsUpdated() {
+	wailsruntime.EventsEmit(a.ctx, "playlists-updated", a.playlists.List())
+}
+
+// reconcilePlaylists marks playlist entries whose tracks disappeared from the index as missing
+func (a *App) reconcilePlaylists() {
+	if a.playlists == nil || a.idx == nil {
+		return
+	}
+	changed, err := a.playlists.Reconcile(a.idx)
+	if err != nil {
+		a.log.Error("playlist reconcile failed", "err", err)
+	}
+	if changed {
+		a.emitPlaylistsUpdated()
+	}
+}
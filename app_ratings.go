@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"penguin-tunes/pkg/indexer"
+	"penguin-tunes/pkg/tagwriter"
+
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// SetRating stores a 0–5 star rating and writes it to the file when rating sync is on
+func (a *App) SetRating(id string, stars int) error {
+	if a.idx == nil || a.cfgManager == nil {
+		return fmt.Errorf("index not initialized")
+	}
+	if stars < 0 || stars > 5 {
+		return fmt.Errorf("rating %d out of range", stars)
+	}
+	t, err := a.updateTrack(id, func(t *indexer.Track) { t.Rating = stars })
+	if err != nil {
+		return err
+	}
+	// a file cut by a CUE sheet has one rating tag for all of its tracks
+	if !a.cfgManager.GetConfig().RatingSync || t.Virtual() {
+		return nil
+	}
+	// the library keeps the rating even if the file can't take it
+	if err := tagwriter.Write(t.Path, tagwriter.Update{Rating: &stars}); err != nil && !errors.Is(err, tagwriter.ErrUnsupported) {
+		return fmt.Errorf("write rating: %w", err)
+	}
+	return nil
+}
+
+// SetLoved marks or unmarks a track as loved
+func (a *App) SetLoved(id string, loved bool) error {
+	if a.idx == nil {
+		return fmt.Errorf("index not initialized")
+	}
+	_, err := a.updateTrack(id, func(t *indexer.Track) { t.Loved = loved })
+	return err
+}
+
+// updateTrack changes library fields of a track, persists the index and notifies the frontend
+func (a *App) updateTrack(id string, fn func(t *indexer.Track)) (*indexer.Track, error) {
+	t, err := a.idx.UpdateTrack(id, fn)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.idx.SaveToFile(); err != nil {
+		return nil, err
+	}
+	wailsruntime.EventsEmit(a.ctx, "track-updated", t)
+	a.refreshSmartPlaylists()
+	return t, nil
+}
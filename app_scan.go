@@ -0,0 +1,71 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+
+	cfg "penguin-tunes/pkg/config"
+	"penguin-tunes/pkg/indexer"
+
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// startScans starts the coordinator that runs library scans one at a time,
+// sending "scan-status" with each change of state
+func (a *App) startScans() {
+	a.scans = indexer.NewScanCoordinator(a.scanDirs)
+	a.scans.SetLogger(a.logger("scan"))
+	a.scans.OnStatus = func(st indexer.ScanStatus) {
+		wailsruntime.EventsEmit(a.ctx, "scan-status", st)
+	}
+	a.jobs.Go("scans", a.scans.Run)
+}
+
+// startScan queues a scan of dirs; it does nothing while the library is
+// read-only
+func (a *App) startScan(dirs []string) {
+	if a.scans == nil || len(dirs) == 0 {
+		return
+	}
+	a.scans.Request(dirs...)
+}
+
+// rescanChanged scans what a config change calls for: everything when the
+// way files are read changed, otherwise only the added source dirs. Scans
+// of removed dirs are dropped and the watcher follows the new dirs.
+func (a *App) rescanChanged(old, cur cfg.Config) {
+	var added []string
+	for _, d := range cur.SrcDirs {
+		if !slices.Contains(old.SrcDirs, d) {
+			added = append(added, d)
+		}
+	}
+	removed := false
+	for _, d := range old.SrcDirs {
+		if !slices.Contains(cur.SrcDirs, d) {
+			removed = true
+		}
+	}
+	if removed && a.scans != nil {
+		a.scans.Retain(cur.SrcDirs)
+	}
+	readChanged := old.RatingSync != cur.RatingSync ||
+		old.AutoImportPlaylists != cur.AutoImportPlaylists ||
+		!reflect.DeepEqual(old.PathTemplates, cur.PathTemplates)
+	if readChanged {
+		a.startScan(cur.SrcDirs)
+	} else {
+		a.startScan(added)
+	}
+	if len(added) > 0 || removed {
+		a.startWatcher()
+	}
+}
+
+// GetScanStatus returns whether a scan is running or queued, and of which dirs
+func (a *App) GetScanStatus() indexer.ScanStatus {
+	if a.scans == nil {
+		return indexer.ScanStatus{State: indexer.ScanIdle, Scanning: []string{}, Queued: []string{}}
+	}
+	return a.scans.Status()
+}
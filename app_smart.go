@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"penguin-tunes/pkg/indexer"
+	"penguin-tunes/pkg/playlist"
+
+	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// SmartPlaylistResult is emitted whenever a smart playlist's tracks change
+type SmartPlaylistResult struct {
+	ID     string           `json:"id"`
+	Tracks []*indexer.Track `json:"tracks"`
+}
+
+// GetSmartPlaylists returns all smart playlists sorted by name
+func (a *App) GetSmartPlaylists() ([]playlist.SmartPlaylist, error) {
+	if a.playlists == nil {
+		return nil, fmt.Errorf("playlists not initialized")
+	}
+	return a.playlists.ListSmart(), nil
+}
+
+// CreateSmartPlaylist stores a new rule-based playlist
+func (a *App) CreateSmartPlaylist(name string, q playlist.Query) (playlist.SmartPlaylist, error) {
+	if a.playlists == nil {
+		return playlist.SmartPlaylist{}, fmt.Errorf("playlists not initialized")
+	}
+	sp, err := a.playlists.CreateSmart(name, q)
+	if err != nil {
+		return playlist.SmartPlaylist{}, err
+	}
+	a.refreshSmartPlaylists()
+	return sp, nil
+}
+
+// UpdateSmartPlaylist replaces the name and rules of a smart playlist
+func (a *App) UpdateSmartPlaylist(id, name string, q playlist.Query) error {
+	if a.playlists == nil {
+		return fmt.Errorf("playlists not initialized")
+	}
+	if err := a.playlists.UpdateSmart(id, name, q); err != nil {
+		return err
+	}
+	a.refreshSmartPlaylists()
+	return nil
+}
+
+// DeleteSmartPlaylist removes a smart playlist
+func (a *App) DeleteSmartPlaylist(id string) error {
+	if a.playlists == nil {
+		return fmt.Errorf("playlists not initialized")
+	}
+	if err := a.playlists.DeleteSmart(id); err != nil {
+		return err
+	}
+	a.smartMtx.Lock()
+	delete(a.smartResults, id)
+	a.smartMtx.Unlock()
+	return nil
+}
+
+// GetSmartPlaylistTracks evaluates a smart playlist against the index
+func (a *App) GetSmartPlaylistTracks(id string) ([]*indexer.Track, error) {
+	if a.playlists == nil || a.idx == nil {
+		return nil, fmt.Errorf("playlists not initialized")
+	}
+	sp, err := a.playlists.GetSmart(id)
+	if err != nil {
+		return nil, err
+	}
+	return playlist.Evaluate(sp.Query, a.idx.GetAll(), a.usage(), sp.Seed()), nil
+}
+
+// PlaySmartPlaylist replaces the queue with a smart playlist's current tracks
+func (a *App) PlaySmartPlaylist(id string) error {
+	tracks, err := a.GetSmartPlaylistTracks(id)
+	if err != nil {
+		return err
+	}
+	if a.player == nil {
+		return fmt.Errorf("player not initialized")
+	}
+	return a.player.SetQueue(tracks, 0)
+}
+
+// QueryTracks runs an ad-hoc rule query against the index
+func (a *App) QueryTracks(q playlist.Query) ([]*indexer.Track, error) {
+	if a.idx == nil {
+		return nil, fmt.Errorf("index not initialized")
+	}
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	return playlist.Evaluate(q, a.idx.GetAll(), a.usage(), 0), nil
+}
+
+// refreshSmartPlaylists re-evaluates every smart playlist and emits the ones whose tracks changed
+func (a *App) refreshSmartPlaylists() {
+	if a.playlists == nil || a.idx == nil {
+		return
+	}
+	all := a.idx.GetAll()
+	a.smartMtx.Lock()
+	defer a.smartMtx.Unlock()
+	if a.smartResults == nil {
+		a.smartResults = make(map[string][]string)
+	}
+	for _, sp := range a.playlists.ListSmart() {
+		tracks := playlist.Evaluate(sp.Query, all, a.usage(), sp.Seed())
+		ids := make([]string, len(tracks))
+		for i, t := range tracks {
+			ids[i] = t.ID
+		}
+		if prev, ok := a.smartResults[sp.ID]; ok && slices.Equal(prev, ids) {
+			continue
+		}
+		a.smartResults[sp.ID] = ids
+		wailsruntime.EventsEmit(a.ctx, "smart-playlist-updated", SmartPlaylistResult{ID: sp.ID, Tracks: tracks})
+	}
+}
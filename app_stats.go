@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"penguin-tunes/pkg/indexer"
+	"penguin-tunes/pkg/player"
+	"penguin-tunes/pkg/playlist"
+	"penguin-tunes/pkg/stats"
+)
+
+// usage returns the usage source for smart playlist evaluation, nil before startup
+func (a *App) usage() playlist.UsageSource {
+	if a.stats == nil {
+		return nil
+	}
+	return a.stats
+}
+
+// startStats loads listening stats and starts following the player
+func (a *App) startStats(appDir string) {
+	a.stats = stats.NewStoreAtBase(appDir)
+	a.stats.SetLogger(a.logger("stats"))
+	if err := a.stats.LoadFromFile(); err != nil {
+		a.log.Error("load stats failed", "err", err)
+	}
+	if a.cfgManager.ReadOnly() {
+		// listens are recorded by the process that holds the data dir
+		a.stats.SetReadOnly(true)
+		return
+	}
+	a.jobs.OnShutdown("stats", a.stats.Flush)
+	a.tracker = stats.NewTracker(a.stats, stats.DefaultThreshold)
+	// play counts feed smart playlist rules
+	a.tracker.OnRecord = func(stats.Event) { a.refreshSmartPlaylists() }
+	a.player.Subscribe(a.trackPlayback)
+}
+
+// trackPlayback feeds player events to the listen tracker
+func (a *App) trackPlayback(ev player.Event) {
+	st := ev.Status
+	var cur *indexer.Track
+	// listens to files outside the library aren't part of its history
+	if st.Current >= 0 && st.Current < len(st.Queue) && !st.Queue[st.Current].External {
+		cur = st.Queue[st.Current]
+	}
+	var err error
+	switch ev.Kind {
+	case player.EventTrack:
+		if cur == nil || st.State == player.Stopped {
+			err = a.tracker.Finish()
+		} else {
+			err = a.tracker.Start(cur.ID)
+		}
+	case player.EventState:
+		switch {
+		case st.State == player.Stopped:
+			err = a.tracker.Finish()
+		case st.State == player.Playing && cur != nil && a.tracker.Following() == "":
+			// resuming after stop starts a new session for the same track
+			err = a.tracker.Start(cur.ID)
+		}
+	case player.EventSeeked:
+		a.tracker.Seek(st.Position)
+	case player.EventPosition:
+		err = a.tracker.Progress(st.Position, st.Duration)
+	}
+	if err != nil {
+		a.log.Error("record stats failed", "err", err)
+	}
+}
+
+// GetTrackStats returns play and skip counts of a track
+func (a *App) GetTrackStats(id string) (stats.TrackStats, error) {
+	if a.stats == nil {
+		return stats.TrackStats{}, fmt.Errorf("stats not initialized")
+	}
+	return a.stats.Get(id), nil
+}
+
+// GetListeningHistory returns history events in [from, to), newest first; zero times are unbounded
+func (a *App) GetListeningHistory(from, to time.Time, limit int) ([]stats.Event, error) {
+	if a.stats == nil {
+		return nil, fmt.Errorf("stats not initialized")
+	}
+	return a.stats.History(from, to, limit), nil
+}
+
+// GetTopTracks ranks the most played tracks in [from, to)
+func (a *App) GetTopTracks(from, to time.Time, limit int) ([]stats.TopItem, error) {
+	return a.top("track", from, to, limit)
+}
+
+// GetTopArtists ranks the most played artists in [from, to)
+func (a *App) GetTopArtists(from, to time.Time, limit int) ([]stats.TopItem, error) {
+	return a.top("artist", from, to, limit)
+}
+
+// GetTopAlbums ranks the most played albums in [from, to)
+func (a *App) GetTopAlbums(from, to time.Time, limit int) ([]stats.TopItem, error) {
+	return a.top("album", from, to, limit)
+}
+
+func (a *App) top(by string, from, to time.Time, limit int) ([]stats.TopItem, error) {
+	if a.stats == nil || a.idx == nil {
+		return nil, fmt.Errorf("stats not initialized")
+	}
+	byID := make(map[string]*indexer.Track)
+	for _, t := range a.idx.GetAll() {
+		byID[t.ID] = t
+	}
+	return a.stats.Top(by, from, to, limit, func(id string) *indexer.Track { return byID[id] })
+}
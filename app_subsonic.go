@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	cfg "penguin-tunes/pkg/config"
+	"penguin-tunes/pkg/subsonic"
+)
+
+// checkSubsonic rejects settings the API server can't run with
+func checkSubsonic(c cfg.SubsonicConfig) error {
+	if c.Enabled && (c.Username == "" || c.Password == "") {
+		return fmt.Errorf("subsonic server needs a username and password")
+	}
+	return nil
+}
+
+// startSubsonic (re)starts the Subsonic API server to match the config,
+// stopping it when disabled. A read-only instance leaves serving to the one
+// holding the data dir.
+func (a *App) startSubsonic() {
+	if a.cfgManager.ReadOnly() {
+		return
+	}
+	a.subsonicMtx.Lock()
+	defer a.subsonicMtx.Unlock()
+	c := a.cfgManager.GetConfig().Subsonic
+	if a.subsonic != nil {
+		if c == a.subsonicCfg {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		_ = a.subsonic.Shutdown(ctx)
+		cancel()
+		a.subsonic = nil
+	}
+	a.subsonicCfg = c
+	if !c.Enabled || checkSubsonic(c) != nil {
+		return
+	}
+	addr := c.Address
+	if addr == "" {
+		addr = cfg.DefaultSubsonicAddress
+	}
+	api := subsonic.New(a.idx, subsonic.Options{
+		Username:           c.Username,
+		Password:           c.Password,
+		Playlists:          a.playlists,
+		Stats:              a.stats,
+		OnPlaylistsChanged: a.emitPlaylistsUpdated,
+		// play counts feed smart playlist rules
+		OnScrobble: a.refreshSmartPlaylists,
+	})
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		a.log.Error("subsonic listen failed", "err", err)
+		return
+	}
+	srv := &http.Server{Handler: api, ReadHeaderTimeout: 10 * time.Second}
+	a.subsonic = srv
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.log.Error("subsonic server failed", "err", err)
+		}
+	}()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"penguin-tunes/pkg/indexer"
+	"penguin-tunes/pkg/tagwriter"
+)
+
+// EditTags writes u to the files of the given tracks and refreshes their index
+// entries. Every file is attempted; failures are reported together.
+func (a *App) EditTags(ids []string, u tagwriter.Update) error {
+	if a.idx == nil || a.cfgManager == nil {
+		return fmt.Errorf("index not initialized")
+	}
+	tracks, err := a.tracksByID(ids)
+	if err != nil {
+		return err
+	}
+	opts := a.scanOptions()
+	var errs []error
+	for _, t := range tracks {
+		if t.Virtual() {
+			errs = append(errs, fmt.Errorf("%s: track is part of a CUE sheet", t.Title))
+			continue
+		}
+		if err := tagwriter.Write(t.Path, u); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if _, err := a.idx.Reload(t.Path, opts); err != nil {
+			errs = append(errs, fmt.Errorf("reload %s: %w", t.Path, err))
+			continue
+		}
+		if u.Rating != nil {
+			// a cleared rating reads back as "none", which would keep the old one
+			_, _ = a.idx.UpdateTrack(t.ID, func(t *indexer.Track) { t.Rating = *u.Rating })
+		}
+	}
+	if len(errs) < len(tracks) {
+		if err := a.idx.SaveToFile(); err != nil {
+			errs = append(errs, err)
+		}
+		a.emitIndexUpdated()
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d of %d files not updated: %w", len(errs), len(tracks), errors.Join(errs...))
+	}
+	return nil
+}
+
+// CanEditTags reports whether the file of a track supports tag editing; tracks
+// cut by a CUE sheet share their file and can't be edited one by one
+func (a *App) CanEditTags(id string) (bool, error) {
+	if a.idx == nil {
+		return false, fmt.Errorf("index not initialized")
+	}
+	t := a.idx.GetByID(id)
+	if t == nil {
+		return false, fmt.Errorf("track %s not found", id)
+	}
+	return !t.Virtual() && tagwriter.Supported(t.Path), nil
+}
+
+// TemplatePreview shows what each template extracts from one track's path; a nil
+// entry means the template doesn't match
+type TemplatePreview struct {
+	ID      string               `json:"id"`
+	Path    string               `json:"path"`
+	Matches []*indexer.TagFields `json:"matches"`
+}
+
+// PreviewPathTemplates runs templates over the paths of the given tracks without changing anything
+func (a *App) PreviewPathTemplates(templates []string, ids []string) ([]TemplatePreview, error) {
+	parsed, err := indexer.ParsePathTemplates(templates)
+	if err != nil {
+		return nil, err
+	}
+	tracks, err := a.tracksByID(ids)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]TemplatePreview, len(tracks))
+	for i, t := range tracks {
+		out[i] = TemplatePreview{ID: t.ID, Path: t.Path, Matches: make([]*indexer.TagFields, len(parsed))}
+		for j, pt := range parsed {
+			if f, ok := pt.Match(t.Path); ok {
+				out[i].Matches[j] = &f
+			}
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,13 @@
+// Command penguin-tunes-cli scans and queries the PenguinTunes library
+// without the GUI, sharing its config and index files
+package main
+
+import (
+	"os"
+
+	"penguin-tunes/pkg/cli"
+)
+
+func main() {
+    os.Exit(cli.Run(os.Args[1:], os.Stdout, os.Stderr))
+}
@@ -0,0 +1,91 @@
+import { GetDSPGraph } from "../../wailsjs/go/main/App";
+import { EventsOn } from "../../wailsjs/runtime/runtime";
+import { dsp } from "../../wailsjs/go/models";
+
+export interface DSPOutput {
+  // setEnabled turns processing off for sources the backend already processed
+  setEnabled(enabled: boolean): void;
+  detach(): void;
+}
+
+// attachDSP routes an audio element through the DSP settings: preamp, the
+// equalizer filters, then balance. The graph is rebuilt whenever the settings
+// change.
+export function attachDSP(audio: HTMLAudioElement): DSPOutput {
+  const ctx = new AudioContext();
+  const source = ctx.createMediaElementSource(audio);
+  let nodes: AudioNode[] = [];
+  let graph: dsp.Graph | null = null;
+  let enabled = true;
+
+  function connect() {
+    source.disconnect();
+    nodes.forEach((n) => n.disconnect());
+    nodes = [];
+    if (!enabled || !graph) {
+      source.connect(ctx.destination);
+      return;
+    }
+    const g = graph;
+
+    const preamp = ctx.createGain();
+    preamp.gain.value = g.preamp;
+    // mono sources are spread over both sides so balance applies to them too
+    preamp.channelCount = 2;
+    preamp.channelCountMode = "explicit";
+    preamp.channelInterpretation = "speakers";
+    const filters = g.filters.map((f) =>
+      ctx.createIIRFilter(f.feedforward, f.feedback),
+    );
+    const splitter = ctx.createChannelSplitter(2);
+    const left = ctx.createGain();
+    left.gain.value = g.left;
+    const right = ctx.createGain();
+    right.gain.value = g.right;
+    const merger = ctx.createChannelMerger(2);
+
+    let last: AudioNode = source;
+    for (const n of [preamp, ...filters, splitter]) {
+      last.connect(n);
+      last = n;
+    }
+    splitter.connect(left, 0);
+    splitter.connect(right, 1);
+    left.connect(merger, 0, 0);
+    right.connect(merger, 0, 1);
+    merger.connect(ctx.destination);
+    nodes = [preamp, ...filters, splitter, left, right, merger];
+  }
+
+  function refresh() {
+    // coefficients depend on the rate the context runs at
+    GetDSPGraph(ctx.sampleRate)
+      .then((g) => {
+        graph = g;
+        connect();
+      })
+      .catch((err) => console.error("dsp:", err));
+  }
+
+  // play unprocessed until the first graph arrives
+  connect();
+  refresh();
+  const off = EventsOn("dsp-changed", refresh);
+
+  return {
+    setEnabled(on: boolean) {
+      if (on !== enabled) {
+        enabled = on;
+        connect();
+      }
+      // a context created before any user gesture starts suspended
+      ctx.resume();
+    },
+    detach() {
+      off();
+      source.disconnect();
+      nodes.forEach((n) => n.disconnect());
+      ctx.close();
+    },
+  };
+}
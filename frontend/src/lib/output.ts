@@ -0,0 +1,117 @@
+import {
+  GetMediaSource,
+  GetPlayerStatus,
+  ReportProgress,
+  TrackEnded,
+} from "../../wailsjs/go/main/App";
+import { EventsOn } from "../../wailsjs/runtime/runtime";
+import { player } from "../../wailsjs/go/models";
+import { attachDSP } from "@/lib/dsp";
+
+// startOutput plays what the player says: it loads the current track into
+// an audio element routed through the DSP settings and reports progress
+// back. The returned function stops it.
+export function startOutput(): () => void {
+  const audio = new Audio();
+  const dsp = attachDSP(audio);
+  let trackID = "";
+  let processed = false;
+  let duration = 0;
+  // where in the file the loaded source starts: processed streams begin
+  // where they were requested, other files at their start
+  let base = 0;
+  let loads = 0;
+
+  function follow(state: string) {
+    if (state === "playing") {
+      audio.play().catch((err) => console.error("output:", err));
+    } else {
+      audio.pause();
+    }
+  }
+
+  function stop() {
+    trackID = "";
+    audio.pause();
+    audio.removeAttribute("src");
+    audio.load();
+  }
+
+  async function load(st: player.Status, from: number) {
+    const t = st.queue[st.current];
+    const n = ++loads;
+    const src = await GetMediaSource(t.id, from);
+    if (n !== loads) {
+      // a later track change or seek won
+      return;
+    }
+    trackID = t.id;
+    processed = src.processed;
+    duration = t.duration;
+    base = processed ? from : 0;
+    dsp.setEnabled(!processed);
+    audio.src = src.url;
+    if (!processed) {
+      audio.currentTime = from;
+    }
+    follow(st.state);
+  }
+
+  function loadCurrent(st: player.Status) {
+    if (st.state === "stopped" || !st.queue[st.current]) {
+      stop();
+      return;
+    }
+    load(st, st.offset + st.position).catch((err) =>
+      console.error("output:", err),
+    );
+  }
+
+  const offs = [
+    EventsOn("player-track", loadCurrent),
+    EventsOn("player-state", (st: player.Status) => {
+      const t = st.queue[st.current];
+      if (st.state === "stopped" || !t || t.id !== trackID) {
+        // resuming after a stop loads the track again
+        loadCurrent(st);
+        return;
+      }
+      follow(st.state);
+    }),
+    EventsOn("player-seeked", (st: player.Status) => {
+      const from = st.offset + st.position;
+      if (processed) {
+        // a stream can't seek; request it again from the new position
+        load(st, from).catch((err) => console.error("output:", err));
+      } else {
+        audio.currentTime = from;
+      }
+    }),
+    EventsOn("player-volume", (st: player.Status) => {
+      audio.volume = st.volume;
+    }),
+  ];
+  audio.addEventListener("timeupdate", () => {
+    if (trackID) {
+      ReportProgress(
+        base + audio.currentTime,
+        processed ? duration : audio.duration || 0,
+      );
+    }
+  });
+  audio.addEventListener("ended", () => {
+    if (trackID) {
+      TrackEnded();
+    }
+  });
+  GetPlayerStatus().then((st) => {
+    audio.volume = st.volume;
+    loadCurrent(st);
+  });
+
+  return () => {
+    offs.forEach((off) => off());
+    stop();
+    dsp.detach();
+  };
+}
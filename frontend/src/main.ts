@@ -1,8 +1,10 @@
 import { createApp } from "vue";
 import App from "./App.vue";
 import { createPinia } from "pinia";
+import { startOutput } from "@/lib/output";
 import "./style.css";
 
 const app = createApp(App);
 app.use(createPinia());
 app.mount("#app");
+startOutput();
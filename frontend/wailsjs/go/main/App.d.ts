@@ -1,14 +1,146 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
-import {config} from '../models';
+import {playlist} from '../models';
+import {tagwriter} from '../models';
 import {indexer} from '../models';
+import {config} from '../models';
+import {dsp} from '../models';
+import {time} from '../models';
+import {stats} from '../models';
+import {lyrics} from '../models';
+import {media} from '../models';
+import {player} from '../models';
+import {logging} from '../models';
+import {waveform} from '../models';
+import {organizer} from '../models';
+import {main} from '../models';
 
 export function AddSrcDir(arg1:string):Promise<void>;
 
+export function AddToPlaylist(arg1:string,arg2:Array<string>,arg3:number):Promise<void>;
+
+export function ApplyEQPreset(arg1:string):Promise<void>;
+
+export function ApplyOrganize(arg1:Array<string>,arg2:string,arg3:string):Promise<number>;
+
+export function CanEditTags(arg1:string):Promise<boolean>;
+
+export function CanUndoOrganize():Promise<boolean>;
+
+export function ClearQueue():Promise<void>;
+
+export function CreatePlaylist(arg1:string):Promise<playlist.Playlist>;
+
+export function CreateSmartPlaylist(arg1:string,arg2:playlist.Query):Promise<playlist.SmartPlaylist>;
+
+export function DeleteEQPreset(arg1:string):Promise<void>;
+
+export function DeletePlaylist(arg1:string):Promise<void>;
+
+export function DeleteSmartPlaylist(arg1:string):Promise<void>;
+
+export function EditTags(arg1:Array<string>,arg2:tagwriter.Update):Promise<void>;
+
+export function EnqueueTracks(arg1:Array<string>):Promise<void>;
+
+export function ExportPlaylist(arg1:string,arg2:string,arg3:boolean):Promise<void>;
+
+export function FindDuplicates(arg1:indexer.DuplicateOptions):Promise<Array<indexer.DuplicateGroup>>;
+
+export function FindSimilar(arg1:string,arg2:number):Promise<Array<indexer.SimilarTrack>>;
+
 export function GetConfig():Promise<config.Config>;
 
+export function GetDSPGraph(arg1:number):Promise<dsp.Graph>;
+
+export function GetEQPresets():Promise<Array<config.EQPreset>>;
+
+export function GetListeningHistory(arg1:time.Time,arg2:time.Time,arg3:number):Promise<Array<stats.Event>>;
+
+export function GetLyrics(arg1:string):Promise<lyrics.Lyrics>;
+
+export function GetMediaSource(arg1:string,arg2:number):Promise<media.Source>;
+
+export function GetPlayerStatus():Promise<player.Status>;
+
+export function GetPlaylistItems(arg1:string):Promise<Array<playlist.Item>>;
+
+export function GetPlaylists():Promise<Array<playlist.Playlist>>;
+
+export function GetRecentLogs(arg1:number,arg2:string,arg3:string):Promise<Array<logging.Entry>>;
+
+export function GetScanStatus():Promise<indexer.ScanStatus>;
+
+export function GetSmartPlaylistTracks(arg1:string):Promise<Array<indexer.Track>>;
+
+export function GetSmartPlaylists():Promise<Array<playlist.SmartPlaylist>>;
+
+export function GetTopAlbums(arg1:time.Time,arg2:time.Time,arg3:number):Promise<Array<stats.TopItem>>;
+
+export function GetTopArtists(arg1:time.Time,arg2:time.Time,arg3:number):Promise<Array<stats.TopItem>>;
+
+export function GetTopTracks(arg1:time.Time,arg2:time.Time,arg3:number):Promise<Array<stats.TopItem>>;
+
+export function GetTrackStats(arg1:string):Promise<stats.TrackStats>;
+
 export function GetTracks():Promise<Array<indexer.Track>>;
 
+export function GetWaveform(arg1:string):Promise<waveform.Peaks>;
+
+export function ImportPlaylist(arg1:string):Promise<playlist.ImportResult>;
+
+export function MovePlaylistEntry(arg1:string,arg2:number,arg3:number):Promise<void>;
+
+export function Next():Promise<void>;
+
+export function Pause():Promise<void>;
+
+export function PlanOrganize(arg1:Array<string>,arg2:string,arg3:string):Promise<organizer.Plan>;
+
+export function Play():Promise<void>;
+
+export function PlayPause():Promise<void>;
+
+export function PlayPlaylist(arg1:string,arg2:number):Promise<void>;
+
+export function PlayQueueIndex(arg1:number):Promise<void>;
+
+export function PlaySmartPlaylist(arg1:string):Promise<void>;
+
+export function PlayTracks(arg1:Array<string>,arg2:number):Promise<void>;
+
+export function PreviewPathTemplates(arg1:Array<string>,arg2:Array<string>):Promise<Array<main.TemplatePreview>>;
+
+export function Previous():Promise<void>;
+
+export function QueryTracks(arg1:playlist.Query):Promise<Array<indexer.Track>>;
+
+export function RemoveFromPlaylist(arg1:string,arg2:Array<number>):Promise<void>;
+
 export function RemoveSrcDir(arg1:string):Promise<void>;
 
+export function RenamePlaylist(arg1:string,arg2:string):Promise<void>;
+
+export function ReportProgress(arg1:number,arg2:number):Promise<void>;
+
 export function SaveConfig(arg1:config.Config):Promise<void>;
+
+export function SaveEQPreset(arg1:config.EQPreset):Promise<void>;
+
+export function Seek(arg1:number):Promise<void>;
+
+export function SetDSPConfig(arg1:config.DSPConfig):Promise<void>;
+
+export function SetLoved(arg1:string,arg2:boolean):Promise<void>;
+
+export function SetRating(arg1:string,arg2:number):Promise<void>;
+
+export function SetVolume(arg1:number):Promise<void>;
+
+export function Stop():Promise<void>;
+
+export function TrackEnded():Promise<void>;
+
+export function UndoOrganize():Promise<number>;
+
+export function UpdateSmartPlaylist(arg1:string,arg2:string,arg3:playlist.Query):Promise<void>;
@@ -6,18 +6,258 @@ export function AddSrcDir(arg1) {
   return window['go']['main']['App']['AddSrcDir'](arg1);
 }
 
+export function AddToPlaylist(arg1, arg2, arg3) {
+  return window['go']['main']['App']['AddToPlaylist'](arg1, arg2, arg3);
+}
+
+export function ApplyEQPreset(arg1) {
+  return window['go']['main']['App']['ApplyEQPreset'](arg1);
+}
+
+export function ApplyOrganize(arg1, arg2, arg3) {
+  return window['go']['main']['App']['ApplyOrganize'](arg1, arg2, arg3);
+}
+
+export function CanEditTags(arg1) {
+  return window['go']['main']['App']['CanEditTags'](arg1);
+}
+
+export function CanUndoOrganize() {
+  return window['go']['main']['App']['CanUndoOrganize']();
+}
+
+export function ClearQueue() {
+  return window['go']['main']['App']['ClearQueue']();
+}
+
+export function CreatePlaylist(arg1) {
+  return window['go']['main']['App']['CreatePlaylist'](arg1);
+}
+
+export function CreateSmartPlaylist(arg1, arg2) {
+  return window['go']['main']['App']['CreateSmartPlaylist'](arg1, arg2);
+}
+
+export function DeleteEQPreset(arg1) {
+  return window['go']['main']['App']['DeleteEQPreset'](arg1);
+}
+
+export function DeletePlaylist(arg1) {
+  return window['go']['main']['App']['DeletePlaylist'](arg1);
+}
+
+export function DeleteSmartPlaylist(arg1) {
+  return window['go']['main']['App']['DeleteSmartPlaylist'](arg1);
+}
+
+export function EditTags(arg1, arg2) {
+  return window['go']['main']['App']['EditTags'](arg1, arg2);
+}
+
+export function EnqueueTracks(arg1) {
+  return window['go']['main']['App']['EnqueueTracks'](arg1);
+}
+
+export function ExportPlaylist(arg1, arg2, arg3) {
+  return window['go']['main']['App']['ExportPlaylist'](arg1, arg2, arg3);
+}
+
+export function FindDuplicates(arg1) {
+  return window['go']['main']['App']['FindDuplicates'](arg1);
+}
+
+export function FindSimilar(arg1, arg2) {
+  return window['go']['main']['App']['FindSimilar'](arg1, arg2);
+}
+
 export function GetConfig() {
   return window['go']['main']['App']['GetConfig']();
 }
 
+export function GetDSPGraph(arg1) {
+  return window['go']['main']['App']['GetDSPGraph'](arg1);
+}
+
+export function GetEQPresets() {
+  return window['go']['main']['App']['GetEQPresets']();
+}
+
+export function GetListeningHistory(arg1, arg2, arg3) {
+  return window['go']['main']['App']['GetListeningHistory'](arg1, arg2, arg3);
+}
+
+export function GetLyrics(arg1) {
+  return window['go']['main']['App']['GetLyrics'](arg1);
+}
+
+export function GetMediaSource(arg1, arg2) {
+  return window['go']['main']['App']['GetMediaSource'](arg1, arg2);
+}
+
+export function GetPlayerStatus() {
+  return window['go']['main']['App']['GetPlayerStatus']();
+}
+
+export function GetPlaylistItems(arg1) {
+  return window['go']['main']['App']['GetPlaylistItems'](arg1);
+}
+
+export function GetPlaylists() {
+  return window['go']['main']['App']['GetPlaylists']();
+}
+
+export function GetRecentLogs(arg1, arg2, arg3) {
+  return window['go']['main']['App']['GetRecentLogs'](arg1, arg2, arg3);
+}
+
+export function GetScanStatus() {
+  return window['go']['main']['App']['GetScanStatus']();
+}
+
+export function GetSmartPlaylistTracks(arg1) {
+  return window['go']['main']['App']['GetSmartPlaylistTracks'](arg1);
+}
+
+export function GetSmartPlaylists() {
+  return window['go']['main']['App']['GetSmartPlaylists']();
+}
+
+export function GetTopAlbums(arg1, arg2, arg3) {
+  return window['go']['main']['App']['GetTopAlbums'](arg1, arg2, arg3);
+}
+
+export function GetTopArtists(arg1, arg2, arg3) {
+  return window['go']['main']['App']['GetTopArtists'](arg1, arg2, arg3);
+}
+
+export function GetTopTracks(arg1, arg2, arg3) {
+  return window['go']['main']['App']['GetTopTracks'](arg1, arg2, arg3);
+}
+
+export function GetTrackStats(arg1) {
+  return window['go']['main']['App']['GetTrackStats'](arg1);
+}
+
 export function GetTracks() {
   return window['go']['main']['App']['GetTracks']();
 }
 
+export function GetWaveform(arg1) {
+  return window['go']['main']['App']['GetWaveform'](arg1);
+}
+
+export function ImportPlaylist(arg1) {
+  return window['go']['main']['App']['ImportPlaylist'](arg1);
+}
+
+export function MovePlaylistEntry(arg1, arg2, arg3) {
+  return window['go']['main']['App']['MovePlaylistEntry'](arg1, arg2, arg3);
+}
+
+export function Next() {
+  return window['go']['main']['App']['Next']();
+}
+
+export function Pause() {
+  return window['go']['main']['App']['Pause']();
+}
+
+export function PlanOrganize(arg1, arg2, arg3) {
+  return window['go']['main']['App']['PlanOrganize'](arg1, arg2, arg3);
+}
+
+export function Play() {
+  return window['go']['main']['App']['Play']();
+}
+
+export function PlayPause() {
+  return window['go']['main']['App']['PlayPause']();
+}
+
+export function PlayPlaylist(arg1, arg2) {
+  return window['go']['main']['App']['PlayPlaylist'](arg1, arg2);
+}
+
+export function PlayQueueIndex(arg1) {
+  return window['go']['main']['App']['PlayQueueIndex'](arg1);
+}
+
+export function PlaySmartPlaylist(arg1) {
+  return window['go']['main']['App']['PlaySmartPlaylist'](arg1);
+}
+
+export function PlayTracks(arg1, arg2) {
+  return window['go']['main']['App']['PlayTracks'](arg1, arg2);
+}
+
+export function PreviewPathTemplates(arg1, arg2) {
+  return window['go']['main']['App']['PreviewPathTemplates'](arg1, arg2);
+}
+
+export function Previous() {
+  return window['go']['main']['App']['Previous']();
+}
+
+export function QueryTracks(arg1) {
+  return window['go']['main']['App']['QueryTracks'](arg1);
+}
+
+export function RemoveFromPlaylist(arg1, arg2) {
+  return window['go']['main']['App']['RemoveFromPlaylist'](arg1, arg2);
+}
+
 export function RemoveSrcDir(arg1) {
   return window['go']['main']['App']['RemoveSrcDir'](arg1);
 }
 
+export function RenamePlaylist(arg1, arg2) {
+  return window['go']['main']['App']['RenamePlaylist'](arg1, arg2);
+}
+
+export function ReportProgress(arg1, arg2) {
+  return window['go']['main']['App']['ReportProgress'](arg1, arg2);
+}
+
 export function SaveConfig(arg1) {
   return window['go']['main']['App']['SaveConfig'](arg1);
 }
+
+export function SaveEQPreset(arg1) {
+  return window['go']['main']['App']['SaveEQPreset'](arg1);
+}
+
+export function Seek(arg1) {
+  return window['go']['main']['App']['Seek'](arg1);
+}
+
+export function SetDSPConfig(arg1) {
+  return window['go']['main']['App']['SetDSPConfig'](arg1);
+}
+
+export function SetLoved(arg1, arg2) {
+  return window['go']['main']['App']['SetLoved'](arg1, arg2);
+}
+
+export function SetRating(arg1, arg2) {
+  return window['go']['main']['App']['SetRating'](arg1, arg2);
+}
+
+export function SetVolume(arg1) {
+  return window['go']['main']['App']['SetVolume'](arg1);
+}
+
+export function Stop() {
+  return window['go']['main']['App']['Stop']();
+}
+
+export function TrackEnded() {
+  return window['go']['main']['App']['TrackEnded']();
+}
+
+export function UndoOrganize() {
+  return window['go']['main']['App']['UndoOrganize']();
+}
+
+export function UpdateSmartPlaylist(arg1, arg2, arg3) {
+  return window['go']['main']['App']['UpdateSmartPlaylist'](arg1, arg2, arg3);
+}
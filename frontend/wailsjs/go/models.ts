@@ -1,7 +1,160 @@
 export namespace config {
 	
+	export class LoggingConfig {
+	    level: string;
+	    components: Record<string, string>;
+	
+	    static createFrom(source: any = {}) {
+	        return new LoggingConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.level = source["level"];
+	        this.components = source["components"];
+	    }
+	}
+	export class MPDConfig {
+	    enabled: boolean;
+	    address: string;
+	    password: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new MPDConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.address = source["address"];
+	        this.password = source["password"];
+	    }
+	}
+	export class SubsonicConfig {
+	    enabled: boolean;
+	    address: string;
+	    username: string;
+	    password: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new SubsonicConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.address = source["address"];
+	        this.username = source["username"];
+	        this.password = source["password"];
+	    }
+	}
+	export class EQPreset {
+	    name: string;
+	    preamp: number;
+	    bands: EQBand[];
+	
+	    static createFrom(source: any = {}) {
+	        return new EQPreset(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.preamp = source["preamp"];
+	        this.bands = this.convertValues(source["bands"], EQBand);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class EQBand {
+	    type: string;
+	    freq: number;
+	    gain: number;
+	    q: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new EQBand(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.type = source["type"];
+	        this.freq = source["freq"];
+	        this.gain = source["gain"];
+	        this.q = source["q"];
+	    }
+	}
+	export class DSPConfig {
+	    preset: string;
+	    bands: EQBand[];
+	    preamp: number;
+	    balance: number;
+	    eqBypass: boolean;
+	    preampBypass: boolean;
+	    balanceBypass: boolean;
+	    presets: EQPreset[];
+	
+	    static createFrom(source: any = {}) {
+	        return new DSPConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.preset = source["preset"];
+	        this.bands = this.convertValues(source["bands"], EQBand);
+	        this.preamp = source["preamp"];
+	        this.balance = source["balance"];
+	        this.eqBypass = source["eqBypass"];
+	        this.preampBypass = source["preampBypass"];
+	        this.balanceBypass = source["balanceBypass"];
+	        this.presets = this.convertValues(source["presets"], EQPreset);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	export class Config {
 	    srcDirs: string[];
+	    dsp: DSPConfig;
+	    autoImportPlaylists: boolean;
+	    ratingSync: boolean;
+	    pathTemplates: string[];
+	    fingerprintSeconds: number;
+	    analyzeTempoKey: boolean;
+	    subsonic: SubsonicConfig;
+	    mpd: MPDConfig;
+	    logging: LoggingConfig;
 	
 	    static createFrom(source: any = {}) {
 	        return new Config(source);
@@ -10,7 +163,96 @@ export namespace config {
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.srcDirs = source["srcDirs"];
+	        this.dsp = this.convertValues(source["dsp"], DSPConfig);
+	        this.autoImportPlaylists = source["autoImportPlaylists"];
+	        this.ratingSync = source["ratingSync"];
+	        this.pathTemplates = source["pathTemplates"];
+	        this.fingerprintSeconds = source["fingerprintSeconds"];
+	        this.analyzeTempoKey = source["analyzeTempoKey"];
+	        this.subsonic = this.convertValues(source["subsonic"], SubsonicConfig);
+	        this.mpd = this.convertValues(source["mpd"], MPDConfig);
+	        this.logging = this.convertValues(source["logging"], LoggingConfig);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	
+	
+	
+	
+
+}
+
+export namespace dsp {
+	
+	export class Filter {
+	    feedforward: number[];
+	    feedback: number[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Filter(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.feedforward = source["feedforward"];
+	        this.feedback = source["feedback"];
+	    }
+	}
+	export class Graph {
+	    sampleRate: number;
+	    preamp: number;
+	    filters: Filter[];
+	    left: number;
+	    right: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Graph(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.sampleRate = source["sampleRate"];
+	        this.preamp = source["preamp"];
+	        this.filters = this.convertValues(source["filters"], Filter);
+	        this.left = source["left"];
+	        this.right = source["right"];
 	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
 	}
 
 }
@@ -23,11 +265,28 @@ export namespace indexer {
 	    title: string;
 	    album: string;
 	    artist: string;
+	    album_artist: string;
 	    composer: string;
 	    genre: string;
 	    track_number: number;
+	    disc_number: number;
 	    cover: string;
 	    year: number;
+	    codec: string;
+	    duration: number;
+	    bitrate: number;
+	    fingerprint?: string;
+	    bpm: number;
+	    bpm_confidence: number;
+	    key: string;
+	    key_confidence: number;
+	    has_lyrics: boolean;
+	    rating: number;
+	    loved: boolean;
+	    cue?: string;
+	    start?: number;
+	    end?: number;
+	    external?: boolean;
 	
 	    static createFrom(source: any = {}) {
 	        return new Track(source);
@@ -40,11 +299,945 @@ export namespace indexer {
 	        this.title = source["title"];
 	        this.album = source["album"];
 	        this.artist = source["artist"];
+	        this.album_artist = source["album_artist"];
 	        this.composer = source["composer"];
 	        this.genre = source["genre"];
 	        this.track_number = source["track_number"];
+	        this.disc_number = source["disc_number"];
 	        this.cover = source["cover"];
 	        this.year = source["year"];
+	        this.codec = source["codec"];
+	        this.duration = source["duration"];
+	        this.bitrate = source["bitrate"];
+	        this.fingerprint = source["fingerprint"];
+	        this.bpm = source["bpm"];
+	        this.bpm_confidence = source["bpm_confidence"];
+	        this.key = source["key"];
+	        this.key_confidence = source["key_confidence"];
+	        this.has_lyrics = source["has_lyrics"];
+	        this.rating = source["rating"];
+	        this.loved = source["loved"];
+	        this.cue = source["cue"];
+	        this.start = source["start"];
+	        this.end = source["end"];
+	        this.external = source["external"];
+	    }
+	}
+	export class DuplicateGroup {
+	    match: string;
+	    tracks: Track[];
+	    best: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new DuplicateGroup(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.match = source["match"];
+	        this.tracks = this.convertValues(source["tracks"], Track);
+	        this.best = source["best"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class DuplicateOptions {
+	    tiers: string[];
+	    tolerance: number;
+	    similarity: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new DuplicateOptions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.tiers = source["tiers"];
+	        this.tolerance = source["tolerance"];
+	        this.similarity = source["similarity"];
+	    }
+	}
+	export class ScanStatus {
+	    state: string;
+	    scanning: string[];
+	    queued: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ScanStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.state = source["state"];
+	        this.scanning = source["scanning"];
+	        this.queued = source["queued"];
+	    }
+	}
+	export class SimilarTrack {
+	    track?: Track;
+	    score: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new SimilarTrack(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.track = this.convertValues(source["track"], Track);
+	        this.score = source["score"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class TagFields {
+	    title: string;
+	    artist: string;
+	    album: string;
+	    album_artist: string;
+	    genre: string;
+	    composer: string;
+	    year: number;
+	    track_number: number;
+	    disc_number: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new TagFields(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.title = source["title"];
+	        this.artist = source["artist"];
+	        this.album = source["album"];
+	        this.album_artist = source["album_artist"];
+	        this.genre = source["genre"];
+	        this.composer = source["composer"];
+	        this.year = source["year"];
+	        this.track_number = source["track_number"];
+	        this.disc_number = source["disc_number"];
+	    }
+	}
+
+}
+
+export namespace logging {
+	
+	export class Entry {
+	    time: time.Time;
+	    level: string;
+	    component: string;
+	    msg: string;
+	    attrs?: Record<string, any>;
+	
+	    static createFrom(source: any = {}) {
+	        return new Entry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.time = this.convertValues(source["time"], time.Time);
+	        this.level = source["level"];
+	        this.component = source["component"];
+	        this.msg = source["msg"];
+	        this.attrs = source["attrs"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+export namespace lyrics {
+	
+	export class Word {
+	    time: number;
+	    text: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Word(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.time = source["time"];
+	        this.text = source["text"];
+	    }
+	}
+	export class Line {
+	    time: number;
+	    text: string;
+	    words?: Word[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Line(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.time = source["time"];
+	        this.text = source["text"];
+	        this.words = this.convertValues(source["words"], Word);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Lyrics {
+	    synced: boolean;
+	    lines: Line[];
+	    source: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Lyrics(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.synced = source["synced"];
+	        this.lines = this.convertValues(source["lines"], Line);
+	        this.source = source["source"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+export namespace main {
+	
+	export class TemplatePreview {
+	    id: string;
+	    path: string;
+	    matches: indexer.TagFields[];
+	
+	    static createFrom(source: any = {}) {
+	        return new TemplatePreview(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.path = source["path"];
+	        this.matches = this.convertValues(source["matches"], indexer.TagFields);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+export namespace media {
+	
+	export class Source {
+	    url: string;
+	    processed: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Source(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.url = source["url"];
+	        this.processed = source["processed"];
+	    }
+	}
+
+}
+
+export namespace organizer {
+	
+	export class Move {
+	    trackId: string;
+	    from: string;
+	    to: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Move(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.trackId = source["trackId"];
+	        this.from = source["from"];
+	        this.to = source["to"];
+	    }
+	}
+	export class Skip {
+	    path: string;
+	    reason: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Skip(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.path = source["path"];
+	        this.reason = source["reason"];
+	    }
+	}
+	export class Plan {
+	    moves: Move[];
+	    skipped: Skip[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Plan(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.moves = this.convertValues(source["moves"], Move);
+	        this.skipped = this.convertValues(source["skipped"], Skip);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+export namespace player {
+	
+	export class Status {
+	    state: string;
+	    queue: indexer.Track[];
+	    current: number;
+	    position: number;
+	    duration: number;
+	    volume: number;
+	    offset: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Status(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.state = source["state"];
+	        this.queue = this.convertValues(source["queue"], indexer.Track);
+	        this.current = source["current"];
+	        this.position = source["position"];
+	        this.duration = source["duration"];
+	        this.volume = source["volume"];
+	        this.offset = source["offset"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+export namespace playlist {
+	
+	export class Entry {
+	    trackId: string;
+	    path: string;
+	    title: string;
+	    artist: string;
+	    missing: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Entry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.trackId = source["trackId"];
+	        this.path = source["path"];
+	        this.title = source["title"];
+	        this.artist = source["artist"];
+	        this.missing = source["missing"];
+	    }
+	}
+	export class FileEntry {
+	    location: string;
+	    title: string;
+	    duration: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new FileEntry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.location = source["location"];
+	        this.title = source["title"];
+	        this.duration = source["duration"];
+	    }
+	}
+	export class Rule {
+	    field: string;
+	    op: string;
+	    value: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new Rule(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.field = source["field"];
+	        this.op = source["op"];
+	        this.value = source["value"];
+	    }
+	}
+	export class Group {
+	    match: string;
+	    rules: Rule[];
+	    groups: Group[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Group(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.match = source["match"];
+	        this.rules = this.convertValues(source["rules"], Rule);
+	        this.groups = this.convertValues(source["groups"], Group);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Playlist {
+	    id: string;
+	    name: string;
+	    entries: Entry[];
+	    created: time.Time;
+	    updated: time.Time;
+	    source?: string;
+	    sourceModTime: time.Time;
+	
+	    static createFrom(source: any = {}) {
+	        return new Playlist(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.entries = this.convertValues(source["entries"], Entry);
+	        this.created = this.convertValues(source["created"], time.Time);
+	        this.updated = this.convertValues(source["updated"], time.Time);
+	        this.source = source["source"];
+	        this.sourceModTime = this.convertValues(source["sourceModTime"], time.Time);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ImportResult {
+	    playlist: Playlist;
+	    resolved: number;
+	    unresolved: FileEntry[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ImportResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.playlist = this.convertValues(source["playlist"], Playlist);
+	        this.resolved = source["resolved"];
+	        this.unresolved = this.convertValues(source["unresolved"], FileEntry);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Item {
+	    position: number;
+	    entry: Entry;
+	    track?: indexer.Track;
+	
+	    static createFrom(source: any = {}) {
+	        return new Item(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.position = source["position"];
+	        this.entry = this.convertValues(source["entry"], Entry);
+	        this.track = this.convertValues(source["track"], indexer.Track);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class SortKey {
+	    field: string;
+	    desc: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new SortKey(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.field = source["field"];
+	        this.desc = source["desc"];
+	    }
+	}
+	export class Query {
+	    where: Group;
+	    sort: SortKey[];
+	    limit: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Query(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.where = this.convertValues(source["where"], Group);
+	        this.sort = this.convertValues(source["sort"], SortKey);
+	        this.limit = source["limit"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class SmartPlaylist {
+	    id: string;
+	    name: string;
+	    query: Query;
+	    created: time.Time;
+	    updated: time.Time;
+	
+	    static createFrom(source: any = {}) {
+	        return new SmartPlaylist(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.query = this.convertValues(source["query"], Query);
+	        this.created = this.convertValues(source["created"], time.Time);
+	        this.updated = this.convertValues(source["updated"], time.Time);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+export namespace stats {
+	
+	export class Event {
+	    trackId: string;
+	    type: string;
+	    time: time.Time;
+	    listened: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Event(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.trackId = source["trackId"];
+	        this.type = source["type"];
+	        this.time = this.convertValues(source["time"], time.Time);
+	        this.listened = source["listened"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class TopItem {
+	    name: string;
+	    artist: string;
+	    trackId: string;
+	    plays: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new TopItem(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.artist = source["artist"];
+	        this.trackId = source["trackId"];
+	        this.plays = source["plays"];
+	    }
+	}
+	export class TrackStats {
+	    playCount: number;
+	    skipCount: number;
+	    firstPlayed: time.Time;
+	    lastPlayed: time.Time;
+	
+	    static createFrom(source: any = {}) {
+	        return new TrackStats(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.playCount = source["playCount"];
+	        this.skipCount = source["skipCount"];
+	        this.firstPlayed = this.convertValues(source["firstPlayed"], time.Time);
+	        this.lastPlayed = this.convertValues(source["lastPlayed"], time.Time);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+export namespace tagwriter {
+	
+	export class Cover {
+	    mime: string;
+	    data: number[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Cover(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.mime = source["mime"];
+	        this.data = source["data"];
+	    }
+	}
+	export class Update {
+	    title?: string;
+	    artist?: string;
+	    album?: string;
+	    albumArtist?: string;
+	    genre?: string;
+	    composer?: string;
+	    trackNumber?: number;
+	    discNumber?: number;
+	    year?: number;
+	    rating?: number;
+	    cover?: Cover;
+	
+	    static createFrom(source: any = {}) {
+	        return new Update(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.title = source["title"];
+	        this.artist = source["artist"];
+	        this.album = source["album"];
+	        this.albumArtist = source["albumArtist"];
+	        this.genre = source["genre"];
+	        this.composer = source["composer"];
+	        this.trackNumber = source["trackNumber"];
+	        this.discNumber = source["discNumber"];
+	        this.year = source["year"];
+	        this.rating = source["rating"];
+	        this.cover = this.convertValues(source["cover"], Cover);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+export namespace time {
+	
+	export class Time {
+	
+	
+	    static createFrom(source: any = {}) {
+	        return new Time(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	
+	    }
+	}
+
+}
+
+export namespace waveform {
+	
+	export class Peaks {
+	    min: number[];
+	    max: number[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Peaks(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.min = source["min"];
+	        this.max = source["max"];
 	    }
 	}
 
@@ -2,6 +2,7 @@ package main
 
 import (
 	"embed"
+	"os"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
@@ -12,8 +13,15 @@ import (
 var assets embed.FS
 
 func main() {
+	// Hand the command line to a running instance, if any
+	srv, ok := claimInstance(os.Args[1:])
+	if !ok {
+		return
+	}
 	// Create an instance of the app structure
 	app := NewApp()
+	app.instance = srv
+	app.launchArgs = os.Args[1:]
 
 	// Create application with options
 	err := wails.Run(&options.App{
@@ -24,10 +32,13 @@ func main() {
 		MinHeight: 420,
 		AssetServer: &assetserver.Options{
 			Assets: assets,
+			// tracks are streamed to the audio element from here
+			Handler: app.media,
 		},
 		Frameless: true,
 		BackgroundColour: &options.RGBA{R: 23, G: 23, B: 25, A: 1},
 		OnStartup:        app.startup,
+		OnShutdown:       app.shutdown,
 		Bind: []interface{}{
 			app,
 		},
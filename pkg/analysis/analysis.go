@@ -0,0 +1,226 @@
+package analysis
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sort"
+	"sync"
+
+	"penguin-tunes/pkg/audio"
+	"penguin-tunes/pkg/indexer"
+)
+
+// Task is one kind of analysis the runner performs on decoded audio
+type Task interface {
+    Name() string
+    // Pending reports whether t still lacks this task's result
+    Pending(t *indexer.Track) bool
+    // Seconds is how much audio from the start the task needs; 0 means all of it
+    Seconds() int
+    // Start prepares to analyze t, decoded to mono at rate
+    Start(t *indexer.Track, rate int) Analyzer
+}
+
+// Analyzer consumes the audio of one track
+type Analyzer interface {
+    // Write receives the next block of samples; the slice is reused afterwards
+    Write(samples []float32)
+    // Finish returns a function that stores the result on the track, or nil
+    // when the result is kept elsewhere. A task that returns an error isn't
+    // tried on the track again until a restart.
+    Finish() (func(t *indexer.Track), error)
+}
+
+// Progress reports how far a pass has come
+type Progress struct {
+    Done  int    `json:"done"`
+    Total int    `json:"total"`
+    // ID and Path are the track just analyzed
+    ID   string `json:"id"`
+    Path string `json:"path"`
+}
+
+// saveEvery is the number of analyzed tracks between index saves
+const saveEvery = 25
+
+// Runner decodes tracks that have pending tasks, one at a time, and stores
+// the results in the index
+type Runner struct {
+    idx   *indexer.Index
+    tasks []Task
+    // OnProgress is called after each track when set
+    OnProgress func(Progress)
+
+    mtx     sync.Mutex
+    running bool
+    again   bool
+    // failed holds task and track ID pairs that gave no result; they are retried after a restart
+    failed map[failure]bool
+    log    *slog.Logger
+}
+
+type failure struct{ task, id string }
+
+// NewRunner creates a runner for the given tasks
+func NewRunner(idx *indexer.Index, tasks ...Task) *Runner {
+    return &Runner{idx: idx, tasks: tasks, failed: make(map[failure]bool), log: slog.Default()}
+}
+
+// SetLogger sets where the runner logs; call it before Run
+func (r *Runner) SetLogger(l *slog.Logger) {
+    r.log = l
+}
+
+// Run analyzes every track with pending tasks until none are left or ctx is
+// cancelled. A call while a pass is running returns at once and makes the
+// running pass look for new work when it finishes.
+func (r *Runner) Run(ctx context.Context) error {
+    r.mtx.Lock()
+    if r.running {
+        r.again = true
+        r.mtx.Unlock()
+        return nil
+    }
+    r.running = true
+    r.mtx.Unlock()
+    for {
+        err := r.pass(ctx)
+        r.mtx.Lock()
+        if err != nil || !r.again {
+            r.running, r.again = false, false
+            r.mtx.Unlock()
+            return err
+        }
+        r.again = false
+        r.mtx.Unlock()
+    }
+}
+
+func (r *Runner) pass(ctx context.Context) error {
+    var todo []*indexer.Track
+    for _, t := range r.idx.GetAll() {
+        if audio.Supported(t.Path) && len(r.pending(t)) > 0 {
+            todo = append(todo, t)
+        }
+    }
+    sort.Slice(todo, func(i, j int) bool { return todo[i].Path < todo[j].Path })
+    dirty := 0
+    for i, t := range todo {
+        if err := ctx.Err(); err != nil {
+            r.save(dirty)
+            return err
+        }
+        if r.analyze(t) {
+            dirty++
+        }
+        if dirty >= saveEvery {
+            r.save(dirty)
+            dirty = 0
+        }
+        if r.OnProgress != nil {
+            r.OnProgress(Progress{Done: i + 1, Total: len(todo), ID: t.ID, Path: t.Path})
+        }
+    }
+    r.save(dirty)
+    return nil
+}
+
+// analyze decodes as much of t as its pending tasks need, feeding every task
+// in the same pass, and applies their results. Tasks that fail are not tried
+// on t again until a restart. It reports whether the track record changed.
+func (r *Runner) analyze(t *indexer.Track) bool {
+    tasks := r.pending(t)
+    s, err := audio.Open(t.Path)
+    if err != nil {
+        r.fail(tasks, t, err)
+        return false
+    }
+    defer s.Close()
+    if t.Virtual() {
+        s = audio.Section(s, t.Start, t.End)
+    }
+    rate := s.SampleRate()
+    analyzers := make([]Analyzer, len(tasks))
+    limits := make([]int, len(tasks))
+    for i, task := range tasks {
+        analyzers[i] = task.Start(t, rate)
+        limits[i] = task.Seconds() * rate
+    }
+    m := audio.NewMono(s)
+    buf := make([]float32, 8192)
+    for pos := 0; ; {
+        n, err := m.Read(buf)
+        wanted := false
+        for i, a := range analyzers {
+            if limits[i] <= 0 || pos < limits[i] {
+                end := n
+                if limits[i] > 0 {
+                    end = min(n, limits[i]-pos)
+                }
+                a.Write(buf[:end])
+                wanted = wanted || limits[i] <= 0 || pos+n < limits[i]
+            }
+        }
+        pos += n
+        if err == io.EOF || !wanted {
+            break
+        }
+        if err != nil {
+            r.fail(tasks, t, err)
+            return false
+        }
+    }
+    var apply []func(*indexer.Track)
+    for i, a := range analyzers {
+        // a task may store part of its result and still report what it couldn't find
+        fn, err := a.Finish()
+        if err != nil {
+            r.fail(tasks[i:i+1], t, err)
+        }
+        if fn != nil {
+            apply = append(apply, fn)
+        }
+    }
+    if len(apply) == 0 {
+        return false
+    }
+    // the track may have been removed while it was decoded, leaving nothing to update
+    r.idx.UpdateTrack(t.ID, func(t *indexer.Track) {
+        for _, fn := range apply {
+            fn(t)
+        }
+    })
+    return true
+}
+
+func (r *Runner) fail(tasks []Task, t *indexer.Track, err error) {
+    r.mtx.Lock()
+    defer r.mtx.Unlock()
+    for _, task := range tasks {
+        r.log.Warn("analysis failed", "task", task.Name(), "path", t.Path, "err", err)
+        r.failed[failure{task.Name(), t.ID}] = true
+    }
+}
+
+// pending returns the tasks t still needs that haven't failed on it
+func (r *Runner) pending(t *indexer.Track) []Task {
+    r.mtx.Lock()
+    defer r.mtx.Unlock()
+    var out []Task
+    for _, task := range r.tasks {
+        if !r.failed[failure{task.Name(), t.ID}] && task.Pending(t) {
+            out = append(out, task)
+        }
+    }
+    return out
+}
+
+func (r *Runner) save(dirty int) {
+    if dirty == 0 {
+        return
+    }
+    if err := r.idx.SaveToFile(); err != nil {
+        r.log.Error("index save failed", "err", err)
+    }
+}
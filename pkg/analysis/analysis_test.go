@@ -0,0 +1,188 @@
+package analysis
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"penguin-tunes/pkg/indexer"
+	"penguin-tunes/pkg/waveform"
+)
+
+// toneWAV writes a mono 16-bit WAV of a tone that changes pitch every half second
+func toneWAV(t *testing.T, path string, base float64, rate int, seconds float64) {
+    samples := make([]float32, int(seconds*float64(rate)))
+    for i := range samples {
+        step := float64(i / (rate / 2) % 6)
+        f := base * math.Pow(2, step/4)
+        samples[i] = float32(0.35 * math.Sin(2*math.Pi*f*float64(i)/float64(rate)))
+    }
+    writeWAV(t, path, rate, samples)
+}
+
+// writeWAV writes mono samples as a 16-bit WAV file
+func writeWAV(t *testing.T, path string, rate int, samples []float32) {
+    pcm := make([]int16, len(samples))
+    for i, v := range samples {
+        pcm[i] = int16(math.Max(-1, math.Min(1, float64(v))) * 32767)
+    }
+    var b bytes.Buffer
+    b.WriteString("RIFF")
+    binary.Write(&b, binary.LittleEndian, uint32(36+2*len(pcm)))
+    b.WriteString("WAVEfmt ")
+    binary.Write(&b, binary.LittleEndian, []uint32{16})
+    binary.Write(&b, binary.LittleEndian, []uint16{1, 1})
+    binary.Write(&b, binary.LittleEndian, []uint32{uint32(rate), uint32(rate * 2)})
+    binary.Write(&b, binary.LittleEndian, []uint16{2, 16})
+    b.WriteString("data")
+    binary.Write(&b, binary.LittleEndian, uint32(2*len(pcm)))
+    binary.Write(&b, binary.LittleEndian, pcm)
+    if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+}
+
+func TestRunnerFingerprints(t *testing.T) {
+    base := t.TempDir()
+    idx := indexer.NewIndexAtBase(base)
+    files := []struct {
+        name string
+        freq float64
+        rate int
+    }{
+        {"a.wav", 220, 44100},
+        {"a-copy.wav", 220, 22050},
+        {"b.wav", 330, 44100},
+        {"c.mp3", 0, 0},
+    }
+    for _, f := range files {
+        path := filepath.Join(base, f.name)
+        if f.rate > 0 {
+            toneWAV(t, path, f.freq, f.rate, 12)
+        }
+        idx.AddOrUpdateTrack(&indexer.Track{ID: f.name, Path: path})
+    }
+
+    r := NewRunner(idx, NewFingerprintTask(10))
+    var progress []Progress
+    r.OnProgress = func(p Progress) { progress = append(progress, p) }
+    if err := r.Run(context.Background()); err != nil {
+        t.Fatalf("Run: %v", err)
+    }
+    if len(progress) != 3 || progress[2].Done != 3 || progress[2].Total != 3 {
+        t.Fatalf("progress = %+v", progress)
+    }
+    for _, id := range []string{"a.wav", "a-copy.wav", "b.wav"} {
+        if idx.GetByID(id).Fingerprint == "" {
+            t.Fatalf("%s was not fingerprinted", id)
+        }
+    }
+    if idx.GetByID("c.mp3").Fingerprint != "" {
+        t.Fatalf("undecodable track got a fingerprint")
+    }
+
+    similar, err := idx.FindSimilar("a.wav", 0)
+    if err != nil {
+        t.Fatalf("FindSimilar: %v", err)
+    }
+    if len(similar) != 1 || similar[0].Track.ID != "a-copy.wav" {
+        t.Fatalf("FindSimilar = %+v", similar)
+    }
+    groups, err := idx.FindDuplicates(indexer.DuplicateOptions{Tiers: []string{indexer.MatchAcoustic}})
+    if err != nil || len(groups) != 1 || len(groups[0].Tracks) != 2 || groups[0].Match != indexer.MatchAcoustic {
+        t.Fatalf("FindDuplicates = %+v, %v", groups, err)
+    }
+
+    // results are saved and a second pass has nothing left to do
+    reloaded := indexer.NewIndexAtBase(base)
+    if err := reloaded.LoadFromFile(); err != nil || reloaded.GetByID("b.wav").Fingerprint == "" {
+        t.Fatalf("fingerprints not saved: %v", err)
+    }
+    progress = nil
+    if err := r.Run(context.Background()); err != nil || len(progress) != 0 {
+        t.Fatalf("second Run = %v with %d tracks", err, len(progress))
+    }
+}
+
+func TestRunnerWaveforms(t *testing.T) {
+    base := t.TempDir()
+    idx := indexer.NewIndexAtBase(base)
+    path := filepath.Join(base, "a.wav")
+    toneWAV(t, path, 220, 8000, 30)
+    idx.AddOrUpdateTrack(&indexer.Track{ID: "a", Path: path})
+    cache := waveform.NewCacheAtBase(base)
+    task := NewWaveformTask(cache)
+    // a short fingerprint alongside shouldn't cut the decode short
+    r := NewRunner(idx, NewFingerprintTask(5), task)
+    if err := r.Run(context.Background()); err != nil {
+        t.Fatalf("Run: %v", err)
+    }
+    st, _ := waveform.StampOf(path)
+    p, err := cache.Get("a", st)
+    if err != nil || p == nil || p.Len() != waveform.DefaultBuckets {
+        t.Fatalf("cached peaks = %v, %v", p, err)
+    }
+    if p.Max[p.Len()-1] < 0.3 {
+        t.Fatalf("last bucket is silent; the track wasn't decoded to the end")
+    }
+    if task.Pending(idx.GetByID("a")) {
+        t.Fatalf("waveform still pending after the run")
+    }
+
+    // rewriting the file invalidates the peaks
+    toneWAV(t, path, 220, 8000, 20)
+    os.Chtimes(path, time.Now(), time.Now().Add(time.Hour))
+    if !task.Pending(idx.GetByID("a")) {
+        t.Fatalf("waveform not pending after the file changed")
+    }
+    st, _ = waveform.StampOf(path)
+    if p, _ := cache.Get("a", st); p != nil {
+        t.Fatalf("stale peaks served")
+    }
+}
+
+func TestRunnerTempoKey(t *testing.T) {
+    base := t.TempDir()
+    idx := indexer.NewIndexAtBase(base)
+    // an A minor chord struck on every beat at 125 BPM
+    rate := 22050
+    samples := make([]float32, 30*rate)
+    period := 60.0 / 125 * float64(rate)
+    for i := range samples {
+        since := math.Mod(float64(i), period) / float64(rate)
+        var v float64
+        for _, f := range []float64{220, 261.63, 329.63} {
+            v += math.Sin(2 * math.Pi * f * float64(i) / float64(rate))
+        }
+        samples[i] = float32(0.25 * v * math.Exp(-since*8))
+    }
+    for _, id := range []string{"a", "tagged"} {
+        writeWAV(t, filepath.Join(base, id+".wav"), rate, samples)
+    }
+    idx.AddOrUpdateTrack(&indexer.Track{ID: "a", Path: filepath.Join(base, "a.wav")})
+    idx.AddOrUpdateTrack(&indexer.Track{ID: "tagged", Path: filepath.Join(base, "tagged.wav"), BPM: 100, BPMConfidence: 1})
+
+    enabled := false
+    task := NewTempoKeyTask(func() bool { return enabled })
+    if task.Pending(idx.GetByID("a")) {
+        t.Fatalf("disabled task is pending")
+    }
+    enabled = true
+    if err := NewRunner(idx, task).Run(context.Background()); err != nil {
+        t.Fatalf("Run: %v", err)
+    }
+    a := idx.GetByID("a")
+    if math.Abs(a.BPM-125) > 1 || a.Key != "Am" || a.BPMConfidence <= 0 || a.BPMConfidence >= 1 || a.KeyConfidence <= 0 {
+        t.Fatalf("estimated %v BPM (%.2f), key %q (%.2f)", a.BPM, a.BPMConfidence, a.Key, a.KeyConfidence)
+    }
+    // tags are kept; only the missing key is estimated
+    tagged := idx.GetByID("tagged")
+    if tagged.BPM != 100 || tagged.BPMConfidence != 1 || tagged.Key != "Am" {
+        t.Fatalf("tagged track = %v BPM (%.2f), key %q", tagged.BPM, tagged.BPMConfidence, tagged.Key)
+    }
+}
@@ -0,0 +1,42 @@
+package analysis
+
+import (
+	"penguin-tunes/pkg/fingerprint"
+	"penguin-tunes/pkg/indexer"
+)
+
+// fingerprintTask stores a chromaprint of the opening seconds of each track
+type fingerprintTask struct{ seconds int }
+
+// NewFingerprintTask fingerprints the first seconds of audio, fingerprint.DefaultSeconds when 0
+func NewFingerprintTask(seconds int) Task {
+    if seconds <= 0 {
+        seconds = fingerprint.DefaultSeconds
+    }
+    return fingerprintTask{seconds}
+}
+
+func (fingerprintTask) Name() string { return "fingerprint" }
+
+func (fingerprintTask) Pending(t *indexer.Track) bool { return t.Fingerprint == "" }
+
+func (f fingerprintTask) Seconds() int { return f.seconds }
+
+func (f fingerprintTask) Start(t *indexer.Track, rate int) Analyzer {
+    return &fingerprintAnalyzer{rate: rate, samples: make([]float32, 0, f.seconds*rate)}
+}
+
+// fingerprintAnalyzer collects the opening of the track and fingerprints it at the end
+type fingerprintAnalyzer struct {
+    rate    int
+    samples []float32
+}
+
+func (a *fingerprintAnalyzer) Write(samples []float32) {
+    a.samples = append(a.samples, samples...)
+}
+
+func (a *fingerprintAnalyzer) Finish() (func(*indexer.Track), error) {
+    fp := fingerprint.Encode(fingerprint.Compute(a.samples, a.rate))
+    return func(t *indexer.Track) { t.Fingerprint = fp }, nil
+}
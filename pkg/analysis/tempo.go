@@ -0,0 +1,71 @@
+package analysis
+
+import (
+	"fmt"
+
+	"penguin-tunes/pkg/indexer"
+	"penguin-tunes/pkg/tempo"
+	"penguin-tunes/pkg/tonal"
+)
+
+// TempoKeySeconds is how much of each track the tempo and key estimates listen to
+const TempoKeySeconds = 120
+
+// tempoKeyTask estimates BPM and key for tracks whose tags lack them
+type tempoKeyTask struct{ enabled func() bool }
+
+// NewTempoKeyTask estimates tempo and key while enabled reports true
+func NewTempoKeyTask(enabled func() bool) Task {
+    return tempoKeyTask{enabled}
+}
+
+func (tempoKeyTask) Name() string { return "tempo and key" }
+
+func (k tempoKeyTask) Pending(t *indexer.Track) bool {
+    return (t.BPM == 0 || t.Key == "") && k.enabled()
+}
+
+func (tempoKeyTask) Seconds() int { return TempoKeySeconds }
+
+func (tempoKeyTask) Start(t *indexer.Track, rate int) Analyzer {
+    return &tempoKeyAnalyzer{rate: rate, bpm: t.BPM == 0, key: t.Key == ""}
+}
+
+type tempoKeyAnalyzer struct {
+    rate     int
+    bpm, key bool // which of the two are missing
+    samples  []float32
+}
+
+func (a *tempoKeyAnalyzer) Write(samples []float32) {
+    a.samples = append(a.samples, samples...)
+}
+
+func (a *tempoKeyAnalyzer) Finish() (func(*indexer.Track), error) {
+    var bpm, bpmConf, keyConf float64
+    var key string
+    if a.bpm {
+        bpm, bpmConf = tempo.Estimate(a.samples, a.rate)
+    }
+    if a.key {
+        if k, conf, ok := tonal.Detect(a.samples, a.rate); ok {
+            key, keyConf = k.String(), conf
+        }
+    }
+    var err error
+    if (a.bpm && bpm == 0) || (a.key && key == "") {
+        err = fmt.Errorf("no steady tempo or key found")
+    }
+    if bpm == 0 && key == "" {
+        return nil, err
+    }
+    return func(t *indexer.Track) {
+        // tags read by a rescan meanwhile take precedence
+        if bpm > 0 && t.BPM == 0 {
+            t.BPM, t.BPMConfidence = bpm, bpmConf
+        }
+        if key != "" && t.Key == "" {
+            t.Key, t.KeyConfidence = key, keyConf
+        }
+    }, err
+}
@@ -0,0 +1,46 @@
+package analysis
+
+import (
+	"penguin-tunes/pkg/indexer"
+	"penguin-tunes/pkg/waveform"
+)
+
+// waveformTask stores the peaks of each whole track in a cache, redone when the file changes
+type waveformTask struct{ cache *waveform.Cache }
+
+// NewWaveformTask keeps cache filled with waveform.DefaultBuckets peaks per track
+func NewWaveformTask(cache *waveform.Cache) Task {
+    return waveformTask{cache}
+}
+
+func (waveformTask) Name() string { return "waveform" }
+
+func (w waveformTask) Pending(t *indexer.Track) bool {
+    st, err := waveform.StampOf(t.Path)
+    return err == nil && !w.cache.Fresh(t.ID, st)
+}
+
+func (waveformTask) Seconds() int { return 0 }
+
+func (w waveformTask) Start(t *indexer.Track, rate int) Analyzer {
+    // stamped before decoding so a file changed meanwhile is redone on the next pass
+    st, err := waveform.StampOf(t.Path)
+    return &waveformAnalyzer{cache: w.cache, id: t.ID, stamp: st, err: err, b: waveform.NewBuilder(rate)}
+}
+
+type waveformAnalyzer struct {
+    cache *waveform.Cache
+    id    string
+    stamp waveform.Stamp
+    err   error
+    b     *waveform.Builder
+}
+
+func (a *waveformAnalyzer) Write(samples []float32) { a.b.Write(samples) }
+
+func (a *waveformAnalyzer) Finish() (func(*indexer.Track), error) {
+    if a.err != nil {
+        return nil, a.err
+    }
+    return nil, a.cache.Put(a.id, a.stamp, a.b.Peaks(waveform.DefaultBuckets))
+}
@@ -0,0 +1,156 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnsupported is returned for formats without a decoder
+var ErrUnsupported = errors.New("no decoder for this format")
+
+// Stream is decoded audio; Read fills p with interleaved samples in [-1, 1]
+// and returns io.EOF after the last sample
+type Stream interface {
+    SampleRate() int
+    Channels() int
+    Read(p []float32) (int, error)
+    Close() error
+}
+
+// Supported reports whether Open can decode path
+func Supported(path string) bool {
+    switch strings.ToLower(filepath.Ext(path)) {
+    case ".wav", ".flac", ".ogg":
+        return true
+    }
+    return false
+}
+
+// Open starts decoding the file at path
+func Open(path string) (Stream, error) {
+    if !Supported(path) {
+        return nil, ErrUnsupported
+    }
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    var s Stream
+    switch strings.ToLower(filepath.Ext(path)) {
+    case ".wav":
+        s, err = newWAVStream(f)
+    case ".flac":
+        s, err = newFLACStream(f)
+    case ".ogg":
+        s, err = newVorbisStream(f)
+    }
+    if err != nil {
+        f.Close()
+        return nil, fmt.Errorf("decode %s: %w", path, err)
+    }
+    return s, nil
+}
+
+// section is the part of a stream between two frame positions
+type section struct {
+    Stream
+    skip int
+    left int // frames still to read; negative for no limit
+}
+
+// Section limits s to the audio from start to end seconds; end 0 reads to the end
+func Section(s Stream, start, end float64) Stream {
+    rate := float64(s.SampleRate())
+    sec := &section{Stream: s, skip: int(start * rate), left: -1}
+    if end > 0 {
+        sec.left = max(0, int(end*rate)-sec.skip)
+    }
+    return sec
+}
+
+// Read skips to the start of the section on first use and stops at its end
+func (s *section) Read(p []float32) (int, error) {
+    ch := s.Channels()
+    if ch <= 0 {
+        return 0, fmt.Errorf("stream without channels")
+    }
+    for s.skip > 0 {
+        n, err := s.Stream.Read(p[:min(len(p), s.skip*ch)])
+        s.skip -= n / ch
+        if err != nil {
+            return 0, err
+        }
+    }
+    if s.left == 0 {
+        return 0, io.EOF
+    }
+    if s.left > 0 && len(p) > s.left*ch {
+        p = p[:s.left*ch]
+    }
+    n, err := s.Stream.Read(p)
+    if s.left > 0 {
+        s.left -= n / ch
+    }
+    return n, err
+}
+
+// Mono mixes a stream down to one channel as it is read
+type Mono struct {
+    s   Stream
+    buf []float32
+}
+
+// NewMono wraps s
+func NewMono(s Stream) *Mono {
+    return &Mono{s: s}
+}
+
+// Read fills p with mono samples and returns io.EOF after the last one
+func (m *Mono) Read(p []float32) (int, error) {
+    ch := m.s.Channels()
+    if ch <= 0 {
+        return 0, fmt.Errorf("stream without channels")
+    }
+    if ch == 1 {
+        return m.s.Read(p)
+    }
+    if cap(m.buf) < len(p)*ch {
+        m.buf = make([]float32, len(p)*ch)
+    }
+    n, err := m.s.Read(m.buf[:len(p)*ch])
+    frames := n / ch
+    for i := 0; i < frames; i++ {
+        var sum float32
+        for c := 0; c < ch; c++ {
+            sum += m.buf[i*ch+c]
+        }
+        p[i] = sum / float32(ch)
+    }
+    return frames, err
+}
+
+// ReadMono reads up to maxFrames frames from s (all of it when maxFrames <= 0)
+// and mixes them down to one channel
+func ReadMono(s Stream, maxFrames int) ([]float32, error) {
+    m := NewMono(s)
+    var out []float32
+    buf := make([]float32, 4096)
+    for maxFrames <= 0 || len(out) < maxFrames {
+        n, err := m.Read(buf)
+        out = append(out, buf[:n]...)
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return out, err
+        }
+    }
+    if maxFrames > 0 && len(out) > maxFrames {
+        out = out[:maxFrames]
+    }
+    return out, nil
+}
@@ -0,0 +1,215 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// wavFile builds a 16-bit PCM WAV file from interleaved samples
+func wavFile(rate, channels int, samples []int16) []byte {
+    var b bytes.Buffer
+    b.WriteString("RIFF")
+    binary.Write(&b, binary.LittleEndian, uint32(36+2*len(samples)))
+    b.WriteString("WAVEfmt ")
+    binary.Write(&b, binary.LittleEndian, []uint32{16})
+    binary.Write(&b, binary.LittleEndian, []uint16{1, uint16(channels)})
+    binary.Write(&b, binary.LittleEndian, []uint32{uint32(rate), uint32(rate * channels * 2)})
+    binary.Write(&b, binary.LittleEndian, []uint16{uint16(channels * 2), 16})
+    b.WriteString("data")
+    binary.Write(&b, binary.LittleEndian, uint32(2*len(samples)))
+    binary.Write(&b, binary.LittleEndian, samples)
+    return b.Bytes()
+}
+
+// bitWriter packs big-endian bit fields the way FLAC stores them
+type bitWriter struct {
+    buf []byte
+    n   uint
+}
+
+func (w *bitWriter) write(v uint64, n uint) {
+    for i := int(n) - 1; i >= 0; i-- {
+        if w.n%8 == 0 {
+            w.buf = append(w.buf, 0)
+        }
+        w.buf[len(w.buf)-1] |= byte(v>>uint(i)&1) << (7 - w.n%8)
+        w.n++
+    }
+}
+
+func (w *bitWriter) align() { w.n += (8 - w.n%8) % 8 }
+
+// rice writes residuals with a single partition and parameter k
+func (w *bitWriter) rice(res []int64, k uint) {
+    w.write(0, 2)
+    w.write(0, 4)
+    w.write(uint64(k), 4)
+    for _, r := range res {
+        u := uint64(r<<1) ^ uint64(r>>63)
+        for q := u >> k; q > 0; q-- {
+            w.write(0, 1)
+        }
+        w.write(1, 1)
+        w.write(u, k)
+    }
+}
+
+// flacFile encodes 16-bit stereo as left/side frames: left verbatim, side with a fixed order-1 predictor
+func flacFile(rate int, left, right []int64, block int) []byte {
+    w := &bitWriter{}
+    for _, c := range "fLaC" {
+        w.write(uint64(c), 8)
+    }
+    w.write(0x80, 8)
+    w.write(34, 24)
+    w.write(uint64(block), 16)
+    w.write(uint64(block), 16)
+    w.write(0, 48)
+    w.write(uint64(rate), 20)
+    w.write(1, 3)
+    w.write(15, 5)
+    w.write(uint64(len(left)), 36)
+    w.write(0, 64)
+    w.write(0, 64)
+    for f, start := 0, 0; start < len(left); f, start = f+1, start+block {
+        end := min(start+block, len(left))
+        w.write(0xfff8, 16)
+        w.write(7, 4)  // block size follows the header
+        w.write(0, 4)  // rate from STREAMINFO
+        w.write(8, 4)  // left/side
+        w.write(4, 3)  // 16 bits
+        w.write(0, 1)
+        w.write(uint64(f), 8)
+        w.write(uint64(end-start-1), 16)
+        w.write(0, 8) // CRC-8, not checked
+        w.write(1<<1, 8)
+        for _, v := range left[start:end] {
+            w.write(uint64(v), 16)
+        }
+        side := make([]int64, end-start)
+        for i := range side {
+            side[i] = left[start+i] - right[start+i]
+        }
+        w.write(9<<1, 8)
+        w.write(uint64(side[0]), 17)
+        res := make([]int64, len(side)-1)
+        for i := range res {
+            res[i] = side[i+1] - side[i]
+        }
+        w.rice(res, 3)
+        w.align()
+        w.write(0, 16) // CRC-16
+    }
+    return w.buf
+}
+
+func readAll(t *testing.T, path string) (Stream, []float32) {
+    s, err := Open(path)
+    if err != nil {
+        t.Fatalf("Open: %v", err)
+    }
+    var out []float32
+    buf := make([]float32, 100)
+    for {
+        n, err := s.Read(buf)
+        out = append(out, buf[:n]...)
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            t.Fatalf("Read: %v", err)
+        }
+    }
+    return s, out
+}
+
+func TestDecodeWAV(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "a.wav")
+    samples := []int16{0, 16384, -16384, 0, 32767, -32768}
+    if err := os.WriteFile(path, wavFile(8000, 2, samples), 0o644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+    s, got := readAll(t, path)
+    defer s.Close()
+    if s.SampleRate() != 8000 || s.Channels() != 2 {
+        t.Fatalf("format = %d Hz %d ch", s.SampleRate(), s.Channels())
+    }
+    if len(got) != len(samples) {
+        t.Fatalf("decoded %d samples, want %d", len(got), len(samples))
+    }
+    for i, v := range samples {
+        if want := float32(v) / 32768; got[i] != want {
+            t.Fatalf("sample %d = %v, want %v", i, got[i], want)
+        }
+    }
+
+    s2, _ := Open(path)
+    defer s2.Close()
+    mono, err := ReadMono(s2, 2)
+    if err != nil || len(mono) != 2 || mono[0] != 0.25 || mono[1] != -0.25 {
+        t.Fatalf("ReadMono = %v, %v", mono, err)
+    }
+}
+
+func TestDecodeFLAC(t *testing.T) {
+    n := 1000
+    left, right := make([]int64, n), make([]int64, n)
+    for i := range left {
+        left[i] = int64(i*37%2000 - 1000)
+        right[i] = int64(-i * 13 % 3000)
+    }
+    path := filepath.Join(t.TempDir(), "a.flac")
+    if err := os.WriteFile(path, flacFile(22050, left, right, 300), 0o644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+    s, got := readAll(t, path)
+    defer s.Close()
+    if s.SampleRate() != 22050 || s.Channels() != 2 {
+        t.Fatalf("format = %d Hz %d ch", s.SampleRate(), s.Channels())
+    }
+    if len(got) != 2*n {
+        t.Fatalf("decoded %d samples, want %d", len(got), 2*n)
+    }
+    for i := 0; i < n; i++ {
+        if got[2*i] != float32(left[i])/32768 || got[2*i+1] != float32(right[i])/32768 {
+            t.Fatalf("frame %d = %v %v, want %d %d", i, got[2*i]*32768, got[2*i+1]*32768, left[i], right[i])
+        }
+    }
+}
+
+func TestOpenUnsupported(t *testing.T) {
+    if _, err := Open("song.mp3"); err != ErrUnsupported {
+        t.Fatalf("Open mp3 = %v, want ErrUnsupported", err)
+    }
+}
+
+func TestSection(t *testing.T) {
+    // stereo at 10 Hz, each frame holding its own index
+    samples := make([]int16, 2*50)
+    for i := range samples {
+        samples[i] = int16(i / 2 * 100)
+    }
+    path := filepath.Join(t.TempDir(), "a.wav")
+    if err := os.WriteFile(path, wavFile(10, 2, samples), 0o644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+    s, err := Open(path)
+    if err != nil {
+        t.Fatalf("Open: %v", err)
+    }
+    defer s.Close()
+    got, err := ReadMono(Section(s, 1.2, 3), 0)
+    if err != nil {
+        t.Fatalf("ReadMono: %v", err)
+    }
+    if len(got) != 18 {
+        t.Fatalf("expected 18 frames, got %d", len(got))
+    }
+    if first := int(got[0]*32768 + 0.5); first != 1200 {
+        t.Fatalf("expected the section to start at frame 12, got sample %d", first)
+    }
+}
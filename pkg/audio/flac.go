@@ -0,0 +1,435 @@
+package audio
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+)
+
+// bitReader reads big-endian bit fields
+type bitReader struct {
+    r    *bufio.Reader
+    bits uint64
+    n    uint
+}
+
+func (b *bitReader) read(n uint) (uint64, error) {
+    for b.n < n {
+        c, err := b.r.ReadByte()
+        if err != nil {
+            return 0, err
+        }
+        b.bits = b.bits<<8 | uint64(c)
+        b.n += 8
+    }
+    if n == 0 {
+        return 0, nil
+    }
+    v := b.bits >> (b.n - n) & (1<<n - 1)
+    b.n -= n
+    return v, nil
+}
+
+// signed reads an n-bit two's complement value
+func (b *bitReader) signed(n uint) (int64, error) {
+    v, err := b.read(n)
+    if err != nil || n == 0 {
+        return 0, err
+    }
+    return int64(v<<(64-n)) >> (64 - n), nil
+}
+
+// unary counts zero bits up to the next one
+func (b *bitReader) unary() (uint64, error) {
+    var q uint64
+    for {
+        v, err := b.read(1)
+        if err != nil {
+            return 0, err
+        }
+        if v == 1 {
+            return q, nil
+        }
+        q++
+    }
+}
+
+func (b *bitReader) align() { b.n -= b.n % 8 }
+
+// flacStream decodes FLAC frames one block at a time
+type flacStream struct {
+    f        *os.File
+    br       *bitReader
+    rate     int
+    channels int
+    bps      int
+    buf      []float32
+    block    []float32 // interleaved samples of the current frame not yet returned
+    samples  [][]int64
+}
+
+func newFLACStream(f *os.File) (*flacStream, error) {
+    r := bufio.NewReaderSize(f, 64<<10)
+    head := make([]byte, 10)
+    if _, err := io.ReadFull(r, head[:4]); err != nil {
+        return nil, err
+    }
+    if string(head[:3]) == "ID3" {
+        if _, err := io.ReadFull(r, head[4:]); err != nil {
+            return nil, err
+        }
+        n := int(head[6]&0x7f)<<21 | int(head[7]&0x7f)<<14 | int(head[8]&0x7f)<<7 | int(head[9]&0x7f)
+        if _, err := r.Discard(n); err != nil {
+            return nil, err
+        }
+        if _, err := io.ReadFull(r, head[:4]); err != nil {
+            return nil, err
+        }
+    }
+    if string(head[:4]) != "fLaC" {
+        return nil, fmt.Errorf("not a FLAC file")
+    }
+    s := &flacStream{f: f, br: &bitReader{r: r}}
+    for last := false; !last; {
+        h := make([]byte, 4)
+        if _, err := io.ReadFull(r, h); err != nil {
+            return nil, err
+        }
+        last = h[0]&0x80 != 0
+        n := int(h[1])<<16 | int(h[2])<<8 | int(h[3])
+        data := make([]byte, n)
+        if _, err := io.ReadFull(r, data); err != nil {
+            return nil, err
+        }
+        if h[0]&0x7f == 0 && n >= 18 {
+            s.rate = int(data[10])<<12 | int(data[11])<<4 | int(data[12])>>4
+            s.channels = int(data[12]>>1&7) + 1
+            s.bps = int(data[12]&1)<<4 | int(data[13]>>4) + 1
+        }
+    }
+    if s.rate == 0 {
+        return nil, fmt.Errorf("FLAC file without STREAMINFO")
+    }
+    return s, nil
+}
+
+func (s *flacStream) SampleRate() int { return s.rate }
+func (s *flacStream) Channels() int   { return s.channels }
+func (s *flacStream) Close() error    { return s.f.Close() }
+
+func (s *flacStream) Read(p []float32) (int, error) {
+    n := 0
+    for n < len(p) {
+        if len(s.block) == 0 {
+            if err := s.nextFrame(); err != nil {
+                if err == io.EOF || err == io.ErrUnexpectedEOF {
+                    return n, io.EOF
+                }
+                return n, err
+            }
+        }
+        c := copy(p[n:], s.block)
+        s.block = s.block[c:]
+        n += c
+    }
+    return n, nil
+}
+
+var flacSampleSizes = [8]int{0, 8, 12, 0, 16, 20, 24, 32}
+
+// nextFrame decodes one frame into s.block
+func (s *flacStream) nextFrame() error {
+    br := s.br
+    br.align()
+    // find the sync code: 0xff then 0b1111100x
+    prev, err := br.read(8)
+    for err == nil {
+        var cur uint64
+        if cur, err = br.read(8); err == nil && prev == 0xff && cur&0xfe == 0xf8 {
+            break
+        }
+        prev = cur
+    }
+    if err != nil {
+        return err
+    }
+    hdr, err := br.read(16) // block size, rate, channels, sample size, reserved
+    if err != nil {
+        return err
+    }
+    bsCode := hdr >> 12
+    rateCode := hdr >> 8 & 0xf
+    chCode := int(hdr >> 4 & 0xf)
+    sizeCode := hdr >> 1 & 7
+    // UTF-8 style frame or sample number
+    first, err := br.read(8)
+    if err != nil {
+        return err
+    }
+    for mask := uint64(0x80); first&mask != 0 && mask > 1; mask >>= 1 {
+        if mask != 0x80 {
+            if _, err := br.read(8); err != nil {
+                return err
+            }
+        }
+    }
+    blockSize := 0
+    switch {
+    case bsCode == 1:
+        blockSize = 192
+    case bsCode >= 2 && bsCode <= 5:
+        blockSize = 576 << (bsCode - 2)
+    case bsCode == 6:
+        v, err := br.read(8)
+        if err != nil {
+            return err
+        }
+        blockSize = int(v) + 1
+    case bsCode == 7:
+        v, err := br.read(16)
+        if err != nil {
+            return err
+        }
+        blockSize = int(v) + 1
+    case bsCode >= 8:
+        blockSize = 256 << (bsCode - 8)
+    default:
+        return fmt.Errorf("reserved FLAC block size")
+    }
+    switch rateCode {
+    case 12:
+        _, err = br.read(8)
+    case 13, 14:
+        _, err = br.read(16)
+    case 15:
+        return fmt.Errorf("invalid FLAC sample rate")
+    }
+    if err != nil {
+        return err
+    }
+    if _, err := br.read(8); err != nil { // CRC-8
+        return err
+    }
+    bps := s.bps
+    if sizeCode != 0 {
+        bps = flacSampleSizes[sizeCode]
+    }
+    channels := chCode + 1
+    if chCode >= 8 {
+        channels = 2
+    }
+    if chCode > 10 || channels != s.channels {
+        return fmt.Errorf("invalid FLAC channel assignment")
+    }
+    if len(s.samples) != channels {
+        s.samples = make([][]int64, channels)
+    }
+    for c := 0; c < channels; c++ {
+        width := bps
+        // the side channel carries one extra bit
+        if (chCode == 8 && c == 1) || (chCode == 9 && c == 0) || (chCode == 10 && c == 1) {
+            width++
+        }
+        if cap(s.samples[c]) < blockSize {
+            s.samples[c] = make([]int64, blockSize)
+        }
+        s.samples[c] = s.samples[c][:blockSize]
+        if err := s.subframe(s.samples[c], uint(width)); err != nil {
+            return err
+        }
+    }
+    br.align()
+    if _, err := br.read(16); err != nil { // CRC-16
+        return err
+    }
+    decorrelate(s.samples, chCode)
+
+    scale := float32(int64(1) << (bps - 1))
+    if cap(s.buf) < blockSize*channels {
+        s.buf = make([]float32, blockSize*channels)
+    }
+    s.block = s.buf[:blockSize*channels]
+    for i := 0; i < blockSize; i++ {
+        for c := 0; c < channels; c++ {
+            s.block[i*channels+c] = float32(s.samples[c][i]) / scale
+        }
+    }
+    return nil
+}
+
+func decorrelate(ch [][]int64, code int) {
+    switch code {
+    case 8: // left, side
+        for i := range ch[0] {
+            ch[1][i] = ch[0][i] - ch[1][i]
+        }
+    case 9: // side, right
+        for i := range ch[0] {
+            ch[0][i] += ch[1][i]
+        }
+    case 10: // mid, side
+        for i := range ch[0] {
+            mid, side := ch[0][i]<<1|ch[1][i]&1, ch[1][i]
+            ch[0][i], ch[1][i] = (mid+side)>>1, (mid-side)>>1
+        }
+    }
+}
+
+var fixedCoefficients = [5][]int64{{}, {1}, {2, -1}, {3, -3, 1}, {4, -6, 4, -1}}
+
+func (s *flacStream) subframe(out []int64, bps uint) error {
+    br := s.br
+    h, err := br.read(8)
+    if err != nil {
+        return err
+    }
+    if h&0x80 != 0 {
+        return fmt.Errorf("invalid FLAC subframe")
+    }
+    typ := h >> 1 & 0x3f
+    wasted := uint(0)
+    if h&1 != 0 {
+        k, err := br.unary()
+        if err != nil {
+            return err
+        }
+        wasted = uint(k) + 1
+        bps -= wasted
+    }
+    switch {
+    case typ == 0:
+        v, err := br.signed(bps)
+        if err != nil {
+            return err
+        }
+        for i := range out {
+            out[i] = v
+        }
+    case typ == 1:
+        for i := range out {
+            if out[i], err = br.signed(bps); err != nil {
+                return err
+            }
+        }
+    case typ >= 8 && typ <= 12:
+        order := int(typ - 8)
+        for i := 0; i < order; i++ {
+            if out[i], err = br.signed(bps); err != nil {
+                return err
+            }
+        }
+        if err := s.residual(out, order); err != nil {
+            return err
+        }
+        predict(out, fixedCoefficients[order], 0)
+    case typ >= 32:
+        order := int(typ - 31)
+        for i := 0; i < order; i++ {
+            if out[i], err = br.signed(bps); err != nil {
+                return err
+            }
+        }
+        prec, err := br.read(4)
+        if err != nil || prec == 15 {
+            return fmt.Errorf("invalid LPC precision")
+        }
+        shift, err := br.signed(5)
+        if err != nil {
+            return err
+        }
+        coeffs := make([]int64, order)
+        for i := range coeffs {
+            if coeffs[i], err = br.signed(uint(prec) + 1); err != nil {
+                return err
+            }
+        }
+        if err := s.residual(out, order); err != nil {
+            return err
+        }
+        if shift < 0 {
+            return fmt.Errorf("negative LPC shift")
+        }
+        predict(out, coeffs, uint(shift))
+    default:
+        return fmt.Errorf("reserved FLAC subframe type %d", typ)
+    }
+    if wasted > 0 {
+        for i := range out {
+            out[i] <<= wasted
+        }
+    }
+    return nil
+}
+
+// predict restores samples from residuals in place; coeffs[0] applies to the previous sample
+func predict(out []int64, coeffs []int64, shift uint) {
+    order := len(coeffs)
+    for i := order; i < len(out); i++ {
+        var sum int64
+        for j, c := range coeffs {
+            sum += c * out[i-1-j]
+        }
+        out[i] += sum >> shift
+    }
+}
+
+// residual reads Rice-coded residuals into out[order:]
+func (s *flacStream) residual(out []int64, order int) error {
+    br := s.br
+    method, err := br.read(2)
+    if err != nil || method > 1 {
+        return fmt.Errorf("invalid FLAC residual coding")
+    }
+    paramBits, escape := uint(4), uint64(15)
+    if method == 1 {
+        paramBits, escape = 5, 31
+    }
+    po, err := br.read(4)
+    if err != nil {
+        return err
+    }
+    partitions := 1 << po
+    per := len(out) >> po
+    i := order
+    for p := 0; p < partitions; p++ {
+        n := per
+        if p == 0 {
+            n -= order
+        }
+        if n < 0 || i+n > len(out) {
+            return fmt.Errorf("invalid FLAC partition")
+        }
+        k, err := br.read(paramBits)
+        if err != nil {
+            return err
+        }
+        if k == escape {
+            raw, err := br.read(5)
+            if err != nil {
+                return err
+            }
+            for j := 0; j < n; j++ {
+                if out[i], err = br.signed(uint(raw)); err != nil {
+                    return err
+                }
+                i++
+            }
+            continue
+        }
+        for j := 0; j < n; j++ {
+            q, err := br.unary()
+            if err != nil {
+                return err
+            }
+            r, err := br.read(uint(k))
+            if err != nil {
+                return err
+            }
+            u := q<<k | r
+            out[i] = int64(u>>1) ^ -int64(u&1)
+            i++
+        }
+    }
+    return nil
+}
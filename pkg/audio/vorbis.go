@@ -0,0 +1,24 @@
+package audio
+
+import (
+	"bufio"
+	"os"
+
+	"github.com/jfreymuth/oggvorbis"
+)
+
+// vorbisStream decodes Ogg Vorbis through oggvorbis
+type vorbisStream struct {
+    f *os.File
+    *oggvorbis.Reader
+}
+
+func newVorbisStream(f *os.File) (*vorbisStream, error) {
+    r, err := oggvorbis.NewReader(bufio.NewReader(f))
+    if err != nil {
+        return nil, err
+    }
+    return &vorbisStream{f: f, Reader: r}, nil
+}
+
+func (s *vorbisStream) Close() error { return s.f.Close() }
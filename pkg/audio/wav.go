@@ -0,0 +1,104 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// wavStream decodes integer PCM and 32-bit float WAV files
+type wavStream struct {
+    f        *os.File
+    r        *bufio.Reader
+    rate     int
+    channels int
+    bits     int
+    float    bool
+    left     int64 // bytes of sample data not yet read
+}
+
+func newWAVStream(f *os.File) (*wavStream, error) {
+    r := bufio.NewReader(f)
+    var h [12]byte
+    if _, err := io.ReadFull(r, h[:]); err != nil || string(h[:4]) != "RIFF" || string(h[8:]) != "WAVE" {
+        return nil, fmt.Errorf("not a WAV file")
+    }
+    s := &wavStream{f: f, r: r}
+    for {
+        var c [8]byte
+        if _, err := io.ReadFull(r, c[:]); err != nil {
+            return nil, fmt.Errorf("no data chunk")
+        }
+        n := int64(binary.LittleEndian.Uint32(c[4:]))
+        switch string(c[:4]) {
+        case "fmt ":
+            b := make([]byte, n)
+            if _, err := io.ReadFull(r, b); err != nil || n < 16 {
+                return nil, fmt.Errorf("bad fmt chunk")
+            }
+            format := binary.LittleEndian.Uint16(b)
+            if format == 0xfffe && n >= 26 {
+                format = binary.LittleEndian.Uint16(b[24:]) // sub-format GUID starts with the format tag
+            }
+            s.channels = int(binary.LittleEndian.Uint16(b[2:]))
+            s.rate = int(binary.LittleEndian.Uint32(b[4:]))
+            s.bits = int(binary.LittleEndian.Uint16(b[14:]))
+            s.float = format == 3
+            if (format != 1 && format != 3) || (s.float && s.bits != 32) || s.bits%8 != 0 || s.bits == 0 || s.bits > 32 {
+                return nil, fmt.Errorf("unsupported WAV encoding %d/%d bits", format, s.bits)
+            }
+            if n%2 == 1 {
+                r.Discard(1)
+            }
+        case "data":
+            if s.channels == 0 {
+                return nil, fmt.Errorf("data chunk before fmt")
+            }
+            s.left = n
+            return s, nil
+        default:
+            if _, err := r.Discard(int(n + n%2)); err != nil {
+                return nil, fmt.Errorf("truncated WAV chunk")
+            }
+        }
+    }
+}
+
+func (s *wavStream) SampleRate() int { return s.rate }
+func (s *wavStream) Channels() int   { return s.channels }
+func (s *wavStream) Close() error    { return s.f.Close() }
+
+func (s *wavStream) Read(p []float32) (int, error) {
+    width := s.bits / 8
+    var b [4]byte
+    n := 0
+    for n < len(p) {
+        if s.left < int64(width) {
+            return n, io.EOF
+        }
+        if _, err := io.ReadFull(s.r, b[:width]); err != nil {
+            // a truncated file ends early rather than failing
+            s.left = 0
+            return n, io.EOF
+        }
+        s.left -= int64(width)
+        switch {
+        case s.float:
+            p[n] = math.Float32frombits(binary.LittleEndian.Uint32(b[:]))
+        case width == 1:
+            p[n] = (float32(b[0]) - 128) / 128 // 8-bit PCM is unsigned
+        default:
+            // left-align the sample in 32 bits so the sign is right for every width
+            var v uint32
+            for i := 0; i < width; i++ {
+                v |= uint32(b[i]) << (8 * (4 - width + i))
+            }
+            p[n] = float32(int32(v)) / (1 << 31)
+        }
+        n++
+    }
+    return n, nil
+}
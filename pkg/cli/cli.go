@@ -0,0 +1,204 @@
+// Package cli runs the library tools without the GUI: scanning, watching,
+// querying and checking the config and index files the app uses
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+
+	cfg "penguin-tunes/pkg/config"
+	"penguin-tunes/pkg/indexer"
+	"penguin-tunes/pkg/stats"
+)
+
+// command is one subcommand
+type command struct {
+    usage string
+    help  string
+    run   func(e *env, args []string) error
+}
+
+var commands map[string]command
+
+func init() {
+    commands = map[string]command{
+        "scan":   {"scan [-workers N] [dir...]", "scan dirs, or the configured source dirs, into the index", cmdScan},
+        "watch":  {"watch", "keep the index up to date until interrupted", cmdWatch},
+        "ls":     {"ls [-format table|json|csv] [-fields list] [-sort keys] [-limit N] [expr...]", "list tracks matching a filter expression", cmdQuery},
+        "query":  {"query ...", "same as ls", cmdQuery},
+        "stats":  {"stats [-format table|json|csv] [-top track|artist|album] [-days N] [-limit N]", "summarize the library, or rank plays", cmdStats},
+        "dirs":   {"dirs [list | add dir... | remove dir...]", "show or change the source dirs", cmdDirs},
+        "doctor": {"doctor", "check the config, index and source dirs", cmdDoctor},
+    }
+}
+
+// errUsage asks Run to print the usage of the current command
+var errUsage = errors.New("usage")
+
+// errFailed exits with status 1 after the command reported its own problems
+var errFailed = errors.New("failed")
+
+// env is the state shared by the commands of one run
+type env struct {
+    ctx    context.Context
+    dir    string
+    stdout io.Writer
+    stderr io.Writer
+    // log reports the warnings of the packages; commands print their own output
+    log *slog.Logger
+    // lock is held by commands that write; the others open the files read-only
+    lock *cfg.Lock
+    cm   *cfg.Manager
+    idx  *indexer.Index
+}
+
+// Run executes args (without the program name) and returns the exit status.
+// Interrupts cancel long running commands such as watch.
+func Run(args []string, stdout, stderr io.Writer) int {
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+    return RunContext(ctx, args, stdout, stderr)
+}
+
+// RunContext is Run with a caller supplied context
+func RunContext(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+    e := &env{ctx: ctx, stdout: stdout, stderr: stderr}
+    e.log = slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+    fs := flag.NewFlagSet("penguin-tunes-cli", flag.ContinueOnError)
+    fs.SetOutput(stderr)
+    fs.StringVar(&e.dir, "dir", "", "config `dir` holding config.json and index.json (default: the app's)")
+    fs.Usage = func() { usage(stderr, fs) }
+    if err := fs.Parse(args); err != nil {
+        if errors.Is(err, flag.ErrHelp) {
+            return 0
+        }
+        return 2
+    }
+    if fs.NArg() == 0 {
+        usage(stderr, fs)
+        return 2
+    }
+    if e.dir == "" {
+        dir, err := cfg.DefaultDir()
+        if err != nil {
+            fmt.Fprintf(stderr, "error: %v\n", err)
+            return 1
+        }
+        e.dir = dir
+    }
+    name := fs.Arg(0)
+    if name == "help" {
+        usage(stdout, fs)
+        return 0
+    }
+    cmd, ok := commands[name]
+    if !ok {
+        fmt.Fprintf(stderr, "unknown command %q\n", name)
+        usage(stderr, fs)
+        return 2
+    }
+    err := cmd.run(e, fs.Args()[1:])
+    e.lock.Unlock()
+    switch {
+    case err == nil:
+        return 0
+    case errors.Is(err, flag.ErrHelp):
+        return 0
+    case errors.Is(err, errUsage):
+        fmt.Fprintf(stderr, "usage: penguin-tunes-cli %s\n", cmd.usage)
+        return 2
+    case errors.Is(err, errFailed):
+        return 1
+    }
+    fmt.Fprintf(stderr, "%s: %v\n", name, err)
+    return 1
+}
+
+func usage(w io.Writer, fs *flag.FlagSet) {
+    fmt.Fprintln(w, "usage: penguin-tunes-cli [-dir dir] command [args]")
+    fmt.Fprintln(w, "\ncommands:")
+    names := make([]string, 0, len(commands))
+    for name := range commands {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        fmt.Fprintf(w, "  %-8s %s\n", name, commands[name].help)
+    }
+    fmt.Fprintln(w, "\nflags:")
+    fs.SetOutput(w)
+    fs.PrintDefaults()
+}
+
+// flags returns a flag set for a subcommand that reports errors to stderr
+func (e *env) flags(name string) *flag.FlagSet {
+    fs := flag.NewFlagSet(name, flag.ContinueOnError)
+    fs.SetOutput(e.stderr)
+    fs.Usage = func() {
+        fmt.Fprintf(e.stderr, "usage: penguin-tunes-cli %s\n", commands[name].usage)
+        fs.PrintDefaults()
+    }
+    return fs
+}
+
+// writable takes the data dir lock, failing while the GUI or another command
+// holds it. Call it before opening the config or index.
+func (e *env) writable() error {
+    lock, err := cfg.LockDir(e.dir)
+    if errors.Is(err, cfg.ErrLocked) {
+        return fmt.Errorf("%w; quit it first, or use read-only commands such as ls", err)
+    }
+    if err != nil {
+        return err
+    }
+    e.lock = lock
+    return nil
+}
+
+// config opens the config the GUI uses; without the lock it is read-only
+func (e *env) config() (*cfg.Manager, error) {
+    if e.cm == nil {
+        open := cfg.OpenManagerReadOnly
+        if e.lock != nil {
+            open = cfg.NewManagerAt
+        }
+        cm, err := open(e.dir)
+        if err != nil {
+            return nil, err
+        }
+        cm.SetLogger(e.log)
+        e.cm = cm
+    }
+    return e.cm, nil
+}
+
+// index loads the library index; without the lock it is read-only
+func (e *env) index() (*indexer.Index, error) {
+    if e.idx == nil {
+        idx := indexer.NewIndexAtBase(e.dir)
+        idx.SetLogger(e.log)
+        if err := idx.LoadFromFile(); err != nil {
+            return nil, err
+        }
+        idx.SetReadOnly(e.lock == nil)
+        e.idx = idx
+    }
+    return e.idx, nil
+}
+
+// stats loads the listening history
+func (e *env) stats() (*stats.Store, error) {
+    st := stats.NewStoreAtBase(e.dir)
+    if err := st.LoadFromFile(); err != nil {
+        return nil, err
+    }
+    return st, nil
+}
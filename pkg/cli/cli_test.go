@@ -0,0 +1,201 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cfg "penguin-tunes/pkg/config"
+)
+
+// run executes the CLI against dir and returns its exit status and output
+func run(t *testing.T, dir string, args ...string) (int, string, string) {
+    var stdout, stderr bytes.Buffer
+    code := RunContext(context.Background(), append([]string{"-dir", dir}, args...), &stdout, &stderr)
+    return code, stdout.String(), stderr.String()
+}
+
+func TestScanAndQuery(t *testing.T) {
+    dir := t.TempDir()
+    music := filepath.Join(t.TempDir(), "music")
+    if err := os.MkdirAll(filepath.Join(music, "sub"), 0o755); err != nil {
+        t.Fatalf("mkdir: %v", err)
+    }
+    for _, name := range []string{"alpha.mp3", "beta.flac", "sub/gamma.mp3", "notes.txt"} {
+        if err := os.WriteFile(filepath.Join(music, name), []byte("dummy"), 0o644); err != nil {
+            t.Fatalf("write: %v", err)
+        }
+    }
+
+    if code, _, stderr := run(t, dir, "scan"); code != 1 || !strings.Contains(stderr, "no source dirs") {
+        t.Fatalf("scan without dirs: expected failure, got %d %q", code, stderr)
+    }
+    if code, _, stderr := run(t, dir, "dirs", "add", music); code != 0 {
+        t.Fatalf("dirs add: %d %s", code, stderr)
+    }
+    if _, stdout, _ := run(t, dir, "dirs"); strings.TrimSpace(stdout) != music {
+        t.Fatalf("dirs: expected %s, got %q", music, stdout)
+    }
+    code, stdout, stderr := run(t, dir, "scan")
+    if code != 0 || !strings.Contains(stdout, "3 tracks (+3)") {
+        t.Fatalf("scan: %d %q %q", code, stdout, stderr)
+    }
+
+    code, stdout, stderr = run(t, dir, "ls", "-format", "json", "-fields", "title,path", "-sort", "-title", "path", "$=", ".mp3")
+    if code != 0 {
+        t.Fatalf("ls: %d %s", code, stderr)
+    }
+    var rows []map[string]any
+    if err := json.Unmarshal([]byte(stdout), &rows); err != nil {
+        t.Fatalf("ls json: %v\n%s", err, stdout)
+    }
+    if len(rows) != 2 || rows[0]["title"] != "gamma.mp3" || rows[1]["title"] != "alpha.mp3" {
+        t.Fatalf("ls: unexpected rows %v", rows)
+    }
+
+    _, stdout, _ = run(t, dir, "query", "-format", "csv", "-fields", "title", "-limit", "1", "-sort", "title")
+    if stdout != "title\nalpha.mp3\n" {
+        t.Fatalf("query csv: got %q", stdout)
+    }
+    _, stdout, _ = run(t, dir, "ls", "-fields", "title")
+    if lines := strings.Split(strings.TrimSpace(stdout), "\n"); len(lines) != 4 || lines[0] != "TITLE" {
+        t.Fatalf("ls table: got %q", stdout)
+    }
+    if code, _, stderr := run(t, dir, "ls", "mood", "=", "happy"); code != 1 || !strings.Contains(stderr, "unknown field") {
+        t.Fatalf("ls with a bad field: %d %q", code, stderr)
+    }
+
+    _, stdout, _ = run(t, dir, "stats", "-format", "csv")
+    if !strings.Contains(stdout, "tracks,3\n") {
+        t.Fatalf("stats: got %q", stdout)
+    }
+}
+
+func TestDoctor(t *testing.T) {
+    dir := t.TempDir()
+    music := t.TempDir()
+    if code, _, stderr := run(t, dir, "dirs", "add", music); code != 0 {
+        t.Fatalf("dirs add: %d %s", code, stderr)
+    }
+    code, stdout, _ := run(t, dir, "doctor")
+    if code != 0 || strings.Contains(stdout, "FAIL") {
+        t.Fatalf("doctor on a healthy setup: %d\n%s", code, stdout)
+    }
+
+    if err := os.WriteFile(filepath.Join(dir, "index.json.123.tmp"), nil, 0o644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+    if err := os.Remove(music); err != nil {
+        t.Fatalf("remove: %v", err)
+    }
+    code, stdout, _ = run(t, dir, "doctor")
+    if code != 1 || !strings.Contains(stdout, "FAIL  source dir "+music) || !strings.Contains(stdout, "WARN  1 temp file") {
+        t.Fatalf("doctor: expected a failed source dir and a stale temp file, got %d\n%s", code, stdout)
+    }
+
+    if code, _, stderr := run(t, dir, "dirs", "remove", music); code != 0 {
+        t.Fatalf("dirs remove: %d %s", code, stderr)
+    }
+    if _, stdout, _ := run(t, dir, "dirs", "list"); stdout != "" {
+        t.Fatalf("dirs list after remove: got %q", stdout)
+    }
+}
+
+func TestUsage(t *testing.T) {
+    if code, _, stderr := run(t, t.TempDir(), "frobnicate"); code != 2 || !strings.Contains(stderr, "unknown command") {
+        t.Fatalf("unknown command: %d %q", code, stderr)
+    }
+    if code, _, _ := run(t, t.TempDir(), "dirs", "add"); code != 2 {
+        t.Fatalf("dirs add without a dir: expected usage, got %d", code)
+    }
+}
+
+func TestWritesNeedTheLock(t *testing.T) {
+    dir := t.TempDir()
+    music := t.TempDir()
+    l, err := cfg.LockDir(dir)
+    if err != nil {
+        t.Fatalf("LockDir: %v", err)
+    }
+    defer l.Unlock()
+    for _, args := range [][]string{{"scan", music}, {"dirs", "add", music}} {
+        if code, _, stderr := run(t, dir, args...); code != 1 || !strings.Contains(stderr, "in use by another process") {
+            t.Fatalf("%v while locked: expected a lock error, got %d %q", args, code, stderr)
+        }
+    }
+    // reading works alongside the holder
+    if code, _, stderr := run(t, dir, "ls"); code != 0 {
+        t.Fatalf("ls while locked: %d %s", code, stderr)
+    }
+    if _, stdout, _ := run(t, dir, "doctor"); !strings.Contains(stdout, "in use by pid") {
+        t.Fatalf("doctor while locked: got %q", stdout)
+    }
+}
+
+func TestScanInterrupted(t *testing.T) {
+    dir := t.TempDir()
+    music := t.TempDir()
+    if err := os.WriteFile(filepath.Join(music, "a.mp3"), []byte("dummy"), 0o644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    var stdout, stderr bytes.Buffer
+    code := RunContext(ctx, []string{"-dir", dir, "scan", music}, &stdout, &stderr)
+    if code != 1 || !strings.Contains(stdout.String(), "interrupted after") || stderr.Len() != 0 {
+        t.Fatalf("expected an interrupted scan, got %d %q %q", code, stdout.String(), stderr.String())
+    }
+}
+
+func TestScanRelativeDir(t *testing.T) {
+    dir := t.TempDir()
+    work := t.TempDir()
+    if err := os.MkdirAll(filepath.Join(work, "music"), 0o755); err != nil {
+        t.Fatalf("mkdir: %v", err)
+    }
+    if err := os.WriteFile(filepath.Join(work, "music", "a.mp3"), []byte("dummy"), 0o644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+    if err := os.WriteFile(filepath.Join(work, "notes.txt"), nil, 0o644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+    wd, err := os.Getwd()
+    if err != nil {
+        t.Fatalf("getwd: %v", err)
+    }
+    if err := os.Chdir(work); err != nil {
+        t.Fatalf("chdir: %v", err)
+    }
+    defer os.Chdir(wd)
+    if code, _, stderr := run(t, dir, "scan", "notes.txt"); code != 1 || !strings.Contains(stderr, "not a directory") {
+        t.Fatalf("scan of a file: expected failure, got %d %q", code, stderr)
+    }
+    if code, _, stderr := run(t, dir, "scan", "music"); code != 0 {
+        t.Fatalf("scan: %d %s", code, stderr)
+    }
+    b, err := os.ReadFile(filepath.Join(dir, "index.json"))
+    if err != nil {
+        t.Fatalf("read index: %v", err)
+    }
+    var idx struct {
+        Tracks map[string]struct {
+            Path string `json:"path"`
+        } `json:"tracks"`
+    }
+    if err := json.Unmarshal(b, &idx); err != nil {
+        t.Fatalf("index json: %v", err)
+    }
+    want := filepath.Join(work, "music", "a.mp3")
+    if len(idx.Tracks) != 1 {
+        t.Fatalf("expected one track, got %s", b)
+    }
+    for _, tr := range idx.Tracks {
+        if tr.Path != want {
+            t.Fatalf("expected the absolute path %s, got %s", want, tr.Path)
+        }
+    }
+}
@@ -0,0 +1,326 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	cfg "penguin-tunes/pkg/config"
+	"penguin-tunes/pkg/indexer"
+)
+
+func cmdScan(e *env, args []string) error {
+    fs := e.flags("scan")
+    workers := fs.Int("workers", 0, "files read at once; 0 uses one per CPU")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+    if err := e.writable(); err != nil {
+        return err
+    }
+    cm, err := e.config()
+    if err != nil {
+        return err
+    }
+    idx, err := e.index()
+    if err != nil {
+        return err
+    }
+    c := cm.GetConfig()
+    var dirs []string
+    // the index is shared with the app, so paths must not depend on where the cli ran
+    for _, d := range fs.Args() {
+        abs, err := filepath.Abs(d)
+        if err != nil {
+            return err
+        }
+        fi, err := os.Stat(abs)
+        if err != nil {
+            return err
+        }
+        if !fi.IsDir() {
+            return fmt.Errorf("%s is not a directory", abs)
+        }
+        dirs = append(dirs, abs)
+    }
+    if len(dirs) == 0 {
+        dirs = c.SrcDirs
+    }
+    if len(dirs) == 0 {
+        return fmt.Errorf("no source dirs configured; add one with \"dirs add\"")
+    }
+    opts, err := indexer.ConfigScanOptions(c)
+    if err != nil {
+        fmt.Fprintf(e.stderr, "path templates: %v\n", err)
+    }
+    opts.Concurrency = *workers
+    before := len(idx.GetAll())
+    start := time.Now()
+    // the scan saves the index when done, and what it found when interrupted
+    err = indexer.ScanDirsContext(e.ctx, dirs, idx, opts)
+    interrupted := errors.Is(err, context.Canceled)
+    if err != nil && !interrupted {
+        return err
+    }
+    after := len(idx.GetAll())
+    took := time.Since(start).Round(time.Millisecond)
+    if interrupted {
+        fmt.Fprintf(e.stdout, "interrupted after %s: %d tracks (%+d)\n", took, after, after-before)
+        return errFailed
+    }
+    fmt.Fprintf(e.stdout, "scanned %s in %s: %d tracks (%+d)\n", plural(len(dirs), "dir"), took, after, after-before)
+    return nil
+}
+
+// printEmitter reports index updates from the watcher
+type printEmitter struct {
+    e *env
+}
+
+func (p printEmitter) Emit(ctx context.Context, event string, data any) {
+    if tracks, ok := data.([]*indexer.Track); ok {
+        fmt.Fprintf(p.e.stdout, "%s %s: %d tracks\n", time.Now().Format(time.TimeOnly), event, len(tracks))
+        return
+    }
+    fmt.Fprintf(p.e.stdout, "%s %s\n", time.Now().Format(time.TimeOnly), event)
+}
+
+func cmdWatch(e *env, args []string) error {
+    if err := e.flags("watch").Parse(args); err != nil {
+        return err
+    }
+    if err := e.writable(); err != nil {
+        return err
+    }
+    cm, err := e.config()
+    if err != nil {
+        return err
+    }
+    idx, err := e.index()
+    if err != nil {
+        return err
+    }
+    dirs := cm.GetConfig().SrcDirs
+    if len(dirs) == 0 {
+        return fmt.Errorf("no source dirs configured; add one with \"dirs add\"")
+    }
+    w, err := indexer.NewWatcher(e.ctx, idx, cm, printEmitter{e})
+    if err != nil {
+        return err
+    }
+    defer w.Close()
+    w.SetLogger(e.log)
+    stop, err := w.Start()
+    if err != nil {
+        return err
+    }
+    defer stop()
+    fmt.Fprintf(e.stdout, "watching %s; interrupt to stop\n", strings.Join(dirs, ", "))
+    <-e.ctx.Done()
+    return nil
+}
+
+func cmdDirs(e *env, args []string) error {
+    if len(args) > 0 && args[0] != "list" {
+        if err := e.writable(); err != nil {
+            return err
+        }
+    }
+    cm, err := e.config()
+    if err != nil {
+        return err
+    }
+    c := cm.GetConfig()
+    if len(args) == 0 || args[0] == "list" {
+        for _, d := range c.SrcDirs {
+            fmt.Fprintln(e.stdout, d)
+        }
+        return nil
+    }
+    if len(args) < 2 {
+        return errUsage
+    }
+    switch args[0] {
+    case "add":
+        for _, d := range args[1:] {
+            abs, err := filepath.Abs(d)
+            if err != nil {
+                return err
+            }
+            fi, err := os.Stat(abs)
+            if err != nil {
+                return err
+            }
+            if !fi.IsDir() {
+                return fmt.Errorf("%s is not a directory", abs)
+            }
+            if !contains(c.SrcDirs, abs) {
+                c.SrcDirs = append(c.SrcDirs, abs)
+            }
+        }
+    case "remove":
+        for _, d := range args[1:] {
+            i := indexOf(c.SrcDirs, d)
+            if i < 0 {
+                // the dir may be gone, so compare the absolute form textually
+                if abs, err := filepath.Abs(d); err == nil {
+                    i = indexOf(c.SrcDirs, abs)
+                }
+            }
+            if i < 0 {
+                return fmt.Errorf("%s is not a source dir", d)
+            }
+            c.SrcDirs = append(c.SrcDirs[:i], c.SrcDirs[i+1:]...)
+        }
+    default:
+        return errUsage
+    }
+    return cm.SaveConfig(c)
+}
+
+func contains(list []string, s string) bool {
+    return indexOf(list, s) >= 0
+}
+
+func indexOf(list []string, s string) int {
+    for i, v := range list {
+        if v == s {
+            return i
+        }
+    }
+    return -1
+}
+
+// doctor collects check results
+type doctor struct {
+    e      *env
+    failed bool
+}
+
+func (d *doctor) ok(format string, a ...any) {
+    fmt.Fprintf(d.e.stdout, "OK    %s\n", fmt.Sprintf(format, a...))
+}
+
+func (d *doctor) warn(format string, a ...any) {
+    fmt.Fprintf(d.e.stdout, "WARN  %s\n", fmt.Sprintf(format, a...))
+}
+
+func (d *doctor) fail(format string, a ...any) {
+    d.failed = true
+    fmt.Fprintf(d.e.stdout, "FAIL  %s\n", fmt.Sprintf(format, a...))
+}
+
+// cmdDoctor checks that the files the app keeps are readable and point at
+// things that exist. Failures exit with status 1; warnings don't.
+func cmdDoctor(e *env, args []string) error {
+    if err := e.flags("doctor").Parse(args); err != nil {
+        return err
+    }
+    d := &doctor{e: e}
+    d.ok("config dir %s", e.dir)
+    if pid, held := cfg.Holder(e.dir); held {
+        d.ok("data dir in use by pid %d; checking read-only", pid)
+    }
+    cm, err := e.config()
+    if err != nil {
+        d.fail("config: %v", err)
+        return errFailed
+    }
+    c := cm.GetConfig()
+    d.ok("config loaded")
+    if _, err := indexer.ConfigScanOptions(c); err != nil {
+        d.fail("path templates: %v", err)
+    }
+    if len(c.SrcDirs) == 0 {
+        d.warn("no source dirs configured")
+    }
+    for _, dir := range c.SrcDirs {
+        fi, err := os.Stat(dir)
+        switch {
+        case err != nil:
+            d.fail("source dir %s: %v", dir, err)
+        case !fi.IsDir():
+            d.fail("source dir %s is not a directory", dir)
+        default:
+            d.ok("source dir %s", dir)
+        }
+    }
+
+    idx, err := e.index()
+    if err != nil {
+        d.fail("index: %v", err)
+    } else {
+        d.checkIndex(idx, c)
+    }
+    if _, err := e.stats(); err != nil {
+        d.fail("stats: %v", err)
+    }
+
+    // the lock holder may be writing them; it removes stale ones on start
+    if _, held := cfg.Holder(e.dir); !held {
+        stale, _ := cfg.StaleTemp(e.dir)
+        if len(stale) > 0 {
+            d.warn("%s left by interrupted writes: %s", plural(len(stale), "temp file"), strings.Join(stale, ", "))
+        }
+    }
+    if d.failed {
+        return errFailed
+    }
+    return nil
+}
+
+// checkIndex reports tracks whose files or covers are gone, and tracks
+// outside every source dir
+func (d *doctor) checkIndex(idx *indexer.Index, c cfg.Config) {
+    tracks := idx.GetAll()
+    d.ok("index holds %s", plural(len(tracks), "track"))
+    var missing, covers, outside int
+    checked := make(map[string]bool)
+    for _, t := range tracks {
+        if !checked[t.Path] {
+            checked[t.Path] = true
+            if _, err := os.Stat(t.Path); err != nil {
+                missing++
+                continue
+            }
+            if !underAny(t.Path, c.SrcDirs) {
+                outside++
+            }
+        }
+        if t.Cover != "" {
+            if _, err := os.Stat(t.Cover); err != nil {
+                covers++
+            }
+        }
+    }
+    if missing > 0 {
+        d.warn("%s in the index no longer exist", plural(missing, "file"))
+    }
+    if outside > 0 {
+        d.warn("%s in the index are outside the source dirs", plural(outside, "file"))
+    }
+    if covers > 0 {
+        d.warn("%s point at missing cover images", plural(covers, "track"))
+    }
+}
+
+func underAny(path string, dirs []string) bool {
+    for _, dir := range dirs {
+        rel, err := filepath.Rel(dir, path)
+        if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+            return true
+        }
+    }
+    return false
+}
+
+func plural(n int, word string) string {
+    if n == 1 {
+        return fmt.Sprintf("1 %s", word)
+    }
+    return fmt.Sprintf("%d %ss", n, word)
+}
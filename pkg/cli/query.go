@@ -0,0 +1,263 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"penguin-tunes/pkg/indexer"
+	"penguin-tunes/pkg/playlist"
+)
+
+// column is a track attribute that ls can print
+type column struct {
+    get func(t *indexer.Track, u playlist.Usage) any
+    // text formats the value for tables, when it differs from %v
+    text func(v any) string
+}
+
+var columns = map[string]column{
+    "id":           {get: func(t *indexer.Track, u playlist.Usage) any { return t.ID }},
+    "path":         {get: func(t *indexer.Track, u playlist.Usage) any { return t.Path }},
+    "title":        {get: func(t *indexer.Track, u playlist.Usage) any { return t.Title }},
+    "album":        {get: func(t *indexer.Track, u playlist.Usage) any { return t.Album }},
+    "artist":       {get: func(t *indexer.Track, u playlist.Usage) any { return t.Artist }},
+    "album_artist": {get: func(t *indexer.Track, u playlist.Usage) any { return t.AlbumArtist }},
+    "composer":     {get: func(t *indexer.Track, u playlist.Usage) any { return t.Composer }},
+    "genre":        {get: func(t *indexer.Track, u playlist.Usage) any { return t.Genre }},
+    "year":         {get: func(t *indexer.Track, u playlist.Usage) any { return t.Year }},
+    "track_number": {get: func(t *indexer.Track, u playlist.Usage) any { return t.TrackNumber }},
+    "disc_number":  {get: func(t *indexer.Track, u playlist.Usage) any { return t.DiscNumber }},
+    "codec":        {get: func(t *indexer.Track, u playlist.Usage) any { return t.Codec }},
+    "duration":     {get: func(t *indexer.Track, u playlist.Usage) any { return t.Duration }, text: func(v any) string { return clock(v.(float64)) }},
+    "bitrate":      {get: func(t *indexer.Track, u playlist.Usage) any { return t.Bitrate }},
+    "bpm":          {get: func(t *indexer.Track, u playlist.Usage) any { return t.BPM }, text: func(v any) string { return fmt.Sprintf("%.0f", v) }},
+    "key":          {get: func(t *indexer.Track, u playlist.Usage) any { return t.Key }},
+    "rating":       {get: func(t *indexer.Track, u playlist.Usage) any { return t.Rating }},
+    "loved":        {get: func(t *indexer.Track, u playlist.Usage) any { return t.Loved }},
+    "play_count":   {get: func(t *indexer.Track, u playlist.Usage) any { return u.PlayCount }},
+    "skip_count":   {get: func(t *indexer.Track, u playlist.Usage) any { return u.SkipCount }},
+    "last_played":  {get: func(t *indexer.Track, u playlist.Usage) any { return u.LastPlayed }, text: dateText},
+}
+
+const defaultColumns = "artist,album,track_number,title,duration"
+
+// cmdQuery lists the tracks matching a filter expression; the arguments are
+// joined, so the expression needs no quoting as a whole
+func cmdQuery(e *env, args []string) error {
+    fs := e.flags("ls")
+    format := fs.String("format", "table", "output `format`: table, json or csv")
+    fieldList := fs.String("fields", defaultColumns, "comma separated `fields` to print")
+    sortKeys := fs.String("sort", "artist,album,disc_number,track_number", "comma separated sort `fields`; -field sorts descending")
+    limit := fs.Int("limit", 0, "print at most `n` tracks; 0 prints all")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+    where, err := playlist.ParseWhere(strings.Join(fs.Args(), " "))
+    if err != nil {
+        return err
+    }
+    keys, err := playlist.ParseSort(*sortKeys)
+    if err != nil {
+        return err
+    }
+    names, err := parseColumns(*fieldList)
+    if err != nil {
+        return err
+    }
+    q := playlist.Query{Where: where, Sort: keys, Limit: *limit}
+    if err := q.Validate(); err != nil {
+        return err
+    }
+    idx, err := e.index()
+    if err != nil {
+        return err
+    }
+    st, err := e.stats()
+    if err != nil {
+        return err
+    }
+    tracks := playlist.Evaluate(q, idx.GetAll(), st, time.Now().UnixNano())
+    rows := make([][]any, len(tracks))
+    for i, t := range tracks {
+        u := st.Usage(t.ID)
+        row := make([]any, len(names))
+        for j, name := range names {
+            row[j] = columns[name].get(t, u)
+        }
+        rows[i] = row
+    }
+    return write(e.stdout, *format, names, rows)
+}
+
+func parseColumns(list string) ([]string, error) {
+    var names []string
+    for _, name := range strings.Split(list, ",") {
+        name = strings.TrimSpace(name)
+        if name == "" {
+            continue
+        }
+        if _, ok := columns[name]; !ok {
+            return nil, fmt.Errorf("unknown field %q", name)
+        }
+        names = append(names, name)
+    }
+    if len(names) == 0 {
+        return nil, fmt.Errorf("no fields to print")
+    }
+    return names, nil
+}
+
+func cmdStats(e *env, args []string) error {
+    fs := e.flags("stats")
+    format := fs.String("format", "table", "output `format`: table, json or csv")
+    top := fs.String("top", "", "rank plays by track, artist or album instead")
+    days := fs.Int("days", 0, "with -top, count plays of the last `n` days; 0 counts all")
+    limit := fs.Int("limit", 10, "with -top, print at most `n` entries")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+    if fs.NArg() > 0 {
+        return errUsage
+    }
+    idx, err := e.index()
+    if err != nil {
+        return err
+    }
+    if *top != "" {
+        st, err := e.stats()
+        if err != nil {
+            return err
+        }
+        var from time.Time
+        if *days > 0 {
+            from = time.Now().AddDate(0, 0, -*days)
+        }
+        items, err := st.Top(*top, from, time.Time{}, *limit, idx.GetByID)
+        if err != nil {
+            return err
+        }
+        rows := make([][]any, len(items))
+        for i, it := range items {
+            rows[i] = []any{it.Plays, it.Name, it.Artist}
+        }
+        return write(e.stdout, *format, []string{"plays", *top, "artist"}, rows)
+    }
+
+    tracks := idx.GetAll()
+    artists, albums := make(map[string]bool), make(map[string]bool)
+    codecs := make(map[string]int)
+    total := 0.0
+    for _, t := range tracks {
+        if t.Artist != "" {
+            artists[t.Artist] = true
+        }
+        if t.Album != "" {
+            albums[t.Artist+"\x00"+t.Album] = true
+        }
+        codec := t.Codec
+        if codec == "" {
+            codec = "unknown"
+        }
+        codecs[codec]++
+        total += t.Duration
+    }
+    rows := [][]any{
+        {"tracks", len(tracks)},
+        {"artists", len(artists)},
+        {"albums", len(albums)},
+        {"duration", time.Duration(total * float64(time.Second)).Round(time.Second).String()},
+    }
+    names := make([]string, 0, len(codecs))
+    for c := range codecs {
+        names = append(names, c)
+    }
+    sort.Strings(names)
+    for _, c := range names {
+        rows = append(rows, []any{"codec " + c, codecs[c]})
+    }
+    return write(e.stdout, *format, []string{"stat", "value"}, rows)
+}
+
+// write prints rows under the header names as a table, JSON objects or CSV
+func write(w io.Writer, format string, names []string, rows [][]any) error {
+    switch format {
+    case "table":
+        tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+        fmt.Fprintln(tw, strings.ToUpper(strings.Join(names, "\t")))
+        for _, row := range rows {
+            cells := make([]string, len(row))
+            for i, v := range row {
+                cells[i] = text(names[i], v)
+            }
+            fmt.Fprintln(tw, strings.Join(cells, "\t"))
+        }
+        return tw.Flush()
+    case "json":
+        out := make([]map[string]any, len(rows))
+        for i, row := range rows {
+            obj := make(map[string]any, len(row))
+            for j, v := range row {
+                obj[names[j]] = v
+            }
+            out[i] = obj
+        }
+        enc := json.NewEncoder(w)
+        enc.SetIndent("", "  ")
+        return enc.Encode(out)
+    case "csv":
+        cw := csv.NewWriter(w)
+        cw.Write(names)
+        for _, row := range rows {
+            cells := make([]string, len(row))
+            for i, v := range row {
+                cells[i] = plain(v)
+            }
+            cw.Write(cells)
+        }
+        cw.Flush()
+        return cw.Error()
+    }
+    return fmt.Errorf("unknown format %q", format)
+}
+
+// text formats a value for a table
+func text(name string, v any) string {
+    if c, ok := columns[name]; ok && c.text != nil {
+        return c.text(v)
+    }
+    return plain(v)
+}
+
+// plain formats a value for CSV, keeping numbers exact and dates in RFC 3339
+func plain(v any) string {
+    if t, ok := v.(time.Time); ok {
+        if t.IsZero() {
+            return ""
+        }
+        return t.Format(time.RFC3339)
+    }
+    return fmt.Sprint(v)
+}
+
+func dateText(v any) string {
+    t := v.(time.Time)
+    if t.IsZero() {
+        return "never"
+    }
+    return t.Local().Format("2006-01-02 15:04")
+}
+
+// clock formats seconds as m:ss, or h:mm:ss from an hour up
+func clock(seconds float64) string {
+    s := int(seconds + 0.5)
+    if s >= 3600 {
+        return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+    }
+    return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
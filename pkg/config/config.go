@@ -1,8 +1,10 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sync"
@@ -10,14 +12,109 @@ import (
 
 // Config stores application-level configuration; more groups can be added later
 type Config struct {
-    SrcDirs []string `json:"srcDirs"`
+    SrcDirs []string  `json:"srcDirs"`
+    DSP     DSPConfig `json:"dsp"`
+    // AutoImportPlaylists imports playlist files found in SrcDirs during scans
+    AutoImportPlaylists bool `json:"autoImportPlaylists"`
+    // RatingSync reads ratings from tags on scan and writes rating changes back to files
+    RatingSync bool `json:"ratingSync"`
+    // PathTemplates infer tags missing from files from their paths, tried in order
+    PathTemplates []string `json:"pathTemplates"`
+    // FingerprintSeconds is how much of each track the analysis pass fingerprints; 0 uses the default
+    FingerprintSeconds int `json:"fingerprintSeconds"`
+    // AnalyzeTempoKey estimates BPM and key for tracks whose tags lack them
+    AnalyzeTempoKey bool `json:"analyzeTempoKey"`
+    // Subsonic serves the library to remote players
+    Subsonic SubsonicConfig `json:"subsonic"`
+    // MPD lets MPD clients browse the library and control playback
+    MPD MPDConfig `json:"mpd"`
+    // Logging sets how much each component writes to the log files
+    Logging LoggingConfig `json:"logging"`
 }
 
+// LoggingConfig holds log levels: debug, info, warn or error
+type LoggingConfig struct {
+    // Level applies to components without their own; empty means info
+    Level string `json:"level"`
+    // Components overrides Level by component, e.g. "watcher": "debug"
+    Components map[string]string `json:"components"`
+}
+
+// SubsonicConfig controls the Subsonic-compatible HTTP API for remote clients
+type SubsonicConfig struct {
+    Enabled bool `json:"enabled"`
+    // Address is the host:port to listen on; empty uses DefaultSubsonicAddress
+    Address  string `json:"address"`
+    Username string `json:"username"`
+    Password string `json:"password"`
+}
+
+// DefaultSubsonicAddress is the port Subsonic servers usually listen on,
+// bound to this machine only; serving other hosts is opted into by Address
+const DefaultSubsonicAddress = "127.0.0.1:4533"
+
+// MPDConfig controls the MPD protocol server
+type MPDConfig struct {
+    Enabled bool `json:"enabled"`
+    // Address is the host:port to listen on; empty uses localhost:6600
+    Address string `json:"address"`
+    // Password is optional; clients must send it before other commands
+    Password string `json:"password"`
+}
+
+// DSPConfig holds the playback processing chain settings
+type DSPConfig struct {
+    Preset        string     `json:"preset"`
+    Bands         []EQBand   `json:"bands"`
+    Preamp        float64    `json:"preamp"`
+    Balance       float64    `json:"balance"`
+    EQBypass      bool       `json:"eqBypass"`
+    PreampBypass  bool       `json:"preampBypass"`
+    BalanceBypass bool       `json:"balanceBypass"`
+    Presets       []EQPreset `json:"presets"`
+}
+
+// EQBand describes a single equalizer filter; Gain is in dB
+type EQBand struct {
+    Type string  `json:"type"`
+    Freq float64 `json:"freq"`
+    Gain float64 `json:"gain"`
+    Q    float64 `json:"q"`
+}
+
+// EQPreset is a named set of bands and preamp gain
+type EQPreset struct {
+    Name   string   `json:"name"`
+    Preamp float64  `json:"preamp"`
+    Bands  []EQBand `json:"bands"`
+}
+
+// configPerm keeps the config, which holds server passwords, private to the user
+const configPerm = 0o600
+
 // Manager handles reading/writing config file placed inside given baseDir
 type Manager struct {
     path string
     mtx  sync.RWMutex
     cfg  *Config
+    // base is the file as last read or written, for merging edits made by others
+    base     []byte
+    readOnly bool
+    log      *slog.Logger
+}
+
+// SetLogger sets where the manager logs; slog.Default() until then
+func (m *Manager) SetLogger(l *slog.Logger) {
+    m.mtx.Lock()
+    defer m.mtx.Unlock()
+    m.log = l
+}
+
+func (m *Manager) logger() *slog.Logger {
+    if m.log == nil {
+        return slog.Default()
+    }
+    return m.log
 }
 
 // NewManagerAt creates a new manager that stores config at baseDir/config.json
@@ -33,13 +130,39 @@ func NewManagerAt(baseDir string) (*Manager, error) {
     return m, nil
 }
 
-// NewManager uses user config dir and PenguinTunes subdir
-func NewManager() (*Manager, error) {
+// OpenManagerReadOnly loads baseDir/config.json without ever writing it, for
+// processes that don't hold the data dir lock. A missing file reads as the
+// default config; saves fail with ErrReadOnly.
+func OpenManagerReadOnly(baseDir string) (*Manager, error) {
+    m := &Manager{path: filepath.Join(baseDir, "config.json"), readOnly: true}
+    if err := m.loadOrCreate(); err != nil {
+        return nil, err
+    }
+    return m, nil
+}
+
+// ReadOnly reports whether saves are refused
+func (m *Manager) ReadOnly() bool {
+    return m.readOnly
+}
+
+// DefaultDir is where the app keeps its config and library files: the
+// PenguinTunes subdir of the user config dir
+func DefaultDir() (string, error) {
     dir, err := os.UserConfigDir()
     if err != nil {
-        return nil, fmt.Errorf("UserConfigDir: %w", err)
+        return "", fmt.Errorf("UserConfigDir: %w", err)
+    }
+    return filepath.Join(dir, "PenguinTunes"), nil
+}
+
+// NewManager uses DefaultDir
+func NewManager() (*Manager, error) {
+    dir, err := DefaultDir()
+    if err != nil {
+        return nil, err
     }
-    return NewManagerAt(filepath.Join(dir, "PenguinTunes"))
+    return NewManagerAt(dir)
 }
 
 func (m *Manager) loadOrCreate() error {
@@ -55,21 +178,49 @@ func (m *Manager) loadOrCreate() error {
             return fmt.Errorf("unmarshal config: %w", err)
         }
         m.cfg = &cfg
+        m.base = b
         return nil
     }
     m.cfg = &Config{SrcDirs: []string{}}
+    if m.readOnly {
+        return nil
+    }
     return m.saveLocked()
 }
 
 func (m *Manager) GetConfig() Config {
     m.mtx.RLock()
     defer m.mtx.RUnlock()
-    cfg := *m.cfg
-    cfg.SrcDirs = append([]string{}, m.cfg.SrcDirs...)
+    return m.cfg.clone()
+}
+
+// clone returns a deep copy so callers can't mutate manager state
+func (c *Config) clone() Config {
+    cfg := *c
+    cfg.SrcDirs = append([]string{}, c.SrcDirs...)
+    cfg.PathTemplates = append([]string(nil), c.PathTemplates...)
+    cfg.DSP.Bands = append([]EQBand(nil), c.DSP.Bands...)
+    if c.Logging.Components != nil {
+        cfg.Logging.Components = make(map[string]string, len(c.Logging.Components))
+        for k, v := range c.Logging.Components {
+            cfg.Logging.Components[k] = v
+        }
+    }
+    cfg.DSP.Presets = make([]EQPreset, len(c.DSP.Presets))
+    for i, p := range c.DSP.Presets {
+        p.Bands = append([]EQBand(nil), p.Bands...)
+        cfg.DSP.Presets[i] = p
+    }
     return cfg
 }
 
+// SaveConfig replaces the config and writes it. Fields another process
+// changed in the file meanwhile are kept unless cfg changes them too, so
+// GetConfig may afterwards differ from cfg.
 func (m *Manager) SaveConfig(cfg Config) error {
+    if m.readOnly {
+        return fmt.Errorf("save config: %w", ErrReadOnly)
+    }
     m.mtx.Lock()
     defer m.mtx.Unlock()
     m.cfg = &cfg
@@ -81,12 +232,48 @@ func (m *Manager) saveLocked() error {
     if err != nil {
         return fmt.Errorf("marshal config: %w", err)
     }
-    tmp := m.path + ".tmp"
-    if err := os.WriteFile(tmp, b, 0o644); err != nil {
-        return fmt.Errorf("write tmp config: %w", err)
+    written, err := WriteMerged(m.path, m.base, b, configPerm, m.logger())
+    if err != nil {
+        return fmt.Errorf("write config: %w", err)
     }
-    if err := os.Rename(tmp, m.path); err != nil {
-        return fmt.Errorf("rename tmp: %w", err)
+    m.base = written
+    if !bytes.Equal(written, b) {
+        var merged Config
+        if err := json.Unmarshal(written, &merged); err != nil {
+            return fmt.Errorf("unmarshal merged config: %w", err)
+        }
+        m.cfg = &merged
     }
     return nil
 }
+
+// WriteFileAtomic replaces path with data through a uniquely named temp file
+// in the same directory, so readers never see a partial file and processes
+// sharing the directory can't interleave their writes
+func WriteFileAtomic(path string, data []byte) error {
+    return WriteFileAtomicMode(path, data, 0o644)
+}
+
+// WriteFileAtomicMode is WriteFileAtomic for files that need permissions
+// other than 0644, e.g. ones holding passwords
+func WriteFileAtomicMode(path string, data []byte, perm os.FileMode) error {
+    f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+    if err != nil {
+        return err
+    }
+    tmp := f.Name()
+    _, err = f.Write(data)
+    if cerr := f.Close(); err == nil {
+        err = cerr
+    }
+    if err == nil {
+        err = os.Chmod(tmp, perm)
+    }
+    if err == nil {
+        err = os.Rename(tmp, path)
+    }
+    if err != nil {
+        os.Remove(tmp)
+    }
+    return err
+}
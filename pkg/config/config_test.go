@@ -27,3 +27,28 @@ func TestManagerAtCreatesConfig(t *testing.T) {
         t.Fatalf("config file missing: %v", err)
     }
 }
+
+func TestConfigIsPrivate(t *testing.T) {
+    base := t.TempDir()
+    fn := filepath.Join(base, "config.json")
+    // a config written by an older version is readable by everyone
+    if err := os.WriteFile(fn, []byte("{}"), 0o644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+    m, err := NewManagerAt(base)
+    if err != nil {
+        t.Fatalf("NewManagerAt: %v", err)
+    }
+    cfg := m.GetConfig()
+    cfg.Subsonic.Password = "secret"
+    if err := m.SaveConfig(cfg); err != nil {
+        t.Fatalf("SaveConfig: %v", err)
+    }
+    st, err := os.Stat(fn)
+    if err != nil {
+        t.Fatalf("stat: %v", err)
+    }
+    if perm := st.Mode().Perm(); perm != 0o600 {
+        t.Fatalf("expected config.json to be 0600, got %o", perm)
+    }
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LockFile is the name of the lock file inside the data dir
+const LockFile = "lock"
+
+// ErrLocked is matched by the error LockDir returns when another process holds the lock
+var ErrLocked = errors.New("data dir is locked")
+
+// ErrReadOnly is returned by saves of stores opened read-only
+var ErrReadOnly = errors.New("opened read-only because another process holds the data dir lock")
+
+// LockedError reports which process holds a data dir; PID is 0 when unknown
+type LockedError struct {
+    Dir string
+    PID int
+}
+
+func (e *LockedError) Error() string {
+    if e.PID > 0 {
+        return fmt.Sprintf("%s is in use by another process (pid %d)", e.Dir, e.PID)
+    }
+    return fmt.Sprintf("%s is in use by another process", e.Dir)
+}
+
+func (e *LockedError) Is(target error) bool { return target == ErrLocked }
+
+// Lock is an advisory lock on a data dir. The process holding it is the
+// single writer of the files there; others should open them read-only. The
+// OS drops the lock when the process exits.
+type Lock struct {
+    f *os.File
+}
+
+// LockDir takes the lock on dir without waiting, creating dir if needed. When
+// another process holds it the error is a *LockedError.
+func LockDir(dir string) (*Lock, error) {
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+        return nil, fmt.Errorf("mkdir base dir: %w", err)
+    }
+    path := filepath.Join(dir, LockFile)
+    f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+    if err != nil {
+        return nil, fmt.Errorf("open lock: %w", err)
+    }
+    held, err := tryLock(f)
+    if err != nil {
+        f.Close()
+        return nil, fmt.Errorf("lock %s: %w", path, err)
+    }
+    if !held {
+        f.Close()
+        return nil, &LockedError{Dir: dir, PID: lockHolder(path)}
+    }
+    // the pid only serves error messages, so failing to write it is fine
+    if f.Truncate(0) == nil {
+        f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+    }
+    return &Lock{f: f}, nil
+}
+
+// Unlock releases the lock
+func (l *Lock) Unlock() error {
+    if l == nil || l.f == nil {
+        return nil
+    }
+    err := unlock(l.f)
+    if cerr := l.f.Close(); err == nil {
+        err = cerr
+    }
+    l.f = nil
+    return err
+}
+
+// RemoveStaleTemp deletes the temp files that writes interrupted by a crash
+// or kill left below the locked dir. Only the lock holder may: another
+// writer's temp files could be in use.
+func (l *Lock) RemoveStaleTemp() ([]string, error) {
+    if l == nil || l.f == nil {
+        return nil, fmt.Errorf("remove temp files: not locked")
+    }
+    stale, err := StaleTemp(filepath.Dir(l.f.Name()))
+    var removed []string
+    for _, p := range stale {
+        if rerr := os.Remove(p); rerr != nil && !errors.Is(rerr, fs.ErrNotExist) {
+            err = errors.Join(err, rerr)
+            continue
+        }
+        removed = append(removed, p)
+    }
+    return removed, err
+}
+
+// StaleTemp lists the temp files below dir, which are stale unless a write
+// is in progress
+func StaleTemp(dir string) ([]string, error) {
+    var out []string
+    err := filepath.WalkDir(dir, func(path string, de fs.DirEntry, err error) error {
+        if err != nil {
+            if path == dir {
+                return err
+            }
+            return nil
+        }
+        if !de.IsDir() && strings.HasSuffix(de.Name(), ".tmp") {
+            out = append(out, path)
+        }
+        return nil
+    })
+    return out, err
+}
+
+// Holder reports the process holding the lock on dir, without taking it;
+// held is false when the dir is free
+func Holder(dir string) (pid int, held bool) {
+    path := filepath.Join(dir, LockFile)
+    f, err := os.OpenFile(path, os.O_RDWR, 0)
+    if err != nil {
+        return 0, false
+    }
+    defer f.Close()
+    ok, err := tryLock(f)
+    if err != nil {
+        return 0, false
+    }
+    if ok {
+        unlock(f)
+        return 0, false
+    }
+    return lockHolder(path), true
+}
+
+func lockHolder(path string) int {
+    b, err := os.ReadFile(path)
+    if err != nil {
+        return 0
+    }
+    pid, _ := strconv.Atoi(strings.TrimSpace(string(b)))
+    return pid
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLockDirSingleWriter(t *testing.T) {
+    dir := t.TempDir()
+    l, err := LockDir(dir)
+    if err != nil {
+        t.Fatalf("LockDir: %v", err)
+    }
+    _, err = LockDir(dir)
+    var locked *LockedError
+    if !errors.Is(err, ErrLocked) || !errors.As(err, &locked) {
+        t.Fatalf("expected ErrLocked for a second lock, got %v", err)
+    }
+    if locked.PID != os.Getpid() {
+        t.Fatalf("expected holder pid %d, got %d", os.Getpid(), locked.PID)
+    }
+    if pid, held := Holder(dir); !held || pid != os.Getpid() {
+        t.Fatalf("Holder: expected %d held, got %d %v", os.Getpid(), pid, held)
+    }
+    if err := l.Unlock(); err != nil {
+        t.Fatalf("Unlock: %v", err)
+    }
+    if _, held := Holder(dir); held {
+        t.Fatalf("expected the dir to be free after Unlock")
+    }
+    l, err = LockDir(dir)
+    if err != nil {
+        t.Fatalf("LockDir after Unlock: %v", err)
+    }
+    l.Unlock()
+}
+
+func TestReadOnlyManager(t *testing.T) {
+    dir := t.TempDir()
+    m, err := OpenManagerReadOnly(dir)
+    if err != nil {
+        t.Fatalf("OpenManagerReadOnly: %v", err)
+    }
+    if _, err := os.Stat(dir + "/config.json"); !os.IsNotExist(err) {
+        t.Fatalf("expected no config file to be created, got %v", err)
+    }
+    c := m.GetConfig()
+    c.SrcDirs = []string{"/music"}
+    if err := m.SaveConfig(c); !errors.Is(err, ErrReadOnly) {
+        t.Fatalf("expected ErrReadOnly, got %v", err)
+    }
+    if len(m.GetConfig().SrcDirs) != 0 {
+        t.Fatalf("a refused save must not change the config")
+    }
+}
+
+func TestRemoveStaleTemp(t *testing.T) {
+    dir := t.TempDir()
+    l, err := LockDir(dir)
+    if err != nil {
+        t.Fatalf("LockDir: %v", err)
+    }
+    defer l.Unlock()
+    if err := os.MkdirAll(filepath.Join(dir, "waveforms"), 0o755); err != nil {
+        t.Fatalf("mkdir: %v", err)
+    }
+    stale := []string{
+        filepath.Join(dir, "index.json.123.tmp"),
+        filepath.Join(dir, "stats.json.tmp"),
+        filepath.Join(dir, "waveforms", "ab.json.tmp"),
+    }
+    for _, p := range append(stale, filepath.Join(dir, "index.json")) {
+        if err := os.WriteFile(p, []byte("{}"), 0o644); err != nil {
+            t.Fatalf("write: %v", err)
+        }
+    }
+    removed, err := l.RemoveStaleTemp()
+    if err != nil {
+        t.Fatalf("RemoveStaleTemp: %v", err)
+    }
+    if len(removed) != len(stale) {
+        t.Fatalf("expected %v removed, got %v", stale, removed)
+    }
+    for _, p := range stale {
+        if _, err := os.Stat(p); !os.IsNotExist(err) {
+            t.Fatalf("expected %s removed", p)
+        }
+    }
+    if _, err := os.Stat(filepath.Join(dir, "index.json")); err != nil {
+        t.Fatalf("index.json must stay: %v", err)
+    }
+}
@@ -0,0 +1,24 @@
+//go:build windows
+
+package config
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// tryLock takes an exclusive lock on the first byte of f; held is false when another process has it
+func tryLock(f *os.File) (held bool, err error) {
+    ol := new(windows.Overlapped)
+    err = windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, ol)
+    if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+        return false, nil
+    }
+    return err == nil, err
+}
+
+func unlock(f *os.File) error {
+    return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, new(windows.Overlapped))
+}
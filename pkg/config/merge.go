@@ -0,0 +1,93 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"reflect"
+)
+
+// MergeJSON merges two edits of the JSON document base. Objects are merged
+// key by key, recursively; any other value that both sides changed, arrays
+// included, takes ours. The result is indented like the stores write it.
+func MergeJSON(base, ours, theirs []byte) ([]byte, error) {
+    var b, o, t any
+    if len(bytes.TrimSpace(base)) > 0 {
+        if err := json.Unmarshal(base, &b); err != nil {
+            return nil, fmt.Errorf("merge base: %w", err)
+        }
+    }
+    if err := json.Unmarshal(ours, &o); err != nil {
+        return nil, fmt.Errorf("merge ours: %w", err)
+    }
+    if err := json.Unmarshal(theirs, &t); err != nil {
+        return nil, fmt.Errorf("merge theirs: %w", err)
+    }
+    return json.MarshalIndent(merge3(b, o, t), "", "  ")
+}
+
+// missing marks a key absent from one side of an object merge
+type missing struct{}
+
+func merge3(base, ours, theirs any) any {
+    switch {
+    case reflect.DeepEqual(ours, theirs), reflect.DeepEqual(theirs, base):
+        return ours
+    case reflect.DeepEqual(ours, base):
+        return theirs
+    }
+    o, ok1 := ours.(map[string]any)
+    t, ok2 := theirs.(map[string]any)
+    if !ok1 || !ok2 {
+        return ours
+    }
+    b, _ := base.(map[string]any)
+    out := make(map[string]any, len(o))
+    get := func(m map[string]any, k string) any {
+        if v, ok := m[k]; ok {
+            return v
+        }
+        return missing{}
+    }
+    for _, m := range []map[string]any{o, t} {
+        for k := range m {
+            if _, done := out[k]; done {
+                continue
+            }
+            v := merge3(get(b, k), get(o, k), get(t, k))
+            if _, gone := v.(missing); !gone {
+                out[k] = v
+            }
+        }
+    }
+    return out
+}
+
+// WriteMerged writes data to path like WriteFileAtomicMode. When the file no
+// longer holds base, another process changed it since it was read, and its
+// changes are merged into data first. It returns what was written, which is
+// the next base. log receives merge failures.
+func WriteMerged(path string, base, data []byte, perm os.FileMode, log *slog.Logger) ([]byte, error) {
+    disk, err := os.ReadFile(path)
+    switch {
+    case errors.Is(err, fs.ErrNotExist):
+    case err != nil:
+        return nil, err
+    case !bytes.Equal(disk, base) && !bytes.Equal(disk, data):
+        merged, err := MergeJSON(base, data, disk)
+        if err != nil {
+            // an unreadable file can't be merged; ours replaces it
+            log.Warn("merge failed, overwriting", "path", path, "err", err)
+            break
+        }
+        data = merged
+    }
+    if err := WriteFileAtomicMode(path, data, perm); err != nil {
+        return nil, err
+    }
+    return data, nil
+}
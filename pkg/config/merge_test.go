@@ -0,0 +1,63 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMergeJSON(t *testing.T) {
+    base := `{"a": 1, "b": {"x": 1, "y": 1}, "c": [1], "d": 1, "gone": 1}`
+    ours := `{"a": 2, "b": {"x": 2, "y": 1}, "c": [2], "d": 1}`
+    theirs := `{"a": 1, "b": {"x": 1, "y": 3}, "c": [3], "e": 5, "gone": 1}`
+    out, err := MergeJSON([]byte(base), []byte(ours), []byte(theirs))
+    if err != nil {
+        t.Fatalf("MergeJSON: %v", err)
+    }
+    var got map[string]any
+    if err := json.Unmarshal(out, &got); err != nil {
+        t.Fatalf("unmarshal: %v", err)
+    }
+    // edits and deletions from both sides survive; conflicting arrays take ours
+    want := map[string]any{"a": 2.0, "b": map[string]any{"x": 2.0, "y": 3.0}, "c": []any{2.0}, "e": 5.0}
+    if !reflect.DeepEqual(got, want) {
+        t.Fatalf("expected %v, got %v", want, got)
+    }
+}
+
+func TestSaveConfigMergesOutsideEdits(t *testing.T) {
+    dir := t.TempDir()
+    m, err := NewManagerAt(dir)
+    if err != nil {
+        t.Fatalf("NewManagerAt: %v", err)
+    }
+    // another process turns on rating sync behind the manager's back
+    path := filepath.Join(dir, "config.json")
+    b, _ := os.ReadFile(path)
+    var raw map[string]any
+    json.Unmarshal(b, &raw)
+    raw["ratingSync"] = true
+    b, _ = json.Marshal(raw)
+    if err := os.WriteFile(path, b, 0o644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+
+    c := m.GetConfig()
+    c.SrcDirs = []string{"/music"}
+    if err := m.SaveConfig(c); err != nil {
+        t.Fatalf("SaveConfig: %v", err)
+    }
+    got := m.GetConfig()
+    if !got.RatingSync || len(got.SrcDirs) != 1 {
+        t.Fatalf("expected both edits in memory, got %+v", got)
+    }
+    m2, err := NewManagerAt(dir)
+    if err != nil {
+        t.Fatalf("reload: %v", err)
+    }
+    if got := m2.GetConfig(); !got.RatingSync || len(got.SrcDirs) != 1 {
+        t.Fatalf("expected both edits on disk, got %+v", got)
+    }
+}
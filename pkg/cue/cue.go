@@ -0,0 +1,242 @@
+package cue
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// framesPerSecond is the CD frame rate MSF timestamps count in
+const framesPerSecond = 75
+
+// Sheet is a parsed CUE sheet. Genre, Date and Disc come from the REM
+// comments rippers commonly write.
+type Sheet struct {
+    Title      string
+    Performer  string
+    Songwriter string
+    Genre      string
+    Date       string
+    Disc       int
+    Files      []File
+}
+
+// File is an audio file named by the sheet and the tracks cut from it
+type File struct {
+    Name   string
+    Tracks []Track
+}
+
+// Track is one audio track; Start is its INDEX 01 in seconds from the start of the file
+type Track struct {
+    Number     int
+    Title      string
+    Performer  string
+    Songwriter string
+    ISRC       string
+    Start      float64
+}
+
+// ReadFile parses the CUE sheet at path
+func ReadFile(path string) (*Sheet, error) {
+    b, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    s, err := Parse(string(b))
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", path, err)
+    }
+    return s, nil
+}
+
+// Parse reads a CUE sheet. Text that isn't UTF-8 is taken as Latin-1, which
+// older rippers write. Data tracks are left out.
+func Parse(text string) (*Sheet, error) {
+    if !utf8.ValidString(text) {
+        r := make([]rune, len(text))
+        for i := 0; i < len(text); i++ {
+            r[i] = rune(text[i])
+        }
+        text = string(r)
+    }
+    text = strings.TrimPrefix(text, "\ufeff")
+    s := &Sheet{}
+    var file *File
+    var track *Track
+    skip := false // inside a non-audio track
+    sc := bufio.NewScanner(strings.NewReader(text))
+    for n := 1; sc.Scan(); n++ {
+        args := fields(sc.Text())
+        if len(args) == 0 {
+            continue
+        }
+        cmd, args := strings.ToUpper(args[0]), args[1:]
+        arg := strings.Join(args, " ")
+        switch cmd {
+        case "REM":
+            if len(args) < 2 {
+                continue
+            }
+            val := strings.Join(args[1:], " ")
+            switch strings.ToUpper(args[0]) {
+            case "GENRE":
+                s.Genre = val
+            case "DATE":
+                s.Date = val
+            case "DISCNUMBER":
+                s.Disc, _ = strconv.Atoi(val)
+            }
+        case "FILE":
+            if len(args) == 0 {
+                return nil, fmt.Errorf("line %d: FILE without a name", n)
+            }
+            s.Files = append(s.Files, File{Name: args[0]})
+            file, track = &s.Files[len(s.Files)-1], nil
+        case "TRACK":
+            if file == nil {
+                return nil, fmt.Errorf("line %d: TRACK before FILE", n)
+            }
+            if len(args) < 2 {
+                return nil, fmt.Errorf("line %d: malformed TRACK", n)
+            }
+            num, err := strconv.Atoi(args[0])
+            if err != nil {
+                return nil, fmt.Errorf("line %d: bad track number %q", n, args[0])
+            }
+            skip = !strings.EqualFold(args[1], "AUDIO")
+            if skip {
+                track = nil
+                continue
+            }
+            // INDEX 01 is required, so a negative start marks a track that never got one
+            file.Tracks = append(file.Tracks, Track{Number: num, Start: -1})
+            track = &file.Tracks[len(file.Tracks)-1]
+        case "INDEX":
+            if skip || track == nil {
+                continue
+            }
+            if len(args) < 2 {
+                return nil, fmt.Errorf("line %d: malformed INDEX", n)
+            }
+            if num, _ := strconv.Atoi(args[0]); num != 1 {
+                continue
+            }
+            t, err := parseMSF(args[1])
+            if err != nil {
+                return nil, fmt.Errorf("line %d: %w", n, err)
+            }
+            track.Start = t
+        case "TITLE", "PERFORMER", "SONGWRITER":
+            switch {
+            case skip:
+            case track != nil:
+                setField(&track.Title, &track.Performer, &track.Songwriter, cmd, arg)
+            default:
+                setField(&s.Title, &s.Performer, &s.Songwriter, cmd, arg)
+            }
+        case "ISRC":
+            if track != nil {
+                track.ISRC = arg
+            }
+        }
+    }
+    if err := sc.Err(); err != nil {
+        return nil, err
+    }
+    for _, f := range s.Files {
+        for _, t := range f.Tracks {
+            if t.Start < 0 {
+                return nil, fmt.Errorf("track %d has no INDEX 01", t.Number)
+            }
+        }
+    }
+    return s, nil
+}
+
+func setField(title, performer, songwriter *string, cmd, val string) {
+    switch cmd {
+    case "TITLE":
+        *title = val
+    case "PERFORMER":
+        *performer = val
+    case "SONGWRITER":
+        *songwriter = val
+    }
+}
+
+// fields splits a line on spaces, keeping double-quoted strings together
+func fields(line string) []string {
+    var out []string
+    line = strings.TrimSpace(line)
+    for line != "" {
+        if line[0] == '"' {
+            end := strings.IndexByte(line[1:], '"')
+            if end < 0 {
+                out = append(out, line[1:])
+                break
+            }
+            out = append(out, line[1:end+1])
+            line = strings.TrimLeft(line[end+2:], " \t")
+            continue
+        }
+        end := strings.IndexAny(line, " \t")
+        if end < 0 {
+            out = append(out, line)
+            break
+        }
+        out = append(out, line[:end])
+        line = strings.TrimLeft(line[end:], " \t")
+    }
+    return out
+}
+
+// parseMSF converts mm:ss:ff to seconds
+func parseMSF(s string) (float64, error) {
+    parts := strings.Split(s, ":")
+    if len(parts) != 3 {
+        return 0, fmt.Errorf("bad time %q", s)
+    }
+    var v [3]int
+    for i, p := range parts {
+        n, err := strconv.Atoi(p)
+        if err != nil || n < 0 {
+            return 0, fmt.Errorf("bad time %q", s)
+        }
+        v[i] = n
+    }
+    if v[1] >= 60 || v[2] >= framesPerSecond {
+        return 0, fmt.Errorf("bad time %q", s)
+    }
+    return float64(v[0]*60+v[1]) + float64(v[2])/framesPerSecond, nil
+}
+
+// FileFor returns the entry naming the audio file at audio. Rippers often keep
+// the name of the original image after re-encoding, so when no name matches
+// exactly, the stem alone is compared.
+func (s *Sheet) FileFor(audio string) *File {
+    base := filepath.Base(audio)
+    for i := range s.Files {
+        if strings.EqualFold(fileBase(s.Files[i].Name), base) {
+            return &s.Files[i]
+        }
+    }
+    stem := strings.TrimSuffix(base, filepath.Ext(base))
+    for i := range s.Files {
+        name := fileBase(s.Files[i].Name)
+        if strings.EqualFold(strings.TrimSuffix(name, filepath.Ext(name)), stem) {
+            return &s.Files[i]
+        }
+    }
+    return nil
+}
+
+// fileBase returns the last element of a FILE name, which may use either separator
+func fileBase(name string) string {
+    return path.Base(strings.ReplaceAll(name, `\`, "/"))
+}
@@ -0,0 +1,80 @@
+package cue
+
+import (
+	"math"
+	"testing"
+)
+
+const sheet = `REM GENRE Classical
+REM DATE 1998
+PERFORMER "Some Orchestra"
+TITLE "Symphonies"
+FILE "CDImage.wav" WAVE
+  TRACK 01 AUDIO
+    TITLE "I. Allegro"
+    COMPOSER "ignored"
+    INDEX 01 00:00:00
+  TRACK 02 AUDIO
+    TITLE "II. Adagio"
+    PERFORMER "Soloist"
+    INDEX 00 04:58:00
+    INDEX 01 05:00:37
+  TRACK 03 MODE1/2352
+    TITLE "Data"
+    INDEX 01 20:00:00
+`
+
+func TestParse(t *testing.T) {
+    s, err := Parse(sheet)
+    if err != nil {
+        t.Fatalf("Parse: %v", err)
+    }
+    if s.Title != "Symphonies" || s.Performer != "Some Orchestra" || s.Genre != "Classical" || s.Date != "1998" {
+        t.Fatalf("unexpected sheet %+v", s)
+    }
+    if len(s.Files) != 1 || s.Files[0].Name != "CDImage.wav" {
+        t.Fatalf("unexpected files %+v", s.Files)
+    }
+    tracks := s.Files[0].Tracks
+    if len(tracks) != 2 {
+        t.Fatalf("expected the data track to be dropped, got %+v", tracks)
+    }
+    if tracks[1].Title != "II. Adagio" || tracks[1].Performer != "Soloist" || tracks[1].Number != 2 {
+        t.Fatalf("unexpected track %+v", tracks[1])
+    }
+    if want := 300 + 37.0/75; math.Abs(tracks[1].Start-want) > 1e-9 {
+        t.Fatalf("expected start %v, got %v", want, tracks[1].Start)
+    }
+}
+
+func TestParseLatin1AndErrors(t *testing.T) {
+    s, err := Parse("TITLE \"Caf\xe9\"\nFILE a.flac WAVE\nTRACK 1 AUDIO\nINDEX 01 00:00:00\n")
+    if err != nil {
+        t.Fatalf("Parse: %v", err)
+    }
+    if s.Title != "Café" {
+        t.Fatalf("expected Latin-1 title, got %q", s.Title)
+    }
+    for _, bad := range []string{
+        "TRACK 01 AUDIO\nINDEX 01 00:00:00",
+        "FILE a.wav WAVE\nTRACK 01 AUDIO\nTITLE x",
+        "FILE a.wav WAVE\nTRACK 01 AUDIO\nINDEX 01 00:61:00",
+    } {
+        if _, err := Parse(bad); err == nil {
+            t.Fatalf("expected %q to fail", bad)
+        }
+    }
+}
+
+func TestFileFor(t *testing.T) {
+    s := &Sheet{Files: []File{{Name: `C:\rips\Disc 1.wav`}, {Name: "Disc 2.flac"}}}
+    if f := s.FileFor("/music/Disc 2.flac"); f == nil || f.Name != "Disc 2.flac" {
+        t.Fatalf("expected exact match, got %+v", f)
+    }
+    if f := s.FileFor("/music/disc 1.flac"); f == nil || f.Name != `C:\rips\Disc 1.wav` {
+        t.Fatalf("expected stem match, got %+v", f)
+    }
+    if f := s.FileFor("/music/other.flac"); f != nil {
+        t.Fatalf("expected no match, got %+v", f)
+    }
+}
@@ -0,0 +1,118 @@
+package dsp
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+// FilterType selects the biquad response shape
+type FilterType string
+
+const (
+    Peaking   FilterType = "peaking"
+    LowShelf  FilterType = "lowshelf"
+    HighShelf FilterType = "highshelf"
+    LowPass   FilterType = "lowpass"
+    HighPass  FilterType = "highpass"
+)
+
+// Biquad is a second order IIR filter using the RBJ audio EQ cookbook formulas
+type Biquad struct {
+    Type FilterType
+    Freq float64
+    Gain float64
+    Q    float64
+
+    b0, b1, b2, a1, a2 float64
+    // per-channel state for transposed direct form II
+    z1, z2 []float64
+}
+
+// NewBiquad computes coefficients for the given filter at sampleRate
+func NewBiquad(typ FilterType, freq, gainDB, q, sampleRate float64) (*Biquad, error) {
+    if sampleRate <= 0 {
+        return nil, fmt.Errorf("invalid sample rate %v", sampleRate)
+    }
+    if freq <= 0 || freq >= sampleRate/2 {
+        return nil, fmt.Errorf("frequency %v out of range for sample rate %v", freq, sampleRate)
+    }
+    if q <= 0 {
+        return nil, fmt.Errorf("invalid Q %v", q)
+    }
+    a := math.Pow(10, gainDB/40)
+    w0 := 2 * math.Pi * freq / sampleRate
+    cs := math.Cos(w0)
+    alpha := math.Sin(w0) / (2 * q)
+    sa := 2 * math.Sqrt(a) * alpha
+
+    var b0, b1, b2, a0, a1, a2 float64
+    switch typ {
+    case Peaking:
+        b0, b1, b2 = 1+alpha*a, -2*cs, 1-alpha*a
+        a0, a1, a2 = 1+alpha/a, -2*cs, 1-alpha/a
+    case LowShelf:
+        b0 = a * ((a + 1) - (a-1)*cs + sa)
+        b1 = 2 * a * ((a - 1) - (a+1)*cs)
+        b2 = a * ((a + 1) - (a-1)*cs - sa)
+        a0 = (a + 1) + (a-1)*cs + sa
+        a1 = -2 * ((a - 1) + (a+1)*cs)
+        a2 = (a + 1) + (a-1)*cs - sa
+    case HighShelf:
+        b0 = a * ((a + 1) + (a-1)*cs + sa)
+        b1 = -2 * a * ((a - 1) + (a+1)*cs)
+        b2 = a * ((a + 1) + (a-1)*cs - sa)
+        a0 = (a + 1) - (a-1)*cs + sa
+        a1 = 2 * ((a - 1) - (a+1)*cs)
+        a2 = (a + 1) - (a-1)*cs - sa
+    case LowPass:
+        b0, b1, b2 = (1-cs)/2, 1-cs, (1-cs)/2
+        a0, a1, a2 = 1+alpha, -2*cs, 1-alpha
+    case HighPass:
+        b0, b1, b2 = (1+cs)/2, -(1 + cs), (1+cs)/2
+        a0, a1, a2 = 1+alpha, -2*cs, 1-alpha
+    default:
+        return nil, fmt.Errorf("unknown filter type %q", typ)
+    }
+    return &Biquad{
+        Type: typ, Freq: freq, Gain: gainDB, Q: q,
+        b0: b0 / a0, b1: b1 / a0, b2: b2 / a0, a1: a1 / a0, a2: a2 / a0,
+    }, nil
+}
+
+// Response returns the filter magnitude in dB at freq
+func (b *Biquad) Response(freq, sampleRate float64) float64 {
+    w := 2 * math.Pi * freq / sampleRate
+    z1 := cmplx.Exp(complex(0, -w))
+    z2 := z1 * z1
+    num := complex(b.b0, 0) + complex(b.b1, 0)*z1 + complex(b.b2, 0)*z2
+    den := 1 + complex(b.a1, 0)*z1 + complex(b.a2, 0)*z2
+    return 20 * math.Log10(cmplx.Abs(num)/cmplx.Abs(den))
+}
+
+// Process filters interleaved samples in place
+func (b *Biquad) Process(buf []float32, channels int) {
+    if channels <= 0 {
+        return
+    }
+    if len(b.z1) != channels {
+        b.z1 = make([]float64, channels)
+        b.z2 = make([]float64, channels)
+    }
+    for i := 0; i+channels <= len(buf); i += channels {
+        for ch := 0; ch < channels; ch++ {
+            x := float64(buf[i+ch])
+            y := b.b0*x + b.z1[ch]
+            b.z1[ch] = b.b1*x - b.a1*y + b.z2[ch]
+            b.z2[ch] = b.b2*x - b.a2*y
+            buf[i+ch] = float32(y)
+        }
+    }
+}
+
+// Reset clears filter state, e.g. after a seek
+func (b *Biquad) Reset() {
+    for i := range b.z1 {
+        b.z1[i], b.z2[i] = 0, 0
+    }
+}
@@ -0,0 +1,79 @@
+package dsp
+
+import (
+	"sync"
+
+	cfg "penguin-tunes/pkg/config"
+)
+
+// Chain runs stages in order between the decoder and the output; stages can be bypassed individually
+type Chain struct {
+    mtx    sync.Mutex
+    stages []Stage
+    bypass map[string]bool
+}
+
+// NewChain creates a chain from stages
+func NewChain(stages ...Stage) *Chain {
+    return &Chain{stages: stages, bypass: make(map[string]bool)}
+}
+
+// FromConfig builds the preamp -> eq -> balance chain described by c
+func FromConfig(c cfg.DSPConfig, sampleRate float64) (*Chain, error) {
+    eq, err := NewEqualizer(c.Bands, sampleRate)
+    if err != nil {
+        return nil, err
+    }
+    ch := NewChain(NewPreamp(c.Preamp), eq, NewBalance(c.Balance))
+    ch.SetBypass("preamp", c.PreampBypass)
+    ch.SetBypass("eq", c.EQBypass)
+    ch.SetBypass("balance", c.BalanceBypass)
+    return ch, nil
+}
+
+// SetBypass enables or disables bypass for the stage with the given name
+func (c *Chain) SetBypass(name string, bypass bool) {
+    c.mtx.Lock()
+    defer c.mtx.Unlock()
+    c.bypass[name] = bypass
+}
+
+// Bypassed reports whether the named stage is bypassed
+func (c *Chain) Bypassed(name string) bool {
+    c.mtx.Lock()
+    defer c.mtx.Unlock()
+    return c.bypass[name]
+}
+
+// Stage returns the named stage, or nil
+func (c *Chain) Stage(name string) Stage {
+    c.mtx.Lock()
+    defer c.mtx.Unlock()
+    for _, s := range c.stages {
+        if s.Name() == name {
+            return s
+        }
+    }
+    return nil
+}
+
+// Process runs interleaved samples through every active stage in place
+func (c *Chain) Process(buf []float32, channels int) {
+    c.mtx.Lock()
+    defer c.mtx.Unlock()
+    for _, s := range c.stages {
+        if c.bypass[s.Name()] {
+            continue
+        }
+        s.Process(buf, channels)
+    }
+}
+
+// Reset clears stage state, e.g. after a seek or track change
+func (c *Chain) Reset() {
+    c.mtx.Lock()
+    defer c.mtx.Unlock()
+    for _, s := range c.stages {
+        s.Reset()
+    }
+}
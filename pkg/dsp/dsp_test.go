@@ -0,0 +1,232 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+
+	cfg "penguin-tunes/pkg/config"
+)
+
+const sr = 44100.0
+
+func near(a, b, tol float64) bool {
+    return math.Abs(a-b) <= tol
+}
+
+func TestBiquadKnownResponses(t *testing.T) {
+    cases := []struct {
+        typ    FilterType
+        freq   float64
+        gain   float64
+        q      float64
+        at     float64
+        expect float64
+        tol    float64
+    }{
+        {Peaking, 1000, 6, 1, 1000, 6, 0.01},
+        {Peaking, 1000, 6, 1, 20, 0, 0.1},
+        {Peaking, 1000, -12, 2, 1000, -12, 0.01},
+        {LowShelf, 100, 6, 0.707, 10, 6, 0.1},
+        {LowShelf, 100, 6, 0.707, 10000, 0, 0.05},
+        {HighShelf, 4000, -6, 0.707, 20000, -6, 0.2},
+        {HighShelf, 4000, -6, 0.707, 50, 0, 0.05},
+        {LowPass, 1000, 0, math.Sqrt2 / 2, 1000, -3.01, 0.02},
+        {LowPass, 1000, 0, math.Sqrt2 / 2, 50, 0, 0.05},
+        {HighPass, 1000, 0, math.Sqrt2 / 2, 1000, -3.01, 0.02},
+        {HighPass, 1000, 0, math.Sqrt2 / 2, 15000, 0, 0.05},
+    }
+    for _, c := range cases {
+        b, err := NewBiquad(c.typ, c.freq, c.gain, c.q, sr)
+        if err != nil {
+            t.Fatalf("NewBiquad %s: %v", c.typ, err)
+        }
+        if got := b.Response(c.at, sr); !near(got, c.expect, c.tol) {
+            t.Fatalf("%s %vHz %vdB at %vHz: expected %.2fdB got %.2fdB", c.typ, c.freq, c.gain, c.at, c.expect, got)
+        }
+    }
+    lp, _ := NewBiquad(LowPass, 1000, 0, math.Sqrt2/2, sr)
+    if got := lp.Response(10000, sr); got > -35 {
+        t.Fatalf("expected strong attenuation above cutoff, got %.2fdB", got)
+    }
+}
+
+func TestBiquadRejectsInvalidParams(t *testing.T) {
+    if _, err := NewBiquad(Peaking, 30000, 0, 1, sr); err == nil {
+        t.Fatalf("expected error for frequency above Nyquist")
+    }
+    if _, err := NewBiquad(Peaking, 1000, 0, 0, sr); err == nil {
+        t.Fatalf("expected error for zero Q")
+    }
+    if _, err := NewBiquad("notch-ish", 1000, 0, 1, sr); err == nil {
+        t.Fatalf("expected error for unknown type")
+    }
+}
+
+// sineGain feeds a sine through stage and returns output/input peak ratio in dB after settling
+func sineGain(s Stage, freq float64) float64 {
+    n := int(sr)
+    buf := make([]float32, n)
+    for i := range buf {
+        buf[i] = float32(0.25 * math.Sin(2*math.Pi*freq*float64(i)/sr))
+    }
+    s.Process(buf, 1)
+    peak := 0.0
+    for _, v := range buf[n/2:] {
+        peak = math.Max(peak, math.Abs(float64(v)))
+    }
+    return 20 * math.Log10(peak/0.25)
+}
+
+func TestEqualizerProcessMatchesResponse(t *testing.T) {
+    bands := []cfg.EQBand{
+        {Type: "lowshelf", Freq: 120, Gain: 4, Q: 0.707},
+        {Type: "peaking", Freq: 1000, Gain: -6, Q: 1.5},
+        {Type: "highshelf", Freq: 6000, Gain: 3, Q: 0.707},
+    }
+    for _, f := range []float64{60, 1000, 3000, 12000} {
+        eq, err := NewEqualizer(bands, sr)
+        if err != nil {
+            t.Fatalf("NewEqualizer: %v", err)
+        }
+        want := eq.Response(f)
+        if got := sineGain(eq, f); !near(got, want, 0.1) {
+            t.Fatalf("at %vHz expected %.2fdB got %.2fdB", f, want, got)
+        }
+    }
+}
+
+func TestGraphicPresets(t *testing.T) {
+    flat, ok := FindPreset("Flat", nil)
+    if !ok {
+        t.Fatalf("Flat preset missing")
+    }
+    eq, err := NewEqualizer(flat.Bands, sr)
+    if err != nil {
+        t.Fatalf("NewEqualizer: %v", err)
+    }
+    for _, f := range []float64{20, 100, 1000, 10000, 20000} {
+        if got := eq.Response(f); !near(got, 0, 1e-9) {
+            t.Fatalf("flat response at %vHz: %v", f, got)
+        }
+    }
+    bass, _ := FindPreset("Bass Boost", nil)
+    eq, _ = NewEqualizer(bass.Bands, sr)
+    if eq.Response(40) < 5 || !near(eq.Response(10000), 0, 0.5) {
+        t.Fatalf("unexpected bass boost curve: %.2f / %.2f", eq.Response(40), eq.Response(10000))
+    }
+    user := []cfg.EQPreset{{Name: "Mine", Bands: GraphicBands([10]float64{1})}}
+    if _, ok := FindPreset("Mine", user); !ok {
+        t.Fatalf("user preset not found")
+    }
+    if IsBuiltinPreset("Mine") || !IsBuiltinPreset("Rock") {
+        t.Fatalf("IsBuiltinPreset mismatch")
+    }
+}
+
+func TestChainStagesAndBypass(t *testing.T) {
+    c, err := FromConfig(cfg.DSPConfig{Preamp: -6, Balance: 1}, sr)
+    if err != nil {
+        t.Fatalf("FromConfig: %v", err)
+    }
+    buf := []float32{1, 1, 0.5, 0.5}
+    c.Process(buf, 2)
+    if buf[0] != 0 || buf[2] != 0 {
+        t.Fatalf("expected left channel muted by balance, got %v", buf)
+    }
+    if !near(float64(buf[1]), dbToLinear(-6), 1e-6) {
+        t.Fatalf("expected preamp gain on right channel, got %v", buf[1])
+    }
+
+    c.SetBypass("preamp", true)
+    c.SetBypass("balance", true)
+    buf = []float32{1, 1}
+    c.Process(buf, 2)
+    if buf[0] != 1 || buf[1] != 1 {
+        t.Fatalf("expected bypassed chain to pass through, got %v", buf)
+    }
+    if !c.Bypassed("preamp") || c.Bypassed("eq") {
+        t.Fatalf("bypass state mismatch")
+    }
+    if c.Stage("eq") == nil {
+        t.Fatalf("expected eq stage")
+    }
+}
+
+func TestFFTMatchesDFT(t *testing.T) {
+    n := 64
+    re, im := make([]float64, n), make([]float64, n)
+    for i := range re {
+        re[i] = math.Sin(float64(i)*0.3) + float64(i%5)
+    }
+    in := append([]float64(nil), re...)
+    FFT(re, im)
+    for k := 0; k < n; k++ {
+        var wr, wi float64
+        for i, v := range in {
+            a := -2 * math.Pi * float64(k*i) / float64(n)
+            wr += v * math.Cos(a)
+            wi += v * math.Sin(a)
+        }
+        if !near(re[k], wr, 1e-9) || !near(im[k], wi, 1e-9) {
+            t.Fatalf("bin %d = %v%+vi, want %v%+vi", k, re[k], im[k], wr, wi)
+        }
+    }
+}
+
+func TestResample(t *testing.T) {
+    tone := func(freq float64, rate, n int) []float32 {
+        out := make([]float32, n)
+        for i := range out {
+            out[i] = float32(math.Sin(2 * math.Pi * freq * float64(i) / float64(rate)))
+        }
+        return out
+    }
+    // a tone below both Nyquist rates survives
+    out := Resample(tone(1000, 44100, 44100), 44100, 11025)
+    if len(out) != 11025 {
+        t.Fatalf("resampled to %d samples, want 11025", len(out))
+    }
+    want := tone(1000, 11025, 11025)
+    for i := 100; i < len(out)-100; i++ {
+        if !near(float64(out[i]), float64(want[i]), 0.01) {
+            t.Fatalf("sample %d = %v, want %v", i, out[i], want[i])
+        }
+    }
+    // one above the new Nyquist rate is filtered out instead of aliasing
+    var peak float64
+    for _, v := range Resample(tone(8000, 44100, 44100), 44100, 11025)[100:11000] {
+        peak = math.Max(peak, math.Abs(float64(v)))
+    }
+    if peak > 0.01 {
+        t.Fatalf("aliased tone peak = %v", peak)
+    }
+}
+
+func TestChainGraph(t *testing.T) {
+    d := cfg.DSPConfig{Preamp: -6, Balance: 0.5, Bands: []cfg.EQBand{{Freq: 1000, Gain: 6, Q: 1}}}
+    c, err := FromConfig(d, sr)
+    if err != nil {
+        t.Fatalf("FromConfig: %v", err)
+    }
+    g := c.Graph(sr)
+    if !near(g.Preamp, dbToLinear(-6), 1e-6) || g.Left != 0.5 || g.Right != 1 {
+        t.Fatalf("unexpected gains %+v", g)
+    }
+    if len(g.Filters) != 1 {
+        t.Fatalf("expected one filter, got %+v", g.Filters)
+    }
+    // the exported coefficients must filter like the chain does
+    b, _ := NewBiquad(Peaking, 1000, 6, 1, sr)
+    f := g.Filters[0]
+    if f.Feedback[0] != 1 || f.Feedforward[0] != b.b0 || f.Feedforward[2] != b.b2 || f.Feedback[1] != b.a1 || f.Feedback[2] != b.a2 {
+        t.Fatalf("coefficients differ from the filter: %+v", f)
+    }
+
+    c.SetBypass("eq", true)
+    c.SetBypass("preamp", true)
+    c.SetBypass("balance", true)
+    g = c.Graph(sr)
+    if g.Preamp != 1 || len(g.Filters) != 0 || g.Left != 1 || g.Right != 1 {
+        t.Fatalf("expected a bypassed chain to pass through, got %+v", g)
+    }
+}
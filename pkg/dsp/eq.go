@@ -0,0 +1,139 @@
+package dsp
+
+import (
+	"fmt"
+	"math"
+
+	cfg "penguin-tunes/pkg/config"
+)
+
+// GraphicFrequencies are the center frequencies of the 10-band graphic equalizer
+var GraphicFrequencies = [10]float64{31, 62, 125, 250, 500, 1000, 2000, 4000, 8000, 16000}
+
+// graphicQ gives each graphic band roughly one octave of bandwidth
+const graphicQ = 1.41
+
+// Stage is a single processing step operating on interleaved float samples
+type Stage interface {
+    Name() string
+    Process(buf []float32, channels int)
+    Reset()
+}
+
+// Equalizer runs a series of biquad filters
+type Equalizer struct {
+    sampleRate float64
+    filters    []*Biquad
+}
+
+// NewEqualizer builds a parametric equalizer from bands
+func NewEqualizer(bands []cfg.EQBand, sampleRate float64) (*Equalizer, error) {
+    eq := &Equalizer{sampleRate: sampleRate}
+    for i, b := range bands {
+        typ := FilterType(b.Type)
+        if typ == "" {
+            typ = Peaking
+        }
+        q := b.Q
+        if q == 0 {
+            q = graphicQ
+        }
+        // bands above Nyquist can't be realized at low sample rates; skip them
+        if b.Freq >= sampleRate/2 {
+            continue
+        }
+        f, err := NewBiquad(typ, b.Freq, b.Gain, q, sampleRate)
+        if err != nil {
+            return nil, fmt.Errorf("band %d: %w", i, err)
+        }
+        eq.filters = append(eq.filters, f)
+    }
+    return eq, nil
+}
+
+// GraphicBands returns peaking bands at GraphicFrequencies with given gains
+func GraphicBands(gains [10]float64) []cfg.EQBand {
+    bands := make([]cfg.EQBand, len(gains))
+    for i, g := range gains {
+        bands[i] = cfg.EQBand{Type: string(Peaking), Freq: GraphicFrequencies[i], Gain: g, Q: graphicQ}
+    }
+    return bands
+}
+
+func (eq *Equalizer) Name() string { return "eq" }
+
+// Process runs all filters over buf in order
+func (eq *Equalizer) Process(buf []float32, channels int) {
+    for _, f := range eq.filters {
+        f.Process(buf, channels)
+    }
+}
+
+// Reset clears the state of every filter
+func (eq *Equalizer) Reset() {
+    for _, f := range eq.filters {
+        f.Reset()
+    }
+}
+
+// Response returns the combined magnitude in dB at freq
+func (eq *Equalizer) Response(freq float64) float64 {
+    db := 0.0
+    for _, f := range eq.filters {
+        db += f.Response(freq, eq.sampleRate)
+    }
+    return db
+}
+
+// Preamp applies a fixed gain in dB
+type Preamp struct {
+    gain float32
+}
+
+// NewPreamp creates a preamp stage with gainDB
+func NewPreamp(gainDB float64) *Preamp {
+    return &Preamp{gain: float32(dbToLinear(gainDB))}
+}
+
+func (p *Preamp) Name() string { return "preamp" }
+
+func (p *Preamp) Process(buf []float32, channels int) {
+    if p.gain == 1 {
+        return
+    }
+    for i := range buf {
+        buf[i] *= p.gain
+    }
+}
+
+func (p *Preamp) Reset() {}
+
+// Balance attenuates one side of a stereo signal; -1 is full left, 1 is full right
+type Balance struct {
+    left, right float32
+}
+
+// NewBalance creates a balance stage, clamping value to [-1, 1]
+func NewBalance(value float64) *Balance {
+    value = math.Max(-1, math.Min(1, value))
+    return &Balance{left: float32(math.Min(1, 1-value)), right: float32(math.Min(1, 1+value))}
+}
+
+func (b *Balance) Name() string { return "balance" }
+
+// Process only affects stereo buffers; other layouts pass through untouched
+func (b *Balance) Process(buf []float32, channels int) {
+    if channels != 2 {
+        return
+    }
+    for i := 0; i+1 < len(buf); i += 2 {
+        buf[i] *= b.left
+        buf[i+1] *= b.right
+    }
+}
+
+func (b *Balance) Reset() {}
+
+func dbToLinear(db float64) float64 {
+    return math.Pow(10, db/20)
+}
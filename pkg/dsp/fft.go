@@ -0,0 +1,59 @@
+package dsp
+
+import (
+	"math"
+	"math/bits"
+)
+
+// FFT computes the discrete Fourier transform of re + i·im in place; the
+// length must be a power of two
+func FFT(re, im []float64) {
+    n := len(re)
+    if n <= 1 {
+        return
+    }
+    shift := 64 - uint(bits.TrailingZeros(uint(n)))
+    for i := 0; i < n; i++ {
+        if j := int(bits.Reverse64(uint64(i)) >> shift); j > i {
+            re[i], re[j] = re[j], re[i]
+            im[i], im[j] = im[j], im[i]
+        }
+    }
+    for size := 2; size <= n; size <<= 1 {
+        step := -2 * math.Pi / float64(size)
+        for k := 0; k < size/2; k++ {
+            wr, wi := math.Cos(step*float64(k)), math.Sin(step*float64(k))
+            for i := k; i < n; i += size {
+                j := i + size/2
+                tr := wr*re[j] - wi*im[j]
+                ti := wr*im[j] + wi*re[j]
+                re[j], im[j] = re[i]-tr, im[i]-ti
+                re[i], im[i] = re[i]+tr, im[i]+ti
+            }
+        }
+    }
+}
+
+// Hamming returns a Hamming window of length n
+func Hamming(n int) []float64 {
+    w := make([]float64, n)
+    for i := range w {
+        w[i] = 0.54 - 0.46*math.Cos(2*math.Pi*float64(i)/float64(n-1))
+    }
+    return w
+}
+
+// PowerSpectrum windows frame and returns the squared magnitudes of its first len/2+1 bins
+func PowerSpectrum(frame []float32, window []float64) []float64 {
+    n := len(window)
+    re, im := make([]float64, n), make([]float64, n)
+    for i := 0; i < n && i < len(frame); i++ {
+        re[i] = float64(frame[i]) * window[i]
+    }
+    FFT(re, im)
+    out := make([]float64, n/2+1)
+    for i := range out {
+        out[i] = re[i]*re[i] + im[i]*im[i]
+    }
+    return out
+}
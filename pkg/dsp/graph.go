@@ -0,0 +1,46 @@
+package dsp
+
+// Filter holds normalized biquad coefficients in the layout of a Web Audio
+// IIRFilterNode: Feedforward is b0..b2 and Feedback is 1, a1, a2
+type Filter struct {
+    Feedforward []float64 `json:"feedforward"`
+    Feedback    []float64 `json:"feedback"`
+}
+
+// Graph describes a chain as gains and filters for players that process
+// audio themselves, e.g. the frontend's Web Audio graph. Bypassed stages
+// are left out: a unity gain or no filters.
+type Graph struct {
+    SampleRate float64  `json:"sampleRate"`
+    Preamp     float64  `json:"preamp"`
+    Filters    []Filter `json:"filters"`
+    Left       float64  `json:"left"`
+    Right      float64  `json:"right"`
+}
+
+// Graph returns the active stages of the chain with coefficients computed
+// for sampleRate, which must be the rate the chain was built for
+func (c *Chain) Graph(sampleRate float64) Graph {
+    c.mtx.Lock()
+    defer c.mtx.Unlock()
+    g := Graph{SampleRate: sampleRate, Preamp: 1, Filters: []Filter{}, Left: 1, Right: 1}
+    for _, s := range c.stages {
+        if c.bypass[s.Name()] {
+            continue
+        }
+        switch s := s.(type) {
+        case *Preamp:
+            g.Preamp = float64(s.gain)
+        case *Equalizer:
+            for _, f := range s.filters {
+                g.Filters = append(g.Filters, Filter{
+                    Feedforward: []float64{f.b0, f.b1, f.b2},
+                    Feedback:    []float64{1, f.a1, f.a2},
+                })
+            }
+        case *Balance:
+            g.Left, g.Right = float64(s.left), float64(s.right)
+        }
+    }
+    return g
+}
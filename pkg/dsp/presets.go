@@ -0,0 +1,56 @@
+package dsp
+
+import (
+	cfg "penguin-tunes/pkg/config"
+)
+
+// builtinGains holds the 10-band graphic gains (dB) of each built-in preset
+var builtinGains = []struct {
+    name   string
+    preamp float64
+    gains  [10]float64
+}{
+    {"Flat", 0, [10]float64{}},
+    {"Bass Boost", -4, [10]float64{6, 5, 4, 2, 0, 0, 0, 0, 0, 0}},
+    {"Treble Boost", -4, [10]float64{0, 0, 0, 0, 0, 1, 2, 4, 5, 6}},
+    {"Rock", -3, [10]float64{5, 4, 3, 1, -1, -1, 1, 3, 4, 5}},
+    {"Pop", -2, [10]float64{-1, 1, 3, 4, 3, 0, -1, -1, -1, -1}},
+    {"Jazz", -2, [10]float64{3, 2, 1, 2, -1, -1, 0, 1, 2, 3}},
+    {"Classical", -2, [10]float64{4, 3, 2, 1, 0, 0, 0, 1, 2, 3}},
+    {"Vocal", -2, [10]float64{-2, -2, -1, 1, 3, 3, 2, 1, 0, -1}},
+    {"Loudness", -4, [10]float64{5, 4, 2, 0, -1, 0, 0, 2, 4, 5}},
+}
+
+// BuiltinPresets returns the presets shipped with the app
+func BuiltinPresets() []cfg.EQPreset {
+    out := make([]cfg.EQPreset, 0, len(builtinGains))
+    for _, p := range builtinGains {
+        out = append(out, cfg.EQPreset{Name: p.name, Preamp: p.preamp, Bands: GraphicBands(p.gains)})
+    }
+    return out
+}
+
+// IsBuiltinPreset reports whether name refers to a built-in preset
+func IsBuiltinPreset(name string) bool {
+    for _, p := range builtinGains {
+        if p.name == name {
+            return true
+        }
+    }
+    return false
+}
+
+// FindPreset looks name up in built-in presets first, then in user presets
+func FindPreset(name string, user []cfg.EQPreset) (cfg.EQPreset, bool) {
+    for _, p := range BuiltinPresets() {
+        if p.Name == name {
+            return p, true
+        }
+    }
+    for _, p := range user {
+        if p.Name == name {
+            return p, true
+        }
+    }
+    return cfg.EQPreset{}, false
+}
@@ -0,0 +1,75 @@
+package dsp
+
+import "math"
+
+// resampleTaps is the number of input samples on each side of an output sample
+const resampleTaps = 8
+
+// resamplePhases is the resolution of the tabulated filter
+const resamplePhases = 256
+
+// Resample converts mono samples between sample rates with a Kaiser-windowed
+// sinc low-pass that removes content above the lower of the two Nyquist rates
+func Resample(in []float32, from, to int) []float32 {
+    if from == to || from <= 0 || to <= 0 {
+        return append([]float32(nil), in...)
+    }
+    ratio := float64(from) / float64(to)
+    cutoff := 0.9 * math.Min(1, 1/ratio) // relative to the input Nyquist rate
+    span := float64(resampleTaps) / cutoff
+    // the filter is tabulated at resamplePhases points per input sample and interpolated
+    table := make([]float64, int(span*resamplePhases)+2)
+    for k := range table {
+        x := float64(k) / resamplePhases
+        table[k] = sinc(cutoff*x) * kaiser(x/span, 8)
+    }
+    out := make([]float32, int(float64(len(in))/ratio))
+    for i := range out {
+        t := float64(i) * ratio
+        lo := max(int(math.Ceil(t-span)), 0)
+        hi := min(int(math.Floor(t+span)), len(in)-1)
+        var sum, norm float64
+        for j := lo; j <= hi; j++ {
+            pos := math.Abs(t-float64(j)) * resamplePhases
+            k := int(pos)
+            if k+1 >= len(table) {
+                continue
+            }
+            w := table[k] + (table[k+1]-table[k])*(pos-float64(k))
+            sum += float64(in[j]) * w
+            norm += w
+        }
+        if norm != 0 {
+            out[i] = float32(sum / norm)
+        }
+    }
+    return out
+}
+
+func sinc(x float64) float64 {
+    if x == 0 {
+        return 1
+    }
+    return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// kaiser evaluates a Kaiser window at x in [-1, 1]
+func kaiser(x, beta float64) float64 {
+    if x <= -1 || x >= 1 {
+        return 0
+    }
+    return bessel0(beta*math.Sqrt(1-x*x)) / bessel0(beta)
+}
+
+// bessel0 is the zeroth-order modified Bessel function of the first kind
+func bessel0(x float64) float64 {
+    sum, term := 1.0, 1.0
+    for k := 1; k < 50; k++ {
+        term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+        sum += term
+        if term < 1e-12*sum {
+            break
+        }
+    }
+    return sum
+}
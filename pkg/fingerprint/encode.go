@@ -0,0 +1,128 @@
+package fingerprint
+
+import (
+	"encoding/base64"
+	"fmt"
+)
+
+// chromaprint stores bit positions in 3-bit fields and spills larger gaps into 5-bit ones
+const (
+    normalBits     = 3
+    exceptionBits  = 5
+    maxNormalValue = 1<<normalBits - 1
+)
+
+// Encode compresses fp into the base64 string fpcalc prints and AcoustID accepts
+func Encode(fp Fingerprint) string {
+    var normal, exceptional []byte
+    var prev uint32
+    for _, v := range fp {
+        x := v ^ prev
+        prev = v
+        last := 0
+        for bit := 1; x != 0; bit, x = bit+1, x>>1 {
+            if x&1 == 0 {
+                continue
+            }
+            if d := bit - last; d >= maxNormalValue {
+                normal = append(normal, maxNormalValue)
+                exceptional = append(exceptional, byte(d-maxNormalValue))
+            } else {
+                normal = append(normal, byte(d))
+            }
+            last = bit
+        }
+        normal = append(normal, 0)
+    }
+    n := len(fp)
+    out := []byte{Algorithm, byte(n >> 16), byte(n >> 8), byte(n)}
+    out = pack(out, normal, normalBits)
+    out = pack(out, exceptional, exceptionBits)
+    return base64.RawURLEncoding.EncodeToString(out)
+}
+
+// Decode reverses Encode
+func Decode(s string) (Fingerprint, error) {
+    data, err := base64.RawURLEncoding.DecodeString(s)
+    if err != nil {
+        return nil, fmt.Errorf("fingerprint: %w", err)
+    }
+    if len(data) < 4 {
+        return nil, fmt.Errorf("fingerprint: truncated header")
+    }
+    if data[0] != Algorithm {
+        return nil, fmt.Errorf("fingerprint: unsupported algorithm %d", data[0])
+    }
+    n := int(data[1])<<16 | int(data[2])<<8 | int(data[3])
+    data = data[4:]
+
+    // 3-bit values run until n zero terminators have been seen
+    var normal []byte
+    for ends, pos := 0, 0; ends < n; pos++ {
+        if (pos+1)*normalBits > len(data)*8 {
+            return nil, fmt.Errorf("fingerprint: truncated data")
+        }
+        v := unpack(data, pos, normalBits)
+        if v == 0 {
+            ends++
+        }
+        normal = append(normal, v)
+    }
+    exceptions := data[(len(normal)*normalBits+7)/8:]
+    next := 0
+
+    fp := make(Fingerprint, 0, n)
+    var x, prev uint32
+    last := 0
+    for _, v := range normal {
+        if v == 0 {
+            prev ^= x
+            fp = append(fp, prev)
+            x, last = 0, 0
+            continue
+        }
+        d := int(v)
+        if v == maxNormalValue {
+            if (next+1)*exceptionBits > len(exceptions)*8 {
+                return nil, fmt.Errorf("fingerprint: truncated exceptions")
+            }
+            d += int(unpack(exceptions, next, exceptionBits))
+            next++
+        }
+        last += d
+        if last > 32 {
+            return nil, fmt.Errorf("fingerprint: bit position out of range")
+        }
+        x |= 1 << (last - 1)
+    }
+    return fp, nil
+}
+
+// pack appends width-bit values to out, least significant bits first
+func pack(out []byte, values []byte, width uint) []byte {
+    var acc uint32
+    var n uint
+    for _, v := range values {
+        acc |= uint32(v) << n
+        n += width
+        for n >= 8 {
+            out = append(out, byte(acc))
+            acc >>= 8
+            n -= 8
+        }
+    }
+    if n > 0 {
+        out = append(out, byte(acc))
+    }
+    return out
+}
+
+// unpack returns the i-th width-bit value packed by pack
+func unpack(data []byte, i int, width uint) byte {
+    bit := i * int(width)
+    v := uint32(data[bit/8])
+    if bit/8+1 < len(data) {
+        v |= uint32(data[bit/8+1]) << 8
+    }
+    return byte(v >> (bit % 8) & (1<<width - 1))
+}
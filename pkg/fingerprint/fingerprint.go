@@ -0,0 +1,193 @@
+package fingerprint
+
+import (
+	"math"
+
+	"penguin-tunes/pkg/dsp"
+)
+
+// Chromaprint parameters of the default algorithm (TEST2)
+const (
+    SampleRate = 11025
+    Algorithm  = 1
+    frameSize  = 4096
+    frameHop   = frameSize / 3
+    minFreq    = 28
+    maxFreq    = 3520
+    bands      = 12
+)
+
+// DefaultSeconds is how much audio fpcalc fingerprints by default
+const DefaultSeconds = 120
+
+// Fingerprint is a sequence of 32-bit sub-fingerprints, one every frameHop samples
+type Fingerprint []uint32
+
+var chromaFilter = []float64{0.25, 0.75, 1.0, 0.75, 0.25}
+
+type filter struct{ typ, y, height, width int }
+
+type quantizer struct{ t0, t1, t2 float64 }
+
+type classifier struct {
+    f filter
+    q quantizer
+}
+
+// classifiers are chromaprint's trained TEST2 filters, applied to the chroma image
+var classifiers = [16]classifier{
+    {filter{0, 4, 3, 15}, quantizer{1.98215, 2.35817, 2.63523}},
+    {filter{4, 4, 6, 15}, quantizer{-1.03809, -0.651211, -0.282167}},
+    {filter{1, 0, 4, 16}, quantizer{-0.298702, 0.119262, 0.558497}},
+    {filter{3, 8, 2, 12}, quantizer{-0.105439, 0.0153946, 0.135898}},
+    {filter{3, 4, 4, 8}, quantizer{-0.142891, 0.0258736, 0.200632}},
+    {filter{4, 0, 3, 5}, quantizer{-0.826319, -0.590612, -0.368214}},
+    {filter{1, 2, 2, 9}, quantizer{-0.557409, -0.233035, 0.0534525}},
+    {filter{2, 7, 3, 4}, quantizer{-0.0646826, 0.00620476, 0.0784847}},
+    {filter{2, 6, 2, 16}, quantizer{-0.192387, -0.029699, 0.215855}},
+    {filter{2, 1, 3, 2}, quantizer{-0.0397818, -0.00568076, 0.0292026}},
+    {filter{5, 10, 1, 15}, quantizer{-0.53823, -0.369934, -0.190235}},
+    {filter{3, 6, 2, 10}, quantizer{-0.124877, 0.0296483, 0.139239}},
+    {filter{2, 1, 1, 14}, quantizer{-0.101475, 0.0225617, 0.231971}},
+    {filter{3, 5, 6, 4}, quantizer{-0.0799915, -0.00729616, 0.063262}},
+    {filter{1, 9, 2, 12}, quantizer{-0.272556, 0.019424, 0.302559}},
+    {filter{3, 4, 2, 14}, quantizer{-0.164292, -0.0321188, 0.0846339}},
+}
+
+// maxFilterWidth is the number of chroma frames each sub-fingerprint looks at
+const maxFilterWidth = 16
+
+var grayCode = [4]uint32{0, 1, 3, 2}
+
+// Compute fingerprints mono samples at the given rate
+func Compute(samples []float32, rate int) Fingerprint {
+    x := dsp.Resample(samples, rate, SampleRate)
+    // chromaprint works on 16-bit samples, which its silence threshold assumes
+    for i := range x {
+        x[i] *= 32768
+    }
+    image := chromaImage(x)
+    if len(image) < maxFilterWidth {
+        return Fingerprint{}
+    }
+    integral := newIntegralImage(image)
+    fp := make(Fingerprint, len(image)-maxFilterWidth+1)
+    for i := range fp {
+        var bits uint32
+        for _, c := range classifiers {
+            bits = bits<<2 | grayCode[c.q.quantize(c.f.apply(integral, i))]
+        }
+        fp[i] = bits
+    }
+    return fp
+}
+
+// chromaImage returns the filtered and normalized 12-band chroma of each frame
+func chromaImage(x []float32) [][bands]float64 {
+    window := dsp.Hamming(frameSize)
+    minIndex := max(1, int(math.Round(frameSize*minFreq/float64(SampleRate))))
+    maxIndex := min(frameSize/2, int(math.Round(frameSize*maxFreq/float64(SampleRate))))
+    notes := make([]int, maxIndex)
+    for i := minIndex; i < maxIndex; i++ {
+        freq := float64(i) * SampleRate / frameSize
+        octave := math.Log2(freq / (440.0 / 16))
+        notes[i] = int(bands * (octave - math.Floor(octave)))
+    }
+
+    var raw [][bands]float64
+    for start := 0; start+frameSize <= len(x); start += frameHop {
+        power := dsp.PowerSpectrum(x[start:start+frameSize], window)
+        var row [bands]float64
+        for i := minIndex; i < maxIndex; i++ {
+            row[notes[i]] += power[i]
+        }
+        raw = append(raw, row)
+    }
+
+    var image [][bands]float64
+    for k := 0; k+len(chromaFilter) <= len(raw); k++ {
+        var row [bands]float64
+        for j, c := range chromaFilter {
+            for b := range row {
+                row[b] += c * raw[k+j][b]
+            }
+        }
+        var norm float64
+        for _, v := range row {
+            norm += v * v
+        }
+        norm = math.Sqrt(norm)
+        for b := range row {
+            if norm < 0.01 {
+                row[b] = 0
+            } else {
+                row[b] /= norm
+            }
+        }
+        image = append(image, row)
+    }
+    return image
+}
+
+// integralImage holds cumulative sums so any rectangle of the chroma image sums in constant time
+type integralImage [][bands + 1]float64
+
+func newIntegralImage(image [][bands]float64) integralImage {
+    ii := make(integralImage, len(image)+1)
+    for r, row := range image {
+        for c, v := range row {
+            ii[r+1][c+1] = v + ii[r][c+1] + ii[r+1][c] - ii[r][c]
+        }
+    }
+    return ii
+}
+
+// area sums frames [x1, x2) and bands [y1, y2)
+func (ii integralImage) area(x1, y1, x2, y2 int) float64 {
+    return ii[x2][y2] - ii[x1][y2] - ii[x2][y1] + ii[x1][y1]
+}
+
+func subtractLog(a, b float64) float64 {
+    return math.Log((1 + a) / (1 + b))
+}
+
+func (f filter) apply(ii integralImage, x int) float64 {
+    y, w, h := f.y, f.width, f.height
+    switch f.typ {
+    case 0:
+        return subtractLog(ii.area(x, y, x+w, y+h), 0)
+    case 1:
+        h2 := h / 2
+        return subtractLog(ii.area(x, y+h2, x+w, y+h), ii.area(x, y, x+w, y+h2))
+    case 2:
+        w2 := w / 2
+        return subtractLog(ii.area(x+w2, y, x+w, y+h), ii.area(x, y, x+w2, y+h))
+    case 3:
+        w2, h2 := w/2, h/2
+        a := ii.area(x, y+h2, x+w2, y+h) + ii.area(x+w2, y, x+w, y+h2)
+        b := ii.area(x, y, x+w2, y+h2) + ii.area(x+w2, y+h2, x+w, y+h)
+        return subtractLog(a, b)
+    case 4:
+        h3 := h / 3
+        a := ii.area(x, y+h3, x+w, y+2*h3)
+        b := ii.area(x, y, x+w, y+h3) + ii.area(x, y+2*h3, x+w, y+h)
+        return subtractLog(a, b)
+    default:
+        w3 := w / 3
+        a := ii.area(x+w3, y, x+2*w3, y+h)
+        b := ii.area(x, y, x+w3, y+h) + ii.area(x+2*w3, y, x+w, y+h)
+        return subtractLog(a, b)
+    }
+}
+
+func (q quantizer) quantize(v float64) int {
+    switch {
+    case v < q.t0:
+        return 0
+    case v < q.t1:
+        return 1
+    case v < q.t2:
+        return 2
+    }
+    return 3
+}
@@ -0,0 +1,85 @@
+package fingerprint
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// chords synthesizes seconds of audio at rate: a new random triad every half second
+func chords(seed int64, seconds float64, rate int) []float32 {
+    rng := rand.New(rand.NewSource(seed))
+    out := make([]float32, int(seconds*float64(rate)))
+    var freqs [3]float64
+    for i := range out {
+        if i%(rate/2) == 0 {
+            for k := range freqs {
+                freqs[k] = 110 * math.Pow(2, float64(rng.Intn(36))/12)
+            }
+        }
+        var v float64
+        for _, f := range freqs {
+            v += math.Sin(2 * math.Pi * f * float64(i) / float64(rate))
+        }
+        out[i] = float32(v / 4)
+    }
+    return out
+}
+
+func TestEncodeDecode(t *testing.T) {
+    rng := rand.New(rand.NewSource(1))
+    fp := make(Fingerprint, 500)
+    for i := range fp {
+        fp[i] = rng.Uint32()
+        if i%7 == 0 {
+            fp[i] = 0x80000001 // gaps wider than the 3-bit fields
+        }
+    }
+    dec, err := Decode(Encode(fp))
+    if err != nil {
+        t.Fatalf("Decode: %v", err)
+    }
+    if len(dec) != len(fp) {
+        t.Fatalf("decoded %d values, want %d", len(dec), len(fp))
+    }
+    for i := range fp {
+        if dec[i] != fp[i] {
+            t.Fatalf("value %d = %08x, want %08x", i, dec[i], fp[i])
+        }
+    }
+    if got, err := Decode(Encode(Fingerprint{})); err != nil || len(got) != 0 {
+        t.Fatalf("empty round trip = %v, %v", got, err)
+    }
+    if _, err := Decode("AQAAEA"); err == nil {
+        t.Fatalf("truncated fingerprint decoded without error")
+    }
+}
+
+func TestSimilarity(t *testing.T) {
+    a := Compute(chords(1, 20, 44100), 44100)
+    if len(a) < 100 {
+        t.Fatalf("fingerprint has %d values", len(a))
+    }
+    // the same music at another rate and level, starting a little later
+    other := chords(1, 21, 22050)
+    for i := range other {
+        other[i] *= 0.5
+    }
+    b := Compute(other[22050/2:], 22050)
+    c := Compute(chords(2, 20, 44100), 44100)
+
+    if s := Similarity(a, b, DefaultMaxOffset); s < 0.9 {
+        t.Fatalf("similarity of the same audio = %.3f", s)
+    }
+    if s := Similarity(a, c, DefaultMaxOffset); s >= DefaultThreshold {
+        t.Fatalf("similarity of different audio = %.3f", s)
+    }
+
+    m := NewMatcher()
+    m.Add("a", a)
+    m.Add("c", c)
+    got := m.Search(b, DefaultThreshold)
+    if len(got) != 1 || got[0].ID != "a" {
+        t.Fatalf("Search = %+v, want only a", got)
+    }
+}
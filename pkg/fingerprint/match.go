@@ -0,0 +1,116 @@
+package fingerprint
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// DefaultMaxOffset is how far in sub-fingerprints (about 12 s) two fingerprints are shifted when aligning them
+const DefaultMaxOffset = 100
+
+// DefaultThreshold is the similarity at which two fingerprints are taken to be the same recording
+const DefaultThreshold = 0.8
+
+// minOverlap is the fewest aligned sub-fingerprints (about 4 s) a score is computed from
+const minOverlap = 32
+
+// Similarity aligns a and b within maxOffset sub-fingerprints and returns the
+// share of equal bits at the best alignment, from about 0.5 for unrelated
+// audio to 1 for identical audio
+func Similarity(a, b Fingerprint, maxOffset int) float64 {
+    best := 0.0
+    for off := -maxOffset; off <= maxOffset; off++ {
+        if s, ok := score(a, b, off); ok && s > best {
+            best = s
+        }
+    }
+    return best
+}
+
+// score compares a[i] with b[i+off]
+func score(a, b Fingerprint, off int) (float64, bool) {
+    start := max(0, -off)
+    end := min(len(a), len(b)-off)
+    if end-start < min(minOverlap, len(a), len(b)) || end <= start {
+        return 0, false
+    }
+    errs := 0
+    for i := start; i < end; i++ {
+        errs += bits.OnesCount32(a[i] ^ b[i+off])
+    }
+    return 1 - float64(errs)/float64(32*(end-start)), true
+}
+
+// Match is a library entry similar to a query
+type Match struct {
+    ID    string  `json:"id"`
+    Score float64 `json:"score"`
+}
+
+// Matcher finds similar fingerprints among many without comparing every pair.
+// Candidates are entries sharing a sub-fingerprint's top keyBits bits with the
+// query; they are then scored with Similarity.
+type Matcher struct {
+    ids   []string
+    fps   []Fingerprint
+    index map[uint32][]int
+}
+
+// keyBits of each sub-fingerprint are indexed; the low bits are the noisiest
+const keyBits = 20
+
+// NewMatcher returns an empty matcher
+func NewMatcher() *Matcher {
+    return &Matcher{index: make(map[uint32][]int)}
+}
+
+// Add indexes fp under id
+func (m *Matcher) Add(id string, fp Fingerprint) {
+    n := len(m.ids)
+    m.ids = append(m.ids, id)
+    m.fps = append(m.fps, fp)
+    seen := make(map[uint32]bool)
+    for _, v := range fp {
+        k := v >> (32 - keyBits)
+        if !seen[k] {
+            seen[k] = true
+            m.index[k] = append(m.index[k], n)
+        }
+    }
+}
+
+// Len returns the number of indexed fingerprints
+func (m *Matcher) Len() int { return len(m.ids) }
+
+// Search returns the entries at least threshold similar to fp, best first
+func (m *Matcher) Search(fp Fingerprint, threshold float64) []Match {
+    hits := make(map[int]int)
+    seen := make(map[uint32]bool)
+    for _, v := range fp {
+        k := v >> (32 - keyBits)
+        if seen[k] {
+            continue
+        }
+        seen[k] = true
+        for _, n := range m.index[k] {
+            hits[n]++
+        }
+    }
+    matches := []Match{}
+    for n, count := range hits {
+        // a single shared key is usually chance, unless the fingerprints are tiny
+        if count < 2 && len(fp) > minOverlap {
+            continue
+        }
+        if s := Similarity(fp, m.fps[n], DefaultMaxOffset); s >= threshold {
+            matches = append(matches, Match{ID: m.ids[n], Score: s})
+        }
+    }
+    sort.Slice(matches, func(i, j int) bool {
+        if matches[i].Score != matches[j].Score {
+            return matches[i].Score > matches[j].Score
+        }
+        return matches[i].ID < matches[j].ID
+    })
+    return matches
+}
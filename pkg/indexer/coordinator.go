@@ -0,0 +1,214 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ScanState is what a ScanCoordinator is doing
+type ScanState string
+
+const (
+    ScanIdle     ScanState = "idle"
+    ScanScanning ScanState = "scanning"
+    // ScanQueued means dirs wait for the running scan, or for Run to pick them up
+    ScanQueued ScanState = "queued"
+)
+
+// ScanStatus reports a coordinator's state with the dirs being scanned and
+// those waiting
+type ScanStatus struct {
+    State    ScanState `json:"state"`
+    Scanning []string  `json:"scanning"`
+    Queued   []string  `json:"queued"`
+}
+
+// ScanCoordinator runs one scan at a time. Requests made meanwhile are
+// merged into a single follow-up scan, and a running scan is cancelled when
+// the queued one covers all its dirs, as it would be redone anyway.
+type ScanCoordinator struct {
+    scan func(ctx context.Context, dirs []string) error
+    // OnStatus is called with every change of state when set
+    OnStatus func(ScanStatus)
+
+    mtx     sync.Mutex
+    pending []string
+    running []string
+    cancel  context.CancelFunc
+    wake    chan struct{}
+    log     *slog.Logger
+    // notifyMtx orders calls of OnStatus
+    notifyMtx sync.Mutex
+}
+
+// NewScanCoordinator creates a coordinator that scans with scan, which must
+// stop when its ctx is cancelled
+func NewScanCoordinator(scan func(ctx context.Context, dirs []string) error) *ScanCoordinator {
+    return &ScanCoordinator{scan: scan, wake: make(chan struct{}, 1), log: slog.Default()}
+}
+
+// SetLogger sets where failed scans are logged; call it before Run
+func (c *ScanCoordinator) SetLogger(l *slog.Logger) {
+    c.log = l
+}
+
+// Request queues dirs for scanning. Dirs already queued, or below a queued
+// dir, are scanned once.
+func (c *ScanCoordinator) Request(dirs ...string) {
+    if len(dirs) == 0 {
+        return
+    }
+    c.mtx.Lock()
+    c.pending = mergeDirs(c.pending, dirs)
+    if c.running != nil && coversDirs(c.pending, c.running) {
+        // superseded: the queued scan redoes everything this one would
+        c.cancel()
+    }
+    c.mtx.Unlock()
+    c.signal()
+    c.notify()
+}
+
+// Retain drops queued dirs outside roots, e.g. after a source dir was
+// removed. A running scan of such a dir is cancelled and its other dirs
+// are queued again.
+func (c *ScanCoordinator) Retain(roots []string) {
+    c.mtx.Lock()
+    c.pending = keepDirs(c.pending, roots)
+    if c.running != nil && len(keepDirs(c.running, roots)) < len(c.running) {
+        c.pending = mergeDirs(c.pending, keepDirs(c.running, roots))
+        c.cancel()
+    }
+    c.mtx.Unlock()
+    c.signal()
+    c.notify()
+}
+
+// Status returns the current state
+func (c *ScanCoordinator) Status() ScanStatus {
+    c.mtx.Lock()
+    defer c.mtx.Unlock()
+    return c.statusLocked()
+}
+
+func (c *ScanCoordinator) statusLocked() ScanStatus {
+    st := ScanStatus{
+        State:    ScanIdle,
+        Scanning: append([]string{}, c.running...),
+        Queued:   append([]string{}, c.pending...),
+    }
+    switch {
+    case len(c.pending) > 0:
+        st.State = ScanQueued
+    case c.running != nil:
+        st.State = ScanScanning
+    }
+    return st
+}
+
+// Run scans the requested dirs until ctx is cancelled
+func (c *ScanCoordinator) Run(ctx context.Context) error {
+    for {
+        select {
+        case <-ctx.Done():
+            return ctx.Err()
+        case <-c.wake:
+        }
+        for {
+            c.mtx.Lock()
+            dirs := c.pending
+            if len(dirs) == 0 || ctx.Err() != nil {
+                c.mtx.Unlock()
+                break
+            }
+            scanCtx, cancel := context.WithCancel(ctx)
+            c.pending, c.running, c.cancel = nil, dirs, cancel
+            c.mtx.Unlock()
+            c.notify()
+
+            err := c.scan(scanCtx, dirs)
+            cancel()
+            if err != nil && !errors.Is(err, context.Canceled) {
+                c.log.Error("scan failed", "dirs", dirs, "err", err)
+            }
+            c.mtx.Lock()
+            c.running, c.cancel = nil, nil
+            c.mtx.Unlock()
+            c.notify()
+        }
+    }
+}
+
+func (c *ScanCoordinator) signal() {
+    select {
+    case c.wake <- struct{}{}:
+    default:
+    }
+}
+
+// notify reports the state as of the call; notifications are serialized so
+// the last one holds the latest state
+func (c *ScanCoordinator) notify() {
+    if c.OnStatus == nil {
+        return
+    }
+    c.notifyMtx.Lock()
+    defer c.notifyMtx.Unlock()
+    c.OnStatus(c.Status())
+}
+
+// underDir reports whether path is dir or inside it
+func underDir(path, dir string) bool {
+    return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// mergeDirs adds dirs to set, keeping only the outermost of nested dirs
+func mergeDirs(set, dirs []string) []string {
+    var out []string
+    for _, d := range append(append([]string{}, set...), dirs...) {
+        d = filepath.Clean(d)
+        covered := false
+        kept := out[:0]
+        for _, o := range out {
+            if underDir(d, o) {
+                covered = true
+            }
+            if covered || !underDir(o, d) {
+                kept = append(kept, o)
+            }
+        }
+        out = kept
+        if !covered {
+            out = append(out, d)
+        }
+    }
+    return out
+}
+
+// coversDirs reports whether every dir of b is inside a dir of a
+func coversDirs(a, b []string) bool {
+    for _, d := range b {
+        if len(keepDirs([]string{d}, a)) == 0 {
+            return false
+        }
+    }
+    return true
+}
+
+// keepDirs returns the dirs inside one of roots
+func keepDirs(dirs, roots []string) []string {
+    var out []string
+    for _, d := range dirs {
+        for _, r := range roots {
+            if underDir(d, filepath.Clean(r)) {
+                out = append(out, d)
+                break
+            }
+        }
+    }
+    return out
+}
@@ -0,0 +1,154 @@
+package indexer
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeScans records the scans a coordinator runs; each blocks until released
+// or cancelled
+type fakeScans struct {
+    mtx     sync.Mutex
+    calls   [][]string
+    started chan []string
+    release chan struct{}
+    ended   chan error
+}
+
+func newFakeScans() *fakeScans {
+    return &fakeScans{started: make(chan []string, 8), release: make(chan struct{}), ended: make(chan error, 8)}
+}
+
+func (f *fakeScans) scan(ctx context.Context, dirs []string) error {
+    f.mtx.Lock()
+    f.calls = append(f.calls, dirs)
+    f.mtx.Unlock()
+    f.started <- dirs
+    var err error
+    select {
+    case <-f.release:
+    case <-ctx.Done():
+        err = ctx.Err()
+    }
+    f.ended <- err
+    return err
+}
+
+func (f *fakeScans) next(t *testing.T) []string {
+    t.Helper()
+    select {
+    case dirs := <-f.started:
+        return dirs
+    case <-time.After(3 * time.Second):
+        t.Fatalf("timed out waiting for a scan")
+    }
+    return nil
+}
+
+func (f *fakeScans) end(t *testing.T) error {
+    t.Helper()
+    select {
+    case err := <-f.ended:
+        return err
+    case <-time.After(3 * time.Second):
+        t.Fatalf("timed out waiting for a scan to end")
+    }
+    return nil
+}
+
+func startCoordinator(t *testing.T, f *fakeScans) *ScanCoordinator {
+    c := NewScanCoordinator(f.scan)
+    ctx, cancel := context.WithCancel(context.Background())
+    done := make(chan struct{})
+    go func() {
+        c.Run(ctx)
+        close(done)
+    }()
+    t.Cleanup(func() {
+        cancel()
+        <-done
+    })
+    return c
+}
+
+func TestScanRequestsMergeWhileScanning(t *testing.T) {
+    a, b, x := filepath.FromSlash("/m/a"), filepath.FromSlash("/m/b"), filepath.FromSlash("/x")
+    f := newFakeScans()
+    c := startCoordinator(t, f)
+    c.Request(x)
+    if got := f.next(t); !reflect.DeepEqual(got, []string{x}) {
+        t.Fatalf("unexpected first scan %v", got)
+    }
+    c.Request(a)
+    c.Request(b)
+    c.Request(filepath.Join(a, "sub"))
+    st := c.Status()
+    if st.State != ScanQueued || !reflect.DeepEqual(st.Scanning, []string{x}) || !reflect.DeepEqual(st.Queued, []string{a, b}) {
+        t.Fatalf("unexpected status %+v", st)
+    }
+    f.release <- struct{}{}
+    if err := f.end(t); err != nil {
+        t.Fatalf("the first scan should finish, got %v", err)
+    }
+    if got := f.next(t); !reflect.DeepEqual(got, []string{a, b}) {
+        t.Fatalf("expected one merged scan, got %v", got)
+    }
+    f.release <- struct{}{}
+    f.end(t)
+    deadline := time.Now().Add(3 * time.Second)
+    for c.Status().State != ScanIdle {
+        if time.Now().After(deadline) {
+            t.Fatalf("expected idle, got %+v", c.Status())
+        }
+        time.Sleep(5 * time.Millisecond)
+    }
+    if len(f.calls) != 2 {
+        t.Fatalf("expected 2 scans, got %v", f.calls)
+    }
+}
+
+func TestSupersededScanIsCancelled(t *testing.T) {
+    root := filepath.FromSlash("/m")
+    f := newFakeScans()
+    c := startCoordinator(t, f)
+    c.Request(filepath.Join(root, "new"))
+    f.next(t)
+    // a full rescan covers the running one
+    c.Request(root)
+    if err := f.end(t); err != context.Canceled {
+        t.Fatalf("expected the running scan to be cancelled, got %v", err)
+    }
+    if got := f.next(t); !reflect.DeepEqual(got, []string{root}) {
+        t.Fatalf("unexpected follow-up scan %v", got)
+    }
+    f.release <- struct{}{}
+}
+
+func TestRetainDropsRemovedRoots(t *testing.T) {
+    a, b := filepath.FromSlash("/m/a"), filepath.FromSlash("/m/b")
+    f := newFakeScans()
+    c := startCoordinator(t, f)
+    c.Request(a, b)
+    f.next(t)
+    c.Retain([]string{b})
+    if err := f.end(t); err != context.Canceled {
+        t.Fatalf("expected the scan of a removed root to be cancelled, got %v", err)
+    }
+    if got := f.next(t); !reflect.DeepEqual(got, []string{b}) {
+        t.Fatalf("expected only the kept root rescanned, got %v", got)
+    }
+    f.release <- struct{}{}
+}
+
+func TestMergeDirs(t *testing.T) {
+    p := filepath.FromSlash
+    got := mergeDirs([]string{p("/m/a/x"), p("/n")}, []string{p("/m/a"), p("/n/y"), p("/m/ab"), p("/n")})
+    want := []string{p("/n"), p("/m/a"), p("/m/ab")}
+    if !reflect.DeepEqual(got, want) {
+        t.Fatalf("expected %v, got %v", want, got)
+    }
+}
@@ -0,0 +1,180 @@
+package indexer
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"penguin-tunes/pkg/cue"
+)
+
+// EmbeddedCue is the Cue of tracks cut by a CUESHEET tag rather than a .cue file
+const EmbeddedCue = "embedded"
+
+// IsCueFile reports whether path is a CUE sheet
+func IsCueFile(path string) bool {
+    return strings.EqualFold(filepath.Ext(path), ".cue")
+}
+
+// readTracks reads the file at path and returns its track, or the virtual
+// tracks a CUE sheet cuts it into
+func readTracks(path string, cfgDir string, opts ScanOptions) ([]*Track, error) {
+    t, embedded, err := readMetadata(path, cfgDir, opts)
+    if err != nil {
+        return nil, err
+    }
+    if sheet, file, src := findCue(path, embedded); file != nil {
+        if tracks := cueTracks(t, sheet, file, src); len(tracks) > 0 {
+            return tracks, nil
+        }
+    }
+    return []*Track{t}, nil
+}
+
+// audioBeside returns the audio files next to the sheet at path that it may describe
+func audioBeside(path string) []string {
+    dir := filepath.Dir(path)
+    stem := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+    sheet, _ := cue.ReadFile(path)
+    entries, _ := os.ReadDir(dir)
+    var out []string
+    for _, e := range entries {
+        p := filepath.Join(dir, e.Name())
+        if e.IsDir() || !isAudioFile(p) {
+            continue
+        }
+        if strings.TrimSuffix(e.Name(), filepath.Ext(e.Name())) == stem || e.Name() == stem || (sheet != nil && sheet.FileFor(p) != nil) {
+            out = append(out, p)
+        }
+    }
+    return out
+}
+
+// cutBy returns the files with tracks cut by the sheet at cuePath
+func (idx *Index) cutBy(cuePath string) []string {
+    idx.mtx.RLock()
+    defer idx.mtx.RUnlock()
+    seen := make(map[string]bool)
+    var out []string
+    for _, t := range idx.Tracks {
+        if t.Cue == cuePath && !seen[t.Path] {
+            seen[t.Path] = true
+            out = append(out, t.Path)
+        }
+    }
+    sort.Strings(out)
+    return out
+}
+
+// findCue returns the sheet describing the audio file at path and its source.
+// A .cue file beside the audio wins over a CUESHEET tag, since it is the one
+// users edit. A sheet named after the audio may call the file anything as long
+// as it lists only one; other sheets in the folder must name it.
+func findCue(path string, embedded string) (*cue.Sheet, *cue.File, string) {
+    dir := filepath.Dir(path)
+    stem := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+    entries, _ := os.ReadDir(dir)
+    var named, others []string
+    for _, e := range entries {
+        name := e.Name()
+        if e.IsDir() || !IsCueFile(name) {
+            continue
+        }
+        n := strings.TrimSuffix(name, filepath.Ext(name))
+        if n == stem || n == filepath.Base(path) {
+            named = append(named, filepath.Join(dir, name))
+        } else {
+            others = append(others, filepath.Join(dir, name))
+        }
+    }
+    sort.Strings(named)
+    sort.Strings(others)
+    for i, p := range append(named, others...) {
+        sheet, err := cue.ReadFile(p)
+        if err != nil {
+            continue
+        }
+        f := sheet.FileFor(path)
+        if f == nil && i < len(named) && len(sheet.Files) == 1 {
+            f = &sheet.Files[0]
+        }
+        if f != nil && len(f.Tracks) > 0 {
+            return sheet, f, p
+        }
+    }
+    if strings.TrimSpace(embedded) != "" {
+        // an embedded sheet can only describe the file holding it
+        if sheet, err := cue.Parse(embedded); err == nil && len(sheet.Files) > 0 && len(sheet.Files[0].Tracks) > 0 {
+            return sheet, &sheet.Files[0], EmbeddedCue
+        }
+    }
+    return nil, nil, ""
+}
+
+// cueTracks cuts parent into the tracks f lists. Sheet values take precedence
+// over the file's tags, which fill in what the sheet leaves out. Tracks with
+// an invalid number, a number used before or a start past the end are left
+// out; the audio they would have held belongs to the track before them.
+func cueTracks(parent *Track, sheet *cue.Sheet, f *cue.File, src string) []*Track {
+    kept := make([]cue.Track, 0, len(f.Tracks))
+    seen := make(map[int]bool)
+    for _, ct := range f.Tracks {
+        if ct.Number < 1 || ct.Number > maxCueTracks || seen[ct.Number] || (parent.Duration > 0 && ct.Start >= parent.Duration) {
+            continue
+        }
+        // IDs are made from the number, so a repeated one would clash
+        seen[ct.Number] = true
+        kept = append(kept, ct)
+    }
+    tracks := make([]*Track, 0, len(kept))
+    for i, ct := range kept {
+        t := *parent
+        t.Cue, t.Start, t.End = src, ct.Start, 0
+        t.TrackNumber = ct.Number
+        t.ID = idFromPath(parent.Path + "#" + strconv.Itoa(ct.Number))
+        // results for the whole file don't describe a part of it
+        t.Fingerprint, t.BPM, t.BPMConfidence, t.Key, t.KeyConfidence = "", 0, 0, "", 0
+        t.Rating, t.HasLyrics = 0, false
+        if i+1 < len(kept) {
+            t.End = kept[i+1].Start
+            t.Duration = t.End - t.Start
+        } else if parent.Duration > 0 {
+            t.Duration = parent.Duration - t.Start
+        }
+        t.Title = firstOf(ct.Title, "Track "+strconv.Itoa(ct.Number))
+        t.Artist = firstOf(ct.Performer, sheet.Performer, parent.Artist)
+        t.AlbumArtist = firstOf(sheet.Performer, parent.AlbumArtist)
+        t.Album = firstOf(sheet.Title, parent.Album)
+        t.Composer = firstOf(ct.Songwriter, sheet.Songwriter, parent.Composer)
+        t.Genre = firstOf(sheet.Genre, parent.Genre)
+        if y := yearOf(sheet.Date); y > 0 {
+            t.Year = y
+        }
+        if sheet.Disc > 0 {
+            t.DiscNumber = sheet.Disc
+        }
+        tracks = append(tracks, &t)
+    }
+    return tracks
+}
+
+// yearOf reads the year from a REM DATE, which is usually a bare year but may be a full date
+func yearOf(date string) int {
+    date = strings.TrimSpace(date)
+    if len(date) < 4 {
+        return 0
+    }
+    y, _ := strconv.Atoi(date[:4])
+    return y
+}
+
+func firstOf(vals ...string) string {
+    for _, v := range vals {
+        if v != "" {
+            return v
+        }
+    }
+    return ""
+}
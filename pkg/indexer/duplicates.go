@@ -0,0 +1,289 @@
+package indexer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"penguin-tunes/pkg/fingerprint"
+)
+
+// Duplicate match tiers, strongest first
+const (
+    MatchExact    = "exact"    // byte-identical files
+    MatchAudio    = "audio"    // the same audio data with different tags
+    MatchAcoustic = "acoustic" // similar fingerprints, such as re-encodes of one recording
+    MatchTags     = "tags"     // same artist and title with a similar duration
+)
+
+var matchTiers = []string{MatchExact, MatchAudio, MatchAcoustic, MatchTags}
+
+// DefaultDurationTolerance is the duration difference in seconds accepted for tag matches
+const DefaultDurationTolerance = 2.0
+
+// DuplicateOptions selects the tiers FindDuplicates uses
+type DuplicateOptions struct {
+    // Tiers lists the match tiers to run; all of them when empty
+    Tiers []string `json:"tiers"`
+    // Tolerance overrides DefaultDurationTolerance when positive
+    Tolerance float64 `json:"tolerance"`
+    // Similarity overrides fingerprint.DefaultThreshold for the acoustic tier when positive
+    Similarity float64 `json:"similarity"`
+}
+
+// DuplicateGroup is a set of copies of one song. Match is the weakest tier
+// needed to connect them and Best the ID of the copy suggested for keeping.
+type DuplicateGroup struct {
+    Match  string   `json:"match"`
+    Tracks []*Track `json:"tracks"`
+    Best   string   `json:"best"`
+}
+
+// FindDuplicates groups indexed tracks that are copies of each other. Files
+// that can't be read and tracks cut from a larger file by a CUE sheet are
+// left out of the byte and audio tiers.
+func (idx *Index) FindDuplicates(opts DuplicateOptions) ([]DuplicateGroup, error) {
+    tiers := make(map[string]bool)
+    for _, t := range opts.Tiers {
+        known := false
+        for _, m := range matchTiers {
+            known = known || m == t
+        }
+        if !known {
+            return nil, fmt.Errorf("unknown duplicate tier %q", t)
+        }
+        tiers[t] = true
+    }
+    if len(tiers) == 0 {
+        for _, m := range matchTiers {
+            tiers[m] = true
+        }
+    }
+    tolerance := opts.Tolerance
+    if tolerance <= 0 {
+        tolerance = DefaultDurationTolerance
+    }
+    similarity := opts.Similarity
+    if similarity <= 0 {
+        similarity = fingerprint.DefaultThreshold
+    }
+
+    tracks := idx.GetAll()
+    sort.Slice(tracks, func(i, j int) bool { return tracks[i].Path < tracks[j].Path })
+    infos := make([]AudioInfo, len(tracks))
+    for i, t := range tracks {
+        infos[i] = AudioInfo{Codec: t.Codec, Duration: t.Duration, Bitrate: t.Bitrate}
+        if t.Codec == "" {
+            // indexed before probing existed
+            infos[i], _, _ = probeAudio(t.Path)
+        }
+    }
+    g := newGrouping(len(tracks))
+    if tiers[MatchExact] {
+        bySize := make(map[string][]int)
+        for i, t := range tracks {
+            if t.Virtual() {
+                continue
+            }
+            if fi, err := os.Stat(t.Path); err == nil {
+                k := fmt.Sprint(fi.Size())
+                bySize[k] = append(bySize[k], i)
+            }
+        }
+        g.linkByHash(bySize, 0, func(i int) (string, error) {
+            return hashSpans(tracks[i].Path, nil)
+        })
+    }
+    if tiers[MatchAudio] {
+        // equal audio implies the same codec and exact duration, so only those are hashed
+        byStream := make(map[string][]int)
+        for i, info := range infos {
+            if info.Duration > 0 && !tracks[i].Virtual() {
+                k := fmt.Sprintf("%s|%.6f", info.Codec, info.Duration)
+                byStream[k] = append(byStream[k], i)
+            }
+        }
+        g.linkByHash(byStream, 1, func(i int) (string, error) {
+            _, spans, err := probeAudio(tracks[i].Path)
+            if err != nil {
+                return "", err
+            }
+            return hashSpans(tracks[i].Path, spans)
+        })
+    }
+    if tiers[MatchAcoustic] {
+        // only tracks the analysis pass has fingerprinted take part
+        m := fingerprint.NewMatcher()
+        fps := make(map[int]fingerprint.Fingerprint)
+        pos := make(map[string]int)
+        for i, t := range tracks {
+            if t.Fingerprint == "" {
+                continue
+            }
+            if fp, err := fingerprint.Decode(t.Fingerprint); err == nil && len(fp) > 0 {
+                fps[i] = fp
+                pos[t.ID] = i
+                m.Add(t.ID, fp)
+            }
+        }
+        for i, fp := range fps {
+            for _, match := range m.Search(fp, similarity) {
+                if j := pos[match.ID]; j != i {
+                    g.union(i, j, 2)
+                }
+            }
+        }
+    }
+    if tiers[MatchTags] {
+        byName := make(map[string][]int)
+        for i, t := range tracks {
+            artist, title := normalizeTag(t.Artist), normalizeTag(t.Title)
+            if artist == "" || title == "" || t.Artist == "Unknown Artist" || infos[i].Duration <= 0 {
+                continue
+            }
+            byName[artist+"\x00"+title] = append(byName[artist+"\x00"+title], i)
+        }
+        for _, members := range byName {
+            sort.Slice(members, func(a, b int) bool { return infos[members[a]].Duration < infos[members[b]].Duration })
+            for k := 1; k < len(members); k++ {
+                if infos[members[k]].Duration-infos[members[k-1]].Duration <= tolerance {
+                    g.union(members[k-1], members[k], 3)
+                }
+            }
+        }
+    }
+
+    byRoot := make(map[int][]int)
+    for i := range tracks {
+        r := g.find(i)
+        byRoot[r] = append(byRoot[r], i)
+    }
+    groups := []DuplicateGroup{}
+    for r, members := range byRoot {
+        if len(members) < 2 {
+            continue
+        }
+        group := DuplicateGroup{Match: matchTiers[g.level[r]]}
+        best := members[0]
+        for _, i := range members {
+            group.Tracks = append(group.Tracks, tracks[i])
+            if betterCopy(tracks[i], infos[i], tracks[best], infos[best]) {
+                best = i
+            }
+        }
+        group.Best = tracks[best].ID
+        groups = append(groups, group)
+    }
+    sort.Slice(groups, func(i, j int) bool { return groups[i].Tracks[0].Path < groups[j].Tracks[0].Path })
+    return groups, nil
+}
+
+// grouping is a union-find over track positions that remembers the weakest tier of each set
+type grouping struct {
+    parent []int
+    level  []int
+}
+
+func newGrouping(n int) *grouping {
+    g := &grouping{parent: make([]int, n), level: make([]int, n)}
+    for i := range g.parent {
+        g.parent[i] = i
+    }
+    return g
+}
+
+func (g *grouping) find(i int) int {
+    for g.parent[i] != i {
+        g.parent[i] = g.parent[g.parent[i]]
+        i = g.parent[i]
+    }
+    return i
+}
+
+func (g *grouping) union(a, b, level int) {
+    ra, rb := g.find(a), g.find(b)
+    if ra == rb {
+        return
+    }
+    g.parent[rb] = ra
+    g.level[ra] = max(g.level[ra], g.level[rb], level)
+}
+
+// linkByHash hashes the members of every candidate bucket with more than one entry and links equal hashes
+func (g *grouping) linkByHash(buckets map[string][]int, level int, hash func(i int) (string, error)) {
+    for _, members := range buckets {
+        if len(members) < 2 {
+            continue
+        }
+        seen := make(map[string]int)
+        for _, i := range members {
+            h, err := hash(i)
+            if err != nil {
+                continue
+            }
+            if j, ok := seen[h]; ok {
+                g.union(j, i, level)
+            } else {
+                seen[h] = i
+            }
+        }
+    }
+}
+
+// hashSpans returns the SHA-256 of the given byte ranges of path, or of the whole file when spans is nil
+func hashSpans(path string, spans []span) (string, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return "", err
+    }
+    defer f.Close()
+    h := sha256.New()
+    if spans == nil {
+        if _, err := io.Copy(h, f); err != nil {
+            return "", err
+        }
+    }
+    for _, s := range spans {
+        if _, err := io.Copy(h, io.NewSectionReader(f, s.start, s.end-s.start)); err != nil {
+            return "", err
+        }
+    }
+    return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// normalizeTag folds case and punctuation so "The Beatles" and "beatles" compare equal
+func normalizeTag(s string) string {
+    s = strings.Map(func(r rune) rune {
+        if unicode.IsLetter(r) || unicode.IsDigit(r) {
+            return unicode.ToLower(r)
+        }
+        return ' '
+    }, s)
+    s = strings.Join(strings.Fields(s), " ")
+    return strings.TrimPrefix(s, "the ")
+}
+
+var losslessCodecs = map[string]bool{"flac": true, "alac": true, "pcm": true, "wav": true}
+
+// codecRank orders lossy codecs by efficiency for equal bitrates
+var codecRank = map[string]int{"opus": 4, "aac": 3, "vorbis": 2, "mp3": 1}
+
+// betterCopy reports whether a should be kept over b: lossless first, then
+// higher bitrate, then the more efficient codec, then the higher rating
+func betterCopy(a *Track, ai AudioInfo, b *Track, bi AudioInfo) bool {
+    if la, lb := losslessCodecs[ai.Codec], losslessCodecs[bi.Codec]; la != lb {
+        return la
+    }
+    if ai.Bitrate != bi.Bitrate {
+        return ai.Bitrate > bi.Bitrate
+    }
+    if ca, cb := codecRank[ai.Codec], codecRank[bi.Codec]; ca != cb {
+        return ca > cb
+    }
+    return a.Rating > b.Rating
+}
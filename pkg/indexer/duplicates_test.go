@@ -0,0 +1,119 @@
+package indexer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// mp3File builds an ID3v2.3 tag with title and artist followed by n silent 128 kbit/s frames
+func mp3File(title, artist string, n int) []byte {
+    var frames bytes.Buffer
+    for _, f := range [][2]string{{"TIT2", title}, {"TPE1", artist}} {
+        frames.WriteString(f[0])
+        binary.Write(&frames, binary.BigEndian, uint32(len(f[1])+1))
+        frames.Write([]byte{0, 0, 0})
+        frames.WriteString(f[1])
+    }
+    size := frames.Len()
+    var b bytes.Buffer
+    b.WriteString("ID3\x03\x00\x00")
+    b.Write([]byte{byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)})
+    b.Write(frames.Bytes())
+    frame := make([]byte, 417)
+    copy(frame, []byte{0xff, 0xfb, 0x90, 0x00})
+    for i := 0; i < n; i++ {
+        b.Write(frame)
+    }
+    return b.Bytes()
+}
+
+// flacFile builds a FLAC file with the given tags, length and some stand-in audio
+func flacFile(title, artist string, seconds float64) []byte {
+    var b bytes.Buffer
+    b.WriteString("fLaC")
+    info := make([]byte, 34)
+    rate := 44100
+    total := uint64(seconds * float64(rate))
+    info[10], info[11], info[12] = byte(rate>>12), byte(rate>>4), byte(rate<<4)|0x02
+    info[13] = 0xf0 | byte(total>>32&0x0f)
+    binary.BigEndian.PutUint32(info[14:], uint32(total))
+    b.Write([]byte{0, 0, 0, byte(len(info))})
+    b.Write(info)
+    var vc bytes.Buffer
+    binary.Write(&vc, binary.LittleEndian, uint32(0))
+    binary.Write(&vc, binary.LittleEndian, uint32(2))
+    for _, c := range []string{"TITLE=" + title, "ARTIST=" + artist} {
+        binary.Write(&vc, binary.LittleEndian, uint32(len(c)))
+        vc.WriteString(c)
+    }
+    b.Write([]byte{0x84, 0, byte(vc.Len() >> 8), byte(vc.Len())})
+    b.Write(vc.Bytes())
+    b.Write(bytes.Repeat([]byte{0xff, 0xf8}, 4096))
+    return b.Bytes()
+}
+
+func TestFindDuplicates(t *testing.T) {
+    base := t.TempDir()
+    mdir := filepath.Join(base, "music")
+    if err := os.MkdirAll(mdir, 0o755); err != nil {
+        t.Fatalf("mkdir: %v", err)
+    }
+    files := map[string][]byte{
+        "a.mp3":  mp3File("Song", "The Band", 100),
+        "b.mp3":  mp3File("Song", "The Band", 100),
+        "c.mp3":  mp3File("Song (retagged)", "The Band", 100),
+        "d.flac": flacFile("song", "band", 2.6),
+        "e.mp3":  mp3File("Song", "The Band", 300),
+    }
+    for name, data := range files {
+        if err := os.WriteFile(filepath.Join(mdir, name), data, 0o644); err != nil {
+            t.Fatalf("write: %v", err)
+        }
+    }
+    idx := NewIndexAtBase(base)
+    if err := ScanDirs([]string{mdir}, idx, 1); err != nil {
+        t.Fatalf("ScanDirs: %v", err)
+    }
+    id := func(name string) string { return idFromPath(filepath.Join(mdir, name)) }
+    if a := idx.GetByID(id("a.mp3")); a.Codec != "mp3" || a.Bitrate != 128 || math.Abs(a.Duration-2.606) > 0.01 {
+        t.Fatalf("unexpected mp3 probe %+v", a)
+    }
+    if d := idx.GetByID(id("d.flac")); d.Codec != "flac" || math.Abs(d.Duration-2.6) > 0.001 {
+        t.Fatalf("unexpected flac probe %+v", d)
+    }
+
+    cases := []struct {
+        tiers []string
+        match string
+        ids   []string
+        best  string
+    }{
+        {[]string{MatchExact}, MatchExact, []string{"a.mp3", "b.mp3"}, "a.mp3"},
+        {[]string{MatchAudio}, MatchAudio, []string{"a.mp3", "b.mp3", "c.mp3"}, "a.mp3"},
+        {nil, MatchTags, []string{"a.mp3", "b.mp3", "c.mp3", "d.flac"}, "d.flac"},
+    }
+    for _, c := range cases {
+        groups, err := idx.FindDuplicates(DuplicateOptions{Tiers: c.tiers})
+        if err != nil {
+            t.Fatalf("FindDuplicates(%v): %v", c.tiers, err)
+        }
+        if len(groups) != 1 || groups[0].Match != c.match || len(groups[0].Tracks) != len(c.ids) {
+            t.Fatalf("FindDuplicates(%v) = %+v", c.tiers, groups)
+        }
+        for i, name := range c.ids {
+            if groups[0].Tracks[i].ID != id(name) {
+                t.Fatalf("FindDuplicates(%v): track %d is %s, want %s", c.tiers, i, groups[0].Tracks[i].Path, name)
+            }
+        }
+        if groups[0].Best != id(c.best) {
+            t.Fatalf("FindDuplicates(%v): best %s, want %s", c.tiers, groups[0].Best, c.best)
+        }
+    }
+    if _, err := idx.FindDuplicates(DuplicateOptions{Tiers: []string{"fuzzy"}}); err == nil {
+        t.Fatalf("expected unknown tier to be rejected")
+    }
+}
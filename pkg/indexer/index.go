@@ -1,12 +1,17 @@
 package indexer
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
+
+	cfg "penguin-tunes/pkg/config"
 )
 
 // Track holds metadata for a single audio file
@@ -16,24 +21,90 @@ type Track struct {
     Title       string `json:"title"`
     Album       string `json:"album"`
     Artist      string `json:"artist"`
+    AlbumArtist string `json:"album_artist"`
     Composer    string `json:"composer"`
     Genre       string `json:"genre"`
     TrackNumber int    `json:"track_number"`
+    DiscNumber  int    `json:"disc_number"`
     Cover       string `json:"cover"`
     Year        int    `json:"year"`
+    // Codec, Duration (seconds) and Bitrate (kbit/s) are probed from the audio stream
+    Codec    string  `json:"codec"`
+    Duration float64 `json:"duration"`
+    Bitrate  int     `json:"bitrate"`
+    // Fingerprint is the compressed chromaprint of the opening of the audio, set by the analysis pass
+    Fingerprint string `json:"fingerprint,omitempty"`
+    // BPM and Key come from tags or the tempo and key analysis; the confidences
+    // run from 0 to 1 and are 1 for tagged values
+    BPM           float64 `json:"bpm"`
+    BPMConfidence float64 `json:"bpm_confidence"`
+    Key           string  `json:"key"`
+    KeyConfidence float64 `json:"key_confidence"`
+    // HasLyrics is set when the tags or a sidecar .lrc/.txt file hold lyrics
+    HasLyrics bool `json:"has_lyrics"`
+    // Rating (0–5 stars) and Loved belong to the library and survive rescans
+    Rating int  `json:"rating"`
+    Loved  bool `json:"loved"`
+    // Cue is the sheet a virtual track was cut from, or "embedded" for a
+    // CUESHEET tag. Such tracks play Path from Start to End seconds; End 0
+    // runs to the end of the file.
+    Cue   string  `json:"cue,omitempty"`
+    Start float64 `json:"start,omitempty"`
+    End   float64 `json:"end,omitempty"`
+    // External tracks were opened from outside the library and are never indexed
+    External bool `json:"external,omitempty"`
+}
+
+// Virtual reports whether t is one of several tracks cut from a single file
+func (t *Track) Virtual() bool { return t.Cue != "" }
+
+// key is the index key: the path, plus the CUE track number for virtual tracks
+func (t *Track) key() string {
+    if t.Virtual() {
+        return t.Path + "#" + strconv.Itoa(t.TrackNumber)
+    }
+    return t.Path
 }
 
+// maxCueTracks is the highest track number a CUE sheet may use
+const maxCueTracks = 99
+
 // Index stores tracks keyed by path for quick lookups
 type Index struct {
     mtx    sync.RWMutex
     Tracks map[string]*Track `json:"tracks"`
+    // byID maps track IDs to their keys in Tracks
+    byID   map[string]string
     path   string
     cfgDir string
+    // base is the file as last read or written, for merging edits made by others
+    base     []byte
+    readOnly bool
+    log      *slog.Logger
+
+    // saveMtx serializes saves, which hold mtx only to take a snapshot and
+    // to apply merged changes
+    saveMtx sync.Mutex
+}
+
+// SetLogger sets where the index and its scans log; slog.Default() until then
+func (idx *Index) SetLogger(l *slog.Logger) {
+    idx.mtx.Lock()
+    defer idx.mtx.Unlock()
+    idx.log = l
+}
+
+// logger must be called with idx.mtx held
+func (idx *Index) logger() *slog.Logger {
+    if idx.log == nil {
+        return slog.Default()
+    }
+    return idx.log
 }
 
 // NewIndex creates a new index manager at path with cfgDir for covers
 func NewIndex(path string, cfgDir string) *Index {
-    return &Index{Tracks: make(map[string]*Track), path: path, cfgDir: cfgDir}
+    return &Index{Tracks: make(map[string]*Track), byID: make(map[string]string), path: path, cfgDir: cfgDir}
 }
 
 // NewIndexAtBase constructs an index file path under baseDir/index.json
@@ -43,6 +114,8 @@ func NewIndexAtBase(baseDir string) *Index {
 
 // LoadFromFile loads index from disk if exists
 func (idx *Index) LoadFromFile() error {
+    idx.saveMtx.Lock()
+    defer idx.saveMtx.Unlock()
     idx.mtx.Lock()
     defer idx.mtx.Unlock()
     if _, err := os.Stat(idx.path); err != nil {
@@ -61,40 +134,256 @@ func (idx *Index) LoadFromFile() error {
     if idx.Tracks == nil {
         idx.Tracks = make(map[string]*Track)
     }
+    idx.byID = make(map[string]string, len(idx.Tracks))
+    for k, t := range idx.Tracks {
+        idx.byID[t.ID] = k
+    }
+    idx.base = b
     return nil
 }
 
-// SaveToFile saves index atomically to disk
-func (idx *Index) SaveToFile() error {
+// SetReadOnly makes SaveToFile fail with cfg.ErrReadOnly, for processes that
+// don't hold the data dir lock
+func (idx *Index) SetReadOnly(ro bool) {
+    idx.mtx.Lock()
+    defer idx.mtx.Unlock()
+    idx.readOnly = ro
+}
+
+// ReadOnly reports whether SetReadOnly was turned on
+func (idx *Index) ReadOnly() bool {
     idx.mtx.RLock()
     defer idx.mtx.RUnlock()
-    wrapper := struct{ Tracks map[string]*Track `json:"tracks"` }{Tracks: idx.Tracks}
+    return idx.readOnly
+}
+
+// SaveToFile saves index atomically to disk. Tracks another process changed
+// in the file since it was read are merged in field by field, with this
+// index winning where both changed the same field. The index stays readable
+// and writable while the file is written.
+func (idx *Index) SaveToFile() error {
+    idx.saveMtx.Lock()
+    defer idx.saveMtx.Unlock()
+    idx.mtx.RLock()
+    if idx.readOnly {
+        idx.mtx.RUnlock()
+        return fmt.Errorf("save index: %w", cfg.ErrReadOnly)
+    }
+    // tracks are replaced, never changed in place, so copying the map is enough
+    saved := make(map[string]*Track, len(idx.Tracks))
+    for k, t := range idx.Tracks {
+        saved[k] = t
+    }
+    base, log := idx.base, idx.logger()
+    idx.mtx.RUnlock()
+
+    wrapper := struct{ Tracks map[string]*Track `json:"tracks"` }{Tracks: saved}
     b, err := json.MarshalIndent(wrapper, "", "  ")
     if err != nil {
         return fmt.Errorf("marshal index: %w", err)
     }
-    tmp := idx.path + ".tmp"
-    if err := os.WriteFile(tmp, b, 0o644); err != nil {
-        return fmt.Errorf("write tmp index: %w", err)
+    written, err := cfg.WriteMerged(idx.path, base, b, 0o644, log)
+    if err != nil {
+        return fmt.Errorf("write index: %w", err)
     }
-    if err := os.Rename(tmp, idx.path); err != nil {
-        return fmt.Errorf("rename tmp index: %w", err)
+    var merged struct{ Tracks map[string]*Track `json:"tracks"` }
+    if !bytes.Equal(written, b) {
+        if err := json.Unmarshal(written, &merged); err != nil {
+            return fmt.Errorf("unmarshal merged index: %w", err)
+        }
+    }
+
+    idx.mtx.Lock()
+    defer idx.mtx.Unlock()
+    idx.base = written
+    if merged.Tracks != nil {
+        idx.mergeLocked(saved, merged.Tracks)
     }
     return nil
 }
 
-// AddOrUpdateTrack adds or updates a track in the index
+// mergeLocked applies what another process changed between saved, the
+// tracks as written, and merged, the file after merging. Entries changed
+// here since saved was taken are left for the next save.
+func (idx *Index) mergeLocked(saved, merged map[string]*Track) {
+    for k, t := range saved {
+        if _, ok := merged[k]; !ok && idx.Tracks[k] == t {
+            idx.deleteLocked(k)
+        }
+    }
+    for k, t := range merged {
+        old, ok := saved[k]
+        if ok && *old == *t {
+            continue
+        }
+        if cur, has := idx.Tracks[k]; cur == old && has == ok {
+            idx.setLocked(k, t)
+        }
+    }
+}
+
+// setLocked stores t under key k. Entries are replaced, never changed in
+// place, as tracks handed out earlier are read without the lock.
+func (idx *Index) setLocked(k string, t *Track) {
+    if old, ok := idx.Tracks[k]; ok && idx.byID[old.ID] == k {
+        delete(idx.byID, old.ID)
+    }
+    idx.Tracks[k] = t
+    idx.byID[t.ID] = k
+}
+
+// deleteLocked removes the entry under key k
+func (idx *Index) deleteLocked(k string) {
+    if old, ok := idx.Tracks[k]; ok && idx.byID[old.ID] == k {
+        delete(idx.byID, old.ID)
+    }
+    delete(idx.Tracks, k)
+}
+
+// AddOrUpdateTrack adds or updates a track in the index. The ID and library
+// fields of an existing entry are kept; a rating read from the file takes precedence.
 func (idx *Index) AddOrUpdateTrack(t *Track) {
     idx.mtx.Lock()
     defer idx.mtx.Unlock()
-    idx.Tracks[t.Path] = t
+    idx.addLocked(t)
+}
+
+func (idx *Index) addLocked(t *Track) {
+    if old, ok := idx.Tracks[t.key()]; ok {
+        // a rescan keeps the entry's ID, which differs from one made from the
+        // path after the organizer moved the file. Files moved otherwise are
+        // found under a new path and get a new ID.
+        t.ID = old.ID
+        if t.Rating == 0 {
+            t.Rating = old.Rating
+        }
+        t.Loved = old.Loved
+        // analysis results stay valid while the audio stream looks the same
+        if t.Codec == old.Codec && t.Duration == old.Duration {
+            if t.Fingerprint == "" {
+                t.Fingerprint = old.Fingerprint
+            }
+            if t.BPM == 0 {
+                t.BPM, t.BPMConfidence = old.BPM, old.BPMConfidence
+            }
+            if t.Key == "" {
+                t.Key, t.KeyConfidence = old.Key, old.KeyConfidence
+            }
+        }
+    }
+    idx.setLocked(t.key(), t)
 }
 
-// RemoveTrack removes a track from index
+// fileKeysLocked returns the keys of every entry for the file at path
+func (idx *Index) fileKeysLocked(path string) []string {
+    var keys []string
+    if _, ok := idx.Tracks[path]; ok {
+        keys = append(keys, path)
+    }
+    for n := 1; n <= maxCueTracks; n++ {
+        k := path + "#" + strconv.Itoa(n)
+        if _, ok := idx.Tracks[k]; ok {
+            keys = append(keys, k)
+        }
+    }
+    return keys
+}
+
+// replaceFile makes tracks the only entries for the file at path, so a file
+// that gains or loses a CUE sheet swaps between its whole and its parts
+func (idx *Index) replaceFile(path string, tracks []*Track) {
+    idx.mtx.Lock()
+    defer idx.mtx.Unlock()
+    keep := make(map[string]bool, len(tracks))
+    for _, t := range tracks {
+        keep[t.key()] = true
+    }
+    for _, k := range idx.fileKeysLocked(path) {
+        if !keep[k] {
+            idx.deleteLocked(k)
+        }
+    }
+    for _, t := range tracks {
+        idx.addLocked(t)
+    }
+}
+
+// Reload re-reads the tags and CUE sheet of an indexed or new file and
+// updates its entries, which it returns
+func (idx *Index) Reload(path string, opts ScanOptions) ([]*Track, error) {
+    tracks, err := readTracks(path, idx.cfgDir, opts)
+    if err != nil {
+        return nil, err
+    }
+    idx.replaceFile(path, tracks)
+    return tracks, nil
+}
+
+// ReadExternal reads a file the way a scan would but leaves the index
+// alone; the tracks it returns are marked External
+func (idx *Index) ReadExternal(path string, opts ScanOptions) ([]*Track, error) {
+    tracks, err := readTracks(path, idx.cfgDir, opts)
+    if err != nil {
+        return nil, err
+    }
+    for _, t := range tracks {
+        t.External = true
+    }
+    return tracks, nil
+}
+
+// FileTracks returns the indexed entries of the file at path in track order
+func (idx *Index) FileTracks(path string) []*Track {
+    idx.mtx.RLock()
+    defer idx.mtx.RUnlock()
+    var out []*Track
+    for _, k := range idx.fileKeysLocked(path) {
+        out = append(out, idx.Tracks[k])
+    }
+    return out
+}
+
+// UpdateTrack applies fn to a copy of the track with the given ID, which
+// then replaces it, and returns the copy. Tracks handed out earlier keep
+// their old values.
+func (idx *Index) UpdateTrack(id string, fn func(t *Track)) (*Track, error) {
+    idx.mtx.Lock()
+    defer idx.mtx.Unlock()
+    k, ok := idx.byID[id]
+    if !ok {
+        return nil, fmt.Errorf("track %s not found", id)
+    }
+    c := *idx.Tracks[k]
+    fn(&c)
+    idx.setLocked(k, &c)
+    return &c, nil
+}
+
+// MoveTrack re-keys the entry at from to the path to, keeping its ID
+func (idx *Index) MoveTrack(from, to string) error {
+    idx.mtx.Lock()
+    defer idx.mtx.Unlock()
+    t, ok := idx.Tracks[from]
+    if !ok {
+        return fmt.Errorf("track %s not indexed", from)
+    }
+    if _, taken := idx.Tracks[to]; taken && to != from {
+        return fmt.Errorf("track %s already indexed", to)
+    }
+    idx.deleteLocked(from)
+    c := *t
+    c.Path = to
+    idx.setLocked(to, &c)
+    return nil
+}
+
+// RemoveTrack removes the file at path from the index, with any tracks cut from it
 func (idx *Index) RemoveTrack(path string) {
     idx.mtx.Lock()
     defer idx.mtx.Unlock()
-    delete(idx.Tracks, path)
+    for _, k := range idx.fileKeysLocked(path) {
+        idx.deleteLocked(k)
+    }
 }
 
 // GetAll returns a copy of all tracks
@@ -129,3 +418,13 @@ func (idx *Index) SaveCover(id string, mime string, r io.Reader) (string, error)
     }
     return outPath, nil
 }
+
+// GetByID returns the track with the given ID, or nil
+func (idx *Index) GetByID(id string) *Track {
+    idx.mtx.RLock()
+    defer idx.mtx.RUnlock()
+    if k, ok := idx.byID[id]; ok {
+        return idx.Tracks[k]
+    }
+    return nil
+}
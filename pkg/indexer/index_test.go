@@ -0,0 +1,124 @@
+package indexer
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+
+	cfg "penguin-tunes/pkg/config"
+)
+
+func TestSaveMergesOtherWriters(t *testing.T) {
+    base := t.TempDir()
+    a := NewIndexAtBase(base)
+    a.AddOrUpdateTrack(&Track{ID: "1", Path: "/m/1.mp3", Title: "One"})
+    a.AddOrUpdateTrack(&Track{ID: "2", Path: "/m/2.mp3", Title: "Two"})
+    if err := a.SaveToFile(); err != nil {
+        t.Fatalf("SaveToFile: %v", err)
+    }
+    kept := a.GetByID("1")
+
+    // a second writer rates one track and adds another
+    b := NewIndexAtBase(base)
+    if err := b.LoadFromFile(); err != nil {
+        t.Fatalf("LoadFromFile: %v", err)
+    }
+    b.UpdateTrack("1", func(t *Track) { t.Rating = 4 })
+    b.AddOrUpdateTrack(&Track{ID: "3", Path: "/m/3.mp3", Title: "Three"})
+    if err := b.SaveToFile(); err != nil {
+        t.Fatalf("SaveToFile: %v", err)
+    }
+
+    a.UpdateTrack("1", func(t *Track) { t.Title = "Uno" })
+    a.RemoveTrack("/m/2.mp3")
+    if err := a.SaveToFile(); err != nil {
+        t.Fatalf("SaveToFile: %v", err)
+    }
+    if got := a.GetByID("1"); got.Title != "Uno" || got.Rating != 4 {
+        t.Fatalf("expected both edits on the existing track, got %+v", got)
+    }
+    // tracks handed out are never changed under their readers
+    if kept.Title != "One" || kept.Rating != 0 {
+        t.Fatalf("a track handed out earlier changed: %+v", kept)
+    }
+    if a.GetByID("2") != nil || a.GetByID("3") == nil {
+        t.Fatalf("expected track 2 removed and track 3 merged in")
+    }
+
+    c := NewIndexAtBase(base)
+    c.LoadFromFile()
+    if len(c.GetAll()) != 2 || c.GetByID("1").Rating != 4 {
+        t.Fatalf("expected the merged index on disk, got %d tracks", len(c.GetAll()))
+    }
+    c.SetReadOnly(true)
+    if err := c.SaveToFile(); !errors.Is(err, cfg.ErrReadOnly) {
+        t.Fatalf("expected ErrReadOnly, got %v", err)
+    }
+}
+
+func TestGetByIDFollowsChanges(t *testing.T) {
+    idx := NewIndexAtBase(t.TempDir())
+    idx.AddOrUpdateTrack(&Track{ID: "1", Path: "/m/a.mp3"})
+    idx.AddOrUpdateTrack(&Track{ID: "2", Path: "/m/b.mp3"})
+    if err := idx.MoveTrack("/m/a.mp3", "/n/a.mp3"); err != nil {
+        t.Fatalf("MoveTrack: %v", err)
+    }
+    if got := idx.GetByID("1"); got == nil || got.Path != "/n/a.mp3" {
+        t.Fatalf("expected the moved track, got %+v", got)
+    }
+    idx.RemoveTrack("/m/b.mp3")
+    if got := idx.GetByID("2"); got != nil {
+        t.Fatalf("expected a removed track to be gone, got %+v", got)
+    }
+    if err := idx.SaveToFile(); err != nil {
+        t.Fatalf("SaveToFile: %v", err)
+    }
+    reloaded := NewIndex(idx.path, idx.cfgDir)
+    if err := reloaded.LoadFromFile(); err != nil {
+        t.Fatalf("LoadFromFile: %v", err)
+    }
+    if got := reloaded.GetByID("1"); got == nil || got.Path != "/n/a.mp3" {
+        t.Fatalf("expected the track after a reload, got %+v", got)
+    }
+}
+
+func TestSaveWhileUpdating(t *testing.T) {
+    base := t.TempDir()
+    a := NewIndexAtBase(base)
+    // another writer's file forces every save of a to merge
+    other := NewIndexAtBase(base)
+    other.AddOrUpdateTrack(&Track{ID: "x", Path: "/m/x.mp3"})
+    if err := other.SaveToFile(); err != nil {
+        t.Fatalf("SaveToFile: %v", err)
+    }
+
+    var wg sync.WaitGroup
+    wg.Add(2)
+    go func() {
+        defer wg.Done()
+        for i := 0; i < 50; i++ {
+            a.AddOrUpdateTrack(&Track{ID: strconv.Itoa(i), Path: "/m/" + strconv.Itoa(i) + ".mp3"})
+            a.GetByID(strconv.Itoa(i))
+        }
+    }()
+    go func() {
+        defer wg.Done()
+        for i := 0; i < 10; i++ {
+            if err := a.SaveToFile(); err != nil {
+                t.Errorf("SaveToFile: %v", err)
+            }
+        }
+    }()
+    wg.Wait()
+    if err := a.SaveToFile(); err != nil {
+        t.Fatalf("SaveToFile: %v", err)
+    }
+
+    // tracks added during saves are neither lost nor undone by merging
+    c := NewIndexAtBase(base)
+    c.LoadFromFile()
+    if len(a.GetAll()) != 51 || len(c.GetAll()) != 51 {
+        t.Fatalf("expected 51 tracks in memory and on disk, got %d and %d", len(a.GetAll()), len(c.GetAll()))
+    }
+}
@@ -0,0 +1,98 @@
+package indexer
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// NaturalLess orders strings the way people number files: case-insensitively,
+// with runs of digits compared by value, so "2 b" sorts before "10 a"
+func NaturalLess(a, b string) bool {
+    return naturalCompare(a, b) < 0
+}
+
+func naturalCompare(a, b string) int {
+    for a != "" && b != "" {
+        if isDigit(a[0]) && isDigit(b[0]) {
+            na, ra := digitRun(a)
+            nb, rb := digitRun(b)
+            // compare by value: strip leading zeros, then longer is bigger
+            ta, tb := strings.TrimLeft(na, "0"), strings.TrimLeft(nb, "0")
+            if len(ta) != len(tb) {
+                return sign(len(ta) - len(tb))
+            }
+            if c := strings.Compare(ta, tb); c != 0 {
+                return c
+            }
+            a, b = ra, rb
+            continue
+        }
+        ca, sa := utf8.DecodeRuneInString(a)
+        cb, sb := utf8.DecodeRuneInString(b)
+        if la, lb := unicode.ToLower(ca), unicode.ToLower(cb); la != lb {
+            return sign(int(la) - int(lb))
+        }
+        a, b = a[sa:], b[sb:]
+    }
+    return sign(len(a) - len(b))
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func digitRun(s string) (run, rest string) {
+    i := 0
+    for i < len(s) && isDigit(s[i]) {
+        i++
+    }
+    return s[:i], s[i:]
+}
+
+func sign(n int) int {
+    switch {
+    case n < 0:
+        return -1
+    case n > 0:
+        return 1
+    }
+    return 0
+}
+
+// NaturalPathLess compares paths directory by directory with NaturalLess, so
+// a folder's files stay together
+func NaturalPathLess(a, b string) bool {
+    pa := strings.Split(filepath.ToSlash(a), "/")
+    pb := strings.Split(filepath.ToSlash(b), "/")
+    for i := 0; i < len(pa) && i < len(pb); i++ {
+        if c := naturalCompare(pa[i], pb[i]); c != 0 {
+            return c < 0
+        }
+    }
+    return len(pa) < len(pb)
+}
+
+// AudioFiles returns the audio files below dir in natural path order
+func AudioFiles(dir string) ([]string, error) {
+    var out []string
+    err := filepath.WalkDir(dir, func(path string, de os.DirEntry, walkErr error) error {
+        if walkErr != nil {
+            if path == dir {
+                return walkErr
+            }
+            if de != nil && de.IsDir() {
+                return fs.SkipDir
+            }
+            return nil
+        }
+        if !de.IsDir() && isAudioFile(path) {
+            out = append(out, path)
+        }
+        return nil
+    })
+    sort.Slice(out, func(i, j int) bool { return NaturalPathLess(out[i], out[j]) })
+    return out, err
+}
@@ -0,0 +1,59 @@
+package indexer
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestNaturalLess(t *testing.T) {
+    names := []string{"10 Ten.mp3", "2 Two.mp3", "track01.mp3", "Track1b.mp3", "track002.mp3", "1 One.mp3", "b.mp3", "A.mp3"}
+    sort.Slice(names, func(i, j int) bool { return NaturalLess(names[i], names[j]) })
+    want := "1 One.mp3|2 Two.mp3|10 Ten.mp3|A.mp3|b.mp3|track01.mp3|Track1b.mp3|track002.mp3"
+    if got := strings.Join(names, "|"); got != want {
+        t.Fatalf("expected %s, got %s", want, got)
+    }
+}
+
+func TestAudioFilesNaturalOrder(t *testing.T) {
+    dir := t.TempDir()
+    for _, name := range []string{"CD10/1.flac", "CD2/10.flac", "CD2/9.flac", "cover.jpg", "11.mp3", "3.mp3"} {
+        p := filepath.Join(dir, name)
+        os.MkdirAll(filepath.Dir(p), 0o755)
+        if err := os.WriteFile(p, []byte("dummy"), 0o644); err != nil {
+            t.Fatalf("write: %v", err)
+        }
+    }
+    files, err := AudioFiles(dir)
+    if err != nil {
+        t.Fatalf("AudioFiles: %v", err)
+    }
+    for i := range files {
+        files[i], _ = filepath.Rel(dir, files[i])
+    }
+    want := "3.mp3|11.mp3|CD2/9.flac|CD2/10.flac|CD10/1.flac"
+    if got := filepath.ToSlash(strings.Join(files, "|")); got != want {
+        t.Fatalf("expected %s, got %s", want, got)
+    }
+}
+
+func TestReadExternalLeavesIndexAlone(t *testing.T) {
+    base := t.TempDir()
+    p := filepath.Join(t.TempDir(), "elsewhere.mp3")
+    if err := os.WriteFile(p, []byte("dummy"), 0o644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+    idx := NewIndexAtBase(base)
+    tracks, err := idx.ReadExternal(p, ScanOptions{})
+    if err != nil {
+        t.Fatalf("ReadExternal: %v", err)
+    }
+    if len(tracks) != 1 || !tracks[0].External || tracks[0].Path != p {
+        t.Fatalf("expected one external track for %s, got %+v", p, tracks)
+    }
+    if len(idx.GetAll()) != 0 || len(idx.FileTracks(p)) != 0 {
+        t.Fatalf("expected the index to stay empty")
+    }
+}
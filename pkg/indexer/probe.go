@@ -0,0 +1,493 @@
+package indexer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// AudioInfo describes the audio stream of a file; Bitrate is in kbit/s
+type AudioInfo struct {
+    Codec    string  `json:"codec"`
+    Duration float64 `json:"duration"`
+    Bitrate  int     `json:"bitrate"`
+}
+
+// span is a byte range [start, end) of a file
+type span struct{ start, end int64 }
+
+// probeAudio reads container headers to find the codec, duration and bitrate,
+// along with the byte ranges holding audio rather than tags
+func probeAudio(path string) (AudioInfo, []span, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return AudioInfo{}, nil, err
+    }
+    defer f.Close()
+    fi, err := f.Stat()
+    if err != nil {
+        return AudioInfo{}, nil, err
+    }
+    size := fi.Size()
+    var info AudioInfo
+    var spans []span
+    switch strings.ToLower(filepath.Ext(path)) {
+    case ".mp3":
+        info, spans, err = probeMP3(f, size)
+    case ".flac":
+        info, spans, err = probeFLAC(f, size)
+    case ".ogg", ".opus":
+        info, spans, err = probeOgg(f, size)
+    case ".m4a":
+        info, spans, err = probeMP4(f, size)
+    case ".wav":
+        info, spans, err = probeWAV(f, size)
+    case ".aac":
+        info, spans, err = probeADTS(f, size)
+    case ".wma":
+        info, spans, err = probeASF(f, size)
+    default:
+        err = fmt.Errorf("unsupported format")
+    }
+    if err != nil {
+        return AudioInfo{}, nil, fmt.Errorf("probe %s: %w", path, err)
+    }
+    if info.Bitrate == 0 && info.Duration > 0 {
+        var n int64
+        for _, s := range spans {
+            n += s.end - s.start
+        }
+        info.Bitrate = int(float64(n) * 8 / info.Duration / 1000)
+    }
+    return info, spans, nil
+}
+
+func readAt(r io.ReaderAt, off int64, n int) ([]byte, error) {
+    b := make([]byte, n)
+    if _, err := r.ReadAt(b, off); err != nil {
+        return nil, err
+    }
+    return b, nil
+}
+
+// id3v2Size returns the length of an ID3v2 tag at off, 0 if there is none
+func id3v2Size(r io.ReaderAt, off int64) int64 {
+    h, err := readAt(r, off, 10)
+    if err != nil || string(h[:3]) != "ID3" {
+        return 0
+    }
+    n := int64(h[6]&0x7f)<<21 | int64(h[7]&0x7f)<<14 | int64(h[8]&0x7f)<<7 | int64(h[9]&0x7f)
+    if h[5]&0x10 != 0 {
+        n += 10 // footer
+    }
+    return 10 + n
+}
+
+// trailingTagsStart returns where ID3v1 and APEv2 tags at the end of the file begin
+func trailingTagsStart(r io.ReaderAt, size int64) int64 {
+    end := size
+    if end >= 128 {
+        if b, err := readAt(r, end-128, 3); err == nil && string(b) == "TAG" {
+            end -= 128
+        }
+    }
+    if end >= 32 {
+        if b, err := readAt(r, end-32, 32); err == nil && string(b[:8]) == "APETAGEX" {
+            n := int64(binary.LittleEndian.Uint32(b[12:]))
+            if binary.LittleEndian.Uint32(b[20:])&(1<<31) != 0 {
+                n += 32 // header
+            }
+            if n <= end {
+                end -= n
+            }
+        }
+    }
+    return end
+}
+
+var mp3Bitrates = [2][3][16]int{
+    { // MPEG-1 layers I, II, III
+        {0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+        {0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+        {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+    },
+    { // MPEG-2 and 2.5
+        {0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+        {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+        {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+    },
+}
+
+var mp3SampleRates = [3]int{44100, 48000, 32000}
+
+// mp3Frame is a decoded MPEG audio frame header
+type mp3Frame struct {
+    mpeg1      bool
+    layer      int
+    bitrate    int
+    sampleRate int
+    samples    int
+    mono       bool
+}
+
+func parseMP3Frame(h []byte) (mp3Frame, bool) {
+    if h[0] != 0xff || h[1]&0xe0 != 0xe0 {
+        return mp3Frame{}, false
+    }
+    version := (h[1] >> 3) & 3 // 0: 2.5, 2: 2, 3: 1
+    layer := 4 - int((h[1]>>1)&3)
+    bi := h[2] >> 4
+    si := (h[2] >> 2) & 3
+    if version == 1 || layer == 4 || bi == 0 || bi == 15 || si == 3 {
+        return mp3Frame{}, false
+    }
+    f := mp3Frame{mpeg1: version == 3, layer: layer, mono: h[3]>>6 == 3}
+    table := 1
+    if f.mpeg1 {
+        table = 0
+    }
+    f.bitrate = mp3Bitrates[table][layer-1][bi]
+    f.sampleRate = mp3SampleRates[si]
+    switch version {
+    case 2:
+        f.sampleRate /= 2
+    case 0:
+        f.sampleRate /= 4
+    }
+    switch {
+    case layer == 1:
+        f.samples = 384
+    case layer == 3 && !f.mpeg1:
+        f.samples = 576
+    default:
+        f.samples = 1152
+    }
+    return f, true
+}
+
+func probeMP3(r io.ReaderAt, size int64) (AudioInfo, []span, error) {
+    start := id3v2Size(r, 0)
+    end := trailingTagsStart(r, size)
+    buf := make([]byte, 64<<10)
+    n, _ := r.ReadAt(buf, start)
+    buf = buf[:n]
+    for i := 0; i+4 <= len(buf); i++ {
+        f, ok := parseMP3Frame(buf[i:])
+        if !ok {
+            continue
+        }
+        start += int64(i)
+        info := AudioInfo{Codec: "mp3"}
+        // a Xing/Info or VBRI header in the first frame gives the frame count of VBR files
+        side := 32
+        switch {
+        case f.mpeg1 && f.mono:
+            side = 17
+        case !f.mpeg1 && !f.mono:
+            side = 17
+        case !f.mpeg1:
+            side = 9
+        }
+        frames := 0
+        if x := buf[i:]; len(x) >= 4+side+12 {
+            tag := string(x[4+side : 8+side])
+            if (tag == "Xing" || tag == "Info") && x[11+side]&1 != 0 {
+                frames = int(binary.BigEndian.Uint32(x[12+side:]))
+            } else if len(x) >= 54 && string(x[36:40]) == "VBRI" {
+                frames = int(binary.BigEndian.Uint32(x[50:]))
+            }
+        }
+        if frames > 0 {
+            info.Duration = float64(frames*f.samples) / float64(f.sampleRate)
+        } else {
+            info.Bitrate = f.bitrate
+            info.Duration = float64(end-start) * 8 / float64(f.bitrate*1000)
+        }
+        return info, []span{{start, end}}, nil
+    }
+    return AudioInfo{}, nil, fmt.Errorf("no MPEG frame found")
+}
+
+func probeFLAC(r io.ReaderAt, size int64) (AudioInfo, []span, error) {
+    pos := id3v2Size(r, 0)
+    if b, err := readAt(r, pos, 4); err != nil || string(b) != "fLaC" {
+        return AudioInfo{}, nil, fmt.Errorf("not a FLAC file")
+    }
+    pos += 4
+    var info AudioInfo
+    for {
+        h, err := readAt(r, pos, 4)
+        if err != nil {
+            return AudioInfo{}, nil, fmt.Errorf("read FLAC block header: %w", err)
+        }
+        n := int64(h[1])<<16 | int64(h[2])<<8 | int64(h[3])
+        if h[0]&0x7f == 0 && n >= 18 {
+            si, err := readAt(r, pos+4, 18)
+            if err != nil {
+                return AudioInfo{}, nil, err
+            }
+            rate := int(si[10])<<12 | int(si[11])<<4 | int(si[12])>>4
+            total := int64(si[13]&0x0f)<<32 | int64(binary.BigEndian.Uint32(si[14:]))
+            if rate > 0 {
+                info.Duration = float64(total) / float64(rate)
+            }
+        }
+        pos += 4 + n
+        if h[0]&0x80 != 0 {
+            break
+        }
+    }
+    info.Codec = "flac"
+    return info, []span{{pos, trailingTagsStart(r, size)}}, nil
+}
+
+// probeOgg reads the codec from the first packet and the length from the last granule position
+func probeOgg(r io.ReaderAt, size int64) (AudioInfo, []span, error) {
+    h, err := readAt(r, 0, 27)
+    if err != nil || string(h[:4]) != "OggS" {
+        return AudioInfo{}, nil, fmt.Errorf("not an Ogg file")
+    }
+    pkt, err := readAt(r, 27+int64(h[26]), 19)
+    if err != nil {
+        return AudioInfo{}, nil, fmt.Errorf("read Ogg header packet: %w", err)
+    }
+    var info AudioInfo
+    var rate, preSkip int64
+    headers := 0
+    switch {
+    case bytes.HasPrefix(pkt, []byte("\x01vorbis")):
+        info.Codec, rate, headers = "vorbis", int64(binary.LittleEndian.Uint32(pkt[12:])), 3
+    case bytes.HasPrefix(pkt, []byte("OpusHead")):
+        info.Codec, rate, headers = "opus", 48000, 2
+        preSkip = int64(binary.LittleEndian.Uint16(pkt[10:]))
+    default:
+        return AudioInfo{}, nil, fmt.Errorf("unknown Ogg codec")
+    }
+    spans, last, err := oggAudioPages(r, size, headers)
+    if err != nil {
+        return AudioInfo{}, nil, err
+    }
+    if rate > 0 && last > preSkip {
+        info.Duration = float64(last-preSkip) / float64(rate)
+    }
+    return info, spans, nil
+}
+
+// oggAudioPages walks the pages of a logical stream, returning the payload of
+// pages after the header packets and the last granule position
+func oggAudioPages(r io.ReaderAt, size int64, headers int) ([]span, int64, error) {
+    var spans []span
+    var granule int64
+    packets := 0
+    for pos := int64(0); pos+27 <= size; {
+        h, err := readAt(r, pos, 27)
+        if err != nil || string(h[:4]) != "OggS" {
+            return nil, 0, fmt.Errorf("bad Ogg page at %d", pos)
+        }
+        lacing, err := readAt(r, pos+27, int(h[26]))
+        if err != nil {
+            return nil, 0, err
+        }
+        var n int64
+        for _, l := range lacing {
+            n += int64(l)
+        }
+        body := pos + 27 + int64(len(lacing))
+        if packets >= headers {
+            spans = append(spans, span{body, body + n})
+        } else {
+            for _, l := range lacing {
+                if l < 255 {
+                    packets++
+                }
+            }
+        }
+        if g := int64(binary.LittleEndian.Uint64(h[6:])); g > 0 {
+            granule = g
+        }
+        pos = body + n
+    }
+    return spans, granule, nil
+}
+
+// probeMP4 reads the duration from mvhd, the codec from the first sample entry and the audio from mdat
+func probeMP4(r io.ReaderAt, size int64) (AudioInfo, []span, error) {
+    var info AudioInfo
+    var spans []span
+    for pos := int64(0); pos+8 <= size; {
+        h, err := readAt(r, pos, int(min(16, size-pos)))
+        if err != nil {
+            return AudioInfo{}, nil, err
+        }
+        n, hdr := int64(binary.BigEndian.Uint32(h)), int64(8)
+        switch n {
+        case 0:
+            n = size - pos
+        case 1:
+            if len(h) < 16 {
+                return AudioInfo{}, nil, fmt.Errorf("truncated atom")
+            }
+            n, hdr = int64(binary.BigEndian.Uint64(h[8:])), 16
+        }
+        if n < hdr || pos+n > size {
+            break
+        }
+        switch string(h[4:8]) {
+        case "mdat":
+            spans = append(spans, span{pos + hdr, pos + n})
+        case "moov":
+            moov, err := readAt(r, pos+hdr, int(n-hdr))
+            if err != nil {
+                return AudioInfo{}, nil, err
+            }
+            mp4Info(moov, &info)
+        }
+        pos += n
+    }
+    if info.Codec == "" {
+        return AudioInfo{}, nil, fmt.Errorf("no audio track found")
+    }
+    return info, spans, nil
+}
+
+// mp4Info walks the children of a container atom looking for mvhd and stsd
+func mp4Info(b []byte, info *AudioInfo) {
+    for len(b) >= 8 {
+        n := int(binary.BigEndian.Uint32(b))
+        if n < 8 || n > len(b) {
+            return
+        }
+        name, body := string(b[4:8]), b[8:n]
+        switch name {
+        case "trak", "mdia", "minf", "stbl":
+            mp4Info(body, info)
+        case "mvhd":
+            if len(body) >= 20 && body[0] == 0 {
+                if scale := binary.BigEndian.Uint32(body[12:]); scale > 0 {
+                    info.Duration = float64(binary.BigEndian.Uint32(body[16:])) / float64(scale)
+                }
+            } else if len(body) >= 32 && body[0] == 1 {
+                if scale := binary.BigEndian.Uint32(body[20:]); scale > 0 {
+                    info.Duration = float64(binary.BigEndian.Uint64(body[24:])) / float64(scale)
+                }
+            }
+        case "stsd":
+            if len(body) >= 16 && info.Codec == "" {
+                switch string(body[12:16]) {
+                case "mp4a":
+                    info.Codec = "aac"
+                case "alac":
+                    info.Codec = "alac"
+                }
+            }
+        }
+        b = b[n:]
+    }
+}
+
+func probeWAV(r io.ReaderAt, size int64) (AudioInfo, []span, error) {
+    h, err := readAt(r, 0, 12)
+    if err != nil || string(h[:4]) != "RIFF" || string(h[8:]) != "WAVE" {
+        return AudioInfo{}, nil, fmt.Errorf("not a WAV file")
+    }
+    info := AudioInfo{Codec: "pcm"}
+    var byteRate int64
+    for pos := int64(12); pos+8 <= size; {
+        c, err := readAt(r, pos, 8)
+        if err != nil {
+            break
+        }
+        n := int64(binary.LittleEndian.Uint32(c[4:]))
+        switch string(c[:4]) {
+        case "fmt ":
+            fmtChunk, err := readAt(r, pos+8, 16)
+            if err != nil {
+                return AudioInfo{}, nil, err
+            }
+            if tag := binary.LittleEndian.Uint16(fmtChunk); tag != 1 && tag != 0xfffe {
+                info.Codec = "wav"
+            }
+            byteRate = int64(binary.LittleEndian.Uint32(fmtChunk[8:]))
+        case "data":
+            end := min(pos+8+n, size)
+            if byteRate > 0 {
+                info.Duration = float64(end-pos-8) / float64(byteRate)
+                info.Bitrate = int(byteRate * 8 / 1000)
+            }
+            return info, []span{{pos + 8, end}}, nil
+        }
+        pos += 8 + n + n&1
+    }
+    return AudioInfo{}, nil, fmt.Errorf("no data chunk")
+}
+
+var adtsSampleRates = []int{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+
+// probeADTS estimates the length of a raw AAC stream from the average of its first frames
+func probeADTS(r io.ReaderAt, size int64) (AudioInfo, []span, error) {
+    start := id3v2Size(r, 0)
+    end := trailingTagsStart(r, size)
+    frames, bytesRead, rate := 0, int64(0), 0
+    for pos := start; pos+7 <= end && frames < 200; {
+        h, err := readAt(r, pos, 7)
+        if err != nil || h[0] != 0xff || h[1]&0xf6 != 0xf0 {
+            break
+        }
+        si := int(h[2]>>2) & 0x0f
+        if si >= len(adtsSampleRates) {
+            break
+        }
+        rate = adtsSampleRates[si]
+        n := int64(h[3]&3)<<11 | int64(h[4])<<3 | int64(h[5]>>5)
+        if n < 7 {
+            break
+        }
+        frames++
+        bytesRead += n
+        pos += n
+    }
+    if frames == 0 {
+        return AudioInfo{}, nil, fmt.Errorf("no ADTS frame found")
+    }
+    total := float64(end-start) / (float64(bytesRead) / float64(frames))
+    return AudioInfo{Codec: "aac", Duration: total * 1024 / float64(rate)}, []span{{start, end}}, nil
+}
+
+var (
+    asfHeaderGUID = []byte{0x30, 0x26, 0xb2, 0x75, 0x8e, 0x66, 0xcf, 0x11, 0xa6, 0xd9, 0x00, 0xaa, 0x00, 0x62, 0xce, 0x6c}
+    asfFileGUID   = []byte{0xa1, 0xdc, 0xab, 0x8c, 0x47, 0xa9, 0xcf, 0x11, 0x8e, 0xe4, 0x00, 0xc0, 0x0c, 0x20, 0x53, 0x65}
+)
+
+// probeASF reads the play duration from the file properties object; audio is everything after the header
+func probeASF(r io.ReaderAt, size int64) (AudioInfo, []span, error) {
+    h, err := readAt(r, 0, 30)
+    if err != nil || !bytes.Equal(h[:16], asfHeaderGUID) {
+        return AudioInfo{}, nil, fmt.Errorf("not an ASF file")
+    }
+    headerSize := int64(binary.LittleEndian.Uint64(h[16:]))
+    if headerSize < 30 || headerSize > size {
+        return AudioInfo{}, nil, fmt.Errorf("bad ASF header")
+    }
+    header, err := readAt(r, 30, int(headerSize-30))
+    if err != nil {
+        return AudioInfo{}, nil, err
+    }
+    info := AudioInfo{Codec: "wma"}
+    for b := header; len(b) >= 24; {
+        n := int(binary.LittleEndian.Uint64(b[16:]))
+        if n < 24 || n > len(b) {
+            break
+        }
+        if bytes.Equal(b[:16], asfFileGUID) && n >= 104 {
+            play := float64(binary.LittleEndian.Uint64(b[64:])) / 1e7
+            preroll := float64(binary.LittleEndian.Uint64(b[80:])) / 1000
+            info.Duration = max(play-preroll, 0)
+        }
+        b = b[n:]
+    }
+    return info, []span{{headerSize, size}}, nil
+}
@@ -0,0 +1,71 @@
+package indexer
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ratingFromTags extracts a 0–5 star rating from raw tags: POPM (ID3), RATING or
+// FMPS_RATING (Vorbis comments) and the iTunes freeform rate (MP4)
+func ratingFromTags(raw map[string]any) int {
+    for k, v := range raw {
+        if k != "POPM" && !strings.HasPrefix(k, "POPM_") {
+            continue
+        }
+        b, ok := v.([]byte)
+        if !ok {
+            continue
+        }
+        // email, NUL, rating byte, optional play counter
+        if at := bytes.IndexByte(b, 0); at >= 0 && at+1 < len(b) && b[at+1] > 0 {
+            return popmStars(b[at+1])
+        }
+    }
+    if v, ok := number(raw["fmps_rating"]); ok {
+        return clampStars(v * 5)
+    }
+    if v, ok := number(raw["rating"]); ok {
+        if v > 5 {
+            v /= 20
+        }
+        return clampStars(v)
+    }
+    if v, ok := number(raw["rate"]); ok {
+        return clampStars(v / 20)
+    }
+    return 0
+}
+
+// popmStars maps a POPM byte to stars using the ranges Windows Media Player writes
+func popmStars(b byte) int {
+    switch {
+    case b == 0:
+        return 0
+    case b < 32:
+        return 1
+    case b < 96:
+        return 2
+    case b < 160:
+        return 3
+    case b < 224:
+        return 4
+    }
+    return 5
+}
+
+func number(v any) (float64, bool) {
+    s, ok := v.(string)
+    if !ok {
+        return 0, false
+    }
+    // MP4 freeform values may carry leftover locale bytes
+    s = strings.Trim(s, "\x00 ")
+    n, err := strconv.ParseFloat(s, 64)
+    return n, err == nil
+}
+
+func clampStars(v float64) int {
+    return int(math.Max(0, math.Min(5, math.Round(v))))
+}
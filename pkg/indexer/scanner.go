@@ -1,6 +1,7 @@
 package indexer
 
 import (
+	"context"
 	"crypto/sha1"
 	"encoding/hex"
 	"fmt"
@@ -9,8 +10,13 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	tag "github.com/dhowden/tag"
+
+	cfg "penguin-tunes/pkg/config"
+	"penguin-tunes/pkg/lyrics"
 )
 
 var audioExtensions = map[string]bool{
@@ -22,48 +28,78 @@ func isAudioFile(path string) bool {
     return audioExtensions[ext]
 }
 
+// IsAudioFile reports whether path has an extension the scanner indexes
+func IsAudioFile(path string) bool {
+    return isAudioFile(path)
+}
+
 func idFromPath(path string) string {
     ab, _ := filepath.Abs(path)
     h := sha1.Sum([]byte(ab))
     return hex.EncodeToString(h[:])
 }
 
-// readMetadata reads an audio file's tags and returns a Track
-func readMetadata(path string, cfgDir string) (*Track, error) {
+// readMetadata reads an audio file's tags and returns a Track along with any
+// embedded CUESHEET. Fields the tags leave empty are inferred from the path
+// templates in opts, then defaulted.
+func readMetadata(path string, cfgDir string, opts ScanOptions) (*Track, string, error) {
     f, err := os.Open(path)
     if err != nil {
-        return nil, err
+        return nil, "", err
     }
     defer f.Close()
-    m, err := tag.ReadFrom(f)
     t := &Track{ID: idFromPath(path), Path: path}
-    if err != nil {
-        // return basic info fallback
-        t.Title = filepath.Base(path)
-        t.Album = "Unknown Album"
-        t.Artist = "Unknown Artist"
-        return t, nil
+    var sheet string
+    if m, err := tag.ReadFrom(f); err == nil {
+        readTags(t, m, cfgDir, opts)
+        sheet, _ = m.Raw()["cuesheet"].(string)
     }
-    if t.Title = m.Title(); t.Title == "" {
+    if info, _, err := probeAudio(path); err == nil {
+        t.Codec, t.Duration, t.Bitrate = info.Codec, info.Duration, info.Bitrate
+    }
+    if !t.HasLyrics {
+        t.HasLyrics = lyrics.Sidecar(path) != ""
+    }
+    inferFromPath(t, opts.Templates)
+    if t.Title == "" {
         t.Title = filepath.Base(path)
     }
-    if t.Album = m.Album(); t.Album == "" {
+    if t.Album == "" {
         t.Album = "Unknown Album"
     }
-    if t.Artist = m.Artist(); t.Artist == "" {
+    if t.Artist == "" {
         t.Artist = "Unknown Artist"
     }
-    if t.Composer = m.Composer(); t.Composer == "" {
+    if t.Composer == "" {
         t.Composer = "Unknown Artist"
     }
-    if t.Genre = m.Genre(); t.Genre == "" {
-        t.Genre = ""
-    }
+    return t, sheet, nil
+}
+
+// readTags copies tag values into t and extracts the embedded cover
+func readTags(t *Track, m tag.Metadata, cfgDir string, opts ScanOptions) {
+    t.Title = m.Title()
+    t.Album = m.Album()
+    t.Artist = m.Artist()
+    t.AlbumArtist = m.AlbumArtist()
+    t.Composer = m.Composer()
+    t.Genre = m.Genre()
     rn, _ := m.Track()
     if rn > 0 {
         t.TrackNumber = rn
     }
+    t.DiscNumber, _ = m.Disc()
     t.Year = m.Year()
+    if opts.ReadRatings {
+        t.Rating = ratingFromTags(m.Raw())
+    }
+    if bpm := bpmFromTags(m.Raw()); bpm > 0 {
+        t.BPM, t.BPMConfidence = bpm, 1
+    }
+    if key, ok := keyFromTags(m.Raw()); ok {
+        t.Key, t.KeyConfidence = key, 1
+    }
+    t.HasLyrics = lyrics.FromTags(m) != nil
     p := m.Picture()
     if p != nil {
         ext := ".jpg"
@@ -77,17 +113,52 @@ func readMetadata(path string, cfgDir string) (*Track, error) {
             t.Cover = pth
         }
     }
-    return t, nil
+}
+
+// ScanOptions tunes ScanDirsWithOptions
+type ScanOptions struct {
+    Concurrency int
+    // OnFile, when set, is called from the walking goroutine for every non-audio file found
+    OnFile func(path string)
+    // ReadRatings takes ratings from POPM, RATING/FMPS_RATING and rate tags
+    ReadRatings bool
+    // Templates infer missing tags from the path; the first match wins
+    Templates []*PathTemplate
+}
+
+// ConfigScanOptions builds the metadata options set in the config; invalid
+// path templates are reported and leave the options without templates
+func ConfigScanOptions(c cfg.Config) (ScanOptions, error) {
+    templates, err := ParsePathTemplates(c.PathTemplates)
+    return ScanOptions{ReadRatings: c.RatingSync, Templates: templates}, err
 }
 
 // ScanDirs will scan dirs recursively and update index
 func ScanDirs(dirs []string, idx *Index, concurrency int) error {
+    return ScanDirsWithOptions(dirs, idx, ScanOptions{Concurrency: concurrency})
+}
+
+// ScanDirsWithOptions scans dirs like ScanDirs with extra hooks
+func ScanDirsWithOptions(dirs []string, idx *Index, opts ScanOptions) error {
+    return ScanDirsContext(context.Background(), dirs, idx, opts)
+}
+
+// ScanDirsContext scans like ScanDirsWithOptions until ctx is cancelled. A
+// cancelled scan stops walking, lets the files being read finish, saves
+// what it found and returns ctx.Err().
+func ScanDirsContext(ctx context.Context, dirs []string, idx *Index, opts ScanOptions) error {
     if idx == nil {
         return fmt.Errorf("nil index")
     }
+    concurrency := opts.Concurrency
     if concurrency <= 0 {
         concurrency = runtime.NumCPU()
     }
+    idx.mtx.RLock()
+    log := idx.logger()
+    idx.mtx.RUnlock()
+    start := time.Now()
+    var read, failed atomic.Int64
     paths := make(chan string, 2048)
     var wg sync.WaitGroup
     for i := 0; i < concurrency; i++ {
@@ -95,29 +166,53 @@ func ScanDirs(dirs []string, idx *Index, concurrency int) error {
         go func() {
             defer wg.Done()
             for p := range paths {
-                t, err := readMetadata(p, idx.cfgDir)
+                tracks, err := readTracks(p, idx.cfgDir, opts)
                 if err != nil {
+                    failed.Add(1)
+                    log.Debug("read failed", "path", p, "err", err)
                     continue
                 }
-                idx.AddOrUpdateTrack(t)
+                read.Add(1)
+                idx.replaceFile(p, tracks)
             }
         }()
     }
     for _, d := range dirs {
+        if ctx.Err() != nil {
+            break
+        }
         _ = filepath.WalkDir(d, func(path string, de os.DirEntry, walkErr error) error {
+            if ctx.Err() != nil {
+                return filepath.SkipAll
+            }
             if walkErr != nil {
+                log.Debug("walk failed", "path", path, "err", walkErr)
                 return nil
             }
             if de.IsDir() {
                 return nil
             }
             if isAudioFile(path) {
-                paths <- path
+                select {
+                case paths <- path:
+                case <-ctx.Done():
+                    return filepath.SkipAll
+                }
+            } else if opts.OnFile != nil {
+                opts.OnFile(path)
             }
             return nil
         })
     }
     close(paths)
     wg.Wait()
+    if ctx.Err() != nil {
+        log.Info("scan cancelled", "dirs", len(dirs), "files", read.Load(), "failed", failed.Load(), "took", time.Since(start))
+        if err := idx.SaveToFile(); err != nil {
+            return err
+        }
+        return ctx.Err()
+    }
+    log.Info("scan done", "dirs", len(dirs), "files", read.Load(), "failed", failed.Load(), "took", time.Since(start))
     return idx.SaveToFile()
 }
@@ -1,10 +1,15 @@
 package indexer
 
 import (
+	"context"
+	"errors"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"testing"
+
+	"penguin-tunes/pkg/cue"
 )
 
 func TestScanDirsFindsAudioFiles(t *testing.T) {
@@ -48,3 +53,278 @@ func TestScanDirsFindsAudioFiles(t *testing.T) {
         t.Fatalf("expected 2 tracks, got %d", len(idx.GetAll()))
     }
 }
+
+func TestCancelledScanSavesAndStops(t *testing.T) {
+    base := t.TempDir()
+    mdir := filepath.Join(base, "music")
+    if err := os.MkdirAll(mdir, 0o755); err != nil {
+        t.Fatalf("mkdir: %v", err)
+    }
+    for _, n := range []string{"a.mp3", "b.mp3", "c.mp3"} {
+        if err := os.WriteFile(filepath.Join(mdir, n), []byte("dummy"), 0o644); err != nil {
+            t.Fatalf("write: %v", err)
+        }
+    }
+    ctx, cancel := context.WithCancel(context.Background())
+    idx := NewIndexAtBase(base)
+    // cancel once the walk reaches the first non-audio file
+    if err := os.WriteFile(filepath.Join(mdir, "0.txt"), nil, 0o644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+    opts := ScanOptions{Concurrency: 1, OnFile: func(string) { cancel() }}
+    if err := ScanDirsContext(ctx, []string{mdir}, idx, opts); !errors.Is(err, context.Canceled) {
+        t.Fatalf("expected context.Canceled, got %v", err)
+    }
+    if n := len(idx.GetAll()); n != 0 {
+        t.Fatalf("expected the walk to stop before the audio files, got %d tracks", n)
+    }
+    if _, err := os.Stat(filepath.Join(base, "index.json")); err != nil {
+        t.Fatalf("expected a cancelled scan to save the index: %v", err)
+    }
+}
+
+func TestScanDirsReportsOtherFiles(t *testing.T) {
+    base := t.TempDir()
+    mdir := filepath.Join(base, "music")
+    if err := os.MkdirAll(mdir, 0o755); err != nil {
+        t.Fatalf("mkdir: %v", err)
+    }
+    for _, n := range []string{"a.mp3", "list.m3u8", "cover.jpg"} {
+        if err := os.WriteFile(filepath.Join(mdir, n), []byte("dummy"), 0o644); err != nil {
+            t.Fatalf("write: %v", err)
+        }
+    }
+    var others []string
+    idx := NewIndexAtBase(base)
+    opts := ScanOptions{Concurrency: 1, OnFile: func(p string) { others = append(others, filepath.Base(p)) }}
+    if err := ScanDirsWithOptions([]string{mdir}, idx, opts); err != nil {
+        t.Fatalf("ScanDirsWithOptions: %v", err)
+    }
+    if len(idx.GetAll()) != 1 || len(others) != 2 {
+        t.Fatalf("expected 1 track and 2 other files, got %d / %v", len(idx.GetAll()), others)
+    }
+}
+
+func TestSidecarLyricsFlagged(t *testing.T) {
+    base := t.TempDir()
+    mdir := filepath.Join(base, "music")
+    if err := os.MkdirAll(mdir, 0o755); err != nil {
+        t.Fatalf("mkdir: %v", err)
+    }
+    for _, n := range []string{"a.mp3", "a.lrc", "b.mp3"} {
+        if err := os.WriteFile(filepath.Join(mdir, n), []byte("dummy"), 0o644); err != nil {
+            t.Fatalf("write: %v", err)
+        }
+    }
+    idx := NewIndexAtBase(base)
+    if err := ScanDirs([]string{mdir}, idx, 1); err != nil {
+        t.Fatalf("ScanDirs: %v", err)
+    }
+    for _, tr := range idx.GetAll() {
+        if want := filepath.Base(tr.Path) == "a.mp3"; tr.HasLyrics != want {
+            t.Fatalf("%s: expected HasLyrics %v", tr.Path, want)
+        }
+    }
+}
+
+func TestCueSheetSplitsFile(t *testing.T) {
+    base := t.TempDir()
+    mdir := filepath.Join(base, "music")
+    if err := os.MkdirAll(mdir, 0o755); err != nil {
+        t.Fatalf("mkdir: %v", err)
+    }
+    audio := filepath.Join(mdir, "album.flac")
+    sheet := filepath.Join(mdir, "album.cue")
+    if err := os.WriteFile(audio, []byte("dummy"), 0o644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+    idx := NewIndexAtBase(base)
+    if err := ScanDirs([]string{mdir}, idx, 1); err != nil {
+        t.Fatalf("ScanDirs: %v", err)
+    }
+    cue := "PERFORMER \"Band\"\nTITLE \"Live\"\nFILE \"image.wav\" WAVE\n" +
+        "TRACK 01 AUDIO\nTITLE \"Intro\"\nINDEX 01 00:00:00\n" +
+        "TRACK 02 AUDIO\nTITLE \"Song\"\nINDEX 01 01:30:00\n"
+    if err := os.WriteFile(sheet, []byte(cue), 0o644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+    if err := ScanDirs([]string{mdir}, idx, 1); err != nil {
+        t.Fatalf("ScanDirs: %v", err)
+    }
+    tracks := idx.GetAll()
+    sort.Slice(tracks, func(i, j int) bool { return tracks[i].TrackNumber < tracks[j].TrackNumber })
+    if len(tracks) != 2 {
+        t.Fatalf("expected the file to be replaced by 2 tracks, got %+v", tracks)
+    }
+    intro, song := tracks[0], tracks[1]
+    if intro.Title != "Intro" || intro.Album != "Live" || intro.Artist != "Band" || intro.Cue != sheet {
+        t.Fatalf("unexpected track %+v", intro)
+    }
+    if intro.Start != 0 || intro.End != 90 || song.Start != 90 || song.End != 0 || intro.ID == song.ID {
+        t.Fatalf("unexpected ranges %+v / %+v", intro, song)
+    }
+    if err := os.Remove(sheet); err != nil {
+        t.Fatalf("remove: %v", err)
+    }
+    if _, err := idx.Reload(audio, ScanOptions{}); err != nil {
+        t.Fatalf("Reload: %v", err)
+    }
+    if got := idx.GetAll(); len(got) != 1 || got[0].Virtual() {
+        t.Fatalf("expected the whole file back, got %+v", got)
+    }
+
+    // a CUESHEET tag is used when no sheet file describes the audio
+    parent := &Track{ID: "p", Path: audio, Artist: "Tagged", Duration: 200}
+    s, f, src := findCue(audio, "FILE x.flac WAVE\nTRACK 01 AUDIO\nINDEX 01 00:00:00\nTRACK 02 AUDIO\nINDEX 01 02:00:00\n")
+    if f == nil || src != EmbeddedCue {
+        t.Fatalf("expected the embedded sheet, got %v", src)
+    }
+    parts := cueTracks(parent, s, f, src)
+    if len(parts) != 2 || parts[1].Duration != 80 || parts[1].Artist != "Tagged" || parts[1].Title != "Track 2" {
+        t.Fatalf("unexpected embedded tracks %+v", parts)
+    }
+    idx.RemoveTrack(audio)
+    idx.replaceFile(audio, parts)
+    idx.RemoveTrack(audio)
+    if len(idx.GetAll()) != 0 {
+        t.Fatalf("expected every part removed with the file")
+    }
+}
+
+func TestCueTracksSkipBadEntries(t *testing.T) {
+    parent := &Track{ID: "p", Path: "/m/live.flac", Duration: 300}
+    sheet, err := cue.Parse("FILE live.flac WAVE\n" +
+        "TRACK 01 AUDIO\nINDEX 01 00:00:00\n" +
+        // invalid number: its audio stays with track 1
+        "TRACK 00 AUDIO\nINDEX 01 00:30:00\n" +
+        "TRACK 02 AUDIO\nINDEX 01 01:00:00\n" +
+        // repeated number: kept with track 2 instead of clashing with its ID
+        "TRACK 02 AUDIO\nINDEX 01 02:00:00\n" +
+        "TRACK 03 AUDIO\nINDEX 01 03:00:00\n" +
+        // starts past the end of the file
+        "TRACK 04 AUDIO\nINDEX 01 06:00:00\n")
+    if err != nil {
+        t.Fatalf("Parse: %v", err)
+    }
+    parts := cueTracks(parent, sheet, &sheet.Files[0], EmbeddedCue)
+    if len(parts) != 3 {
+        t.Fatalf("expected 3 tracks, got %+v", parts)
+    }
+    want := []struct {
+        num             int
+        start, end, dur float64
+    }{{1, 0, 60, 60}, {2, 60, 180, 120}, {3, 180, 0, 120}}
+    ids := make(map[string]bool)
+    for i, w := range want {
+        p := parts[i]
+        if p.TrackNumber != w.num || p.Start != w.start || p.End != w.end || p.Duration != w.dur {
+            t.Fatalf("track %d: expected %+v, got start %v end %v duration %v", p.TrackNumber, w, p.Start, p.End, p.Duration)
+        }
+        if ids[p.ID] {
+            t.Fatalf("track %d reuses ID %s", p.TrackNumber, p.ID)
+        }
+        ids[p.ID] = true
+    }
+}
+
+func TestRatingsSurviveRescan(t *testing.T) {
+    base := t.TempDir()
+    mdir := filepath.Join(base, "music")
+    if err := os.MkdirAll(mdir, 0o755); err != nil {
+        t.Fatalf("mkdir: %v", err)
+    }
+    if err := os.WriteFile(filepath.Join(mdir, "a.mp3"), []byte("dummy"), 0o644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+    idx := NewIndexAtBase(base)
+    if err := ScanDirs([]string{mdir}, idx, 1); err != nil {
+        t.Fatalf("ScanDirs: %v", err)
+    }
+    id := idx.GetAll()[0].ID
+    if _, err := idx.UpdateTrack(id, func(t *Track) { t.Rating, t.Loved = 4, true }); err != nil {
+        t.Fatalf("UpdateTrack: %v", err)
+    }
+    if err := ScanDirsWithOptions([]string{mdir}, idx, ScanOptions{Concurrency: 1, ReadRatings: true}); err != nil {
+        t.Fatalf("ScanDirsWithOptions: %v", err)
+    }
+    if got := idx.GetByID(id); got.Rating != 4 || !got.Loved {
+        t.Fatalf("library fields lost on rescan: %+v", got)
+    }
+    if _, err := idx.UpdateTrack("missing", func(*Track) {}); err == nil {
+        t.Fatalf("expected unknown track to be rejected")
+    }
+}
+
+func TestMovedTrackKeepsID(t *testing.T) {
+    base := t.TempDir()
+    mdir := filepath.Join(base, "music")
+    if err := os.MkdirAll(mdir, 0o755); err != nil {
+        t.Fatalf("mkdir: %v", err)
+    }
+    from, to := filepath.Join(mdir, "a.mp3"), filepath.Join(mdir, "b.mp3")
+    if err := os.WriteFile(from, []byte("dummy"), 0o644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+    idx := NewIndexAtBase(base)
+    if err := ScanDirs([]string{mdir}, idx, 1); err != nil {
+        t.Fatalf("ScanDirs: %v", err)
+    }
+    id := idx.GetAll()[0].ID
+    if err := idx.MoveTrack(from, to); err != nil {
+        t.Fatalf("MoveTrack: %v", err)
+    }
+    if err := os.Rename(from, to); err != nil {
+        t.Fatalf("rename: %v", err)
+    }
+    if err := ScanDirs([]string{mdir}, idx, 1); err != nil {
+        t.Fatalf("ScanDirs: %v", err)
+    }
+    if got := idx.GetAll(); len(got) != 1 || got[0].ID != id || got[0].Path != to {
+        t.Fatalf("moved track not kept in place: %+v", got)
+    }
+    if err := idx.MoveTrack(from, to); err == nil {
+        t.Fatalf("expected moving an unknown path to fail")
+    }
+}
+
+func TestRatingFromTags(t *testing.T) {
+    cases := []struct {
+        raw  map[string]any
+        want int
+    }{
+        {map[string]any{"POPM": []byte("no@email\x00\xc4\x00\x00\x00\x01")}, 4},
+        {map[string]any{"POPM": []byte("x\x00\x00"), "POPM_1": []byte("y\x00\xff")}, 5},
+        {map[string]any{"rating": "3"}, 3},
+        {map[string]any{"rating": "60"}, 3},
+        {map[string]any{"fmps_rating": "0.8", "rating": "1"}, 4},
+        {map[string]any{"rate": "\x00\x00\x00\x00100"}, 5},
+        {map[string]any{"rating": "great"}, 0},
+        {map[string]any{}, 0},
+    }
+    for i, c := range cases {
+        if got := ratingFromTags(c.raw); got != c.want {
+            t.Fatalf("case %d: got %d, want %d", i, got, c.want)
+        }
+    }
+}
+
+func TestTempoAndKeyFromTags(t *testing.T) {
+    cases := []struct {
+        raw map[string]any
+        bpm float64
+        key string
+    }{
+        {map[string]any{"TBPM": "128", "TKEY": "Am"}, 128, "Am"},
+        {map[string]any{"bpm": "127.96", "initialkey": "8A"}, 128, "Am"},
+        {map[string]any{"tempo": "90", "key": "F# minor"}, 90, "F#m"},
+        {map[string]any{"initialkey": "\x00Gb"}, 0, "F#"},
+        {map[string]any{"TBPM": "fast", "TKEY": "o"}, 0, ""},
+        {map[string]any{"tmpo": 120}, 0, ""},
+    }
+    for i, c := range cases {
+        key, _ := keyFromTags(c.raw)
+        if got := bpmFromTags(c.raw); got != c.bpm || key != c.key {
+            t.Fatalf("case %d: got %v %q, want %v %q", i, got, key, c.bpm, c.key)
+        }
+    }
+}
@@ -0,0 +1,48 @@
+package indexer
+
+import (
+	"fmt"
+
+	"penguin-tunes/pkg/fingerprint"
+)
+
+// SimilarTrack is a track whose fingerprint resembles a query's; Score runs from 0.5 (unrelated) to 1
+type SimilarTrack struct {
+    Track *Track  `json:"track"`
+    Score float64 `json:"score"`
+}
+
+// FindSimilar returns the fingerprinted tracks that sound like the track with
+// the given ID, best match first. threshold defaults to fingerprint.DefaultThreshold.
+func (idx *Index) FindSimilar(id string, threshold float64) ([]SimilarTrack, error) {
+    if threshold <= 0 {
+        threshold = fingerprint.DefaultThreshold
+    }
+    query := idx.GetByID(id)
+    if query == nil {
+        return nil, fmt.Errorf("track %s not found", id)
+    }
+    if query.Fingerprint == "" {
+        return nil, fmt.Errorf("track %s has not been fingerprinted yet", id)
+    }
+    qfp, err := fingerprint.Decode(query.Fingerprint)
+    if err != nil {
+        return nil, err
+    }
+    m := fingerprint.NewMatcher()
+    byID := make(map[string]*Track)
+    for _, t := range idx.GetAll() {
+        if t.ID == id || t.Fingerprint == "" {
+            continue
+        }
+        if fp, err := fingerprint.Decode(t.Fingerprint); err == nil {
+            m.Add(t.ID, fp)
+            byID[t.ID] = t
+        }
+    }
+    out := []SimilarTrack{}
+    for _, match := range m.Search(qfp, threshold) {
+        out = append(out, SimilarTrack{Track: byID[match.ID], Score: match.Score})
+    }
+    return out, nil
+}
@@ -0,0 +1,178 @@
+package indexer
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// TagFields holds tag values inferred from a path; zero values were not found
+type TagFields struct {
+    Title       string `json:"title"`
+    Artist      string `json:"artist"`
+    Album       string `json:"album"`
+    AlbumArtist string `json:"album_artist"`
+    Genre       string `json:"genre"`
+    Composer    string `json:"composer"`
+    Year        int    `json:"year"`
+    TrackNumber int    `json:"track_number"`
+    DiscNumber  int    `json:"disc_number"`
+}
+
+// placeholders maps template names to the pattern they match; {*} matches anything and is discarded
+var placeholders = map[string]string{
+    "title":       `(.+?)`,
+    "artist":      `(.+?)`,
+    "album":       `(.+?)`,
+    "albumartist": `(.+?)`,
+    "genre":       `(.+?)`,
+    "composer":    `(.+?)`,
+    "year":        `(\d{4})`,
+    "track":       `(\d{1,3})`,
+    "disc":        `(\d{1,2})`,
+    "*":           `(.*?)`,
+}
+
+var placeholderRe = regexp.MustCompile(`\{([a-z*]+)\}`)
+
+// PathTemplate matches the trailing components of a file path, e.g.
+// "{albumartist}/{year} - {album}/{disc}-{track} {title}". The last segment is
+// matched against the file name without its extension.
+type PathTemplate struct {
+    source   string
+    segments []*regexp.Regexp
+    names    [][]string
+}
+
+// ParsePathTemplate compiles a template string
+func ParsePathTemplate(s string) (*PathTemplate, error) {
+    s = strings.Trim(strings.TrimSpace(s), "/")
+    if s == "" {
+        return nil, fmt.Errorf("empty path template")
+    }
+    pt := &PathTemplate{source: s}
+    for _, seg := range strings.Split(s, "/") {
+        var pattern strings.Builder
+        var names []string
+        last := 0
+        for _, loc := range placeholderRe.FindAllStringSubmatchIndex(seg, -1) {
+            name := seg[loc[2]:loc[3]]
+            p, ok := placeholders[name]
+            if !ok {
+                return nil, fmt.Errorf("unknown placeholder {%s} in %q", name, s)
+            }
+            pattern.WriteString(regexp.QuoteMeta(seg[last:loc[0]]))
+            pattern.WriteString(p)
+            names = append(names, name)
+            last = loc[1]
+        }
+        pattern.WriteString(regexp.QuoteMeta(seg[last:]))
+        re, err := regexp.Compile("^" + pattern.String() + "$")
+        if err != nil {
+            return nil, fmt.Errorf("path template %q: %w", s, err)
+        }
+        pt.segments = append(pt.segments, re)
+        pt.names = append(pt.names, names)
+    }
+    return pt, nil
+}
+
+// ParsePathTemplates compiles templates in order
+func ParsePathTemplates(ss []string) ([]*PathTemplate, error) {
+    out := make([]*PathTemplate, 0, len(ss))
+    for _, s := range ss {
+        pt, err := ParsePathTemplate(s)
+        if err != nil {
+            return nil, err
+        }
+        out = append(out, pt)
+    }
+    return out, nil
+}
+
+// String returns the template source
+func (pt *PathTemplate) String() string {
+    return pt.source
+}
+
+// Match extracts fields from path; ok is false if the path doesn't fit the template
+func (pt *PathTemplate) Match(path string) (TagFields, bool) {
+    var f TagFields
+    parts := strings.Split(filepath.ToSlash(filepath.Clean(path)), "/")
+    if len(parts) < len(pt.segments) {
+        return f, false
+    }
+    parts = parts[len(parts)-len(pt.segments):]
+    last := len(parts) - 1
+    parts[last] = strings.TrimSuffix(parts[last], filepath.Ext(parts[last]))
+    for i, re := range pt.segments {
+        m := re.FindStringSubmatch(parts[i])
+        if m == nil {
+            return TagFields{}, false
+        }
+        for j, name := range pt.names[i] {
+            f.set(name, strings.TrimSpace(m[j+1]))
+        }
+    }
+    return f, true
+}
+
+func (f *TagFields) set(name, v string) {
+    n, _ := strconv.Atoi(v)
+    switch name {
+    case "title":
+        f.Title = v
+    case "artist":
+        f.Artist = v
+    case "album":
+        f.Album = v
+    case "albumartist":
+        f.AlbumArtist = v
+    case "genre":
+        f.Genre = v
+    case "composer":
+        f.Composer = v
+    case "year":
+        f.Year = n
+    case "track":
+        f.TrackNumber = n
+    case "disc":
+        f.DiscNumber = n
+    }
+}
+
+// fillMissing copies fields into t where the tags left them empty
+func (f TagFields) fillMissing(t *Track) {
+    fill := func(dst *string, v string) {
+        if *dst == "" {
+            *dst = v
+        }
+    }
+    fill(&t.Title, f.Title)
+    fill(&t.Artist, f.Artist)
+    fill(&t.Album, f.Album)
+    fill(&t.AlbumArtist, f.AlbumArtist)
+    fill(&t.Genre, f.Genre)
+    fill(&t.Composer, f.Composer)
+    if t.Year == 0 {
+        t.Year = f.Year
+    }
+    if t.TrackNumber == 0 {
+        t.TrackNumber = f.TrackNumber
+    }
+    if t.DiscNumber == 0 {
+        t.DiscNumber = f.DiscNumber
+    }
+}
+
+// inferFromPath applies the first template matching path to the fields t is missing
+func inferFromPath(t *Track, templates []*PathTemplate) {
+    for _, pt := range templates {
+        if f, ok := pt.Match(t.Path); ok {
+            f.fillMissing(t)
+            return
+        }
+    }
+}
@@ -0,0 +1,61 @@
+package indexer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPathTemplateMatch(t *testing.T) {
+    pt, err := ParsePathTemplate("{albumartist}/{year} - {album}/{disc}-{track} {title}")
+    if err != nil {
+        t.Fatalf("ParsePathTemplate: %v", err)
+    }
+    f, ok := pt.Match("/music/Miles Davis/1959 - Kind of Blue (Legacy)/1-02 Freddie Freeloader.flac")
+    want := TagFields{AlbumArtist: "Miles Davis", Year: 1959, Album: "Kind of Blue (Legacy)", DiscNumber: 1, TrackNumber: 2, Title: "Freddie Freeloader"}
+    if !ok || f != want {
+        t.Fatalf("unexpected match %v %+v", ok, f)
+    }
+    if _, ok := pt.Match("/music/Miles Davis/Kind of Blue/02 Freddie Freeloader.flac"); ok {
+        t.Fatalf("expected mismatch")
+    }
+    if _, ok := pt.Match("Kind of Blue/1-02 x.mp3"); ok {
+        t.Fatalf("expected mismatch for a path with too few components")
+    }
+    if _, err := ParsePathTemplate("{artist}/{mood}"); err == nil {
+        t.Fatalf("expected unknown placeholder to be rejected")
+    }
+    if _, err := ParsePathTemplates([]string{"{title}", " / "}); err == nil {
+        t.Fatalf("expected empty template to be rejected")
+    }
+}
+
+func TestScanInfersMissingTags(t *testing.T) {
+    base := t.TempDir()
+    album := filepath.Join(base, "music", "Artist", "Album")
+    if err := os.MkdirAll(album, 0o755); err != nil {
+        t.Fatalf("mkdir: %v", err)
+    }
+    for _, n := range []string{"03 - Song.mp3", "Loose.mp3"} {
+        if err := os.WriteFile(filepath.Join(album, n), []byte("dummy"), 0o644); err != nil {
+            t.Fatalf("write: %v", err)
+        }
+    }
+    templates, err := ParsePathTemplates([]string{"{artist}/{album}/{track} - {title}", "{artist}/{*}/{title}"})
+    if err != nil {
+        t.Fatalf("ParsePathTemplates: %v", err)
+    }
+    idx := NewIndexAtBase(base)
+    opts := ScanOptions{Concurrency: 1, Templates: templates}
+    if err := ScanDirsWithOptions([]string{filepath.Join(base, "music")}, idx, opts); err != nil {
+        t.Fatalf("ScanDirsWithOptions: %v", err)
+    }
+    song := idx.GetByID(idFromPath(filepath.Join(album, "03 - Song.mp3")))
+    if song == nil || song.Title != "Song" || song.Artist != "Artist" || song.Album != "Album" || song.TrackNumber != 3 {
+        t.Fatalf("unexpected inferred track %+v", song)
+    }
+    loose := idx.GetByID(idFromPath(filepath.Join(album, "Loose.mp3")))
+    if loose == nil || loose.Title != "Loose" || loose.Artist != "Artist" || loose.Album != "Unknown Album" {
+        t.Fatalf("unexpected fallback track %+v", loose)
+    }
+}
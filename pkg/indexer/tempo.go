@@ -0,0 +1,35 @@
+package indexer
+
+import (
+	"math"
+	"strings"
+
+	"penguin-tunes/pkg/tonal"
+)
+
+// bpmFromTags reads TBPM (ID3) or BPM/TEMPO (Vorbis comments). MP4 tmpo is
+// left out because the tag reader keeps only its high byte.
+func bpmFromTags(raw map[string]any) float64 {
+    for _, k := range []string{"TBPM", "bpm", "tempo"} {
+        if v, ok := number(raw[k]); ok && v > 0 && v < 1000 {
+            return math.Round(v*10) / 10
+        }
+    }
+    return 0
+}
+
+// keyFromTags reads TKEY (ID3), INITIALKEY/KEY (Vorbis comments) or the iTunes
+// freeform initialkey (MP4) and rewrites it in the usual spelling
+func keyFromTags(raw map[string]any) (string, bool) {
+    for _, k := range []string{"TKEY", "initialkey", "key"} {
+        s, ok := raw[k].(string)
+        if !ok {
+            continue
+        }
+        // MP4 freeform values may carry leftover locale bytes
+        if key, err := tonal.ParseKey(strings.Trim(s, "\x00 ")); err == nil {
+            return key.String(), true
+        }
+    }
+    return "", false
+}
@@ -2,7 +2,7 @@ package indexer
 
 import (
 	"context"
-	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sync"
@@ -28,7 +28,11 @@ type Watcher struct {
     mtx       sync.Mutex
     saveMtx   sync.Mutex
     saveTimer *time.Timer
+    // saves counts scheduled and running saves, so Close can wait for them
+    saves     sync.WaitGroup
+    done      chan struct{}
     emitter   EventEmitter
+    log       *slog.Logger
 }
 
 // NewWatcher creates a new Watcher; ctx may be used by emitter
@@ -37,19 +41,25 @@ func NewWatcher(ctx context.Context, idx *Index, cm *cfg.Manager, emitter EventE
     if err != nil {
         return nil, err
     }
-    return &Watcher{w: w, idx: idx, cm: cm, ctx: ctx, emitter: emitter}, nil
+    return &Watcher{w: w, idx: idx, cm: cm, ctx: ctx, emitter: emitter, log: slog.Default()}, nil
 }
 
-// Start starts the watching loop, returns stop func
+// SetLogger sets where the watcher logs; call it before Start
+func (wa *Watcher) SetLogger(l *slog.Logger) {
+    wa.log = l
+}
+
+// Start starts the watching loop, returns stop func, which is Close
 func (wa *Watcher) Start() (func(), error) {
     cfg := wa.cm.GetConfig()
     for _, d := range cfg.SrcDirs {
         if err := wa.addWatchesRecursive(d); err != nil {
-            fmt.Printf("watch add error: %v\n", err)
+            wa.log.Warn("watch failed", "dir", d, "err", err)
         }
     }
-    stop := make(chan struct{})
+    wa.done = make(chan struct{})
     go func() {
+        defer close(wa.done)
         for {
             select {
             case ev, ok := <-wa.w.Events:
@@ -61,15 +71,11 @@ func (wa *Watcher) Start() (func(), error) {
                 if !ok {
                     return
                 }
-                fmt.Printf("fsnotify error: %v\n", err)
-            case <-stop:
-                wa.w.Close()
-                return
+                wa.log.Error("fsnotify failed", "err", err)
             }
         }
     }()
-    closer := func() { close(stop) }
-    return closer, nil
+    return func() { wa.Close() }, nil
 }
 
 func (wa *Watcher) addWatchesRecursive(root string) error {
@@ -89,52 +95,89 @@ func (wa *Watcher) addWatchesRecursive(root string) error {
 
 func (wa *Watcher) handleEvent(ev fsnotify.Event) {
     p := ev.Name
+    wa.log.Debug("event", "op", ev.Op.String(), "path", p)
     if ev.Op&fsnotify.Create == fsnotify.Create {
         if isDir(p) {
             wa.w.Add(p) // best-effort
             return
         }
-        if isAudioFile(p) {
-            t, err := readMetadata(p, wa.idx.cfgDir)
-            if err == nil {
-                wa.idx.AddOrUpdateTrack(t)
-                wa.scheduleSave(1 * time.Second)
-            }
-        }
+        wa.reload(p)
     }
     if ev.Op&fsnotify.Write == fsnotify.Write {
-        if isAudioFile(p) {
-            t, err := readMetadata(p, wa.idx.cfgDir)
-            if err == nil {
-                wa.idx.AddOrUpdateTrack(t)
-                wa.scheduleSave(1 * time.Second)
-            }
-        }
+        wa.reload(p)
     }
     if ev.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
         if !isDir(p) && isAudioFile(p) {
             wa.idx.RemoveTrack(p)
             wa.scheduleSave(1 * time.Second)
         }
+        if IsCueFile(p) {
+            // the tracks it cut go back to whole files
+            for _, audio := range wa.idx.cutBy(p) {
+                wa.reload(audio)
+            }
+        }
+    }
+}
+
+// reload re-reads an audio file, or the audio files a changed CUE sheet may describe
+func (wa *Watcher) reload(p string) {
+    var paths []string
+    switch {
+    case isAudioFile(p):
+        paths = []string{p}
+    case IsCueFile(p):
+        paths = append(wa.idx.cutBy(p), audioBeside(p)...)
+    }
+    seen := make(map[string]bool)
+    for _, audio := range paths {
+        if seen[audio] {
+            continue
+        }
+        seen[audio] = true
+        tracks, err := readTracks(audio, wa.idx.cfgDir, wa.scanOptions())
+        if err != nil {
+            wa.log.Debug("read failed", "path", audio, "err", err)
+            continue
+        }
+        wa.idx.replaceFile(audio, tracks)
+        wa.scheduleSave(1 * time.Second)
     }
 }
 
+// scanOptions derives metadata options from the current config
+func (wa *Watcher) scanOptions() ScanOptions {
+    // templates are validated when the config is saved
+    opts, _ := ConfigScanOptions(wa.cm.GetConfig())
+    return opts
+}
+
+// scheduleSave saves the index after d, unless another change comes first
 func (wa *Watcher) scheduleSave(d time.Duration) {
     wa.saveMtx.Lock()
     defer wa.saveMtx.Unlock()
-    if wa.saveTimer != nil {
-        wa.saveTimer.Stop()
+    if wa.saveTimer != nil && wa.saveTimer.Stop() {
+        // the stopped save won't run
+        wa.saves.Done()
     }
+    wa.saves.Add(1)
     wa.saveTimer = time.AfterFunc(d, func() {
-        if err := wa.idx.SaveToFile(); err != nil {
-            fmt.Printf("index save error: %v\n", err)
-        }
+        defer wa.saves.Done()
+        wa.save()
         if wa.emitter != nil {
             wa.emitter.Emit(wa.ctx, "index-updated", wa.idx.GetAll())
         }
     })
 }
 
+func (wa *Watcher) save() {
+    if err := wa.idx.SaveToFile(); err != nil {
+        wa.log.Error("index save failed", "err", err)
+    }
+}
+
+// Close stops watching. A save still waiting out its debounce is done at
+// once, and Close returns after it and any running save finished.
 func (wa *Watcher) Close() error {
     wa.mtx.Lock()
     defer wa.mtx.Unlock()
@@ -142,10 +185,21 @@ func (wa *Watcher) Close() error {
         return nil
     }
     wa.closed = true
-    if wa.saveTimer != nil {
-        wa.saveTimer.Stop()
+    err := wa.w.Close()
+    if wa.done != nil {
+        // no event can schedule a save once the loop is gone
+        <-wa.done
+    }
+    wa.saveMtx.Lock()
+    pending := wa.saveTimer != nil && wa.saveTimer.Stop()
+    wa.saveTimer = nil
+    wa.saveMtx.Unlock()
+    if pending {
+        wa.save()
+        wa.saves.Done()
     }
-    return wa.w.Close()
+    wa.saves.Wait()
+    return err
 }
 
 func isDir(path string) bool {
@@ -61,3 +61,48 @@ func TestWatcherEmitsOnCreate(t *testing.T) {
         t.Fatalf("expected event, timed out")
     }
 }
+
+func TestWatcherCloseSavesPendingChanges(t *testing.T) {
+    base := t.TempDir()
+    m, err := cfg.NewManagerAt(base)
+    if err != nil {
+        t.Fatalf("NewManagerAt: %v", err)
+    }
+    c := m.GetConfig()
+    music := filepath.Join(base, "music")
+    if err := os.MkdirAll(music, 0o755); err != nil {
+        t.Fatalf("mkdir: %v", err)
+    }
+    c.SrcDirs = []string{music}
+    if err := m.SaveConfig(c); err != nil {
+        t.Fatalf("SaveConfig: %v", err)
+    }
+    idx := NewIndexAtBase(base)
+    wa, err := NewWatcher(context.Background(), idx, m, nil)
+    if err != nil {
+        t.Fatalf("NewWatcher: %v", err)
+    }
+    stop, err := wa.Start()
+    if err != nil {
+        t.Fatalf("Start: %v", err)
+    }
+    if err := os.WriteFile(filepath.Join(music, "new.mp3"), []byte("dummy"), 0o644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+    deadline := time.Now().Add(3 * time.Second)
+    for len(idx.GetAll()) == 0 {
+        if time.Now().After(deadline) {
+            t.Fatalf("the watcher never indexed the file")
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+    // well within the save debounce
+    stop()
+    saved := NewIndexAtBase(base)
+    if err := saved.LoadFromFile(); err != nil {
+        t.Fatalf("LoadFromFile: %v", err)
+    }
+    if len(saved.GetAll()) != 1 {
+        t.Fatalf("expected Close to save the pending change, got %d tracks", len(saved.GetAll()))
+    }
+}
@@ -0,0 +1,184 @@
+// Package instance keeps the app to one running instance per user. The first
+// launch listens on a Unix socket in the runtime dir; later launches hand
+// their command line to it and exit.
+package instance
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrRunning is returned by Listen when another instance owns the socket
+var ErrRunning = errors.New("another instance is running")
+
+// Request is the command line of a launch, forwarded to the running instance
+type Request struct {
+    // Dir is the working dir of the launch, for resolving relative paths
+    Dir  string   `json:"dir"`
+    Args []string `json:"args"`
+}
+
+type reply struct {
+    Error string `json:"error,omitempty"`
+}
+
+// Handler runs a forwarded request; its error is reported to the sender
+type Handler func(req Request) error
+
+// SocketPath is the socket in $XDG_RUNTIME_DIR, or in a dir of the temp
+// dir private to the user when that isn't set
+func SocketPath() (string, error) {
+    if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+        return filepath.Join(dir, "penguin-tunes.sock"), nil
+    }
+    dir := filepath.Join(os.TempDir(), "penguin-tunes-"+strconv.Itoa(os.Getuid()))
+    if err := privateDir(dir); err != nil {
+        return "", err
+    }
+    return filepath.Join(dir, "instance.sock"), nil
+}
+
+// privateDir creates dir for the user alone, or checks that an existing one
+// is a real dir of the user, as anyone can create names in the temp dir
+func privateDir(dir string) error {
+    if err := os.Mkdir(dir, 0o700); err != nil && !os.IsExist(err) {
+        return fmt.Errorf("socket dir: %w", err)
+    }
+    fi, err := os.Lstat(dir)
+    if err != nil {
+        return fmt.Errorf("socket dir: %w", err)
+    }
+    if !fi.IsDir() || !ownedByUser(fi) {
+        return fmt.Errorf("socket dir %s is not a dir of this user", dir)
+    }
+    if fi.Mode().Perm()&0o077 != 0 {
+        return os.Chmod(dir, 0o700)
+    }
+    return nil
+}
+
+// Server owns the socket of the running instance
+type Server struct {
+    ln   net.Listener
+    path string
+
+    mtx    sync.Mutex
+    closed bool
+}
+
+// Listen claims path for this instance. It fails with ErrRunning when
+// another instance answers there; a socket left by one that crashed is
+// replaced.
+func Listen(path string) (*Server, error) {
+    // the socket accepts commands, so other users are kept out
+    ln, err := listenPrivate(path)
+    if errors.Is(err, syscall.EADDRINUSE) {
+        if c, derr := net.DialTimeout("unix", path, time.Second); derr == nil {
+            c.Close()
+            return nil, ErrRunning
+        }
+        os.Remove(path)
+        ln, err = listenPrivate(path)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("listen %s: %w", path, err)
+    }
+    return &Server{ln: ln, path: path}, nil
+}
+
+// Serve runs handle for each forwarded request until Close. Requests
+// sent before Serve wait in the listen backlog.
+func (s *Server) Serve(handle Handler) error {
+    for {
+        conn, err := s.ln.Accept()
+        if err != nil {
+            s.mtx.Lock()
+            closed := s.closed
+            s.mtx.Unlock()
+            if closed {
+                return nil
+            }
+            return err
+        }
+        go serveConn(conn, handle)
+    }
+}
+
+func serveConn(conn net.Conn, handle Handler) {
+    defer conn.Close()
+    conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+    line, err := bufio.NewReader(conn).ReadBytes('\n')
+    if err != nil {
+        return
+    }
+    var req Request
+    var rep reply
+    if err := json.Unmarshal(line, &req); err != nil {
+        rep.Error = fmt.Sprintf("bad request: %v", err)
+    } else if err := handle(req); err != nil {
+        rep.Error = err.Error()
+    }
+    b, _ := json.Marshal(rep)
+    conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+    conn.Write(append(b, '\n'))
+}
+
+// Close stops serving and removes the socket
+func (s *Server) Close() error {
+    s.mtx.Lock()
+    if s.closed {
+        s.mtx.Unlock()
+        return nil
+    }
+    s.closed = true
+    s.mtx.Unlock()
+    // closing a unix listener unlinks its socket file
+    return s.ln.Close()
+}
+
+// Send forwards req to the instance listening at path and returns the
+// error its handler reported. Only a socket of the same user is trusted
+// with the command line.
+func Send(path string, req Request) error {
+    fi, err := os.Lstat(path)
+    if err != nil {
+        return fmt.Errorf("connect to running instance: %w", err)
+    }
+    if !isOwnSocket(fi) {
+        return fmt.Errorf("connect to running instance: %s is not a socket of this user", path)
+    }
+    conn, err := net.DialTimeout("unix", path, time.Second)
+    if err != nil {
+        return fmt.Errorf("connect to running instance: %w", err)
+    }
+    defer conn.Close()
+    conn.SetDeadline(time.Now().Add(30 * time.Second))
+    b, err := json.Marshal(req)
+    if err != nil {
+        return err
+    }
+    if _, err := conn.Write(append(b, '\n')); err != nil {
+        return fmt.Errorf("send to running instance: %w", err)
+    }
+    line, err := bufio.NewReader(conn).ReadBytes('\n')
+    if err != nil {
+        return fmt.Errorf("read reply: %w", err)
+    }
+    var rep reply
+    if err := json.Unmarshal(line, &rep); err != nil {
+        return fmt.Errorf("read reply: %w", err)
+    }
+    if rep.Error != "" {
+        return errors.New(rep.Error)
+    }
+    return nil
+}
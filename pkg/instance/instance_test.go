@@ -0,0 +1,120 @@
+package instance
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestForwarding(t *testing.T) {
+    sock := filepath.Join(t.TempDir(), "test.sock")
+    srv, err := Listen(sock)
+    if err != nil {
+        t.Fatalf("Listen: %v", err)
+    }
+    got := make(chan Request, 1)
+    go srv.Serve(func(req Request) error {
+        if len(req.Args) > 0 && req.Args[0] == "--bad" {
+            return errors.New("unknown option --bad")
+        }
+        got <- req
+        return nil
+    })
+    defer srv.Close()
+
+    if _, err := Listen(sock); !errors.Is(err, ErrRunning) {
+        t.Fatalf("expected ErrRunning for a second instance, got %v", err)
+    }
+    if err := Send(sock, Request{Dir: "/home/u", Args: []string{"--enqueue", "a b.flac"}}); err != nil {
+        t.Fatalf("Send: %v", err)
+    }
+    req := <-got
+    if req.Dir != "/home/u" || strings.Join(req.Args, "|") != "--enqueue|a b.flac" {
+        t.Fatalf("unexpected request %+v", req)
+    }
+    if err := Send(sock, Request{Args: []string{"--bad"}}); err == nil || err.Error() != "unknown option --bad" {
+        t.Fatalf("expected the handler's error, got %v", err)
+    }
+
+    srv.Close()
+    if _, err := os.Stat(sock); !os.IsNotExist(err) {
+        t.Fatalf("expected Close to remove the socket, got %v", err)
+    }
+}
+
+func TestStaleSocketReplaced(t *testing.T) {
+    sock := filepath.Join(t.TempDir(), "test.sock")
+    // a socket file nobody listens on, as left by a crash
+    ln, err := net.Listen("unix", sock)
+    if err != nil {
+        t.Fatalf("listen: %v", err)
+    }
+    ln.(*net.UnixListener).SetUnlinkOnClose(false)
+    ln.Close()
+    if _, err := os.Stat(sock); err != nil {
+        t.Fatalf("expected a stale socket: %v", err)
+    }
+    srv, err := Listen(sock)
+    if err != nil {
+        t.Fatalf("Listen over a stale socket: %v", err)
+    }
+    srv.Close()
+}
+
+func TestSocketPathFallbackIsPrivate(t *testing.T) {
+    tmp := t.TempDir()
+    t.Setenv("XDG_RUNTIME_DIR", "")
+    t.Setenv("TMPDIR", tmp)
+    sock, err := SocketPath()
+    if err != nil {
+        t.Fatalf("SocketPath: %v", err)
+    }
+    dir := filepath.Dir(sock)
+    if filepath.Dir(dir) != tmp {
+        t.Fatalf("expected a dir in the temp dir, got %s", sock)
+    }
+    // a dir left open to others is closed again
+    if err := os.Chmod(dir, 0o755); err != nil {
+        t.Fatalf("chmod: %v", err)
+    }
+    if _, err := SocketPath(); err != nil {
+        t.Fatalf("SocketPath: %v", err)
+    }
+    if fi, err := os.Stat(dir); err != nil || fi.Mode().Perm() != 0o700 {
+        t.Fatalf("expected a 0700 dir, got %v %v", fi.Mode(), err)
+    }
+
+    srv, err := Listen(sock)
+    if err != nil {
+        t.Fatalf("Listen: %v", err)
+    }
+    fi, err := os.Stat(sock)
+    srv.Close()
+    if err != nil || fi.Mode().Perm() != 0o600 {
+        t.Fatalf("expected a 0600 socket, got %v %v", fi.Mode(), err)
+    }
+
+    // a name planted in the temp dir is refused
+    if err := os.RemoveAll(dir); err != nil {
+        t.Fatalf("remove: %v", err)
+    }
+    if err := os.Symlink(t.TempDir(), dir); err != nil {
+        t.Fatalf("symlink: %v", err)
+    }
+    if _, err := SocketPath(); err == nil {
+        t.Fatalf("expected a symlinked socket dir to be refused")
+    }
+}
+
+func TestSendOnlyToSockets(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "test.sock")
+    if err := os.WriteFile(path, nil, 0o600); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+    if err := Send(path, Request{Args: []string{"a.flac"}}); err == nil || !strings.Contains(err.Error(), "not a socket") {
+        t.Fatalf("expected a plain file to be refused, got %v", err)
+    }
+}
@@ -0,0 +1,28 @@
+//go:build unix
+
+package instance
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// listenPrivate binds a socket only its owner can connect to. The umask
+// applies at bind time, leaving no window for a chmod to close.
+func listenPrivate(path string) (net.Listener, error) {
+    old := syscall.Umask(0o177)
+    defer syscall.Umask(old)
+    return net.Listen("unix", path)
+}
+
+// ownedByUser reports whether fi belongs to the current user
+func ownedByUser(fi os.FileInfo) bool {
+    st, ok := fi.Sys().(*syscall.Stat_t)
+    return ok && int(st.Uid) == os.Getuid()
+}
+
+// isOwnSocket reports whether fi is a socket of the current user
+func isOwnSocket(fi os.FileInfo) bool {
+    return fi.Mode()&os.ModeSocket != 0 && ownedByUser(fi)
+}
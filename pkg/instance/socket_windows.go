@@ -0,0 +1,23 @@
+//go:build windows
+
+package instance
+
+import (
+	"net"
+	"os"
+)
+
+// listenPrivate binds the socket; it lives in the user's own temp dir
+func listenPrivate(path string) (net.Listener, error) {
+    return net.Listen("unix", path)
+}
+
+// ownedByUser can't tell owners apart from file info here; the temp dir
+// is already private to the user
+func ownedByUser(fi os.FileInfo) bool {
+    return true
+}
+
+func isOwnSocket(fi os.FileInfo) bool {
+    return true
+}
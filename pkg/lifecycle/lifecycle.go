@@ -0,0 +1,148 @@
+// Package lifecycle tracks the background jobs of the app so shutdown can
+// cancel them, wait for them to wind down and then release what they used.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrStopped is returned by Go once Shutdown has started
+var ErrStopped = errors.New("shutting down")
+
+// Group runs jobs under a context that Shutdown cancels
+type Group struct {
+    ctx    context.Context
+    cancel context.CancelFunc
+    log    *slog.Logger
+
+    mtx     sync.Mutex
+    stopped bool
+    wg      sync.WaitGroup
+    // running counts the jobs of each name, for reporting stuck ones
+    running map[string]int
+    hooks   []hook
+    done    chan struct{}
+    err     error
+}
+
+type hook struct {
+    name string
+    fn   func() error
+}
+
+// New creates a group whose jobs are cancelled with parent or by Shutdown
+func New(parent context.Context) *Group {
+    ctx, cancel := context.WithCancel(parent)
+    return &Group{ctx: ctx, cancel: cancel, log: slog.Default(), running: make(map[string]int)}
+}
+
+// SetLogger sets where job failures and shutdown are logged
+func (g *Group) SetLogger(l *slog.Logger) {
+    g.mtx.Lock()
+    defer g.mtx.Unlock()
+    g.log = l
+}
+
+// Context is cancelled when shutdown starts
+func (g *Group) Context() context.Context {
+    return g.ctx
+}
+
+// Go runs fn in the background under the group's context. Errors other than
+// cancellation are logged under name. After Shutdown started fn is not run.
+func (g *Group) Go(name string, fn func(ctx context.Context) error) error {
+    g.mtx.Lock()
+    defer g.mtx.Unlock()
+    if g.stopped {
+        return ErrStopped
+    }
+    g.wg.Add(1)
+    g.running[name]++
+    log := g.log
+    go func() {
+        defer g.finish(name)
+        if err := fn(g.ctx); err != nil && !errors.Is(err, context.Canceled) {
+            log.Error("job failed", "job", name, "err", err)
+        }
+    }()
+    return nil
+}
+
+func (g *Group) finish(name string) {
+    g.mtx.Lock()
+    if g.running[name]--; g.running[name] == 0 {
+        delete(g.running, name)
+    }
+    g.mtx.Unlock()
+    g.wg.Done()
+}
+
+// Running lists the names of the running jobs
+func (g *Group) Running() []string {
+    g.mtx.Lock()
+    defer g.mtx.Unlock()
+    names := make([]string, 0, len(g.running))
+    for name := range g.running {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// OnShutdown registers fn to run once the jobs have stopped, e.g. to flush
+// a store or close a server. Hooks run in reverse order of registration.
+func (g *Group) OnShutdown(name string, fn func() error) {
+    g.mtx.Lock()
+    defer g.mtx.Unlock()
+    g.hooks = append(g.hooks, hook{name, fn})
+}
+
+// Shutdown cancels the jobs, waits up to timeout for them to return and
+// then runs the hooks, even when some jobs are still stuck. Later calls
+// wait for the first and return its result.
+func (g *Group) Shutdown(timeout time.Duration) error {
+    g.mtx.Lock()
+    if g.stopped {
+        done := g.done
+        g.mtx.Unlock()
+        <-done
+        return g.err
+    }
+    g.stopped = true
+    g.done = make(chan struct{})
+    log := g.log
+    g.mtx.Unlock()
+    defer close(g.done)
+
+    g.cancel()
+    waited := make(chan struct{})
+    go func() {
+        g.wg.Wait()
+        close(waited)
+    }()
+    select {
+    case <-waited:
+    case <-time.After(timeout):
+        log.Warn("jobs still running at shutdown", "jobs", g.Running())
+    }
+
+    g.mtx.Lock()
+    hooks := g.hooks
+    g.hooks = nil
+    g.mtx.Unlock()
+    var errs []error
+    for i := len(hooks) - 1; i >= 0; i-- {
+        if err := hooks[i].fn(); err != nil {
+            log.Error("shutdown failed", "step", hooks[i].name, "err", err)
+            errs = append(errs, fmt.Errorf("%s: %w", hooks[i].name, err))
+        }
+    }
+    g.err = errors.Join(errs...)
+    return g.err
+}
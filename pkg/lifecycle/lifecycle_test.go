@@ -0,0 +1,79 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestShutdownCancelsJobsThenRunsHooks(t *testing.T) {
+    g := New(context.Background())
+    var order []string
+    stopped := make(chan struct{})
+    started := make(chan struct{})
+    if err := g.Go("scan", func(ctx context.Context) error {
+        close(started)
+        <-ctx.Done()
+        // hooks must not run before the job has wound down
+        time.Sleep(20 * time.Millisecond)
+        close(stopped)
+        return ctx.Err()
+    }); err != nil {
+        t.Fatalf("Go: %v", err)
+    }
+    <-started
+    if got := g.Running(); !reflect.DeepEqual(got, []string{"scan"}) {
+        t.Fatalf("expected scan running, got %v", got)
+    }
+    g.OnShutdown("first", func() error { order = append(order, "first"); return nil })
+    g.OnShutdown("second", func() error {
+        select {
+        case <-stopped:
+        default:
+            t.Errorf("hook ran before the job stopped")
+        }
+        order = append(order, "second")
+        return errors.New("boom")
+    })
+
+    err := g.Shutdown(time.Second)
+    if err == nil || err.Error() != "second: boom" {
+        t.Fatalf("expected the hook error, got %v", err)
+    }
+    if !reflect.DeepEqual(order, []string{"second", "first"}) {
+        t.Fatalf("expected hooks in reverse order, got %v", order)
+    }
+    if len(g.Running()) != 0 {
+        t.Fatalf("expected no running jobs, got %v", g.Running())
+    }
+    if err := g.Go("late", func(context.Context) error { return nil }); !errors.Is(err, ErrStopped) {
+        t.Fatalf("expected ErrStopped after shutdown, got %v", err)
+    }
+    if err2 := g.Shutdown(time.Second); err2 != err {
+        t.Fatalf("a second Shutdown should return the first result, got %v", err2)
+    }
+}
+
+func TestShutdownTimeout(t *testing.T) {
+    g := New(context.Background())
+    release := make(chan struct{})
+    defer close(release)
+    g.Go("stuck", func(context.Context) error {
+        <-release
+        return nil
+    })
+    ran := false
+    g.OnShutdown("flush", func() error { ran = true; return nil })
+    start := time.Now()
+    if err := g.Shutdown(50 * time.Millisecond); err != nil {
+        t.Fatalf("Shutdown: %v", err)
+    }
+    if time.Since(start) > time.Second {
+        t.Fatalf("Shutdown waited past its timeout")
+    }
+    if !ran {
+        t.Fatalf("hooks must run even when a job is stuck")
+    }
+}
@@ -0,0 +1,298 @@
+// Package logging writes the app's diagnostics as JSON lines to rotating
+// files in the data dir and keeps the recent entries for the diagnostics
+// panel. Every component logs through its own slog.Logger and level.
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+    // FileName is the current log file inside the log dir
+    FileName = "penguin-tunes.log"
+    // MaxSize is the size a log file may reach before it is rotated
+    MaxSize = 5 << 20
+    // Backups is how many rotated files are kept
+    Backups = 3
+    // RecentSize is how many entries Recent can return
+    RecentSize = 1000
+)
+
+// Entry is a logged record as the diagnostics panel shows it
+type Entry struct {
+    Time      time.Time      `json:"time"`
+    Level     string         `json:"level"`
+    Component string         `json:"component"`
+    Message   string         `json:"msg"`
+    Attrs     map[string]any `json:"attrs,omitempty"`
+}
+
+// Options tunes Open
+type Options struct {
+    // Echo also receives every record as text, e.g. stderr while developing
+    Echo io.Writer
+}
+
+// Logs is the sink of all component loggers
+type Logs struct {
+    file *RotatingFile
+    out  slog.Handler
+    echo slog.Handler
+
+    mtx    sync.RWMutex
+    def    slog.Level
+    levels map[string]slog.Level
+    recent []Entry
+    next   int
+}
+
+// Open starts logging to dir/FileName, creating dir. The recent entries
+// start with the tail of the existing file, so the previous run shows too.
+func Open(dir string, opts Options) (*Logs, error) {
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+        return nil, fmt.Errorf("mkdir log dir: %w", err)
+    }
+    path := filepath.Join(dir, FileName)
+    l := &Logs{def: slog.LevelInfo, levels: map[string]slog.Level{}}
+    l.seed(path)
+    f, err := OpenRotating(path, MaxSize, Backups)
+    if err != nil {
+        return nil, err
+    }
+    l.file = f
+    // levels are checked per component before records reach the handlers
+    all := &slog.HandlerOptions{Level: slog.LevelDebug}
+    l.out = slog.NewJSONHandler(f, all)
+    if opts.Echo != nil {
+        l.echo = slog.NewTextHandler(opts.Echo, all)
+    }
+    return l, nil
+}
+
+// Close closes the log file; later records only reach the recent entries
+func (l *Logs) Close() error {
+    return l.file.Close()
+}
+
+// Logger returns the logger of a component, e.g. "indexer"
+func (l *Logs) Logger(component string) *slog.Logger {
+    h := &handler{logs: l, component: component}
+    attr := []slog.Attr{slog.String("component", component)}
+    h.out = l.out.WithAttrs(attr)
+    if l.echo != nil {
+        h.echo = l.echo.WithAttrs(attr)
+    }
+    return slog.New(h)
+}
+
+// ParseLevel reads debug, info, warn or error; empty means info
+func ParseLevel(s string) (slog.Level, error) {
+    if s == "" {
+        return slog.LevelInfo, nil
+    }
+    var lvl slog.Level
+    if err := lvl.UnmarshalText([]byte(s)); err != nil {
+        return 0, fmt.Errorf("unknown log level %q", s)
+    }
+    return lvl, nil
+}
+
+// SetLevels sets the level of components not in components to def
+func (l *Logs) SetLevels(def string, components map[string]string) error {
+    d, err := ParseLevel(def)
+    if err != nil {
+        return err
+    }
+    levels := make(map[string]slog.Level, len(components))
+    for c, s := range components {
+        lvl, err := ParseLevel(s)
+        if err != nil {
+            return fmt.Errorf("%s: %w", c, err)
+        }
+        levels[c] = lvl
+    }
+    l.mtx.Lock()
+    l.def, l.levels = d, levels
+    l.mtx.Unlock()
+    return nil
+}
+
+func (l *Logs) level(component string) slog.Level {
+    l.mtx.RLock()
+    defer l.mtx.RUnlock()
+    if lvl, ok := l.levels[component]; ok {
+        return lvl
+    }
+    return l.def
+}
+
+// Recent returns up to limit of the latest entries at or above min, oldest
+// first; component "" matches all and limit 0 means all kept
+func (l *Logs) Recent(limit int, min slog.Level, component string) []Entry {
+    l.mtx.RLock()
+    defer l.mtx.RUnlock()
+    out := []Entry{}
+    n := len(l.recent)
+    for i := 0; i < n; i++ {
+        // the ring starts at next once it has wrapped
+        e := l.recent[(l.next+i)%n]
+        if component != "" && e.Component != component {
+            continue
+        }
+        if lvl, err := ParseLevel(e.Level); err == nil && lvl < min {
+            continue
+        }
+        out = append(out, e)
+    }
+    if limit > 0 && len(out) > limit {
+        out = out[len(out)-limit:]
+    }
+    return out
+}
+
+func (l *Logs) remember(e Entry) {
+    l.mtx.Lock()
+    defer l.mtx.Unlock()
+    if len(l.recent) < RecentSize {
+        l.recent = append(l.recent, e)
+        return
+    }
+    l.recent[l.next] = e
+    l.next = (l.next + 1) % RecentSize
+}
+
+// seed loads the last entries of the log file at path
+func (l *Logs) seed(path string) {
+    b, err := os.ReadFile(path)
+    if err != nil {
+        return
+    }
+    lines := bytes.Split(bytes.TrimSpace(b), []byte("\n"))
+    if len(lines) > RecentSize {
+        lines = lines[len(lines)-RecentSize:]
+    }
+    for _, line := range lines {
+        var m map[string]any
+        if json.Unmarshal(line, &m) != nil {
+            continue
+        }
+        e := Entry{}
+        if s, ok := m[slog.TimeKey].(string); ok {
+            e.Time, _ = time.Parse(time.RFC3339Nano, s)
+        }
+        e.Level, _ = m[slog.LevelKey].(string)
+        e.Message, _ = m[slog.MessageKey].(string)
+        e.Component, _ = m["component"].(string)
+        for _, k := range []string{slog.TimeKey, slog.LevelKey, slog.MessageKey, "component"} {
+            delete(m, k)
+        }
+        if len(m) > 0 {
+            e.Attrs = m
+        }
+        l.remember(e)
+    }
+}
+
+// handler filters by the component's level and sends records to the file,
+// the echo and the recent entries
+type handler struct {
+    logs      *Logs
+    component string
+    out       slog.Handler
+    echo      slog.Handler
+    // attrs and group mirror WithAttrs and WithGroup for the recent entries
+    attrs []slog.Attr
+    group string
+}
+
+func (h *handler) Enabled(_ context.Context, lvl slog.Level) bool {
+    return lvl >= h.logs.level(h.component)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+    e := Entry{Time: r.Time, Level: r.Level.String(), Component: h.component, Message: r.Message}
+    attrs := slices.Clone(h.attrs)
+    r.Attrs(func(a slog.Attr) bool {
+        attrs = append(attrs, prefixed(h.group, a))
+        return true
+    })
+    if len(attrs) > 0 {
+        e.Attrs = make(map[string]any, len(attrs))
+        for _, a := range attrs {
+            addAttr(e.Attrs, "", a)
+        }
+    }
+    h.logs.remember(e)
+    if h.echo != nil {
+        h.echo.Handle(ctx, r.Clone())
+    }
+    return h.out.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+    c := *h
+    c.out = h.out.WithAttrs(attrs)
+    if h.echo != nil {
+        c.echo = h.echo.WithAttrs(attrs)
+    }
+    c.attrs = slices.Clip(h.attrs)
+    for _, a := range attrs {
+        c.attrs = append(c.attrs, prefixed(h.group, a))
+    }
+    return &c
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+    if name == "" {
+        return h
+    }
+    c := *h
+    c.out = h.out.WithGroup(name)
+    if h.echo != nil {
+        c.echo = h.echo.WithGroup(name)
+    }
+    c.group = h.group + name + "."
+    return &c
+}
+
+func prefixed(group string, a slog.Attr) slog.Attr {
+    a.Key = group + a.Key
+    return a
+}
+
+// addAttr flattens a into m with dotted keys, turning values into what
+// encodes well as JSON
+func addAttr(m map[string]any, prefix string, a slog.Attr) {
+    v := a.Value.Resolve()
+    key := prefix + a.Key
+    switch v.Kind() {
+    case slog.KindGroup:
+        // an empty key inlines the group
+        if a.Key != "" {
+            prefix = key + "."
+        }
+        for _, g := range v.Group() {
+            addAttr(m, prefix, g)
+        }
+    case slog.KindDuration:
+        m[key] = v.Duration().String()
+    case slog.KindAny:
+        if err, ok := v.Any().(error); ok {
+            m[key] = err.Error()
+            return
+        }
+        m[key] = v.Any()
+    default:
+        m[key] = v.Any()
+    }
+}
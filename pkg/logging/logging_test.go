@@ -0,0 +1,123 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestComponentLevels(t *testing.T) {
+    dir := t.TempDir()
+    var echo bytes.Buffer
+    logs, err := Open(dir, Options{Echo: &echo})
+    if err != nil {
+        t.Fatalf("Open: %v", err)
+    }
+    defer logs.Close()
+    if err := logs.SetLevels("warn", map[string]string{"watcher": "debug"}); err != nil {
+        t.Fatalf("SetLevels: %v", err)
+    }
+    logs.Logger("indexer").Info("hidden")
+    logs.Logger("indexer").Error("save failed", "err", errors.New("disk full"))
+    logs.Logger("watcher").With("dir", "/music").WithGroup("ev").Debug("event", "op", "CREATE")
+
+    got := logs.Recent(0, slog.LevelDebug, "")
+    if len(got) != 2 {
+        t.Fatalf("expected 2 entries, got %+v", got)
+    }
+    if got[0].Component != "indexer" || got[0].Level != "ERROR" || got[0].Attrs["err"] != "disk full" {
+        t.Fatalf("unexpected first entry %+v", got[0])
+    }
+    if got[1].Attrs["dir"] != "/music" || got[1].Attrs["ev.op"] != "CREATE" {
+        t.Fatalf("unexpected attrs %+v", got[1].Attrs)
+    }
+    if w := logs.Recent(0, slog.LevelDebug, "watcher"); len(w) != 1 || w[0].Message != "event" {
+        t.Fatalf("component filter: %+v", w)
+    }
+    if e := logs.Recent(0, slog.LevelError, ""); len(e) != 1 || e[0].Message != "save failed" {
+        t.Fatalf("level filter: %+v", e)
+    }
+    if strings.Contains(echo.String(), "hidden") || !strings.Contains(echo.String(), "save failed") {
+        t.Fatalf("unexpected echo %q", echo.String())
+    }
+
+    b, err := os.ReadFile(filepath.Join(dir, FileName))
+    if err != nil {
+        t.Fatalf("read log: %v", err)
+    }
+    lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+    if len(lines) != 2 {
+        t.Fatalf("expected 2 lines, got %q", b)
+    }
+    var rec map[string]any
+    if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+        t.Fatalf("log line is not JSON: %v", err)
+    }
+    if rec["component"] != "indexer" || rec["msg"] != "save failed" {
+        t.Fatalf("unexpected record %v", rec)
+    }
+
+    if err := logs.SetLevels("loud", nil); err == nil {
+        t.Fatalf("expected an error for an unknown level")
+    }
+}
+
+func TestRecentSeededAndLimited(t *testing.T) {
+    dir := t.TempDir()
+    logs, err := Open(dir, Options{})
+    if err != nil {
+        t.Fatalf("Open: %v", err)
+    }
+    for i := 0; i < 3; i++ {
+        logs.Logger("app").Info("before", "i", i)
+    }
+    logs.Close()
+
+    logs, err = Open(dir, Options{})
+    if err != nil {
+        t.Fatalf("reopen: %v", err)
+    }
+    defer logs.Close()
+    logs.Logger("app").Info("after")
+    got := logs.Recent(2, slog.LevelInfo, "")
+    if len(got) != 2 || got[0].Message != "before" || got[1].Message != "after" {
+        t.Fatalf("unexpected entries %+v", got)
+    }
+    // numbers come back from the file as JSON numbers
+    if got[0].Attrs["i"] != float64(2) || got[0].Component != "app" {
+        t.Fatalf("unexpected seeded entry %+v", got[0])
+    }
+}
+
+func TestRotatingFile(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "x.log")
+    r, err := OpenRotating(path, 10, 2)
+    if err != nil {
+        t.Fatalf("OpenRotating: %v", err)
+    }
+    for _, s := range []string{"aaaaaa\n", "bbbbbb\n", "cccccc\n", "dddddd\n"} {
+        if _, err := r.Write([]byte(s)); err != nil {
+            t.Fatalf("Write: %v", err)
+        }
+    }
+    r.Close()
+    want := map[string]string{
+        path:        "dddddd\n",
+        path + ".1": "cccccc\n",
+        path + ".2": "bbbbbb\n",
+    }
+    for p, w := range want {
+        b, err := os.ReadFile(p)
+        if err != nil || string(b) != w {
+            t.Fatalf("%s: expected %q, got %q (%v)", p, w, b, err)
+        }
+    }
+    if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+        t.Fatalf("expected only 2 backups")
+    }
+}
@@ -0,0 +1,89 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile appends to a file and, once it would grow past MaxSize,
+// moves it to path.1 (and path.1 to path.2, up to Backups) and starts anew
+type RotatingFile struct {
+    path    string
+    maxSize int64
+    backups int
+
+    mtx  sync.Mutex
+    f    *os.File
+    size int64
+}
+
+// OpenRotating opens path for appending; backups is how many old files to keep
+func OpenRotating(path string, maxSize int64, backups int) (*RotatingFile, error) {
+    r := &RotatingFile{path: path, maxSize: maxSize, backups: backups}
+    if err := r.open(); err != nil {
+        return nil, err
+    }
+    return r, nil
+}
+
+func (r *RotatingFile) open() error {
+    f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+    if err != nil {
+        return fmt.Errorf("open log: %w", err)
+    }
+    fi, err := f.Stat()
+    if err != nil {
+        f.Close()
+        return fmt.Errorf("stat log: %w", err)
+    }
+    r.f, r.size = f, fi.Size()
+    return nil
+}
+
+// Write appends p, rotating first when p doesn't fit; a single write is
+// never split across files
+func (r *RotatingFile) Write(p []byte) (int, error) {
+    r.mtx.Lock()
+    defer r.mtx.Unlock()
+    if r.f == nil {
+        return 0, os.ErrClosed
+    }
+    if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+        if err := r.rotate(); err != nil {
+            return 0, err
+        }
+    }
+    n, err := r.f.Write(p)
+    r.size += int64(n)
+    return n, err
+}
+
+func (r *RotatingFile) rotate() error {
+    r.f.Close()
+    r.f = nil
+    if r.backups <= 0 {
+        os.Remove(r.path)
+    }
+    for i := r.backups; i >= 1; i-- {
+        from := r.path
+        if i > 1 {
+            from = fmt.Sprintf("%s.%d", r.path, i-1)
+        }
+        // missing backups are fine; the oldest is overwritten
+        os.Rename(from, fmt.Sprintf("%s.%d", r.path, i))
+    }
+    return r.open()
+}
+
+// Close closes the current file
+func (r *RotatingFile) Close() error {
+    r.mtx.Lock()
+    defer r.mtx.Unlock()
+    if r.f == nil {
+        return nil
+    }
+    err := r.f.Close()
+    r.f = nil
+    return err
+}
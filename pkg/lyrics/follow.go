@@ -0,0 +1,43 @@
+package lyrics
+
+import "sync"
+
+// Follower tracks which line of the playing track's lyrics is showing
+type Follower struct {
+    mtx    sync.Mutex
+    track  string
+    lyrics *Lyrics
+    line   int
+}
+
+// NewFollower creates a follower with no track
+func NewFollower() *Follower {
+    return &Follower{line: -1}
+}
+
+// Track returns the ID of the followed track, or ""
+func (f *Follower) Track() string {
+    f.mtx.Lock()
+    defer f.mtx.Unlock()
+    return f.track
+}
+
+// Set switches to the lyrics of track id; l may be nil
+func (f *Follower) Set(id string, l *Lyrics) {
+    f.mtx.Lock()
+    defer f.mtx.Unlock()
+    f.track, f.lyrics, f.line = id, l, -1
+}
+
+// Update moves to position pos in seconds and reports the showing line when
+// it differs from the last one reported
+func (f *Follower) Update(pos float64) (int, bool) {
+    f.mtx.Lock()
+    defer f.mtx.Unlock()
+    line := f.lyrics.LineAt(pos)
+    if line == f.line {
+        return line, false
+    }
+    f.line = line
+    return line, true
+}
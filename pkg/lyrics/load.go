@@ -0,0 +1,91 @@
+package lyrics
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	tag "github.com/dhowden/tag"
+)
+
+// sidecarExts are tried next to the track in order; synced files come first
+var sidecarExts = []string{".lrc", ".LRC", ".txt", ".TXT"}
+
+// Sidecar returns the path of a lyrics file next to track, or ""
+func Sidecar(track string) string {
+    base := strings.TrimSuffix(track, filepath.Ext(track))
+    for _, ext := range sidecarExts {
+        if info, err := os.Stat(base + ext); err == nil && info.Mode().IsRegular() {
+            return base + ext
+        }
+    }
+    return ""
+}
+
+// Load returns the lyrics of the track at path, preferring synced lyrics: a
+// sidecar .lrc, then an embedded SYLT frame, then embedded text, then a
+// sidecar .txt. It returns nil, nil when the track has none.
+func Load(path string) (*Lyrics, error) {
+    side := Sidecar(path)
+    if strings.EqualFold(filepath.Ext(side), ".lrc") {
+        if l, err := readSidecar(side); err != nil || len(l.Lines) > 0 {
+            return l, err
+        }
+    }
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    m, err := tag.ReadFrom(f)
+    f.Close()
+    if err == nil {
+        if l := FromTags(m); l != nil {
+            return l, nil
+        }
+    }
+    if side != "" && !strings.EqualFold(filepath.Ext(side), ".lrc") {
+        if l, err := readSidecar(side); err != nil || len(l.Lines) > 0 {
+            return l, err
+        }
+    }
+    return nil, nil
+}
+
+func readSidecar(path string) (*Lyrics, error) {
+    b, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    l := ParseLRC(strings.TrimPrefix(string(b), "\ufeff"))
+    l.Source = "sidecar " + strings.ToLower(filepath.Ext(path))
+    return l, nil
+}
+
+// FromTags returns the lyrics embedded in m: a SYLT frame, or USLT, LYRICS
+// and the like, which are read as LRC when they carry timestamps. It returns
+// nil when there are none.
+func FromTags(m tag.Metadata) *Lyrics {
+    raw := m.Raw()
+    if b, ok := raw["SYLT"].([]byte); ok {
+        if l, err := ParseSYLT(b); err == nil {
+            return l
+        }
+    }
+    // Vorbis comments have no single agreed name; synced text wins
+    text, _ := raw["syncedlyrics"].(string)
+    if strings.TrimSpace(text) == "" {
+        text = m.Lyrics()
+    }
+    if strings.TrimSpace(text) == "" {
+        text, _ = raw["unsyncedlyrics"].(string)
+    }
+    if strings.TrimSpace(text) == "" {
+        return nil
+    }
+    l := ParseLRC(text)
+    if len(l.Lines) == 0 {
+        return nil
+    }
+    l.Source = "embedded"
+    return l
+}
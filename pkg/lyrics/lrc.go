@@ -0,0 +1,147 @@
+package lyrics
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Word is a timed part of a line from enhanced LRC; Time is in seconds
+type Word struct {
+    Time float64 `json:"time"`
+    Text string  `json:"text"`
+}
+
+// Line is one line of lyrics. Time is in seconds and only meaningful when the
+// lyrics are synced; Words is set when the source timed single words.
+type Line struct {
+    Time  float64 `json:"time"`
+    Text  string  `json:"text"`
+    Words []Word  `json:"words,omitempty"`
+}
+
+// Lyrics is the text of a track, sorted by time when Synced
+type Lyrics struct {
+    Synced bool   `json:"synced"`
+    Lines  []Line `json:"lines"`
+    // Source names where the lyrics came from, like "sidecar .lrc" or "SYLT"
+    Source string `json:"source"`
+}
+
+var (
+    lineTag = regexp.MustCompile(`^\[(\d+):(\d{1,2}(?:[.:]\d{1,3})?)\]`)
+    metaTag = regexp.MustCompile(`^\[([a-zA-Z#]+):(.*)\]$`)
+    wordTag = regexp.MustCompile(`<(\d+):(\d{1,2}(?:[.:]\d{1,3})?)>`)
+)
+
+// ParseLRC reads LRC text. Lines may carry several [mm:ss.xx] stamps and
+// enhanced <mm:ss.xx> word stamps; an [offset:ms] tag shifts every stamp, a
+// positive offset showing lines earlier. Text without any stamps comes back
+// as unsynced lines.
+func ParseLRC(text string) *Lyrics {
+    offset := 0.0
+    var lines []Line
+    var plain []Line
+    for _, raw := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+        raw = strings.TrimSpace(raw)
+        var stamps []float64
+        for {
+            m := lineTag.FindStringSubmatch(raw)
+            if m == nil {
+                break
+            }
+            stamps = append(stamps, stamp(m[1], m[2]))
+            raw = strings.TrimSpace(raw[len(m[0]):])
+        }
+        if len(stamps) == 0 {
+            if m := metaTag.FindStringSubmatch(raw); m != nil {
+                if strings.EqualFold(m[1], "offset") {
+                    if ms, err := strconv.Atoi(strings.TrimSpace(m[2])); err == nil {
+                        offset = float64(ms) / 1000
+                    }
+                }
+                continue
+            }
+            plain = append(plain, Line{Text: raw})
+            continue
+        }
+        words := parseWords(raw)
+        text := raw
+        if words != nil {
+            text = ""
+            for _, w := range words {
+                text += w.Text
+            }
+            text = strings.TrimSpace(text)
+        }
+        for _, s := range stamps {
+            lines = append(lines, Line{Time: s, Text: text, Words: words})
+        }
+    }
+    if len(lines) == 0 {
+        return &Lyrics{Lines: trimBlank(plain)}
+    }
+    sort.SliceStable(lines, func(i, j int) bool { return lines[i].Time < lines[j].Time })
+    for i := range lines {
+        lines[i].Time = max(0, lines[i].Time-offset)
+        if lines[i].Words != nil {
+            words := make([]Word, len(lines[i].Words))
+            for j, w := range lines[i].Words {
+                words[j] = Word{Time: max(0, w.Time-offset), Text: w.Text}
+            }
+            lines[i].Words = words
+        }
+    }
+    return &Lyrics{Synced: true, Lines: lines}
+}
+
+// parseWords splits an enhanced LRC line into timed words, or returns nil
+func parseWords(s string) []Word {
+    locs := wordTag.FindAllStringSubmatchIndex(s, -1)
+    if locs == nil {
+        return nil
+    }
+    var words []Word
+    for i, loc := range locs {
+        end := len(s)
+        if i+1 < len(locs) {
+            end = locs[i+1][0]
+        }
+        text := s[loc[1]:end]
+        // a closing stamp only ends the previous word
+        if strings.TrimSpace(text) == "" && i == len(locs)-1 {
+            break
+        }
+        words = append(words, Word{Time: stamp(s[loc[2]:loc[3]], s[loc[4]:loc[5]]), Text: text})
+    }
+    return words
+}
+
+// stamp converts minutes and seconds with an optional fraction to seconds
+func stamp(min, sec string) float64 {
+    m, _ := strconv.Atoi(min)
+    sec = strings.Replace(sec, ":", ".", 1)
+    s, _ := strconv.ParseFloat(sec, 64)
+    return float64(m)*60 + s
+}
+
+// trimBlank drops leading and trailing empty lines
+func trimBlank(lines []Line) []Line {
+    for len(lines) > 0 && lines[0].Text == "" {
+        lines = lines[1:]
+    }
+    for len(lines) > 0 && lines[len(lines)-1].Text == "" {
+        lines = lines[:len(lines)-1]
+    }
+    return lines
+}
+
+// LineAt returns the index of the synced line showing at pos seconds, or -1
+// before the first line and for unsynced lyrics
+func (l *Lyrics) LineAt(pos float64) int {
+    if l == nil || !l.Synced {
+        return -1
+    }
+    return sort.Search(len(l.Lines), func(i int) bool { return l.Lines[i].Time > pos }) - 1
+}
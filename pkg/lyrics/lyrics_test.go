@@ -0,0 +1,156 @@
+package lyrics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-6 }
+
+func TestParseLRC(t *testing.T) {
+    l := ParseLRC("[ar:Someone]\n[ti:Song]\n[00:12.00]Second\n[00:01.50][00:20.25]Chorus\n\n[01:02.5]Last")
+    if !l.Synced {
+        t.Fatalf("expected synced lyrics")
+    }
+    want := []Line{{Time: 1.5, Text: "Chorus"}, {Time: 12, Text: "Second"}, {Time: 20.25, Text: "Chorus"}, {Time: 62.5, Text: "Last"}}
+    if len(l.Lines) != len(want) {
+        t.Fatalf("expected %d lines, got %+v", len(want), l.Lines)
+    }
+    for i, w := range want {
+        if !near(l.Lines[i].Time, w.Time) || l.Lines[i].Text != w.Text {
+            t.Fatalf("line %d: expected %+v, got %+v", i, w, l.Lines[i])
+        }
+    }
+    for pos, line := range map[float64]int{0: -1, 1.5: 0, 11.9: 0, 12: 1, 30: 2, 100: 3} {
+        if got := l.LineAt(pos); got != line {
+            t.Fatalf("LineAt(%v): expected %d, got %d", pos, line, got)
+        }
+    }
+}
+
+func TestParseLRCOffsetAndWords(t *testing.T) {
+    l := ParseLRC("[offset:+500]\n[00:10.00]<00:10.00>Hello <00:10.80>world<00:11.60>")
+    if len(l.Lines) != 1 {
+        t.Fatalf("expected 1 line, got %+v", l.Lines)
+    }
+    ln := l.Lines[0]
+    if !near(ln.Time, 9.5) || ln.Text != "Hello world" {
+        t.Fatalf("unexpected line %+v", ln)
+    }
+    if len(ln.Words) != 2 || !near(ln.Words[1].Time, 10.3) || ln.Words[1].Text != "world" {
+        t.Fatalf("unexpected words %+v", ln.Words)
+    }
+}
+
+func TestParseLRCPlainText(t *testing.T) {
+    l := ParseLRC("\nFirst line\n\nSecond line\n")
+    if l.Synced || len(l.Lines) != 3 || l.Lines[2].Text != "Second line" {
+        t.Fatalf("unexpected lyrics %+v", l)
+    }
+    if l.LineAt(5) != -1 {
+        t.Fatalf("unsynced lyrics should have no current line")
+    }
+}
+
+// syltFrame builds a UTF-8 SYLT body with millisecond stamps
+func syltFrame(entries ...any) []byte {
+    b := []byte{3, 'e', 'n', 'g', 2, 1, 0}
+    for i := 0; i < len(entries); i += 2 {
+        b = append(b, entries[i].(string)...)
+        b = append(b, 0)
+        b = binary.BigEndian.AppendUint32(b, uint32(entries[i+1].(int)))
+    }
+    return b
+}
+
+func TestParseSYLT(t *testing.T) {
+    l, err := ParseSYLT(syltFrame("One", 1000, "\nTwo ", 2500, "parts", 3000))
+    if err != nil {
+        t.Fatalf("ParseSYLT: %v", err)
+    }
+    if len(l.Lines) != 2 || l.Lines[0].Text != "One" || l.Lines[1].Text != "Two parts" {
+        t.Fatalf("unexpected lines %+v", l.Lines)
+    }
+    if !near(l.Lines[1].Time, 2.5) || len(l.Lines[1].Words) != 2 || !near(l.Lines[1].Words[1].Time, 3) {
+        t.Fatalf("unexpected timing %+v", l.Lines[1])
+    }
+    if _, err := ParseSYLT([]byte{3, 'e', 'n', 'g', 1, 1, 0}); err == nil {
+        t.Fatalf("expected MPEG frame stamps to be rejected")
+    }
+}
+
+// id3File writes an ID3v2.3 tag holding a single frame followed by dummy audio
+func id3File(t *testing.T, path, id string, body []byte) {
+    var frame bytes.Buffer
+    frame.WriteString(id)
+    binary.Write(&frame, binary.BigEndian, uint32(len(body)))
+    frame.Write([]byte{0, 0})
+    frame.Write(body)
+    n := frame.Len()
+    head := []byte{'I', 'D', '3', 3, 0, 0, byte(n >> 21 & 0x7f), byte(n >> 14 & 0x7f), byte(n >> 7 & 0x7f), byte(n & 0x7f)}
+    data := append(append(head, frame.Bytes()...), make([]byte, 64)...)
+    if err := os.WriteFile(path, data, 0o644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+}
+
+func TestLoadPrefersSynced(t *testing.T) {
+    dir := t.TempDir()
+    song := filepath.Join(dir, "song.mp3")
+    id3File(t, song, "SYLT", syltFrame("Embedded", 500))
+    if err := os.WriteFile(filepath.Join(dir, "song.txt"), []byte("Plain"), 0o644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+    l, err := Load(song)
+    if err != nil || l == nil || l.Source != "SYLT" || l.Lines[0].Text != "Embedded" {
+        t.Fatalf("expected embedded SYLT over .txt, got %+v, %v", l, err)
+    }
+    if err := os.WriteFile(filepath.Join(dir, "song.lrc"), []byte("[00:01.00]Sidecar"), 0o644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+    l, err = Load(song)
+    if err != nil || l == nil || l.Source != "sidecar .lrc" || l.Lines[0].Text != "Sidecar" {
+        t.Fatalf("expected sidecar .lrc first, got %+v, %v", l, err)
+    }
+
+    plain := filepath.Join(dir, "plain.mp3")
+    uslt := append([]byte{3, 'e', 'n', 'g', 0}, "[00:02.00]Timed text"...)
+    id3File(t, plain, "USLT", uslt)
+    l, err = Load(plain)
+    if err != nil || l == nil || !l.Synced || l.Source != "embedded" || !near(l.Lines[0].Time, 2) {
+        t.Fatalf("expected timed USLT to parse as LRC, got %+v, %v", l, err)
+    }
+
+    none := filepath.Join(dir, "none.mp3")
+    if err := os.WriteFile(none, []byte("dummy"), 0o644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+    if l, err := Load(none); err != nil || l != nil {
+        t.Fatalf("expected no lyrics, got %+v, %v", l, err)
+    }
+}
+
+func TestFollower(t *testing.T) {
+    f := NewFollower()
+    f.Set("a", ParseLRC("[00:01.00]One\n[00:03.00]Two"))
+    var got []int
+    for _, pos := range []float64{0, 0.5, 1.2, 2, 3.1, 1} {
+        if line, changed := f.Update(pos); changed {
+            got = append(got, line)
+        }
+    }
+    // the first update at -1 matches the initial state, so only moves count
+    want := []int{0, 1, 0}
+    if len(got) != len(want) {
+        t.Fatalf("expected changes %v, got %v", want, got)
+    }
+    for i := range want {
+        if got[i] != want[i] {
+            t.Fatalf("expected changes %v, got %v", want, got)
+        }
+    }
+}
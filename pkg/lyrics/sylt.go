@@ -0,0 +1,104 @@
+package lyrics
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// ParseSYLT decodes the body of an ID3v2 SYLT frame. Only millisecond
+// timestamps are supported; MPEG frame counts need the stream's frame rate.
+func ParseSYLT(b []byte) (*Lyrics, error) {
+    if len(b) < 6 {
+        return nil, fmt.Errorf("sylt: frame too short")
+    }
+    enc, format := b[0], b[4]
+    if format != 2 {
+        return nil, fmt.Errorf("sylt: unsupported timestamp format %d", format)
+    }
+    // skip the content descriptor
+    _, rest, ok := cutText(b[6:], enc)
+    if !ok {
+        return nil, fmt.Errorf("sylt: truncated descriptor")
+    }
+    var lines []Line
+    for len(rest) > 0 {
+        text, after, ok := cutText(rest, enc)
+        if !ok || len(after) < 4 {
+            return nil, fmt.Errorf("sylt: truncated entry")
+        }
+        ms := binary.BigEndian.Uint32(after)
+        rest = after[4:]
+        // entries usually start with a newline to mark a new line; others continue it
+        if strings.HasPrefix(text, "\n") || strings.HasPrefix(text, "\r") || len(lines) == 0 {
+            lines = append(lines, Line{Time: float64(ms) / 1000})
+        }
+        l := &lines[len(lines)-1]
+        l.Words = append(l.Words, Word{Time: float64(ms) / 1000, Text: strings.TrimLeft(text, "\r\n")})
+    }
+    for i := range lines {
+        var sb strings.Builder
+        for _, w := range lines[i].Words {
+            sb.WriteString(w.Text)
+        }
+        lines[i].Text = strings.TrimSpace(sb.String())
+        // a single entry per line carries no word timing
+        if len(lines[i].Words) == 1 {
+            lines[i].Words = nil
+        }
+    }
+    if len(lines) == 0 {
+        return nil, fmt.Errorf("sylt: no entries")
+    }
+    return &Lyrics{Synced: true, Lines: lines, Source: "SYLT"}, nil
+}
+
+// cutText splits off a terminated string in ID3v2 text encoding enc
+func cutText(b []byte, enc byte) (string, []byte, bool) {
+    switch enc {
+    case 0, 3:
+        for i, c := range b {
+            if c == 0 {
+                s := b[:i]
+                if enc == 0 {
+                    return latin1(s), b[i+1:], true
+                }
+                return string(s), b[i+1:], true
+            }
+        }
+    case 1, 2:
+        for i := 0; i+1 < len(b); i += 2 {
+            if b[i] == 0 && b[i+1] == 0 {
+                return utf16String(b[:i], enc == 2), b[i+2:], true
+            }
+        }
+    }
+    return "", nil, false
+}
+
+func latin1(b []byte) string {
+    r := make([]rune, len(b))
+    for i, c := range b {
+        r[i] = rune(c)
+    }
+    return string(r)
+}
+
+// utf16String decodes UTF-16, honouring a byte order mark unless bigEndian forces it
+func utf16String(b []byte, bigEndian bool) string {
+    var order binary.ByteOrder = binary.BigEndian
+    if !bigEndian && len(b) >= 2 {
+        if b[0] == 0xff && b[1] == 0xfe {
+            order = binary.LittleEndian
+        }
+        if (b[0] == 0xff && b[1] == 0xfe) || (b[0] == 0xfe && b[1] == 0xff) {
+            b = b[2:]
+        }
+    }
+    u := make([]uint16, len(b)/2)
+    for i := range u {
+        u[i] = order.Uint16(b[2*i:])
+    }
+    return string(utf16.Decode(u))
+}
@@ -0,0 +1,177 @@
+// Package media serves tracks to the audio output of the frontend. Files
+// the app decodes are streamed as PCM run through the DSP chain; others are
+// sent as they are, and the frontend applies the same settings to them.
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"penguin-tunes/pkg/audio"
+	cfg "penguin-tunes/pkg/config"
+	"penguin-tunes/pkg/dsp"
+	"penguin-tunes/pkg/indexer"
+)
+
+// Prefix is the path the handler serves tracks under
+const Prefix = "/media/"
+
+// bufferFrames is how much audio is decoded and processed at once
+const bufferFrames = 4096
+
+// Source tells the output where to load a track from. Processed is set when
+// the DSP chain has already been applied to it.
+type Source struct {
+    URL       string `json:"url"`
+    Processed bool   `json:"processed"`
+}
+
+// Handler streams tracks by ID
+type Handler struct {
+    lookup func(id string) *indexer.Track
+    log    *slog.Logger
+
+    mtx sync.Mutex
+    dsp cfg.DSPConfig
+    // version counts SetDSP calls, so running streams pick up changes
+    version int
+}
+
+// New creates a handler finding tracks with lookup
+func New(lookup func(id string) *indexer.Track) *Handler {
+    return &Handler{lookup: lookup, log: slog.Default()}
+}
+
+// SetLogger sets where failed streams are logged
+func (h *Handler) SetLogger(l *slog.Logger) {
+    h.log = l
+}
+
+// SetDSP changes the settings streams are processed with, including those
+// already playing
+func (h *Handler) SetDSP(d cfg.DSPConfig) {
+    h.mtx.Lock()
+    defer h.mtx.Unlock()
+    h.dsp = d
+    h.version++
+}
+
+func (h *Handler) currentDSP() (cfg.DSPConfig, int) {
+    h.mtx.Lock()
+    defer h.mtx.Unlock()
+    return h.dsp, h.version
+}
+
+// SourceFor returns where the output loads t from, starting at from seconds
+// into its file
+func SourceFor(t *indexer.Track, from float64) Source {
+    u := Prefix + url.PathEscape(t.ID)
+    processed := audio.Supported(t.Path)
+    if processed && from > 0 {
+        u += "?t=" + strconv.FormatFloat(from, 'f', 3, 64)
+    }
+    return Source{URL: u, Processed: processed}
+}
+
+// ServeHTTP streams the track named by the path. Decodable files are sent
+// as 16-bit WAV from the t query parameter on, in seconds into the file,
+// up to the end of the track.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    id, err := url.PathUnescape(strings.TrimPrefix(r.URL.Path, Prefix))
+    if err != nil || !strings.HasPrefix(r.URL.Path, Prefix) {
+        http.NotFound(w, r)
+        return
+    }
+    t := h.lookup(id)
+    if t == nil {
+        http.NotFound(w, r)
+        return
+    }
+    if !audio.Supported(t.Path) {
+        http.ServeFile(w, r, t.Path)
+        return
+    }
+    from, _ := strconv.ParseFloat(r.URL.Query().Get("t"), 64)
+    s, err := audio.Open(t.Path)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    defer s.Close()
+    if err := h.stream(w, audio.Section(s, max(from, t.Start), t.End)); err != nil {
+        h.log.Warn("stream failed", "path", t.Path, "err", err)
+    }
+}
+
+// stream writes s through the DSP chain as a WAV of unknown length
+func (h *Handler) stream(w http.ResponseWriter, s audio.Stream) error {
+    rate, ch := s.SampleRate(), s.Channels()
+    if ch <= 0 {
+        return errors.New("stream without channels")
+    }
+    w.Header().Set("Content-Type", "audio/wav")
+    w.Header().Set("Cache-Control", "no-store")
+    if _, err := w.Write(wavHeader(rate, ch)); err != nil {
+        return err
+    }
+    var chain *dsp.Chain
+    version := -1
+    buf := make([]float32, bufferFrames*ch)
+    out := make([]byte, len(buf)*2)
+    for {
+        if d, v := h.currentDSP(); v != version {
+            c, err := dsp.FromConfig(d, float64(rate))
+            if err != nil {
+                return err
+            }
+            chain, version = c, v
+        }
+        n, rerr := s.Read(buf)
+        n -= n % ch
+        chain.Process(buf[:n], ch)
+        for i, v := range buf[:n] {
+            binary.LittleEndian.PutUint16(out[2*i:], uint16(toInt16(v)))
+        }
+        if _, err := w.Write(out[:2*n]); err != nil {
+            // the output went away, e.g. after a seek
+            return nil
+        }
+        if errors.Is(rerr, io.EOF) {
+            return nil
+        }
+        if rerr != nil {
+            return rerr
+        }
+    }
+}
+
+// wavHeader describes 16-bit PCM whose length isn't known up front
+func wavHeader(rate, channels int) []byte {
+    b := make([]byte, 44)
+    copy(b, "RIFF")
+    binary.LittleEndian.PutUint32(b[4:], math.MaxUint32)
+    copy(b[8:], "WAVEfmt ")
+    binary.LittleEndian.PutUint32(b[16:], 16)
+    binary.LittleEndian.PutUint16(b[20:], 1)
+    binary.LittleEndian.PutUint16(b[22:], uint16(channels))
+    binary.LittleEndian.PutUint32(b[24:], uint32(rate))
+    binary.LittleEndian.PutUint32(b[28:], uint32(rate*channels*2))
+    binary.LittleEndian.PutUint16(b[32:], uint16(channels*2))
+    binary.LittleEndian.PutUint16(b[34:], 16)
+    copy(b[36:], "data")
+    binary.LittleEndian.PutUint32(b[40:], math.MaxUint32-36)
+    return b
+}
+
+// toInt16 converts a sample, clipping what the chain pushed past full scale
+func toInt16(v float32) int16 {
+    return int16(max(-1, min(1, v)) * math.MaxInt16)
+}
@@ -0,0 +1,152 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cfg "penguin-tunes/pkg/config"
+	"penguin-tunes/pkg/indexer"
+)
+
+const rate = 8000
+
+// writeWAV writes frames of a constant stereo signal as 16-bit PCM
+func writeWAV(t *testing.T, path string, frames int, value int16) {
+    t.Helper()
+    var b bytes.Buffer
+    b.WriteString("RIFF")
+    binary.Write(&b, binary.LittleEndian, uint32(36+4*frames))
+    b.WriteString("WAVEfmt ")
+    binary.Write(&b, binary.LittleEndian, []uint32{16})
+    binary.Write(&b, binary.LittleEndian, []uint16{1, 2})
+    binary.Write(&b, binary.LittleEndian, []uint32{rate, rate * 4})
+    binary.Write(&b, binary.LittleEndian, []uint16{4, 16})
+    b.WriteString("data")
+    binary.Write(&b, binary.LittleEndian, uint32(4*frames))
+    for i := 0; i < 2*frames; i++ {
+        binary.Write(&b, binary.LittleEndian, value)
+    }
+    if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+}
+
+// samples decodes the PCM of a streamed WAV
+func samples(t *testing.T, body []byte) []int16 {
+    t.Helper()
+    if len(body) < 44 || string(body[:4]) != "RIFF" || string(body[36:40]) != "data" {
+        t.Fatalf("not a WAV stream: %q", body[:min(len(body), 44)])
+    }
+    out := make([]int16, (len(body)-44)/2)
+    binary.Read(bytes.NewReader(body[44:]), binary.LittleEndian, out)
+    return out
+}
+
+func newHandler(tracks ...*indexer.Track) *Handler {
+    byID := make(map[string]*indexer.Track)
+    for _, t := range tracks {
+        byID[t.ID] = t
+    }
+    return New(func(id string) *indexer.Track { return byID[id] })
+}
+
+func get(h http.Handler, url string) *httptest.ResponseRecorder {
+    rec := httptest.NewRecorder()
+    h.ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+    return rec
+}
+
+func TestStreamAppliesDSP(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "a.wav")
+    writeWAV(t, path, rate, 16000)
+    h := newHandler(&indexer.Track{ID: "a", Path: path})
+    h.SetDSP(cfg.DSPConfig{Preamp: -6.0206, Balance: 1})
+
+    rec := get(h, SourceFor(&indexer.Track{ID: "a", Path: path}, 0).URL)
+    if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "audio/wav" {
+        t.Fatalf("unexpected response %d %v", rec.Code, rec.Header())
+    }
+    got := samples(t, rec.Body.Bytes())
+    if len(got) != 2*rate {
+        t.Fatalf("expected %d samples, got %d", 2*rate, len(got))
+    }
+    // balance mutes the left side, the preamp halves the right
+    if got[0] != 0 || got[1] < 7990 || got[1] > 8010 {
+        t.Fatalf("expected the chain applied, got %v", got[:2])
+    }
+
+    // the bypassed chain plays the file as it is
+    h.SetDSP(cfg.DSPConfig{Preamp: -6.0206, Balance: 1, PreampBypass: true, BalanceBypass: true})
+    got = samples(t, get(h, "/media/a").Body.Bytes())
+    if got[0] < 15990 || got[1] < 15990 {
+        t.Fatalf("expected unprocessed samples, got %v", got[:2])
+    }
+}
+
+func TestStreamRange(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "live.wav")
+    writeWAV(t, path, 4*rate, 1000)
+    // the second second to the third of the file, cut by a CUE sheet
+    part := &indexer.Track{ID: "p", Path: path, Start: 1, End: 3}
+    h := newHandler(part)
+    if n := len(samples(t, get(h, "/media/p").Body.Bytes())); n != 2*2*rate {
+        t.Fatalf("expected the track's 2s, got %d samples", n)
+    }
+    src := SourceFor(part, 2.5)
+    if !src.Processed {
+        t.Fatalf("expected WAV to be processed")
+    }
+    if n := len(samples(t, get(h, src.URL).Body.Bytes())); n != rate {
+        t.Fatalf("expected the 0.5s after the seek, got %d samples", n)
+    }
+}
+
+// changingWriter changes the DSP settings once the stream has started
+type changingWriter struct {
+    *httptest.ResponseRecorder
+    h      *Handler
+    writes int
+}
+
+func (w *changingWriter) Write(b []byte) (int, error) {
+    if w.writes++; w.writes == 2 {
+        w.h.SetDSP(cfg.DSPConfig{Balance: -1})
+    }
+    return w.ResponseRecorder.Write(b)
+}
+
+func TestStreamFollowsChanges(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "a.wav")
+    writeWAV(t, path, 3*bufferFrames, 1000)
+    h := newHandler(&indexer.Track{ID: "a", Path: path})
+    w := &changingWriter{ResponseRecorder: httptest.NewRecorder(), h: h}
+    h.ServeHTTP(w, httptest.NewRequest("GET", "/media/a", nil))
+    got := samples(t, w.Body.Bytes())
+    first, last := got[:2], got[len(got)-2:]
+    if first[1] != 999 || last[1] != 0 {
+        t.Fatalf("expected the right side muted after the change, got %v then %v", first, last)
+    }
+}
+
+func TestServeOtherFormats(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "a.mp3")
+    if err := os.WriteFile(path, []byte("ID3 dummy"), 0o644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+    tr := &indexer.Track{ID: "m", Path: path}
+    h := newHandler(tr)
+    if src := SourceFor(tr, 10); src.Processed || src.URL != "/media/m" {
+        t.Fatalf("unexpected source %+v", src)
+    }
+    if rec := get(h, "/media/m"); rec.Code != http.StatusOK || rec.Body.String() != "ID3 dummy" {
+        t.Fatalf("expected the file as it is, got %d %q", rec.Code, rec.Body.String())
+    }
+    if rec := get(h, "/media/nope"); rec.Code != http.StatusNotFound {
+        t.Fatalf("expected 404 for an unknown track, got %d", rec.Code)
+    }
+}
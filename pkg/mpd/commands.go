@@ -0,0 +1,516 @@
+package mpd
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"penguin-tunes/pkg/indexer"
+	"penguin-tunes/pkg/player"
+)
+
+type commandFunc func(s *Server, c *client, r response, args []string) error
+
+// commandSpec is a command with its argument count; max -1 is unbounded
+type commandSpec struct {
+    min, max int
+    fn       commandFunc
+}
+
+var commands map[string]commandSpec
+
+func init() {
+    commands = map[string]commandSpec{
+        // connection
+        "ping":        {0, 0, cmdOK},
+        "password":    {1, 1, cmdPassword},
+        "commands":    {0, 0, cmdCommands},
+        "notcommands": {0, 0, cmdOK},
+        "tagtypes":    {0, -1, cmdTagTypes},
+        "urlhandlers": {0, 0, cmdOK},
+        "decoders":    {0, 0, cmdOK},
+        "outputs":     {0, 0, cmdOutputs},
+        // status
+        "status":      {0, 0, cmdStatus},
+        "currentsong": {0, 0, cmdCurrentSong},
+        "stats":       {0, 0, cmdStats},
+        "clearerror":  {0, 0, cmdOK},
+        // playback options the player doesn't have can only be turned off
+        "random":             {1, 1, cmdOff},
+        "repeat":             {1, 1, cmdOff},
+        "single":             {1, 1, cmdOff},
+        "consume":            {1, 1, cmdOff},
+        "crossfade":          {1, 1, cmdOff},
+        "replay_gain_status": {0, 0, cmdReplayGain},
+        // playback
+        "play":     {0, 1, cmdPlay},
+        "playid":   {0, 1, cmdPlay},
+        "pause":    {0, 1, cmdPause},
+        "stop":     {0, 0, cmdStop},
+        "next":     {0, 0, cmdNext},
+        "previous": {0, 0, cmdPrevious},
+        "seek":     {2, 2, cmdSeek},
+        "seekid":   {2, 2, cmdSeek},
+        "seekcur":  {1, 1, cmdSeekCur},
+        "setvol":   {1, 1, cmdSetVol},
+        "getvol":   {0, 0, cmdGetVol},
+        "volume":   {1, 1, cmdVolume},
+        // queue
+        "add":            {1, 1, cmdAdd},
+        "addid":          {1, 2, cmdAddID},
+        "clear":          {0, 0, cmdClear},
+        "delete":         {1, 1, cmdDelete},
+        "deleteid":       {1, 1, cmdDelete},
+        "move":           {2, 2, cmdMove},
+        "moveid":         {2, 2, cmdMove},
+        "playlist":       {0, 0, cmdPlaylist},
+        "playlistinfo":   {0, 1, cmdPlaylistInfo},
+        "playlistid":     {0, 1, cmdPlaylistInfo},
+        "playlistfind":   {1, -1, cmdPlaylistFind},
+        "playlistsearch": {1, -1, cmdPlaylistSearch},
+        "plchanges":      {1, 2, cmdPlChanges},
+        "plchangesposid": {1, 2, cmdPlChangesPosID},
+        // database
+        "list":        {1, -1, cmdList},
+        "find":        {1, -1, cmdFind},
+        "search":      {1, -1, cmdSearch},
+        "findadd":     {1, -1, cmdFindAdd},
+        "searchadd":   {1, -1, cmdSearchAdd},
+        "count":       {1, -1, cmdCount},
+        "lsinfo":      {0, 1, cmdLsinfo},
+        "listall":     {0, 1, cmdListAll},
+        "listallinfo": {0, 1, cmdListAllInfo},
+        "update":      {0, 1, cmdUpdate},
+        "rescan":      {0, 1, cmdUpdate},
+    }
+}
+
+func cmdOK(s *Server, c *client, r response, args []string) error {
+    return nil
+}
+
+func cmdPassword(s *Server, c *client, r response, args []string) error {
+    if s.opts.Password == "" || !equal(args[0], s.opts.Password) {
+        return &ackErr{code: ackPassword, msg: "incorrect password"}
+    }
+    c.authed = true
+    return nil
+}
+
+func cmdCommands(s *Server, c *client, r response, args []string) error {
+    names := []string{"close", "idle", "noidle", "command_list_begin", "command_list_ok_begin", "command_list_end"}
+    for name := range commands {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        r.kv("command", name)
+    }
+    return nil
+}
+
+// cmdTagTypes lists the supported tags; the forms that select tags for the
+// connection are accepted, but every tag is always sent
+func cmdTagTypes(s *Server, c *client, r response, args []string) error {
+    if len(args) > 0 {
+        return nil
+    }
+    for _, name := range tagNames {
+        r.kv("tagtype", name)
+    }
+    return nil
+}
+
+// cmdOutputs reports the frontend's audio output
+func cmdOutputs(s *Server, c *client, r response, args []string) error {
+    r.kv("outputid", 0)
+    r.kv("outputname", "PenguinTunes")
+    r.kv("plugin", "penguin-tunes")
+    r.kv("outputenabled", 1)
+    return nil
+}
+
+func cmdOff(s *Server, c *client, r response, args []string) error {
+    if args[0] != "0" {
+        return argErr("not supported")
+    }
+    return nil
+}
+
+func cmdReplayGain(s *Server, c *client, r response, args []string) error {
+    r.kv("replay_gain_mode", "off")
+    return nil
+}
+
+func cmdStatus(s *Server, c *client, r response, args []string) error {
+    st := s.p.Status()
+    r.kv("volume", int(math.Round(st.Volume*100)))
+    for _, name := range []string{"repeat", "random", "single", "consume"} {
+        r.kv(name, 0)
+    }
+    r.kv("playlist", s.queueVersion())
+    r.kv("playlistlength", len(st.Queue))
+    r.kv("state", stateName(st.State))
+    if st.Current >= 0 && st.Current < len(st.Queue) {
+        r.kv("song", st.Current)
+        r.kv("songid", st.Current)
+        if st.Current+1 < len(st.Queue) {
+            r.kv("nextsong", st.Current+1)
+            r.kv("nextsongid", st.Current+1)
+        }
+        if st.State != player.Stopped {
+            duration := st.Duration
+            if duration <= 0 {
+                duration = st.Queue[st.Current].Duration
+            }
+            r.kv("time", fmt.Sprintf("%d:%d", int(st.Position), int(duration+0.5)))
+            r.kv("elapsed", fmt.Sprintf("%.3f", st.Position))
+            r.kv("duration", fmt.Sprintf("%.3f", duration))
+        }
+    }
+    return nil
+}
+
+func stateName(st player.State) string {
+    switch st {
+    case player.Playing:
+        return "play"
+    case player.Paused:
+        return "pause"
+    }
+    return "stop"
+}
+
+func cmdCurrentSong(s *Server, c *client, r response, args []string) error {
+    st := s.p.Status()
+    if st.Current < 0 || st.Current >= len(st.Queue) {
+        return nil
+    }
+    s.writeQueued(r, st.Queue[st.Current], st.Current, s.opts.Roots())
+    return nil
+}
+
+// writeQueued prints a queue entry; positions double as song IDs
+func (s *Server) writeQueued(r response, t *indexer.Track, pos int, roots []string) {
+    writeSong(r, song{uriOf(t, roots), t})
+    r.kv("Pos", pos)
+    r.kv("Id", pos)
+}
+
+func intArg(v string) (int, error) {
+    n, err := strconv.Atoi(v)
+    if err != nil {
+        return 0, argErr("Integer expected: %s", v)
+    }
+    return n, nil
+}
+
+func floatArg(v string) (float64, error) {
+    f, err := strconv.ParseFloat(v, 64)
+    if err != nil {
+        return 0, argErr("Number expected: %s", v)
+    }
+    return f, nil
+}
+
+// position reads a queue position (or song ID, which is the same)
+func (s *Server) position(v string) (int, error) {
+    n, err := intArg(v)
+    if err != nil {
+        return 0, err
+    }
+    if n < 0 || n >= len(s.p.Status().Queue) {
+        return 0, argErr("Bad song index")
+    }
+    return n, nil
+}
+
+// parseRange reads "N" or "START:END"; a missing END runs to the end (-1)
+func parseRange(v string) (start, end int, err error) {
+    a, b, isRange := strings.Cut(v, ":")
+    if start, err = intArg(a); err != nil {
+        return 0, 0, err
+    }
+    if !isRange {
+        return start, start + 1, nil
+    }
+    if b == "" {
+        return start, -1, nil
+    }
+    if end, err = intArg(b); err != nil {
+        return 0, 0, err
+    }
+    if start < 0 || end < start {
+        return 0, 0, argErr("Bad range")
+    }
+    return start, end, nil
+}
+
+func cmdPlay(s *Server, c *client, r response, args []string) error {
+    if len(args) == 0 || args[0] == "-1" {
+        s.p.Play()
+        return nil
+    }
+    i, err := s.position(args[0])
+    if err != nil {
+        return err
+    }
+    return s.p.PlayIndex(i)
+}
+
+func cmdPause(s *Server, c *client, r response, args []string) error {
+    switch {
+    case len(args) == 0:
+        s.p.PlayPause()
+    case args[0] == "1":
+        s.p.Pause()
+    case args[0] == "0":
+        s.p.Play()
+    default:
+        return argErr("Boolean (0/1) expected: %s", args[0])
+    }
+    return nil
+}
+
+func cmdStop(s *Server, c *client, r response, args []string) error {
+    s.p.Stop()
+    return nil
+}
+
+func cmdNext(s *Server, c *client, r response, args []string) error {
+    s.p.Next()
+    return nil
+}
+
+func cmdPrevious(s *Server, c *client, r response, args []string) error {
+    s.p.Previous()
+    return nil
+}
+
+// cmdSeek seeks within the entry at a position, switching to it first
+func cmdSeek(s *Server, c *client, r response, args []string) error {
+    i, err := s.position(args[0])
+    if err != nil {
+        return err
+    }
+    pos, err := floatArg(args[1])
+    if err != nil {
+        return err
+    }
+    if s.p.Status().Current != i {
+        if err := s.p.PlayIndex(i); err != nil {
+            return err
+        }
+    }
+    s.p.Seek(pos)
+    return nil
+}
+
+// cmdSeekCur seeks in the current entry; a leading + or - is relative
+func cmdSeekCur(s *Server, c *client, r response, args []string) error {
+    pos, err := floatArg(args[0])
+    if err != nil {
+        return err
+    }
+    if s.p.Current() == nil {
+        return &ackErr{code: ackNoExist, msg: "Not playing"}
+    }
+    if strings.HasPrefix(args[0], "+") || strings.HasPrefix(args[0], "-") {
+        s.p.SeekBy(pos)
+        return nil
+    }
+    s.p.Seek(pos)
+    return nil
+}
+
+func cmdSetVol(s *Server, c *client, r response, args []string) error {
+    v, err := intArg(args[0])
+    if err != nil {
+        return err
+    }
+    if v < 0 || v > 100 {
+        return argErr("Invalid volume value")
+    }
+    s.p.SetVolume(float64(v) / 100)
+    return nil
+}
+
+func cmdGetVol(s *Server, c *client, r response, args []string) error {
+    r.kv("volume", int(math.Round(s.p.Status().Volume*100)))
+    return nil
+}
+
+// cmdVolume changes the volume by a relative amount
+func cmdVolume(s *Server, c *client, r response, args []string) error {
+    d, err := intArg(args[0])
+    if err != nil {
+        return err
+    }
+    s.p.SetVolume(s.p.Status().Volume + float64(d)/100)
+    return nil
+}
+
+func cmdAdd(s *Server, c *client, r response, args []string) error {
+    tracks := s.lookup(args[0])
+    if len(tracks) == 0 {
+        return noExist("No such song")
+    }
+    s.p.Enqueue(tracks...)
+    return nil
+}
+
+// cmdAddID adds one song, optionally at a position, and prints its ID
+func cmdAddID(s *Server, c *client, r response, args []string) error {
+    var t *indexer.Track
+    roots := s.opts.Roots()
+    for _, cand := range s.lookup(args[0]) {
+        if uriOf(cand, roots) == strings.Trim(args[0], "/") {
+            t = cand
+        }
+    }
+    if t == nil {
+        return noExist("No such song")
+    }
+    s.p.Enqueue(t)
+    pos := len(s.p.Status().Queue) - 1
+    if len(args) > 1 {
+        to, err := intArg(args[1])
+        if err != nil {
+            return err
+        }
+        if err := s.p.Move(pos, to); err != nil {
+            return argErr("Bad song index")
+        }
+        pos = to
+    }
+    r.kv("Id", pos)
+    return nil
+}
+
+func cmdClear(s *Server, c *client, r response, args []string) error {
+    s.p.Clear()
+    return nil
+}
+
+// cmdDelete removes a position or a START:END range from the queue
+func cmdDelete(s *Server, c *client, r response, args []string) error {
+    start, end, err := parseRange(args[0])
+    if err != nil {
+        return err
+    }
+    n := len(s.p.Status().Queue)
+    if end < 0 {
+        end = n
+    }
+    if start < 0 || end > n || start >= end {
+        return argErr("Bad song index")
+    }
+    for i := end - 1; i >= start; i-- {
+        if err := s.p.Remove(i); err != nil {
+            return argErr("Bad song index")
+        }
+    }
+    return nil
+}
+
+func cmdMove(s *Server, c *client, r response, args []string) error {
+    from, err := s.position(args[0])
+    if err != nil {
+        return err
+    }
+    to, err := intArg(args[1])
+    if err != nil {
+        return err
+    }
+    if err := s.p.Move(from, to); err != nil {
+        return argErr("Bad song index")
+    }
+    return nil
+}
+
+// cmdPlaylist is the deprecated listing of queue URIs
+func cmdPlaylist(s *Server, c *client, r response, args []string) error {
+    roots := s.opts.Roots()
+    for i, t := range s.p.Status().Queue {
+        r.kv(strconv.Itoa(i), "file: "+uriOf(t, roots))
+    }
+    return nil
+}
+
+// cmdPlaylistInfo prints the queue, or a position or range of it
+func cmdPlaylistInfo(s *Server, c *client, r response, args []string) error {
+    q := s.p.Status().Queue
+    start, end := 0, len(q)
+    if len(args) > 0 {
+        var err error
+        if start, end, err = parseRange(args[0]); err != nil {
+            return err
+        }
+        if end < 0 || end > len(q) {
+            end = len(q)
+        }
+        if start >= len(q) {
+            return argErr("Bad song index")
+        }
+    }
+    roots := s.opts.Roots()
+    for i := start; i < end; i++ {
+        s.writeQueued(r, q[i], i, roots)
+    }
+    return nil
+}
+
+func cmdPlaylistFind(s *Server, c *client, r response, args []string) error {
+    return s.searchQueue(r, args, false)
+}
+
+func cmdPlaylistSearch(s *Server, c *client, r response, args []string) error {
+    return s.searchQueue(r, args, true)
+}
+
+func (s *Server) searchQueue(r response, args []string, fold bool) error {
+    f, rest, err := parseFilter(args, fold)
+    if err != nil {
+        return err
+    }
+    if len(rest) > 0 {
+        return argErr("Unknown argument: %s", rest[0])
+    }
+    roots := s.opts.Roots()
+    for i, t := range s.p.Status().Queue {
+        if f(song{uriOf(t, roots), t}) {
+            s.writeQueued(r, t, i, roots)
+        }
+    }
+    return nil
+}
+
+// cmdPlChanges lists the whole queue when it changed since the given
+// version; changes aren't tracked per entry
+func cmdPlChanges(s *Server, c *client, r response, args []string) error {
+    v, err := intArg(args[0])
+    if err != nil {
+        return err
+    }
+    if v >= s.queueVersion() {
+        return nil
+    }
+    return cmdPlaylistInfo(s, c, r, args[1:])
+}
+
+func cmdPlChangesPosID(s *Server, c *client, r response, args []string) error {
+    v, err := intArg(args[0])
+    if err != nil {
+        return err
+    }
+    if v >= s.queueVersion() {
+        return nil
+    }
+    for i := range s.p.Status().Queue {
+        r.kv("cpos", i)
+        r.kv("Id", i)
+    }
+    return nil
+}
@@ -0,0 +1,406 @@
+package mpd
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"penguin-tunes/pkg/indexer"
+)
+
+// song is an indexed track with its URI
+type song struct {
+    uri string
+    t   *indexer.Track
+}
+
+// uriOf maps a track to its URI: the path relative to the root holding it,
+// under a directory named after the root when there are several. Tracks
+// cut from a file by a CUE sheet sit below it as trackNNNN, as in MPD.
+// Files outside every root keep their absolute path.
+func uriOf(t *indexer.Track, roots []string) string {
+    uri := t.Path
+    for _, root := range roots {
+        rel, err := filepath.Rel(root, t.Path)
+        if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+            continue
+        }
+        uri = filepath.ToSlash(rel)
+        if len(roots) > 1 {
+            uri = filepath.Base(root) + "/" + uri
+        }
+        break
+    }
+    if t.Virtual() {
+        uri += fmt.Sprintf("/track%04d", t.TrackNumber)
+    }
+    return uri
+}
+
+// songs returns the library ordered by URI
+func (s *Server) songs() []song {
+    roots := s.opts.Roots()
+    all := s.idx.GetAll()
+    out := make([]song, 0, len(all))
+    for _, t := range all {
+        out = append(out, song{uriOf(t, roots), t})
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].uri < out[j].uri })
+    return out
+}
+
+// writeSong prints the tags of sg
+func writeSong(r response, sg song) {
+    t := sg.t
+    r.kv("file", sg.uri)
+    if t.Duration > 0 {
+        r.kv("Time", int(t.Duration+0.5))
+        r.kv("duration", fmt.Sprintf("%.3f", t.Duration))
+    }
+    if t.Virtual() {
+        r.kv("Range", fmt.Sprintf("%.3f-%s", t.Start, rangeEnd(t.End)))
+    }
+    for _, name := range tagNames {
+        for _, v := range tagValues(sg, name) {
+            if v != "" {
+                r.kv(name, v)
+            }
+        }
+    }
+}
+
+func rangeEnd(end float64) string {
+    if end <= 0 {
+        return ""
+    }
+    return fmt.Sprintf("%.3f", end)
+}
+
+// query runs find or search: a filter followed by optional "sort TAG" and
+// "window START:END"
+func (s *Server) query(args []string, fold bool) ([]song, error) {
+    f, rest, err := parseFilter(args, fold)
+    if err != nil {
+        return nil, err
+    }
+    var sortTag string
+    start, end := 0, -1
+    for len(rest) > 0 {
+        if len(rest) < 2 {
+            return nil, argErr("Missing value for %s", rest[0])
+        }
+        switch rest[0] {
+        case "sort":
+            sortTag = rest[1]
+        case "window":
+            if start, end, err = parseRange(rest[1]); err != nil {
+                return nil, err
+            }
+        default:
+            return nil, argErr("Unknown argument: %s", rest[0])
+        }
+        rest = rest[2:]
+    }
+    var out []song
+    for _, sg := range s.songs() {
+        if f(sg) {
+            out = append(out, sg)
+        }
+    }
+    if sortTag != "" {
+        sortSongs(out, sortTag)
+    }
+    if end < 0 || end > len(out) {
+        end = len(out)
+    }
+    if start >= end {
+        return nil, nil
+    }
+    return out[start:end], nil
+}
+
+func cmdFind(s *Server, c *client, r response, args []string) error {
+    songs, err := s.query(args, false)
+    for _, sg := range songs {
+        writeSong(r, sg)
+    }
+    return err
+}
+
+func cmdSearch(s *Server, c *client, r response, args []string) error {
+    songs, err := s.query(args, true)
+    for _, sg := range songs {
+        writeSong(r, sg)
+    }
+    return err
+}
+
+func cmdFindAdd(s *Server, c *client, r response, args []string) error {
+    return s.enqueueQuery(args, false)
+}
+
+func cmdSearchAdd(s *Server, c *client, r response, args []string) error {
+    return s.enqueueQuery(args, true)
+}
+
+func (s *Server) enqueueQuery(args []string, fold bool) error {
+    songs, err := s.query(args, fold)
+    if err != nil {
+        return err
+    }
+    tracks := make([]*indexer.Track, len(songs))
+    for i, sg := range songs {
+        tracks[i] = sg.t
+    }
+    if len(tracks) > 0 {
+        s.p.Enqueue(tracks...)
+    }
+    return nil
+}
+
+func cmdCount(s *Server, c *client, r response, args []string) error {
+    f, rest, err := parseFilter(args, false)
+    if err != nil {
+        return err
+    }
+    if len(rest) > 0 {
+        return argErr("Unknown argument: %s", rest[0])
+    }
+    n, total := 0, 0.0
+    for _, sg := range s.songs() {
+        if f(sg) {
+            n++
+            total += sg.t.Duration
+        }
+    }
+    r.kv("songs", n)
+    r.kv("playtime", int(total+0.5))
+    return nil
+}
+
+// cmdList prints the distinct values of a tag among matching songs, sorted.
+// "group TAG" prints each value below the group values it occurs with. The
+// old form "list album ARTIST" filters by artist.
+func cmdList(s *Server, c *client, r response, args []string) error {
+    tag := args[0]
+    if !knownTag(tag) || strings.EqualFold(tag, "any") || strings.EqualFold(tag, "base") {
+        return argErr("Unknown tag type: %s", tag)
+    }
+    args = args[1:]
+    if strings.EqualFold(tag, "album") && len(args) == 1 && !strings.HasPrefix(args[0], "(") {
+        args = []string{"artist", args[0]}
+    }
+    f, rest, err := parseFilter(args, false)
+    if err != nil {
+        return err
+    }
+    var groups []string
+    for len(rest) > 0 {
+        if rest[0] != "group" || len(rest) < 2 {
+            return argErr("Unknown argument: %s", rest[0])
+        }
+        if !knownTag(rest[1]) {
+            return argErr("Unknown tag type: %s", rest[1])
+        }
+        groups = append(groups, rest[1])
+        rest = rest[2:]
+    }
+    seen := make(map[string]bool)
+    var rows [][]string
+    for _, sg := range s.songs() {
+        if !f(sg) {
+            continue
+        }
+        row := make([]string, 0, len(groups)+1)
+        for _, g := range groups {
+            row = append(row, tagValues(sg, g)[0])
+        }
+        v := tagValues(sg, tag)[0]
+        if v == "" {
+            continue
+        }
+        row = append(row, v)
+        key := strings.Join(row, "\x00")
+        if !seen[key] {
+            seen[key] = true
+            rows = append(rows, row)
+        }
+    }
+    sort.Slice(rows, func(i, j int) bool {
+        for k := range rows[i] {
+            if rows[i][k] != rows[j][k] {
+                return rows[i][k] < rows[j][k]
+            }
+        }
+        return false
+    })
+    names := append(append([]string{}, groups...), tag)
+    for i, name := range names {
+        names[i] = outputName(name)
+    }
+    var last []string
+    for _, row := range rows {
+        for k, v := range row {
+            // group values are printed when they change
+            if k < len(groups) && last != nil && last[k] == v && equalPrefix(last, row, k) {
+                continue
+            }
+            r.kv(names[k], v)
+        }
+        last = row
+    }
+    return nil
+}
+
+func equalPrefix(a, b []string, n int) bool {
+    for i := 0; i < n; i++ {
+        if a[i] != b[i] {
+            return false
+        }
+    }
+    return true
+}
+
+// outputName spells a tag the way MPD prints it
+func outputName(tag string) string {
+    if strings.EqualFold(tag, "file") {
+        return "file"
+    }
+    for _, n := range tagNames {
+        if strings.EqualFold(n, tag) {
+            return n
+        }
+    }
+    return tag
+}
+
+// children lists the directories and songs directly inside dir ("" is the top)
+func children(songs []song, dir string) (dirs []string, files []song, found bool) {
+    prefix := ""
+    if dir != "" {
+        prefix = dir + "/"
+    }
+    seen := make(map[string]bool)
+    for _, sg := range songs {
+        if !strings.HasPrefix(sg.uri, prefix) {
+            continue
+        }
+        found = true
+        rest := sg.uri[len(prefix):]
+        if i := strings.IndexByte(rest, '/'); i >= 0 {
+            sub := prefix + rest[:i]
+            if !seen[sub] {
+                seen[sub] = true
+                dirs = append(dirs, sub)
+            }
+            continue
+        }
+        files = append(files, sg)
+    }
+    return dirs, files, found || dir == ""
+}
+
+func cmdLsinfo(s *Server, c *client, r response, args []string) error {
+    dir := ""
+    if len(args) > 0 {
+        dir = strings.Trim(args[0], "/")
+    }
+    songs := s.songs()
+    for _, sg := range songs {
+        if sg.uri == dir && dir != "" {
+            writeSong(r, sg)
+            return nil
+        }
+    }
+    dirs, files, found := children(songs, dir)
+    if !found {
+        return noExist("No such directory")
+    }
+    for _, d := range dirs {
+        r.kv("directory", d)
+    }
+    for _, sg := range files {
+        writeSong(r, sg)
+    }
+    return nil
+}
+
+func cmdListAll(s *Server, c *client, r response, args []string) error {
+    return s.listAll(r, args, false)
+}
+
+func cmdListAllInfo(s *Server, c *client, r response, args []string) error {
+    return s.listAll(r, args, true)
+}
+
+// listAll walks dir recursively, printing directories and songs
+func (s *Server) listAll(r response, args []string, info bool) error {
+    dir := ""
+    if len(args) > 0 {
+        dir = strings.Trim(args[0], "/")
+    }
+    songs := s.songs()
+    var walk func(dir string)
+    walk = func(dir string) {
+        dirs, files, _ := children(songs, dir)
+        for _, sg := range files {
+            if info {
+                writeSong(r, sg)
+            } else {
+                r.kv("file", sg.uri)
+            }
+        }
+        for _, d := range dirs {
+            r.kv("directory", d)
+            walk(d)
+        }
+    }
+    if _, _, found := children(songs, dir); !found {
+        return noExist("No such directory")
+    }
+    walk(dir)
+    return nil
+}
+
+// lookup returns the songs at uri: the song itself, or everything below a directory
+func (s *Server) lookup(uri string) []*indexer.Track {
+    uri = strings.Trim(uri, "/")
+    var out []*indexer.Track
+    for _, sg := range s.songs() {
+        if uri == "" || sg.uri == uri || strings.HasPrefix(sg.uri, uri+"/") {
+            out = append(out, sg.t)
+        }
+    }
+    return out
+}
+
+func cmdUpdate(s *Server, c *client, r response, args []string) error {
+    if s.opts.OnUpdate == nil {
+        return &ackErr{code: ackSystem, msg: "updates are not supported"}
+    }
+    go s.opts.OnUpdate()
+    r.kv("updating_db", 1)
+    return nil
+}
+
+func cmdStats(s *Server, c *client, r response, args []string) error {
+    artists, albums := make(map[string]bool), make(map[string]bool)
+    total := 0.0
+    all := s.idx.GetAll()
+    for _, t := range all {
+        if t.Artist != "" {
+            artists[t.Artist] = true
+        }
+        if t.Album != "" {
+            albums[t.Album] = true
+        }
+        total += t.Duration
+    }
+    r.kv("artists", len(artists))
+    r.kv("albums", len(albums))
+    r.kv("songs", len(all))
+    r.kv("uptime", int(s.uptime().Seconds()))
+    r.kv("db_playtime", int(total+0.5))
+    return nil
+}
@@ -0,0 +1,329 @@
+package mpd
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// filter matches songs; it is built from either the old "TAG VALUE ..."
+// pairs or a new style expression such as ((artist == 'X') AND (album != 'Y'))
+type filter func(sg song) bool
+
+// tagValues returns the values of a tag for a song; "any" covers every tag
+func tagValues(sg song, tag string) []string {
+    t := sg.t
+    switch strings.ToLower(tag) {
+    case "file":
+        return []string{sg.uri}
+    case "artist":
+        return []string{t.Artist}
+    case "albumartist":
+        if t.AlbumArtist == "" {
+            return []string{t.Artist}
+        }
+        return []string{t.AlbumArtist}
+    case "album":
+        return []string{t.Album}
+    case "title":
+        return []string{t.Title}
+    case "track":
+        return []string{numberTag(t.TrackNumber)}
+    case "disc":
+        return []string{numberTag(t.DiscNumber)}
+    case "date":
+        return []string{numberTag(t.Year)}
+    case "genre":
+        return []string{t.Genre}
+    case "composer":
+        return []string{t.Composer}
+    case "any":
+        return []string{sg.uri, t.Artist, t.AlbumArtist, t.Album, t.Title, t.Genre, t.Composer}
+    }
+    return nil
+}
+
+func numberTag(n int) string {
+    if n <= 0 {
+        return ""
+    }
+    return strconv.Itoa(n)
+}
+
+// tagNames are the tags the server knows, as they are spelled in output
+var tagNames = []string{"Artist", "AlbumArtist", "Album", "Title", "Track", "Disc", "Date", "Genre", "Composer"}
+
+func knownTag(tag string) bool {
+    switch strings.ToLower(tag) {
+    case "file", "any", "base":
+        return true
+    }
+    for _, n := range tagNames {
+        if strings.EqualFold(n, tag) {
+            return true
+        }
+    }
+    return false
+}
+
+// parseFilter reads the filter at the start of args and returns the rest.
+// Old style pairs match exactly, or as case-insensitive substrings when fold
+// is set (search); fold makes expressions case-insensitive too.
+func parseFilter(args []string, fold bool) (filter, []string, error) {
+    var parts []filter
+    for len(args) > 0 {
+        a := args[0]
+        if strings.HasPrefix(a, "(") {
+            f, err := parseExpr(a, fold)
+            if err != nil {
+                return nil, nil, err
+            }
+            parts = append(parts, f)
+            args = args[1:]
+            continue
+        }
+        if isModifier(a) {
+            break
+        }
+        if len(args) < 2 {
+            return nil, nil, argErr("Incorrect number of filter arguments")
+        }
+        if !knownTag(a) {
+            return nil, nil, argErr("Unknown filter type: %s", a)
+        }
+        op := "=="
+        if fold {
+            op = "contains"
+        }
+        f, err := compare(a, op, args[1], fold)
+        if err != nil {
+            return nil, nil, err
+        }
+        parts = append(parts, f)
+        args = args[2:]
+    }
+    return func(sg song) bool {
+        for _, f := range parts {
+            if !f(sg) {
+                return false
+            }
+        }
+        return true
+    }, args, nil
+}
+
+// isModifier reports whether a starts the trailing options of a query
+func isModifier(a string) bool {
+    switch a {
+    case "sort", "window", "group", "position":
+        return true
+    }
+    return false
+}
+
+func compare(tag, op, value string, fold bool) (filter, error) {
+    if strings.EqualFold(tag, "base") {
+        prefix := strings.TrimSuffix(value, "/") + "/"
+        return func(sg song) bool { return strings.HasPrefix(sg.uri, prefix) }, nil
+    }
+    norm := func(v string) string { return v }
+    if fold {
+        norm = strings.ToLower
+    }
+    pattern := value
+    value = norm(value)
+    var match func(v string) bool
+    switch op {
+    case "==", "!=":
+        match = func(v string) bool { return norm(v) == value }
+    case "contains":
+        match = func(v string) bool { return strings.Contains(norm(v), value) }
+    case "starts_with":
+        match = func(v string) bool { return strings.HasPrefix(norm(v), value) }
+    case "=~", "!~":
+        expr := pattern
+        if fold {
+            expr = "(?i)" + expr
+        }
+        re, err := regexp.Compile(expr)
+        if err != nil {
+            return nil, argErr("Invalid regular expression: %v", err)
+        }
+        match = re.MatchString
+    }
+    neg := op == "!=" || op == "!~"
+    return func(sg song) bool {
+        found := false
+        for _, v := range tagValues(sg, tag) {
+            if match(v) {
+                found = true
+                break
+            }
+        }
+        return found != neg
+    }, nil
+}
+
+// exprParser parses a new style filter expression
+type exprParser struct {
+    s    string
+    i    int
+    fold bool
+}
+
+func parseExpr(s string, fold bool) (filter, error) {
+    p := &exprParser{s: s, fold: fold}
+    f, err := p.expr()
+    if err != nil {
+        return nil, err
+    }
+    p.space()
+    if p.i != len(p.s) {
+        return nil, argErr("Unparsed garbage after expression")
+    }
+    return f, nil
+}
+
+func (p *exprParser) space() {
+    for p.i < len(p.s) && p.s[p.i] == ' ' {
+        p.i++
+    }
+}
+
+func (p *exprParser) expr() (filter, error) {
+    p.space()
+    if p.i >= len(p.s) || p.s[p.i] != '(' {
+        return nil, argErr("'(' expected")
+    }
+    p.i++
+    p.space()
+    var f filter
+    switch {
+    case p.i < len(p.s) && p.s[p.i] == '!':
+        p.i++
+        inner, err := p.expr()
+        if err != nil {
+            return nil, err
+        }
+        f = func(sg song) bool { return !inner(sg) }
+    case p.i < len(p.s) && p.s[p.i] == '(':
+        parts := []filter{}
+        for {
+            part, err := p.expr()
+            if err != nil {
+                return nil, err
+            }
+            parts = append(parts, part)
+            p.space()
+            if !strings.HasPrefix(p.s[p.i:], "AND") {
+                break
+            }
+            p.i += len("AND")
+        }
+        f = func(sg song) bool {
+            for _, part := range parts {
+                if !part(sg) {
+                    return false
+                }
+            }
+            return true
+        }
+    default:
+        var err error
+        if f, err = p.comparison(); err != nil {
+            return nil, err
+        }
+    }
+    p.space()
+    if p.i >= len(p.s) || p.s[p.i] != ')' {
+        return nil, argErr("')' expected")
+    }
+    p.i++
+    return f, nil
+}
+
+// comparison reads "TAG OP 'VALUE'" or "base 'DIR'"
+func (p *exprParser) comparison() (filter, error) {
+    start := p.i
+    for p.i < len(p.s) && p.s[p.i] != ' ' && p.s[p.i] != ')' {
+        p.i++
+    }
+    tag := p.s[start:p.i]
+    if !knownTag(tag) {
+        return nil, argErr("Unknown filter type: %s", tag)
+    }
+    p.space()
+    op := ""
+    if !strings.EqualFold(tag, "base") {
+        start = p.i
+        for p.i < len(p.s) && p.s[p.i] != ' ' && p.s[p.i] != '"' && p.s[p.i] != '\'' {
+            p.i++
+        }
+        op = p.s[start:p.i]
+        switch op {
+        case "==", "!=", "contains", "starts_with", "=~", "!~":
+        default:
+            return nil, argErr("Unknown filter operator: %s", op)
+        }
+        p.space()
+    }
+    value, err := p.quoted()
+    if err != nil {
+        return nil, err
+    }
+    return compare(tag, op, value, p.fold)
+}
+
+func (p *exprParser) quoted() (string, error) {
+    if p.i >= len(p.s) || (p.s[p.i] != '"' && p.s[p.i] != '\'') {
+        return "", argErr("Quoted value expected")
+    }
+    q := p.s[p.i]
+    p.i++
+    var b strings.Builder
+    for ; p.i < len(p.s) && p.s[p.i] != q; p.i++ {
+        if p.s[p.i] == '\\' && p.i+1 < len(p.s) {
+            p.i++
+        }
+        b.WriteByte(p.s[p.i])
+    }
+    if p.i >= len(p.s) {
+        return "", argErr("Closing quote not found")
+    }
+    p.i++
+    return b.String(), nil
+}
+
+// sortSongs orders songs by tag, descending with a leading "-"; ties keep
+// URI order
+func sortSongs(songs []song, tag string) {
+    desc := strings.HasPrefix(tag, "-")
+    tag = strings.TrimPrefix(tag, "-")
+    key := func(sg song) string {
+        v := tagValues(sg, tag)
+        if len(v) == 0 {
+            return ""
+        }
+        return v[0]
+    }
+    numeric := false
+    switch strings.ToLower(tag) {
+    case "track", "disc", "date":
+        numeric = true
+    }
+    less := func(a, b song) bool {
+        if numeric {
+            x, _ := strconv.Atoi(key(a))
+            y, _ := strconv.Atoi(key(b))
+            return x < y
+        }
+        return strings.ToLower(key(a)) < strings.ToLower(key(b))
+    }
+    sort.SliceStable(songs, func(i, j int) bool {
+        if desc {
+            return less(songs[j], songs[i])
+        }
+        return less(songs[i], songs[j])
+    })
+}
@@ -0,0 +1,439 @@
+package mpd
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"penguin-tunes/pkg/indexer"
+	"penguin-tunes/pkg/player"
+)
+
+// ProtocolVersion is the MPD protocol version announced to clients
+const ProtocolVersion = "0.23.5"
+
+// DefaultAddress is MPD's port on the loopback interface; other hosts can
+// only connect when an address is configured
+const DefaultAddress = "localhost:6600"
+
+// Options configures the server
+type Options struct {
+    // Roots are the music directories; song URIs are relative to the root
+    // holding the file. It is called per request, so it may follow the config.
+    Roots func() []string
+    // Password, when set, must be sent with "password" before other commands
+    Password string
+    // OnUpdate starts a library rescan for "update" and "rescan"; nil refuses them
+    OnUpdate func()
+}
+
+// Server speaks the MPD protocol over TCP on top of the index and player
+type Server struct {
+    idx     *indexer.Index
+    p       *player.Player
+    opts    Options
+    started time.Time
+    // now is the clock for uptime; tests replace it
+    now func() time.Time
+
+    mtx     sync.Mutex
+    ln      net.Listener
+    clients map[*client]struct{}
+    // version counts queue changes, for "playlist" in status and plchanges
+    version int
+    closed  bool
+
+    unsubscribe func()
+}
+
+// New creates a server for idx and p; call Serve to accept clients
+func New(idx *indexer.Index, p *player.Player, opts Options) *Server {
+    if opts.Roots == nil {
+        opts.Roots = func() []string { return nil }
+    }
+    s := &Server{idx: idx, p: p, opts: opts, started: time.Now(), now: time.Now, clients: make(map[*client]struct{}), version: 1}
+    s.unsubscribe = p.Subscribe(s.playerEvent)
+    return s
+}
+
+// Serve accepts connections on ln until Close
+func (s *Server) Serve(ln net.Listener) error {
+    s.mtx.Lock()
+    if s.closed {
+        s.mtx.Unlock()
+        return net.ErrClosed
+    }
+    s.ln = ln
+    s.mtx.Unlock()
+    for {
+        conn, err := ln.Accept()
+        if err != nil {
+            s.mtx.Lock()
+            closed := s.closed
+            s.mtx.Unlock()
+            if closed {
+                return nil
+            }
+            return err
+        }
+        go s.handle(conn)
+    }
+}
+
+// Close stops accepting clients and disconnects the connected ones
+func (s *Server) Close() error {
+    s.mtx.Lock()
+    if s.closed {
+        s.mtx.Unlock()
+        return nil
+    }
+    s.closed = true
+    var err error
+    if s.ln != nil {
+        err = s.ln.Close()
+    }
+    for c := range s.clients {
+        c.conn.Close()
+    }
+    s.mtx.Unlock()
+    s.unsubscribe()
+    return err
+}
+
+// DatabaseChanged wakes clients idling on "database"; call it after the index changes
+func (s *Server) DatabaseChanged() {
+    s.notify("database")
+}
+
+// playerEvent maps player events to idle subsystems
+func (s *Server) playerEvent(ev player.Event) {
+    switch ev.Kind {
+    case player.EventQueue:
+        s.mtx.Lock()
+        s.version++
+        s.mtx.Unlock()
+        s.notify("playlist")
+    case player.EventTrack, player.EventState, player.EventSeeked:
+        s.notify("player")
+    case player.EventVolume:
+        s.notify("mixer")
+    }
+}
+
+func (s *Server) uptime() time.Duration {
+    return s.now().Sub(s.started)
+}
+
+func (s *Server) queueVersion() int {
+    s.mtx.Lock()
+    defer s.mtx.Unlock()
+    return s.version
+}
+
+// notify marks subsystem as changed for every client
+func (s *Server) notify(subsystem string) {
+    s.mtx.Lock()
+    defer s.mtx.Unlock()
+    for c := range s.clients {
+        c.mark(subsystem)
+    }
+}
+
+// subsystems are the idle names clients may wait for
+var subsystems = []string{"database", "update", "stored_playlist", "playlist", "player", "mixer", "output", "options", "partition", "sticker", "subscription", "message", "neighbor", "mount"}
+
+// client is one connection
+type client struct {
+    conn   net.Conn
+    w      *bufio.Writer
+    authed bool
+
+    mtx     sync.Mutex
+    changed map[string]bool
+    wake    chan struct{}
+}
+
+// mark records a change and wakes the client if it is idling
+func (c *client) mark(subsystem string) {
+    c.mtx.Lock()
+    c.changed[subsystem] = true
+    c.mtx.Unlock()
+    select {
+    case c.wake <- struct{}{}:
+    default:
+    }
+}
+
+// take returns and clears the pending changes in mask, sorted
+func (c *client) take(mask map[string]bool) []string {
+    c.mtx.Lock()
+    defer c.mtx.Unlock()
+    var out []string
+    for name := range c.changed {
+        if len(mask) == 0 || mask[name] {
+            out = append(out, name)
+            delete(c.changed, name)
+        }
+    }
+    sort.Strings(out)
+    return out
+}
+
+func (s *Server) handle(conn net.Conn) {
+    c := &client{
+        conn:    conn,
+        w:       bufio.NewWriter(conn),
+        authed:  s.opts.Password == "",
+        changed: make(map[string]bool),
+        wake:    make(chan struct{}, 1),
+    }
+    s.mtx.Lock()
+    if s.closed {
+        s.mtx.Unlock()
+        conn.Close()
+        return
+    }
+    s.clients[c] = struct{}{}
+    s.mtx.Unlock()
+    defer func() {
+        s.mtx.Lock()
+        delete(s.clients, c)
+        s.mtx.Unlock()
+        conn.Close()
+    }()
+
+    // lines are read on their own goroutine so idle can wait for either a
+    // change or the client's noidle
+    lines := make(chan string)
+    done := make(chan struct{})
+    defer close(done)
+    go func() {
+        defer close(lines)
+        r := bufio.NewReader(conn)
+        for {
+            line, err := r.ReadString('\n')
+            if err != nil {
+                return
+            }
+            select {
+            case lines <- strings.TrimRight(line, "\r\n"):
+            case <-done:
+                return
+            }
+        }
+    }()
+
+    fmt.Fprintf(c.w, "OK MPD %s\n", ProtocolVersion)
+    c.w.Flush()
+    var list []string
+    inList, listOK := false, false
+    for line := range lines {
+        switch {
+        case line == "command_list_begin" || line == "command_list_ok_begin":
+            if inList {
+                s.ack(c, &ackErr{code: ackNotList, msg: "nested command list"}, 0, line)
+                break
+            }
+            inList, listOK, list = true, line == "command_list_ok_begin", nil
+            continue
+        case line == "command_list_end" && inList:
+            inList = false
+            s.runList(c, list, listOK)
+        case inList:
+            list = append(list, line)
+            continue
+        default:
+            args, err := splitArgs(line)
+            if err != nil {
+                s.ack(c, err, 0, "")
+                break
+            }
+            if len(args) > 0 && args[0] == "idle" {
+                if !s.idle(c, args[1:], lines) {
+                    return
+                }
+                break
+            }
+            if len(args) > 0 && args[0] == "close" {
+                return
+            }
+            if err := s.exec(c, args); err != nil {
+                s.ack(c, err, 0, command(args))
+                break
+            }
+            c.w.WriteString("OK\n")
+        }
+        if c.w.Flush() != nil {
+            return
+        }
+    }
+}
+
+// runList executes a command list, stopping at the first error
+func (s *Server) runList(c *client, list []string, listOK bool) {
+    for i, line := range list {
+        args, err := splitArgs(line)
+        if err == nil {
+            err = s.exec(c, args)
+        }
+        if err != nil {
+            s.ack(c, err, i, command(args))
+            return
+        }
+        if listOK {
+            c.w.WriteString("list_OK\n")
+        }
+    }
+    c.w.WriteString("OK\n")
+}
+
+// idle waits until one of the named subsystems (any when empty) changes, or
+// the client sends noidle. It reports false when the connection is gone.
+func (s *Server) idle(c *client, names []string, lines <-chan string) bool {
+    mask := make(map[string]bool)
+    for _, n := range names {
+        if !slices.Contains(subsystems, n) {
+            s.ack(c, argErr("Unrecognized idle event: %s", n), 0, "idle")
+            return true
+        }
+        mask[n] = true
+    }
+    for {
+        if changed := c.take(mask); len(changed) > 0 {
+            for _, name := range changed {
+                fmt.Fprintf(c.w, "changed: %s\n", name)
+            }
+            c.w.WriteString("OK\n")
+            return true
+        }
+        select {
+        case <-c.wake:
+        case line, ok := <-lines:
+            if !ok {
+                return false
+            }
+            // anything but noidle is a protocol error; MPD drops such clients
+            if strings.TrimSpace(line) != "noidle" {
+                return false
+            }
+            c.w.WriteString("OK\n")
+            return true
+        }
+    }
+}
+
+// ACK error codes from the MPD protocol
+const (
+    ackNotList    = 1
+    ackArg        = 2
+    ackPassword   = 3
+    ackPermission = 4
+    ackUnknown    = 5
+    ackNoExist    = 50
+    ackSystem     = 52
+)
+
+// ackErr is an error reported to the client with an ACK code
+type ackErr struct {
+    code int
+    msg  string
+}
+
+func (e *ackErr) Error() string { return e.msg }
+
+func argErr(format string, a ...any) error {
+    return &ackErr{code: ackArg, msg: fmt.Sprintf(format, a...)}
+}
+
+func noExist(msg string) error {
+    return &ackErr{code: ackNoExist, msg: msg}
+}
+
+func (s *Server) ack(c *client, err error, listNum int, cmd string) {
+    var e *ackErr
+    if !errors.As(err, &e) {
+        e = &ackErr{code: ackSystem, msg: err.Error()}
+    }
+    fmt.Fprintf(c.w, "ACK [%d@%d] {%s} %s\n", e.code, listNum, cmd, e.msg)
+}
+
+func equal(a, b string) bool {
+    return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func command(args []string) string {
+    if len(args) == 0 {
+        return ""
+    }
+    return args[0]
+}
+
+// response collects "key: value" lines for a command
+type response struct {
+    w io.Writer
+}
+
+func (r response) kv(key string, value any) {
+    fmt.Fprintf(r.w, "%s: %v\n", key, value)
+}
+
+// exec runs a single command, writing its output to c
+func (s *Server) exec(c *client, args []string) error {
+    if len(args) == 0 {
+        return &ackErr{code: ackUnknown, msg: "No command given"}
+    }
+    name := args[0]
+    cmd, ok := commands[name]
+    if !ok {
+        return &ackErr{code: ackUnknown, msg: fmt.Sprintf("unknown command %q", name)}
+    }
+    if !c.authed && name != "password" && name != "ping" {
+        return &ackErr{code: ackPermission, msg: fmt.Sprintf("you don't have permission for %q", name)}
+    }
+    if len(args)-1 < cmd.min || (cmd.max >= 0 && len(args)-1 > cmd.max) {
+        return argErr("wrong number of arguments for %q", name)
+    }
+    return cmd.fn(s, c, response{c.w}, args[1:])
+}
+
+// splitArgs tokenizes a request line; arguments may be double quoted with
+// backslash escapes
+func splitArgs(line string) ([]string, error) {
+    var args []string
+    for i := 0; i < len(line); {
+        switch {
+        case line[i] == ' ' || line[i] == '\t':
+            i++
+        case line[i] == '"':
+            var b strings.Builder
+            i++
+            for ; i < len(line) && line[i] != '"'; i++ {
+                if line[i] == '\\' && i+1 < len(line) {
+                    i++
+                }
+                b.WriteByte(line[i])
+            }
+            if i >= len(line) {
+                return nil, argErr("Missing closing '\"'")
+            }
+            i++
+            args = append(args, b.String())
+        default:
+            j := i
+            for j < len(line) && line[j] != ' ' && line[j] != '\t' {
+                j++
+            }
+            args = append(args, line[i:j])
+            i = j
+        }
+    }
+    return args, nil
+}
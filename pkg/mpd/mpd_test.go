@@ -0,0 +1,151 @@
+package mpd
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"penguin-tunes/pkg/indexer"
+	"penguin-tunes/pkg/player"
+)
+
+func testLibrary(t *testing.T) *indexer.Index {
+    idx := indexer.NewIndexAtBase(t.TempDir())
+    for _, tr := range []*indexer.Track{
+        {ID: "t1", Path: "/music/The Band/First/01 One.mp3", Title: "One", Artist: "The Band", Album: "First", TrackNumber: 1, Year: 2001, Genre: "Rock", Duration: 180.5},
+        {ID: "t2", Path: "/music/The Band/First/02 Two.mp3", Title: "Two", Artist: "The Band", Album: "First", TrackNumber: 2, Year: 2001, Genre: "Rock", Duration: 200},
+        {ID: "t3", Path: "/music/The Band/Second/01 Three.flac", Title: "Three", Artist: "The Band", Album: "Second", TrackNumber: 1, Year: 2005, Genre: "Rock", Duration: 240},
+        {ID: "t4", Path: "/music/Solo/Live.flac", Cue: "/music/Solo/Live.cue", Title: "Intro", Artist: "Solo Act", Album: "Live", TrackNumber: 1, Genre: "Jazz", Start: 0, End: 95.25, Duration: 95.25},
+        {ID: "t5", Path: "/music/Solo/Live.flac", Cue: "/music/Solo/Live.cue", Title: "Finale", Artist: "Solo Act", Album: "Live", TrackNumber: 2, Genre: "Jazz", Start: 95.25, Duration: 120},
+    } {
+        idx.AddOrUpdateTrack(tr)
+    }
+    return idx
+}
+
+// TestTranscripts replays the conversations in testdata. "N> line" sends a
+// line from client N, connecting it on first use; "N< line" expects the next
+// line client N receives.
+func TestTranscripts(t *testing.T) {
+    files, err := filepath.Glob("testdata/*.txt")
+    if err != nil || len(files) == 0 {
+        t.Fatalf("no transcripts: %v", err)
+    }
+    for _, file := range files {
+        t.Run(strings.TrimSuffix(filepath.Base(file), ".txt"), func(t *testing.T) {
+            runTranscript(t, file)
+        })
+    }
+}
+
+func runTranscript(t *testing.T, file string) {
+    b, err := os.ReadFile(file)
+    if err != nil {
+        t.Fatalf("read transcript: %v", err)
+    }
+    p := player.New(context.Background(), nil)
+    s := New(testLibrary(t), p, Options{
+        Roots:    func() []string { return []string{"/music"} },
+        Password: "secret",
+        OnUpdate: func() {},
+    })
+    s.now = func() time.Time { return s.started.Add(42 * time.Second) }
+    ln, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatalf("listen: %v", err)
+    }
+    go s.Serve(ln)
+    t.Cleanup(func() { s.Close() })
+
+    type conn struct {
+        c net.Conn
+        r *bufio.Reader
+    }
+    conns := make(map[string]*conn)
+    get := func(id string) *conn {
+        if c, ok := conns[id]; ok {
+            return c
+        }
+        c, err := net.Dial("tcp", ln.Addr().String())
+        if err != nil {
+            t.Fatalf("dial: %v", err)
+        }
+        t.Cleanup(func() { c.Close() })
+        conns[id] = &conn{c, bufio.NewReader(c)}
+        return conns[id]
+    }
+    for n, line := range strings.Split(string(b), "\n") {
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+        id, rest, ok := strings.Cut(line, " ")
+        if !ok || len(id) < 2 {
+            t.Fatalf("%s:%d: malformed line %q", file, n+1, line)
+        }
+        c := get(id[:len(id)-1])
+        switch id[len(id)-1] {
+        case '>':
+            if _, err := c.c.Write([]byte(rest + "\n")); err != nil {
+                t.Fatalf("%s:%d: write: %v", file, n+1, err)
+            }
+        case '<':
+            c.c.SetReadDeadline(time.Now().Add(2 * time.Second))
+            got, err := c.r.ReadString('\n')
+            if err != nil {
+                t.Fatalf("%s:%d: expected %q, read error: %v", file, n+1, rest, err)
+            }
+            if got = strings.TrimSuffix(got, "\n"); got != rest {
+                t.Fatalf("%s:%d: expected %q, got %q", file, n+1, rest, got)
+            }
+        default:
+            t.Fatalf("%s:%d: malformed line %q", file, n+1, line)
+        }
+    }
+}
+
+func TestSplitArgs(t *testing.T) {
+    args, err := splitArgs(`find "(artist == \"The \\\"Band\\\"\")" window 0:2`)
+    if err != nil {
+        t.Fatalf("splitArgs: %v", err)
+    }
+    want := []string{"find", `(artist == "The \"Band\"")`, "window", "0:2"}
+    if strings.Join(args, "|") != strings.Join(want, "|") {
+        t.Fatalf("expected %q, got %q", want, args)
+    }
+    if _, err := splitArgs(`find "artist`); err == nil {
+        t.Fatalf("expected an error for an unterminated quote")
+    }
+}
+
+func TestFilterExpressions(t *testing.T) {
+    sg := song{uri: "The Band/First/01 One.mp3", t: &indexer.Track{Artist: "The Band", Album: "First", Title: "One", TrackNumber: 1}}
+    for expr, want := range map[string]bool{
+        `(artist == "The Band")`:                          true,
+        `(artist == 'the band')`:                          false,
+        `((artist == "The Band") AND (album != "First"))`: false,
+        `(!(album == "Second"))`:                          true,
+        `(title starts_with "On")`:                        true,
+        `(any contains "Firs")`:                           true,
+        `(file =~ '^The Band/.*\.mp3$')`:                  true,
+        `(base "The Band/First")`:                         true,
+        `(track == "1")`:                                  true,
+    } {
+        f, err := parseExpr(expr, false)
+        if err != nil {
+            t.Fatalf("%s: %v", expr, err)
+        }
+        if f(sg) != want {
+            t.Fatalf("%s: expected %v", expr, want)
+        }
+    }
+    for _, bad := range []string{`(artist == "x"`, `(mood == "x")`, `(artist ~= "x")`, `(artist == x)`, `(file =~ "(")`} {
+        if _, err := parseExpr(bad, false); err == nil {
+            t.Fatalf("%s: expected an error", bad)
+        }
+    }
+}
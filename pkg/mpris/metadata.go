@@ -0,0 +1,86 @@
+package mpris
+
+import (
+	"net/url"
+	"path/filepath"
+	"strconv"
+
+	"github.com/godbus/dbus/v5"
+
+	"penguin-tunes/pkg/indexer"
+	"penguin-tunes/pkg/player"
+)
+
+// noTrack is the MPRIS sentinel track id used when nothing is selected
+const noTrack = dbus.ObjectPath("/org/mpris/MediaPlayer2/TrackList/NoTrack")
+
+// trackObjectPath maps a track to a valid D-Bus object path; track IDs are hex so they are safe as-is
+func trackObjectPath(t *indexer.Track) dbus.ObjectPath {
+    if t == nil || t.ID == "" {
+        return noTrack
+    }
+    return dbus.ObjectPath("/org/penguintunes/track/" + t.ID)
+}
+
+// fileURL converts a local path to a file:// URL
+func fileURL(path string) string {
+    if abs, err := filepath.Abs(path); err == nil {
+        path = abs
+    }
+    u := url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+    return u.String()
+}
+
+// currentTrack returns the current queue entry, or nil when Current is outside the queue
+func currentTrack(st player.Status) *indexer.Track {
+    if st.Current < 0 || st.Current >= len(st.Queue) {
+        return nil
+    }
+    return st.Queue[st.Current]
+}
+
+// metadata builds the xesam/mpris metadata map for the current queue entry
+func metadata(st player.Status) map[string]dbus.Variant {
+    t := currentTrack(st)
+    if t == nil {
+        return map[string]dbus.Variant{"mpris:trackid": dbus.MakeVariant(noTrack)}
+    }
+    md := map[string]dbus.Variant{
+        "mpris:trackid": dbus.MakeVariant(trackObjectPath(t)),
+        "xesam:title":   dbus.MakeVariant(t.Title),
+        "xesam:url":     dbus.MakeVariant(fileURL(t.Path)),
+    }
+    if t.Album != "" {
+        md["xesam:album"] = dbus.MakeVariant(t.Album)
+    }
+    if t.Artist != "" {
+        md["xesam:artist"] = dbus.MakeVariant([]string{t.Artist})
+    }
+    if t.Composer != "" {
+        md["xesam:composer"] = dbus.MakeVariant([]string{t.Composer})
+    }
+    if t.Genre != "" {
+        md["xesam:genre"] = dbus.MakeVariant([]string{t.Genre})
+    }
+    if t.TrackNumber > 0 {
+        md["xesam:trackNumber"] = dbus.MakeVariant(int32(t.TrackNumber))
+    }
+    if t.Year > 0 {
+        md["xesam:contentCreated"] = dbus.MakeVariant(strconv.Itoa(t.Year))
+    }
+    if t.Cover != "" {
+        md["mpris:artUrl"] = dbus.MakeVariant(fileURL(t.Cover))
+    }
+    if st.Duration > 0 {
+        md["mpris:length"] = dbus.MakeVariant(toMicros(st.Duration))
+    }
+    return md
+}
+
+func toMicros(sec float64) int64 {
+    return int64(sec * 1e6)
+}
+
+func fromMicros(us int64) float64 {
+    return float64(us) / 1e6
+}
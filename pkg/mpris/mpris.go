@@ -0,0 +1,355 @@
+package mpris
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+
+	"penguin-tunes/pkg/player"
+)
+
+const (
+    objectPath  = dbus.ObjectPath("/org/mpris/MediaPlayer2")
+    rootIface   = "org.mpris.MediaPlayer2"
+    playerIface = "org.mpris.MediaPlayer2.Player"
+    propsIface  = "org.freedesktop.DBus.Properties"
+    // DefaultBusName is the well-known name requested when Options.BusName is empty
+    DefaultBusName = "org.mpris.MediaPlayer2.penguintunes"
+)
+
+// Options configures the identity and window hooks exposed over MPRIS
+type Options struct {
+    BusName      string
+    Identity     string
+    DesktopEntry string
+    // OnRaise and OnQuit are called for the root interface methods; nil disables them
+    OnRaise func()
+    OnQuit  func()
+    // OnOpenURI handles OpenUri calls; nil makes OpenUri fail with NotSupported
+    OnOpenURI func(uri string) error
+}
+
+// Server publishes a player on the session bus as an MPRIS2 media player
+type Server struct {
+    conn        *dbus.Conn
+    p           *player.Player
+    opts        Options
+    events      chan player.Event
+    done        chan struct{}
+    unsubscribe func()
+    closeOnce   sync.Once
+    lastLength  float64
+}
+
+// New exports the MPRIS objects on conn and requests the bus name
+func New(conn *dbus.Conn, p *player.Player, opts Options) (*Server, error) {
+    if opts.BusName == "" {
+        opts.BusName = DefaultBusName
+    }
+    if opts.Identity == "" {
+        opts.Identity = "PenguinTunes"
+    }
+    s := &Server{
+        conn:   conn,
+        p:      p,
+        opts:   opts,
+        events: make(chan player.Event, 256),
+        done:   make(chan struct{}),
+    }
+    if err := conn.Export(rootObject{s}, objectPath, rootIface); err != nil {
+        return nil, fmt.Errorf("export root: %w", err)
+    }
+    // Seek is renamed on the Go side so it doesn't clash with io.Seeker's signature
+    if err := conn.ExportWithMap(playerObject{s}, map[string]string{"SeekBy": "Seek"}, objectPath, playerIface); err != nil {
+        return nil, fmt.Errorf("export player: %w", err)
+    }
+    if err := conn.Export(propsObject{s}, objectPath, propsIface); err != nil {
+        return nil, fmt.Errorf("export properties: %w", err)
+    }
+    if err := conn.Export(introspect.Introspectable(introspectXML), objectPath, "org.freedesktop.DBus.Introspectable"); err != nil {
+        return nil, fmt.Errorf("export introspection: %w", err)
+    }
+    reply, err := conn.RequestName(opts.BusName, dbus.NameFlagDoNotQueue)
+    if err != nil {
+        return nil, fmt.Errorf("request name: %w", err)
+    }
+    if reply != dbus.RequestNameReplyPrimaryOwner {
+        return nil, fmt.Errorf("bus name %s already taken", opts.BusName)
+    }
+    // player listeners run synchronously; hand events to our own goroutine so
+    // D-Bus round trips never block the player
+    s.unsubscribe = p.Subscribe(func(ev player.Event) {
+        select {
+        case s.events <- ev:
+        case <-s.done:
+        }
+    })
+    go s.loop()
+    return s, nil
+}
+
+// Close releases the bus name and stops forwarding player events
+func (s *Server) Close() error {
+    var err error
+    s.closeOnce.Do(func() {
+        s.unsubscribe()
+        close(s.done)
+        _, err = s.conn.ReleaseName(s.opts.BusName)
+    })
+    return err
+}
+
+func (s *Server) loop() {
+    for {
+        select {
+        case ev := <-s.events:
+            s.handleEvent(ev)
+        case <-s.done:
+            return
+        }
+    }
+}
+
+func (s *Server) handleEvent(ev player.Event) {
+    st := ev.Status
+    switch ev.Kind {
+    case player.EventState:
+        s.emitChanged(st, "PlaybackStatus", "CanPlay", "CanPause", "CanSeek")
+    case player.EventTrack, player.EventQueue:
+        s.lastLength = st.Duration
+        s.emitChanged(st, "Metadata", "CanGoNext", "CanGoPrevious", "CanPlay", "CanPause", "CanSeek")
+    case player.EventVolume:
+        s.emitChanged(st, "Volume")
+    case player.EventSeeked:
+        _ = s.conn.Emit(objectPath, playerIface+".Seeked", toMicros(st.Position))
+    case player.EventPosition:
+        // the track length only becomes known once the output has loaded it
+        if st.Duration != s.lastLength {
+            s.lastLength = st.Duration
+            s.emitChanged(st, "Metadata")
+        }
+    }
+}
+
+func (s *Server) emitChanged(st player.Status, names ...string) {
+    changed := make(map[string]dbus.Variant, len(names))
+    for _, n := range names {
+        if v, ok := playerProps(st)[n]; ok {
+            changed[n] = v
+        }
+    }
+    _ = s.conn.Emit(objectPath, propsIface+".PropertiesChanged", playerIface, changed, []string{})
+}
+
+func playbackStatus(st player.State) string {
+    switch st {
+    case player.Playing:
+        return "Playing"
+    case player.Paused:
+        return "Paused"
+    }
+    return "Stopped"
+}
+
+func (s *Server) rootProps() map[string]dbus.Variant {
+    return map[string]dbus.Variant{
+        "CanQuit":             dbus.MakeVariant(s.opts.OnQuit != nil),
+        "CanRaise":            dbus.MakeVariant(s.opts.OnRaise != nil),
+        "HasTrackList":        dbus.MakeVariant(false),
+        "Identity":            dbus.MakeVariant(s.opts.Identity),
+        "DesktopEntry":        dbus.MakeVariant(s.opts.DesktopEntry),
+        "SupportedUriSchemes": dbus.MakeVariant([]string{"file"}),
+        "SupportedMimeTypes": dbus.MakeVariant([]string{
+            "audio/mpeg", "audio/flac", "audio/mp4", "audio/ogg", "audio/opus", "audio/wav", "audio/aac", "audio/x-ms-wma",
+        }),
+    }
+}
+
+func playerProps(st player.Status) map[string]dbus.Variant {
+    hasTrack := currentTrack(st) != nil
+    return map[string]dbus.Variant{
+        "PlaybackStatus": dbus.MakeVariant(playbackStatus(st.State)),
+        "LoopStatus":     dbus.MakeVariant("None"),
+        "Rate":           dbus.MakeVariant(1.0),
+        "Shuffle":        dbus.MakeVariant(false),
+        "Metadata":       dbus.MakeVariant(metadata(st)),
+        "Volume":         dbus.MakeVariant(st.Volume),
+        "Position":       dbus.MakeVariant(toMicros(st.Position)),
+        "MinimumRate":    dbus.MakeVariant(1.0),
+        "MaximumRate":    dbus.MakeVariant(1.0),
+        "CanGoNext":      dbus.MakeVariant(st.Current+1 < len(st.Queue)),
+        "CanGoPrevious":  dbus.MakeVariant(hasTrack),
+        "CanPlay":        dbus.MakeVariant(len(st.Queue) > 0),
+        "CanPause":       dbus.MakeVariant(hasTrack),
+        "CanSeek":        dbus.MakeVariant(hasTrack),
+        "CanControl":     dbus.MakeVariant(true),
+    }
+}
+
+// rootObject implements org.mpris.MediaPlayer2
+type rootObject struct{ s *Server }
+
+func (r rootObject) Raise() *dbus.Error {
+    if r.s.opts.OnRaise != nil {
+        r.s.opts.OnRaise()
+    }
+    return nil
+}
+
+func (r rootObject) Quit() *dbus.Error {
+    if r.s.opts.OnQuit != nil {
+        r.s.opts.OnQuit()
+    }
+    return nil
+}
+
+// playerObject implements org.mpris.MediaPlayer2.Player
+type playerObject struct{ s *Server }
+
+func (o playerObject) Next() *dbus.Error      { o.s.p.Next(); return nil }
+func (o playerObject) Previous() *dbus.Error  { o.s.p.Previous(); return nil }
+func (o playerObject) Pause() *dbus.Error     { o.s.p.Pause(); return nil }
+func (o playerObject) PlayPause() *dbus.Error { o.s.p.PlayPause(); return nil }
+func (o playerObject) Stop() *dbus.Error      { o.s.p.Stop(); return nil }
+func (o playerObject) Play() *dbus.Error      { o.s.p.Play(); return nil }
+
+// Seek moves relative to the current position; offset is in microseconds
+func (o playerObject) SeekBy(offset int64) *dbus.Error {
+    o.s.p.SeekBy(fromMicros(offset))
+    return nil
+}
+
+// SetPosition is ignored unless trackID still refers to the current track, per spec
+func (o playerObject) SetPosition(trackID dbus.ObjectPath, pos int64) *dbus.Error {
+    st := o.s.p.Status()
+    t := currentTrack(st)
+    if t == nil || trackObjectPath(t) != trackID {
+        return nil
+    }
+    if pos < 0 || (st.Duration > 0 && fromMicros(pos) > st.Duration) {
+        return nil
+    }
+    o.s.p.Seek(fromMicros(pos))
+    return nil
+}
+
+func (o playerObject) OpenUri(uri string) *dbus.Error {
+    if o.s.opts.OnOpenURI == nil {
+        return dbus.NewError("org.freedesktop.DBus.Error.NotSupported", []interface{}{"OpenUri is not supported"})
+    }
+    if err := o.s.opts.OnOpenURI(uri); err != nil {
+        return dbus.MakeFailedError(err)
+    }
+    return nil
+}
+
+// propsObject implements org.freedesktop.DBus.Properties with values computed from live player state
+type propsObject struct{ s *Server }
+
+func (o propsObject) all(iface string) (map[string]dbus.Variant, *dbus.Error) {
+    switch iface {
+    case rootIface:
+        return o.s.rootProps(), nil
+    case playerIface:
+        return playerProps(o.s.p.Status()), nil
+    }
+    return nil, dbus.NewError("org.freedesktop.DBus.Error.UnknownInterface", []interface{}{iface})
+}
+
+func (o propsObject) Get(iface, name string) (dbus.Variant, *dbus.Error) {
+    props, err := o.all(iface)
+    if err != nil {
+        return dbus.Variant{}, err
+    }
+    v, ok := props[name]
+    if !ok {
+        return dbus.Variant{}, dbus.NewError("org.freedesktop.DBus.Error.UnknownProperty", []interface{}{name})
+    }
+    return v, nil
+}
+
+func (o propsObject) GetAll(iface string) (map[string]dbus.Variant, *dbus.Error) {
+    return o.all(iface)
+}
+
+func (o propsObject) Set(iface, name string, value dbus.Variant) *dbus.Error {
+    if iface == playerIface && name == "Volume" {
+        v, ok := value.Value().(float64)
+        if !ok {
+            return dbus.NewError("org.freedesktop.DBus.Error.InvalidArgs", []interface{}{"Volume must be a double"})
+        }
+        o.s.p.SetVolume(v)
+        return nil
+    }
+    if _, err := o.Get(iface, name); err != nil {
+        return err
+    }
+    return dbus.NewError("org.freedesktop.DBus.Error.PropertyReadOnly", []interface{}{name})
+}
+
+const introspectXML = `<node>
+  <interface name="org.mpris.MediaPlayer2">
+    <method name="Raise"/>
+    <method name="Quit"/>
+    <property name="CanQuit" type="b" access="read"/>
+    <property name="CanRaise" type="b" access="read"/>
+    <property name="HasTrackList" type="b" access="read"/>
+    <property name="Identity" type="s" access="read"/>
+    <property name="DesktopEntry" type="s" access="read"/>
+    <property name="SupportedUriSchemes" type="as" access="read"/>
+    <property name="SupportedMimeTypes" type="as" access="read"/>
+  </interface>
+  <interface name="org.mpris.MediaPlayer2.Player">
+    <method name="Next"/>
+    <method name="Previous"/>
+    <method name="Pause"/>
+    <method name="PlayPause"/>
+    <method name="Stop"/>
+    <method name="Play"/>
+    <method name="Seek"><arg name="Offset" type="x" direction="in"/></method>
+    <method name="SetPosition">
+      <arg name="TrackId" type="o" direction="in"/>
+      <arg name="Position" type="x" direction="in"/>
+    </method>
+    <method name="OpenUri"><arg name="Uri" type="s" direction="in"/></method>
+    <signal name="Seeked"><arg name="Position" type="x"/></signal>
+    <property name="PlaybackStatus" type="s" access="read"/>
+    <property name="LoopStatus" type="s" access="read"/>
+    <property name="Rate" type="d" access="read"/>
+    <property name="Shuffle" type="b" access="read"/>
+    <property name="Metadata" type="a{sv}" access="read"/>
+    <property name="Volume" type="d" access="readwrite"/>
+    <property name="Position" type="x" access="read"/>
+    <property name="MinimumRate" type="d" access="read"/>
+    <property name="MaximumRate" type="d" access="read"/>
+    <property name="CanGoNext" type="b" access="read"/>
+    <property name="CanGoPrevious" type="b" access="read"/>
+    <property name="CanPlay" type="b" access="read"/>
+    <property name="CanPause" type="b" access="read"/>
+    <property name="CanSeek" type="b" access="read"/>
+    <property name="CanControl" type="b" access="read"/>
+  </interface>` + introspect.IntrospectDataString + `
+  <interface name="org.freedesktop.DBus.Properties">
+    <method name="Get">
+      <arg name="interface" type="s" direction="in"/>
+      <arg name="property" type="s" direction="in"/>
+      <arg name="value" type="v" direction="out"/>
+    </method>
+    <method name="GetAll">
+      <arg name="interface" type="s" direction="in"/>
+      <arg name="props" type="a{sv}" direction="out"/>
+    </method>
+    <method name="Set">
+      <arg name="interface" type="s" direction="in"/>
+      <arg name="property" type="s" direction="in"/>
+      <arg name="value" type="v" direction="in"/>
+    </method>
+    <signal name="PropertiesChanged">
+      <arg name="interface" type="s"/>
+      <arg name="changed_properties" type="a{sv}"/>
+      <arg name="invalidated_properties" type="as"/>
+    </signal>
+  </interface>
+</node>`
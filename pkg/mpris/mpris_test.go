@@ -0,0 +1,204 @@
+package mpris
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"penguin-tunes/pkg/indexer"
+	"penguin-tunes/pkg/player"
+)
+
+const busConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:dir=%DIR%</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// startBus launches a private dbus-daemon and returns its address
+func startBus(t *testing.T) string {
+    bin, err := exec.LookPath("dbus-daemon")
+    if err != nil {
+        t.Skip("dbus-daemon not available")
+    }
+    dir := t.TempDir()
+    conf := filepath.Join(dir, "bus.conf")
+    if err := os.WriteFile(conf, []byte(strings.ReplaceAll(busConfig, "%DIR%", dir)), 0o644); err != nil {
+        t.Fatalf("write bus config: %v", err)
+    }
+    cmd := exec.Command(bin, "--config-file="+conf, "--print-address", "--nofork")
+    out, err := cmd.StdoutPipe()
+    if err != nil {
+        t.Fatalf("stdout pipe: %v", err)
+    }
+    if err := cmd.Start(); err != nil {
+        t.Fatalf("start dbus-daemon: %v", err)
+    }
+    t.Cleanup(func() {
+        _ = cmd.Process.Kill()
+        _ = cmd.Wait()
+    })
+    addr, err := bufio.NewReader(out).ReadString('\n')
+    if err != nil {
+        t.Fatalf("read bus address: %v", err)
+    }
+    return strings.TrimSpace(addr)
+}
+
+func connect(t *testing.T, addr string) *dbus.Conn {
+    conn, err := dbus.Connect(addr)
+    if err != nil {
+        t.Fatalf("connect: %v", err)
+    }
+    t.Cleanup(func() { conn.Close() })
+    return conn
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+    deadline := time.Now().Add(2 * time.Second)
+    for !cond() {
+        if time.Now().After(deadline) {
+            t.Fatalf("timed out waiting for %s", what)
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+}
+
+func TestServerOverPrivateBus(t *testing.T) {
+    addr := startBus(t)
+    p := player.New(context.Background(), nil)
+    tracks := []*indexer.Track{
+        {ID: "aa11", Path: "/music/one.flac", Title: "One", Artist: "Band", Album: "First", Cover: "/covers/aa11.jpg", TrackNumber: 1},
+        {ID: "bb22", Path: "/music/two.flac", Title: "Two", Artist: "Band", Album: "First"},
+    }
+    if err := p.SetQueue(tracks, 0); err != nil {
+        t.Fatalf("SetQueue: %v", err)
+    }
+    raised := make(chan struct{}, 1)
+    srv, err := New(connect(t, addr), p, Options{Identity: "PenguinTunes", OnRaise: func() { raised <- struct{}{} }})
+    if err != nil {
+        t.Fatalf("New: %v", err)
+    }
+    defer srv.Close()
+
+    client := connect(t, addr)
+    obj := client.Object(DefaultBusName, objectPath)
+
+    v, err := obj.GetProperty(playerIface + ".PlaybackStatus")
+    if err != nil || v.Value().(string) != "Playing" {
+        t.Fatalf("PlaybackStatus: %v %v", v, err)
+    }
+    v, err = obj.GetProperty(playerIface + ".Metadata")
+    if err != nil {
+        t.Fatalf("Metadata: %v", err)
+    }
+    md := v.Value().(map[string]dbus.Variant)
+    if md["xesam:title"].Value().(string) != "One" {
+        t.Fatalf("unexpected title: %v", md["xesam:title"])
+    }
+    if md["mpris:artUrl"].Value().(string) != "file:///covers/aa11.jpg" {
+        t.Fatalf("unexpected artUrl: %v", md["mpris:artUrl"])
+    }
+    if md["mpris:trackid"].Value().(dbus.ObjectPath) != "/org/penguintunes/track/aa11" {
+        t.Fatalf("unexpected trackid: %v", md["mpris:trackid"])
+    }
+
+    if err := obj.Call(rootIface+".Raise", 0).Err; err != nil {
+        t.Fatalf("Raise: %v", err)
+    }
+    select {
+    case <-raised:
+    case <-time.After(2 * time.Second):
+        t.Fatalf("OnRaise not called")
+    }
+
+    if err := obj.Call(playerIface+".PlayPause", 0).Err; err != nil {
+        t.Fatalf("PlayPause: %v", err)
+    }
+    if p.Status().State != player.Paused {
+        t.Fatalf("expected paused, got %s", p.Status().State)
+    }
+    if err := obj.Call(playerIface+".Next", 0).Err; err != nil {
+        t.Fatalf("Next: %v", err)
+    }
+    if p.Status().Current != 1 {
+        t.Fatalf("expected current 1, got %d", p.Status().Current)
+    }
+
+    if err := client.AddMatchSignal(dbus.WithMatchInterface(playerIface), dbus.WithMatchMember("Seeked")); err != nil {
+        t.Fatalf("AddMatchSignal: %v", err)
+    }
+    sigs := make(chan *dbus.Signal, 4)
+    client.Signal(sigs)
+    if err := obj.Call(playerIface+".Seek", 0, int64(5_000_000)).Err; err != nil {
+        t.Fatalf("Seek: %v", err)
+    }
+    select {
+    case s := <-sigs:
+        if s.Body[0].(int64) != 5_000_000 {
+            t.Fatalf("unexpected Seeked position %v", s.Body[0])
+        }
+    case <-time.After(2 * time.Second):
+        t.Fatalf("expected Seeked signal")
+    }
+    v, _ = obj.GetProperty(playerIface + ".Position")
+    if v.Value().(int64) != 5_000_000 {
+        t.Fatalf("unexpected Position %v", v)
+    }
+
+    if err := obj.SetProperty(playerIface+".Volume", dbus.MakeVariant(0.25)); err != nil {
+        t.Fatalf("set Volume: %v", err)
+    }
+    waitFor(t, "volume change", func() bool { return p.Status().Volume == 0.25 })
+    if err := obj.SetProperty(playerIface+".Rate", dbus.MakeVariant(2.0)); err == nil {
+        t.Fatalf("expected Rate to be read-only")
+    }
+}
+
+func TestMetadataWithoutTrack(t *testing.T) {
+    md := metadata(player.Status{Current: -1})
+    if md["mpris:trackid"].Value().(dbus.ObjectPath) != noTrack {
+        t.Fatalf("expected NoTrack id, got %v", md["mpris:trackid"])
+    }
+    if fileURL("/a b/c#d.mp3") != "file:///a%20b/c%23d.mp3" {
+        t.Fatalf("unexpected file URL %s", fileURL("/a b/c#d.mp3"))
+    }
+}
+
+func TestSetPositionChecksTrack(t *testing.T) {
+    p := player.New(context.Background(), nil)
+    one := &indexer.Track{ID: "aa11", Path: "/music/one.flac"}
+    if err := p.SetQueue([]*indexer.Track{one, {ID: "bb22", Path: "/music/two.flac"}}, 0); err != nil {
+        t.Fatalf("SetQueue: %v", err)
+    }
+    o := playerObject{s: &Server{p: p}}
+    // a stale track id or NoTrack must not move the current track
+    o.SetPosition("/org/penguintunes/track/bb22", 5_000_000)
+    o.SetPosition(noTrack, 5_000_000)
+    if pos := p.Status().Position; pos != 0 {
+        t.Fatalf("expected SetPosition for another track ignored, got %v", pos)
+    }
+    o.SetPosition(trackObjectPath(one), 5_000_000)
+    if pos := p.Status().Position; pos != 5 {
+        t.Fatalf("expected position 5, got %v", pos)
+    }
+
+    if currentTrack(player.Status{Current: 1, Queue: []*indexer.Track{one}}) != nil {
+        t.Fatalf("expected no current track past the end of the queue")
+    }
+}
@@ -0,0 +1,163 @@
+package organizer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	cfg "penguin-tunes/pkg/config"
+	"penguin-tunes/pkg/indexer"
+)
+
+// writable refuses moves that couldn't be recorded: files would move while
+// the index and journal kept their old paths
+func writable(idx *indexer.Index, j *Journal) error {
+    if idx.ReadOnly() || j.ReadOnly() {
+        return cfg.ErrReadOnly
+    }
+    return nil
+}
+
+// Apply carries out p, re-keying each track in idx before its file moves so the
+// watcher sees a known path. It stops at the first failure; the moves done so
+// far are recorded in j either way so they can be undone.
+func Apply(p *Plan, idx *indexer.Index, j *Journal) (int, error) {
+    if err := writable(idx, j); err != nil {
+        return 0, fmt.Errorf("organize: %w", err)
+    }
+    batch := Batch{Time: time.Now(), Stop: p.stop}
+    var failed error
+    for _, m := range p.Moves {
+        if err := move(idx, m.TrackID, m.From, m.To); err != nil {
+            failed = fmt.Errorf("move %s: %w", m.From, err)
+            break
+        }
+        batch.Moves = append(batch.Moves, m)
+    }
+    for _, m := range batch.Moves {
+        removeEmptyDirs(filepath.Dir(m.From), batch.Stop)
+    }
+    if len(batch.Moves) > 0 {
+        if err := j.push(batch); err != nil {
+            return len(batch.Moves), errors.Join(failed, err)
+        }
+    }
+    return len(batch.Moves), failed
+}
+
+// Undo reverses the most recent batch in j. Moves that can't be reverted stay
+// in the journal so Undo can be retried.
+func Undo(idx *indexer.Index, j *Journal) (int, error) {
+    if err := writable(idx, j); err != nil {
+        return 0, fmt.Errorf("undo organize: %w", err)
+    }
+    batch, ok := j.Last()
+    if !ok {
+        return 0, fmt.Errorf("nothing to undo")
+    }
+    undone := 0
+    var failed error
+    for i := len(batch.Moves) - 1; i >= 0; i-- {
+        m := batch.Moves[i]
+        if err := move(idx, m.TrackID, m.To, m.From); err != nil {
+            failed = fmt.Errorf("restore %s: %w", m.From, err)
+            break
+        }
+        batch.Moves = batch.Moves[:i]
+        removeEmptyDirs(filepath.Dir(m.To), batch.Stop)
+        undone++
+    }
+    if err := j.replaceLast(batch); err != nil {
+        return undone, errors.Join(failed, err)
+    }
+    return undone, failed
+}
+
+// move renames one file, keeping the index entry of a track in step
+func move(idx *indexer.Index, trackID, from, to string) error {
+    if trackID != "" {
+        if err := idx.MoveTrack(from, to); err != nil {
+            return err
+        }
+    }
+    if err := moveFile(from, to); err != nil {
+        if trackID != "" {
+            _ = idx.MoveTrack(to, from)
+        }
+        return err
+    }
+    return nil
+}
+
+// moveFile renames from to to, copying across filesystems; it never overwrites
+func moveFile(from, to string) error {
+    if occupied(to, from) {
+        return fmt.Errorf("%s already exists", to)
+    }
+    if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+        return err
+    }
+    err := os.Rename(from, to)
+    if err == nil || !errors.Is(err, syscall.EXDEV) {
+        return err
+    }
+    if err := copyFile(from, to); err != nil {
+        _ = os.Remove(to)
+        return err
+    }
+    return os.Remove(from)
+}
+
+func copyFile(from, to string) error {
+    src, err := os.Open(from)
+    if err != nil {
+        return err
+    }
+    defer src.Close()
+    fi, err := src.Stat()
+    if err != nil {
+        return err
+    }
+    dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fi.Mode().Perm())
+    if err != nil {
+        return err
+    }
+    if _, err := io.Copy(dst, src); err != nil {
+        dst.Close()
+        return err
+    }
+    if err := dst.Sync(); err != nil {
+        dst.Close()
+        return err
+    }
+    if err := dst.Close(); err != nil {
+        return err
+    }
+    return os.Chtimes(to, fi.ModTime(), fi.ModTime())
+}
+
+// removeEmptyDirs deletes dir and its parents while they are empty and lie
+// strictly inside one of the stop directories
+func removeEmptyDirs(dir string, stop []string) {
+    for inside(dir, stop) {
+        if os.Remove(dir) != nil {
+            return
+        }
+        dir = filepath.Dir(dir)
+    }
+}
+
+func inside(dir string, roots []string) bool {
+    for _, r := range roots {
+        rel, err := filepath.Rel(r, dir)
+        if err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,116 @@
+package organizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	cfg "penguin-tunes/pkg/config"
+)
+
+// Batch is one applied plan; Moves lists the completed moves in order
+type Batch struct {
+	Time  time.Time `json:"time"`
+	Moves []Move    `json:"moves"`
+	Stop  []string  `json:"stop"`
+}
+
+// Journal keeps applied batches so they can be undone, most recent last
+type Journal struct {
+	mtx      sync.Mutex
+	path     string
+	batches  []Batch
+	readOnly bool
+}
+
+// NewJournal creates a journal persisted at path
+func NewJournal(path string) *Journal {
+	return &Journal{path: path}
+}
+
+// NewJournalAtBase stores the journal in baseDir/organizer.json
+func NewJournalAtBase(baseDir string) *Journal {
+	return NewJournal(filepath.Join(baseDir, "organizer.json"))
+}
+
+// LoadFromFile loads the journal from disk if the file exists
+func (j *Journal) LoadFromFile() error {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	if _, err := os.Stat(j.path); err != nil {
+		return nil
+	}
+	b, err := os.ReadFile(j.path)
+	if err != nil {
+		return fmt.Errorf("read journal: %w", err)
+	}
+	if err := json.Unmarshal(b, &j.batches); err != nil {
+		return fmt.Errorf("unmarshal journal: %w", err)
+	}
+	return nil
+}
+
+// SetReadOnly makes Apply and Undo refuse to move files, for processes that
+// don't hold the data dir lock
+func (j *Journal) SetReadOnly(ro bool) {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	j.readOnly = ro
+}
+
+// ReadOnly reports whether SetReadOnly was turned on
+func (j *Journal) ReadOnly() bool {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	return j.readOnly
+}
+
+func (j *Journal) saveLocked() error {
+	if j.readOnly {
+		return fmt.Errorf("save journal: %w", cfg.ErrReadOnly)
+	}
+	b, err := json.MarshalIndent(j.batches, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal journal: %w", err)
+	}
+	if err := cfg.WriteFileAtomic(j.path, b); err != nil {
+		return fmt.Errorf("write journal: %w", err)
+	}
+	return nil
+}
+
+// Last returns the most recent batch
+func (j *Journal) Last() (Batch, bool) {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	if len(j.batches) == 0 {
+		return Batch{}, false
+	}
+	return j.batches[len(j.batches)-1], true
+}
+
+// push appends a batch and persists
+func (j *Journal) push(b Batch) error {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	j.batches = append(j.batches, b)
+	return j.saveLocked()
+}
+
+// replaceLast swaps the most recent batch for b, dropping it if b has no moves left
+func (j *Journal) replaceLast(b Batch) error {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	if len(j.batches) == 0 {
+		return nil
+	}
+	if len(b.Moves) == 0 {
+		j.batches = j.batches[:len(j.batches)-1]
+	} else {
+		j.batches[len(j.batches)-1] = b
+	}
+	return j.saveLocked()
+}
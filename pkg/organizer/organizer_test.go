@@ -0,0 +1,193 @@
+package organizer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cfg "penguin-tunes/pkg/config"
+	"penguin-tunes/pkg/indexer"
+)
+
+func TestRenderAndSanitize(t *testing.T) {
+    tr := &indexer.Track{Path: "/m/x.FLAC", Title: `What? / Why: "Now"`, Artist: "AC/DC", Album: "Live.", TrackNumber: 3, DiscNumber: 1, Year: 1992}
+    got := render(DefaultTemplate, tr)
+    want := filepath.Join("AC_DC", "Live. (1992)", `0103 - What_ _ Why_ _Now_.flac`)
+    if got != want {
+        t.Fatalf("render = %q, want %q", got, want)
+    }
+    // empty placeholders leave no stray brackets or dashes
+    tr = &indexer.Track{Path: "/m/Song.mp3", Title: "Song", Artist: "A", Album: "B"}
+    if got := render(DefaultTemplate, tr); got != filepath.Join("A", "B", "Song.mp3") {
+        t.Fatalf("render without numbers = %q", got)
+    }
+    if got := sanitize("con", ".mp3"); got != "_con.mp3" {
+        t.Fatalf("reserved name = %q", got)
+    }
+    if got := sanitize("dots... ", ""); got != "dots" {
+        t.Fatalf("trailing dots = %q", got)
+    }
+    if got := sanitize(strings.Repeat("é", 200), ".mp3"); len(got) > maxComponent || !strings.HasSuffix(got, "é.mp3") {
+        t.Fatalf("long name not truncated on a rune boundary: %d bytes", len(got))
+    }
+    if err := validateTemplate("{artist}/{mood}"); err == nil {
+        t.Fatalf("expected unknown placeholder to be rejected")
+    }
+}
+
+func write(t *testing.T, path string) {
+    t.Helper()
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+        t.Fatalf("mkdir: %v", err)
+    }
+    if err := os.WriteFile(path, []byte(path), 0o644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+}
+
+func exists(path string) bool {
+    _, err := os.Stat(path)
+    return err == nil
+}
+
+func TestApplyAndUndo(t *testing.T) {
+    base := t.TempDir()
+    root := filepath.Join(base, "music")
+    src := filepath.Join(root, "incoming")
+    a := filepath.Join(src, "a.mp3")
+    b := filepath.Join(src, "b.mp3")
+    for _, p := range []string{a, b, filepath.Join(src, "a.lrc"), filepath.Join(src, "Cover.jpg")} {
+        write(t, p)
+    }
+    // an unrelated file already sits where the first track would go
+    taken := filepath.Join(root, "Band", "Album", "01 - Same.mp3")
+    write(t, taken)
+
+    idx := indexer.NewIndexAtBase(base)
+    idx.AddOrUpdateTrack(&indexer.Track{ID: "a", Path: a, Title: "Same", Artist: "Band", Album: "Album", TrackNumber: 1, Rating: 4})
+    idx.AddOrUpdateTrack(&indexer.Track{ID: "b", Path: b, Title: "Same", Artist: "Band", Album: "Album", TrackNumber: 1})
+    plan, err := NewPlan(idx.GetAll(), Options{Template: "{artist}/{album}/{track:02} - {title}.{ext}", Roots: []string{root}})
+    if err != nil {
+        t.Fatalf("NewPlan: %v", err)
+    }
+    dir := filepath.Join(root, "Band", "Album")
+    wantA := filepath.Join(dir, "01 - Same (2).mp3")
+    wantB := filepath.Join(dir, "01 - Same (3).mp3")
+    want := map[string]string{
+        a: wantA, b: wantB,
+        filepath.Join(src, "a.lrc"):     filepath.Join(dir, "01 - Same (2).lrc"),
+        filepath.Join(src, "Cover.jpg"): filepath.Join(dir, "Cover.jpg"),
+    }
+    if len(plan.Moves) != len(want) {
+        t.Fatalf("unexpected plan %+v", plan.Moves)
+    }
+    for _, m := range plan.Moves {
+        if want[m.From] != m.To {
+            t.Fatalf("planned %s -> %s, want %s", m.From, m.To, want[m.From])
+        }
+    }
+    if exists(wantA) {
+        t.Fatalf("dry run touched the disk")
+    }
+
+    j := NewJournalAtBase(base)
+    n, err := Apply(plan, idx, j)
+    if err != nil || n != 4 {
+        t.Fatalf("Apply = %d, %v", n, err)
+    }
+    for _, to := range want {
+        if !exists(to) {
+            t.Fatalf("%s missing after apply", to)
+        }
+    }
+    if exists(src) {
+        t.Fatalf("empty source directory left behind")
+    }
+    if !exists(root) {
+        t.Fatalf("library root removed")
+    }
+    if tr := idx.GetByID("a"); tr == nil || tr.Path != wantA || tr.Rating != 4 {
+        t.Fatalf("index entry not moved in place: %+v", tr)
+    }
+
+    // the journal survives a restart
+    j = NewJournalAtBase(base)
+    if err := j.LoadFromFile(); err != nil {
+        t.Fatalf("LoadFromFile: %v", err)
+    }
+    if n, err := Undo(idx, j); err != nil || n != 4 {
+        t.Fatalf("Undo = %d, %v", n, err)
+    }
+    for from := range want {
+        if !exists(from) {
+            t.Fatalf("%s not restored", from)
+        }
+    }
+    if !exists(taken) || exists(wantA) {
+        t.Fatalf("undo disturbed unrelated files")
+    }
+    if tr := idx.GetByID("b"); tr == nil || tr.Path != b {
+        t.Fatalf("index entry not restored: %+v", tr)
+    }
+    if _, ok := j.Last(); ok {
+        t.Fatalf("journal not emptied after undo")
+    }
+    if _, err := Undo(idx, j); err == nil {
+        t.Fatalf("expected nothing to undo")
+    }
+}
+
+func TestReadOnlyRefusesMoves(t *testing.T) {
+    base := t.TempDir()
+    root := filepath.Join(base, "music")
+    a := filepath.Join(root, "a.mp3")
+    write(t, a)
+    idx := indexer.NewIndexAtBase(base)
+    idx.AddOrUpdateTrack(&indexer.Track{ID: "a", Path: a, Title: "A", Artist: "Band"})
+    plan, err := NewPlan(idx.GetAll(), Options{Template: "{artist}/{title}.{ext}", Roots: []string{root}})
+    if err != nil {
+        t.Fatalf("NewPlan: %v", err)
+    }
+    idx.SetReadOnly(true)
+    j := NewJournalAtBase(base)
+    j.SetReadOnly(true)
+    if n, err := Apply(plan, idx, j); n != 0 || !errors.Is(err, cfg.ErrReadOnly) {
+        t.Fatalf("expected Apply to refuse, got %d, %v", n, err)
+    }
+    if !exists(a) {
+        t.Fatalf("a read-only Apply moved the file")
+    }
+    if _, err := Undo(idx, j); !errors.Is(err, cfg.ErrReadOnly) {
+        t.Fatalf("expected Undo to refuse, got %v", err)
+    }
+}
+
+func TestPlanSkips(t *testing.T) {
+    base := t.TempDir()
+    root := filepath.Join(base, "music")
+    in := filepath.Join(root, "A", "B", "Song.mp3")
+    out := filepath.Join(base, "elsewhere", "x.mp3")
+    keep := filepath.Join(root, "dir", "cover.jpg")
+    write(t, in)
+    write(t, filepath.Join(root, "dir", "one.mp3"))
+    write(t, filepath.Join(root, "dir", "two.mp3"))
+    write(t, keep)
+    tracks := []*indexer.Track{
+        {ID: "in", Path: in, Title: "Song", Artist: "A", Album: "B"},
+        {ID: "out", Path: out, Title: "x", Artist: "A", Album: "B"},
+        // two.mp3 isn't being organized, so the folder cover stays
+        {ID: "one", Path: filepath.Join(root, "dir", "one.mp3"), Title: "One", Artist: "C", Album: "D"},
+    }
+    plan, err := NewPlan(tracks, Options{Template: "{artist}/{album}/{title}", Roots: []string{root}})
+    if err != nil {
+        t.Fatalf("NewPlan: %v", err)
+    }
+    if len(plan.Moves) != 1 || plan.Moves[0].To != filepath.Join(root, "C", "D", "One.mp3") {
+        t.Fatalf("unexpected moves %+v", plan.Moves)
+    }
+    if len(plan.Skipped) != 2 {
+        t.Fatalf("unexpected skips %+v", plan.Skipped)
+    }
+}
@@ -0,0 +1,228 @@
+package organizer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"penguin-tunes/pkg/indexer"
+)
+
+// Move is one file rename; TrackID is empty for sidecar files
+type Move struct {
+    TrackID string `json:"trackId"`
+    From    string `json:"from"`
+    To      string `json:"to"`
+}
+
+// Skip records a track or sidecar left where it is
+type Skip struct {
+    Path   string `json:"path"`
+    Reason string `json:"reason"`
+}
+
+// Plan is the dry-run result: moves in the order they will be applied
+type Plan struct {
+    Moves   []Move `json:"moves"`
+    Skipped []Skip `json:"skipped"`
+    // stop holds the directories empty-directory cleanup never removes
+    stop []string
+}
+
+// Options controls where tracks are placed
+type Options struct {
+    Template string
+    // Root is the destination; when empty each track stays under the library root holding it
+    Root  string
+    Roots []string
+}
+
+// sidecarExts are files sharing a track's stem that move with it
+var sidecarExts = map[string]bool{".lrc": true, ".txt": true, ".jpg": true, ".jpeg": true, ".png": true}
+
+// dirCovers are folder images moved when the whole directory moves together
+var dirCovers = map[string]bool{"cover": true, "folder": true, "front": true, "albumart": true}
+
+// NewPlan works out the target of every track without touching the disk
+func NewPlan(tracks []*indexer.Track, opts Options) (*Plan, error) {
+    if err := validateTemplate(opts.Template); err != nil {
+        return nil, err
+    }
+    p := &Plan{Moves: []Move{}, Skipped: []Skip{}}
+    p.stop = append(p.stop, opts.Roots...)
+    if opts.Root != "" {
+        p.stop = append(p.stop, opts.Root)
+    }
+    sorted := append([]*indexer.Track(nil), tracks...)
+    sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+
+    taken := make(map[string]bool) // lower-cased targets; FAT and NTFS ignore case
+    moving := make(map[string]bool)
+    targetDirs := make(map[string]map[string]bool)
+    for _, t := range sorted {
+        if !t.Virtual() {
+            moving[t.Path] = true
+        }
+    }
+    for _, t := range sorted {
+        if t.Virtual() {
+            // the sheet names the file, and its other tracks would move with it
+            p.Skipped = append(p.Skipped, Skip{Path: t.Path, Reason: "part of a CUE sheet"})
+            continue
+        }
+        root := opts.Root
+        if root == "" {
+            if root = rootOf(t.Path, opts.Roots); root == "" {
+                p.Skipped = append(p.Skipped, Skip{Path: t.Path, Reason: "outside the library folders"})
+                continue
+            }
+        }
+        to := filepath.Join(root, render(opts.Template, t))
+        if to == t.Path {
+            taken[strings.ToLower(to)] = true
+            p.Skipped = append(p.Skipped, Skip{Path: t.Path, Reason: "already in place"})
+            addTarget(targetDirs, t.Path, to)
+            continue
+        }
+        to = p.resolve(to, t.Path, taken)
+        p.Moves = append(p.Moves, Move{TrackID: t.ID, From: t.Path, To: to})
+        addTarget(targetDirs, t.Path, to)
+        p.addSidecars(t.Path, to, taken)
+    }
+    p.addDirCovers(targetDirs, moving, taken)
+    return p, nil
+}
+
+// rootOf returns the library root containing path
+func rootOf(path string, roots []string) string {
+    best := ""
+    for _, r := range roots {
+        if inside(path, []string{r}) && len(r) > len(best) {
+            best = r
+        }
+    }
+    return best
+}
+
+func addTarget(targetDirs map[string]map[string]bool, from, to string) {
+    dir := filepath.Dir(from)
+    if targetDirs[dir] == nil {
+        targetDirs[dir] = make(map[string]bool)
+    }
+    targetDirs[dir][filepath.Dir(to)] = true
+}
+
+// resolve appends " (2)", " (3)"… until to is free in the plan and on disk
+func (p *Plan) resolve(to, from string, taken map[string]bool) string {
+    ext := filepath.Ext(to)
+    stem := strings.TrimSuffix(to, ext)
+    for n := 2; ; n++ {
+        if !taken[strings.ToLower(to)] && !occupied(to, from) {
+            taken[strings.ToLower(to)] = true
+            return to
+        }
+        to = stem + " (" + strconv.Itoa(n) + ")" + ext
+    }
+}
+
+// occupied reports whether another file already exists at to; a case-only
+// rename of from itself doesn't count
+func occupied(to, from string) bool {
+    fi, err := os.Lstat(to)
+    if err != nil {
+        return false
+    }
+    if src, err := os.Lstat(from); err == nil && os.SameFile(fi, src) {
+        return false
+    }
+    return true
+}
+
+// addSidecars plans lyrics and per-track covers named after the track
+func (p *Plan) addSidecars(from, to string, taken map[string]bool) {
+    dir := filepath.Dir(from)
+    stem := strings.TrimSuffix(filepath.Base(from), filepath.Ext(from))
+    newStem := strings.TrimSuffix(to, filepath.Ext(to))
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        return
+    }
+    for _, e := range entries {
+        name := e.Name()
+        ext := filepath.Ext(name)
+        if e.IsDir() || !sidecarExts[strings.ToLower(ext)] || strings.TrimSuffix(name, ext) != stem {
+            continue
+        }
+        src := filepath.Join(dir, name)
+        dst := newStem + ext
+        if taken[strings.ToLower(dst)] || occupied(dst, src) {
+            p.Skipped = append(p.Skipped, Skip{Path: src, Reason: "target exists"})
+            continue
+        }
+        taken[strings.ToLower(dst)] = true
+        p.Moves = append(p.Moves, Move{From: src, To: dst})
+    }
+}
+
+// addDirCovers moves folder images of directories whose audio all goes to one place
+func (p *Plan) addDirCovers(targetDirs map[string]map[string]bool, moving map[string]bool, taken map[string]bool) {
+    dirs := make([]string, 0, len(targetDirs))
+    for dir := range targetDirs {
+        dirs = append(dirs, dir)
+    }
+    sort.Strings(dirs)
+    for _, dir := range dirs {
+        targets := targetDirs[dir]
+        if len(targets) != 1 {
+            continue
+        }
+        var target string
+        for target = range targets {
+        }
+        if target == dir {
+            continue
+        }
+        entries, err := os.ReadDir(dir)
+        if err != nil {
+            continue
+        }
+        whole := true
+        var covers []string
+        for _, e := range entries {
+            name := e.Name()
+            path := filepath.Join(dir, name)
+            if e.IsDir() {
+                continue
+            }
+            if indexer.IsAudioFile(path) && !moving[path] {
+                whole = false
+                break
+            }
+            ext := strings.ToLower(filepath.Ext(name))
+            if (ext == ".jpg" || ext == ".jpeg" || ext == ".png") && dirCovers[strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name)))] {
+                covers = append(covers, name)
+            }
+        }
+        if !whole {
+            continue
+        }
+        for _, name := range covers {
+            src := filepath.Join(dir, name)
+            dst := filepath.Join(target, name)
+            if taken[strings.ToLower(dst)] || occupied(dst, src) {
+                p.Skipped = append(p.Skipped, Skip{Path: src, Reason: "target exists"})
+                continue
+            }
+            taken[strings.ToLower(dst)] = true
+            p.Moves = append(p.Moves, Move{From: src, To: dst})
+        }
+    }
+}
+
+// String summarises the plan for logs
+func (p *Plan) String() string {
+    return fmt.Sprintf("%d moves, %d skipped", len(p.Moves), len(p.Skipped))
+}
@@ -0,0 +1,127 @@
+package organizer
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"penguin-tunes/pkg/indexer"
+)
+
+// DefaultTemplate lays files out by album artist and album
+const DefaultTemplate = "{albumartist}/{album} ({year})/{disc:02}{track:02} - {title}.{ext}"
+
+// maxComponent is the longest file name FAT and NTFS accept, in bytes for simplicity
+const maxComponent = 255
+
+var fieldRe = regexp.MustCompile(`\{([a-z]+)(?::(\d+))?\}`)
+
+var knownFields = map[string]bool{
+    "title": true, "artist": true, "album": true, "albumartist": true, "genre": true,
+    "composer": true, "year": true, "track": true, "disc": true, "ext": true,
+}
+
+// validateTemplate checks placeholders before any path is rendered
+func validateTemplate(tmpl string) error {
+    if strings.TrimSpace(tmpl) == "" {
+        return fmt.Errorf("empty template")
+    }
+    for _, m := range fieldRe.FindAllStringSubmatch(tmpl, -1) {
+        if !knownFields[m[1]] {
+            return fmt.Errorf("unknown placeholder {%s}", m[1])
+        }
+    }
+    return nil
+}
+
+// render fills tmpl with t's tags and returns the relative, sanitized target path
+func render(tmpl string, t *indexer.Track) string {
+    ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(t.Path)), ".")
+    stem := strings.TrimSuffix(filepath.Base(t.Path), filepath.Ext(t.Path))
+    values := map[string]string{
+        "title":       orDefault(t.Title, stem),
+        "artist":      orDefault(t.Artist, "Unknown Artist"),
+        "album":       orDefault(t.Album, "Unknown Album"),
+        "albumartist": orDefault(t.AlbumArtist, orDefault(t.Artist, "Unknown Artist")),
+        "genre":       t.Genre,
+        "composer":    t.Composer,
+        "ext":         ext,
+    }
+    numbers := map[string]int{"year": t.Year, "track": t.TrackNumber, "disc": t.DiscNumber}
+
+    segments := strings.Split(strings.Trim(tmpl, "/"), "/")
+    for i, seg := range segments {
+        seg = fieldRe.ReplaceAllStringFunc(seg, func(m string) string {
+            sub := fieldRe.FindStringSubmatch(m)
+            if n, ok := numbers[sub[1]]; ok {
+                if n == 0 {
+                    return ""
+                }
+                width, _ := strconv.Atoi(sub[2])
+                return fmt.Sprintf("%0*d", width, n)
+            }
+            // separators inside values are replaced by sanitize, so they never create directories
+            return values[sub[1]]
+        })
+        last := i == len(segments)-1
+        segExt := ""
+        if last {
+            // the extension is kept apart so cleanup and truncation never touch it
+            if strings.HasSuffix(seg, "."+ext) {
+                seg = strings.TrimSuffix(seg, "."+ext)
+            }
+            segExt = "." + ext
+        }
+        segments[i] = sanitize(cleanup(seg), segExt)
+    }
+    return filepath.Join(segments...)
+}
+
+func orDefault(s, def string) string {
+    if strings.TrimSpace(s) == "" {
+        return def
+    }
+    return s
+}
+
+var emptyGroupRe = regexp.MustCompile(`\(\s*\)|\[\s*\]|\{\s*\}`)
+
+// cleanup removes what empty placeholders leave behind
+func cleanup(s string) string {
+    s = emptyGroupRe.ReplaceAllString(s, "")
+    s = strings.Join(strings.Fields(s), " ")
+    return strings.Trim(s, " -_")
+}
+
+var reservedNames = map[string]bool{
+    "CON": true, "PRN": true, "AUX": true, "NUL": true,
+    "COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+    "LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// sanitize makes name+ext valid on FAT and NTFS: no reserved characters or device
+// names, no trailing dots or spaces and at most maxComponent bytes
+func sanitize(name, ext string) string {
+    name = strings.Map(func(r rune) rune {
+        if r < 0x20 || strings.ContainsRune(`<>:"/\|?*`, r) {
+            return '_'
+        }
+        return r
+    }, name)
+    name = strings.TrimRight(name, ". ")
+    if name == "" {
+        name = "_"
+    }
+    base, _, _ := strings.Cut(name, ".")
+    if reservedNames[strings.ToUpper(strings.TrimSpace(base))] {
+        name = "_" + name
+    }
+    for len(name)+len(ext) > maxComponent {
+        _, size := utf8.DecodeLastRuneInString(name)
+        name = name[:len(name)-size]
+    }
+    return strings.TrimRight(name, ". ") + ext
+}
@@ -0,0 +1,420 @@
+package player
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"penguin-tunes/pkg/indexer"
+)
+
+// State is the transport state of the player
+type State string
+
+const (
+    Stopped State = "stopped"
+    Playing State = "playing"
+    Paused  State = "paused"
+)
+
+// EventKind tells listeners what part of the player changed
+type EventKind string
+
+const (
+    EventQueue    EventKind = "queue"
+    EventTrack    EventKind = "track"
+    EventState    EventKind = "state"
+    EventSeeked   EventKind = "seeked"
+    EventVolume   EventKind = "volume"
+    EventPosition EventKind = "position"
+)
+
+// restartThreshold is how far into a track Previous restarts it instead of going back
+const restartThreshold = 3.0
+
+// Status is a snapshot of the player; positions and durations are in seconds
+type Status struct {
+    State    State            `json:"state"`
+    Queue    []*indexer.Track `json:"queue"`
+    Current  int              `json:"current"`
+    Position float64          `json:"position"`
+    Duration float64          `json:"duration"`
+    Volume   float64          `json:"volume"`
+    // Offset is where the current track starts in its file when a CUE sheet
+    // cut it from a larger one; the audio output plays from Offset+Position
+    Offset float64 `json:"offset"`
+}
+
+// Event is delivered to listeners after every change
+type Event struct {
+    Kind   EventKind
+    Status Status
+}
+
+// Player keeps the play queue and transport state. Audio is rendered by the
+// frontend, which reports progress back through UpdatePosition and TrackEnded.
+type Player struct {
+    mtx       sync.Mutex
+    ctx       context.Context
+    emitter   indexer.EventEmitter
+    queue     []*indexer.Track
+    current   int
+    state     State
+    position  float64
+    duration  float64
+    volume    float64
+    listeners map[int]func(Event)
+    nextID    int
+}
+
+// New creates a stopped player with an empty queue; emitter may be nil
+func New(ctx context.Context, emitter indexer.EventEmitter) *Player {
+    return &Player{
+        ctx:       ctx,
+        emitter:   emitter,
+        current:   -1,
+        state:     Stopped,
+        volume:    1,
+        listeners: make(map[int]func(Event)),
+    }
+}
+
+// Subscribe registers fn for player events and returns a func that removes it
+func (p *Player) Subscribe(fn func(Event)) func() {
+    p.mtx.Lock()
+    defer p.mtx.Unlock()
+    id := p.nextID
+    p.nextID++
+    p.listeners[id] = fn
+    return func() {
+        p.mtx.Lock()
+        defer p.mtx.Unlock()
+        delete(p.listeners, id)
+    }
+}
+
+// Status returns a snapshot of the current player state
+func (p *Player) Status() Status {
+    p.mtx.Lock()
+    defer p.mtx.Unlock()
+    return p.statusLocked()
+}
+
+// Current returns the selected track, or nil
+func (p *Player) Current() *indexer.Track {
+    p.mtx.Lock()
+    defer p.mtx.Unlock()
+    return p.currentLocked()
+}
+
+func (p *Player) currentLocked() *indexer.Track {
+    if p.current < 0 || p.current >= len(p.queue) {
+        return nil
+    }
+    return p.queue[p.current]
+}
+
+// SetQueue replaces the queue and starts playing at index start
+func (p *Player) SetQueue(tracks []*indexer.Track, start int) error {
+    p.mtx.Lock()
+    if len(tracks) > 0 && (start < 0 || start >= len(tracks)) {
+        p.mtx.Unlock()
+        return fmt.Errorf("start index %d out of range", start)
+    }
+    p.queue = append([]*indexer.Track{}, tracks...)
+    p.position, p.duration = 0, 0
+    if len(tracks) == 0 {
+        p.current = -1
+        p.state = Stopped
+    } else {
+        p.current = start
+        p.state = Playing
+    }
+    p.notifyLocked(EventQueue, EventTrack, EventState)
+    return nil
+}
+
+// Enqueue appends tracks to the end of the queue
+func (p *Player) Enqueue(tracks ...*indexer.Track) {
+    p.mtx.Lock()
+    p.queue = append(p.queue, tracks...)
+    p.notifyLocked(EventQueue)
+}
+
+// Clear empties the queue and stops playback
+func (p *Player) Clear() {
+    p.mtx.Lock()
+    p.queue = nil
+    p.current = -1
+    p.state = Stopped
+    p.position, p.duration = 0, 0
+    p.notifyLocked(EventQueue, EventTrack, EventState)
+}
+
+// Remove drops the queue entry at i; removing the current entry selects the
+// one after it, or stops at the end of the queue
+func (p *Player) Remove(i int) error {
+    p.mtx.Lock()
+    if i < 0 || i >= len(p.queue) {
+        p.mtx.Unlock()
+        return fmt.Errorf("queue index %d out of range", i)
+    }
+    p.queue = append(p.queue[:i:i], p.queue[i+1:]...)
+    kinds := []EventKind{EventQueue}
+    switch {
+    case i < p.current:
+        p.current--
+    case i == p.current:
+        p.position, p.duration = 0, 0
+        if p.current >= len(p.queue) {
+            p.current = -1
+            if p.state != Stopped {
+                p.state = Stopped
+                kinds = append(kinds, EventState)
+            }
+        }
+        kinds = append(kinds, EventTrack)
+    }
+    p.notifyLocked(kinds...)
+    return nil
+}
+
+// Move moves the queue entry at from to position to; the current entry stays selected
+func (p *Player) Move(from, to int) error {
+    p.mtx.Lock()
+    if from < 0 || from >= len(p.queue) || to < 0 || to >= len(p.queue) {
+        p.mtx.Unlock()
+        return fmt.Errorf("queue move %d to %d out of range", from, to)
+    }
+    q := append([]*indexer.Track{}, p.queue...)
+    t := q[from]
+    q = append(q[:from], q[from+1:]...)
+    q = append(q[:to], append([]*indexer.Track{t}, q[to:]...)...)
+    p.queue = q
+    switch {
+    case p.current == from:
+        p.current = to
+    case from < p.current && to >= p.current:
+        p.current--
+    case from > p.current && to <= p.current:
+        p.current++
+    }
+    p.notifyLocked(EventQueue)
+    return nil
+}
+
+// PlayIndex jumps to the queue entry at i and starts playing it
+func (p *Player) PlayIndex(i int) error {
+    p.mtx.Lock()
+    if i < 0 || i >= len(p.queue) {
+        p.mtx.Unlock()
+        return fmt.Errorf("queue index %d out of range", i)
+    }
+    p.current = i
+    p.state = Playing
+    p.position, p.duration = 0, 0
+    p.notifyLocked(EventTrack, EventState)
+    return nil
+}
+
+// Play resumes playback, starting from the first entry if nothing is selected
+func (p *Player) Play() {
+    p.mtx.Lock()
+    if len(p.queue) == 0 || p.state == Playing {
+        p.mtx.Unlock()
+        return
+    }
+    kinds := []EventKind{EventState}
+    if p.current < 0 {
+        p.current = 0
+        p.position, p.duration = 0, 0
+        kinds = append(kinds, EventTrack)
+    }
+    p.state = Playing
+    p.notifyLocked(kinds...)
+}
+
+// Pause pauses playback if playing
+func (p *Player) Pause() {
+    p.mtx.Lock()
+    if p.state != Playing {
+        p.mtx.Unlock()
+        return
+    }
+    p.state = Paused
+    p.notifyLocked(EventState)
+}
+
+// PlayPause toggles between playing and paused
+func (p *Player) PlayPause() {
+    if p.Status().State == Playing {
+        p.Pause()
+        return
+    }
+    p.Play()
+}
+
+// Stop stops playback and rewinds the current track
+func (p *Player) Stop() {
+    p.mtx.Lock()
+    if p.state == Stopped {
+        p.mtx.Unlock()
+        return
+    }
+    p.state = Stopped
+    p.position = 0
+    p.notifyLocked(EventState)
+}
+
+// Next advances to the next queue entry; at the end of the queue playback stops
+func (p *Player) Next() {
+    p.mtx.Lock()
+    if p.current+1 >= len(p.queue) {
+        if p.state != Stopped {
+            p.state = Stopped
+            p.position = 0
+            p.notifyLocked(EventState)
+            return
+        }
+        p.mtx.Unlock()
+        return
+    }
+    p.current++
+    p.position, p.duration = 0, 0
+    if p.state == Stopped {
+        p.state = Playing
+    }
+    p.notifyLocked(EventTrack, EventState)
+}
+
+// Previous restarts the current track, or goes back one entry near its start
+func (p *Player) Previous() {
+    p.mtx.Lock()
+    if p.current < 0 {
+        p.mtx.Unlock()
+        return
+    }
+    if p.position > restartThreshold || p.current == 0 {
+        p.position = 0
+        p.notifyLocked(EventSeeked)
+        return
+    }
+    p.current--
+    p.position, p.duration = 0, 0
+    p.notifyLocked(EventTrack)
+}
+
+// CanGoNext reports whether there is a queue entry after the current one
+func (p *Player) CanGoNext() bool {
+    p.mtx.Lock()
+    defer p.mtx.Unlock()
+    return p.current+1 < len(p.queue)
+}
+
+// CanGoPrevious reports whether Previous has anything to do
+func (p *Player) CanGoPrevious() bool {
+    p.mtx.Lock()
+    defer p.mtx.Unlock()
+    return p.current >= 0
+}
+
+// Seek moves the playback position to pos seconds; seeking past the end advances to the next entry
+func (p *Player) Seek(pos float64) {
+    p.mtx.Lock()
+    if p.current < 0 {
+        p.mtx.Unlock()
+        return
+    }
+    if pos < 0 {
+        pos = 0
+    }
+    if p.duration > 0 && pos > p.duration {
+        p.mtx.Unlock()
+        p.Next()
+        return
+    }
+    p.position = pos
+    p.notifyLocked(EventSeeked)
+}
+
+// SeekBy moves the playback position by offset seconds
+func (p *Player) SeekBy(offset float64) {
+    p.Seek(p.Status().Position + offset)
+}
+
+// SetVolume sets output volume in range [0, 1]
+func (p *Player) SetVolume(v float64) {
+    if v < 0 {
+        v = 0
+    }
+    if v > 1 {
+        v = 1
+    }
+    p.mtx.Lock()
+    if p.volume == v {
+        p.mtx.Unlock()
+        return
+    }
+    p.volume = v
+    p.notifyLocked(EventVolume)
+}
+
+// UpdatePosition records progress reported by the audio output, in the file
+// it plays. For a track cut from a larger file the position is made relative
+// to the track, and passing the track's end moves on to the next entry.
+func (p *Player) UpdatePosition(pos, duration float64) {
+    p.mtx.Lock()
+    if t := p.currentLocked(); t != nil && t.Virtual() {
+        if t.End > 0 && pos >= t.End {
+            p.mtx.Unlock()
+            p.Next()
+            return
+        }
+        pos, duration = max(0, pos-t.Start), t.Duration
+    }
+    p.position = pos
+    if duration > 0 {
+        p.duration = duration
+    }
+    p.notifyLocked(EventPosition)
+}
+
+// TrackEnded is called by the audio output when the current track finished
+func (p *Player) TrackEnded() {
+    p.Next()
+}
+
+func (p *Player) statusLocked() Status {
+    st := Status{
+        State:    p.state,
+        Queue:    append([]*indexer.Track{}, p.queue...),
+        Current:  p.current,
+        Position: p.position,
+        Duration: p.duration,
+        Volume:   p.volume,
+    }
+    if t := p.currentLocked(); t != nil {
+        st.Offset = t.Start
+    }
+    return st
+}
+
+// notifyLocked unlocks p.mtx and then delivers events, so listeners may call back into the player
+func (p *Player) notifyLocked(kinds ...EventKind) {
+    st := p.statusLocked()
+    listeners := make([]func(Event), 0, len(p.listeners))
+    for _, fn := range p.listeners {
+        listeners = append(listeners, fn)
+    }
+    p.mtx.Unlock()
+    for _, k := range kinds {
+        ev := Event{Kind: k, Status: st}
+        for _, fn := range listeners {
+            fn(ev)
+        }
+        // position updates originate from the frontend; don't echo them back
+        if p.emitter != nil && k != EventPosition {
+            p.emitter.Emit(p.ctx, "player-"+string(k), st)
+        }
+    }
+}
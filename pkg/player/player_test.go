@@ -0,0 +1,161 @@
+package player
+
+import (
+	"context"
+	"testing"
+
+	"penguin-tunes/pkg/indexer"
+)
+
+type fakeEmitter struct {
+    events []string
+}
+
+func (f *fakeEmitter) Emit(ctx context.Context, event string, data any) {
+    f.events = append(f.events, event)
+}
+
+func queue(n int) []*indexer.Track {
+    out := make([]*indexer.Track, n)
+    for i := range out {
+        out[i] = &indexer.Track{ID: string(rune('a' + i)), Title: string(rune('A' + i))}
+    }
+    return out
+}
+
+func TestQueueNavigation(t *testing.T) {
+    fe := &fakeEmitter{}
+    p := New(context.Background(), fe)
+    var kinds []EventKind
+    p.Subscribe(func(ev Event) { kinds = append(kinds, ev.Kind) })
+
+    if err := p.SetQueue(queue(3), 1); err != nil {
+        t.Fatalf("SetQueue: %v", err)
+    }
+    if st := p.Status(); st.State != Playing || st.Current != 1 {
+        t.Fatalf("unexpected status after SetQueue: %+v", st)
+    }
+    p.Next()
+    if p.Current().ID != "c" || p.CanGoNext() {
+        t.Fatalf("expected last entry, got %s", p.Current().ID)
+    }
+    // at the end of the queue Next stops playback
+    p.Next()
+    if st := p.Status(); st.State != Stopped || st.Current != 2 {
+        t.Fatalf("expected stop at end, got %+v", st)
+    }
+
+    p.Play()
+    p.UpdatePosition(10, 200)
+    p.Previous()
+    if st := p.Status(); st.Current != 2 || st.Position != 0 {
+        t.Fatalf("expected restart of current track, got %+v", st)
+    }
+    p.Previous()
+    if p.Status().Current != 1 {
+        t.Fatalf("expected previous entry, got %d", p.Status().Current)
+    }
+
+    p.PlayPause()
+    if p.Status().State != Paused {
+        t.Fatalf("expected paused")
+    }
+    p.Enqueue(queue(1)...)
+    if len(p.Status().Queue) != 4 {
+        t.Fatalf("expected 4 queued tracks")
+    }
+    if err := p.SetQueue(queue(2), 5); err == nil {
+        t.Fatalf("expected out of range error")
+    }
+    if len(kinds) == 0 || len(fe.events) == 0 {
+        t.Fatalf("expected listeners and emitter to be notified")
+    }
+    for _, e := range fe.events {
+        if e == "player-position" {
+            t.Fatalf("position updates must not be emitted to the frontend")
+        }
+    }
+}
+
+func TestSeekAndVolume(t *testing.T) {
+    p := New(context.Background(), nil)
+    p.Seek(5)
+    if p.Status().Position != 0 {
+        t.Fatalf("seek without a track should be ignored")
+    }
+    _ = p.SetQueue(queue(2), 0)
+    p.UpdatePosition(1, 100)
+    p.SeekBy(20)
+    if p.Status().Position != 21 {
+        t.Fatalf("expected position 21, got %v", p.Status().Position)
+    }
+    p.Seek(150)
+    if p.Status().Current != 1 {
+        t.Fatalf("seeking past the end should advance")
+    }
+    p.SetVolume(2)
+    if p.Status().Volume != 1 {
+        t.Fatalf("volume should be clamped")
+    }
+    p.SetVolume(-1)
+    if p.Status().Volume != 0 {
+        t.Fatalf("volume should be clamped")
+    }
+}
+
+func TestCueTrackOffsets(t *testing.T) {
+    p := New(context.Background(), nil)
+    parts := []*indexer.Track{
+        {ID: "1", Path: "/m/a.flac", Cue: "/m/a.cue", TrackNumber: 1, Start: 0, End: 90, Duration: 90},
+        {ID: "2", Path: "/m/a.flac", Cue: "/m/a.cue", TrackNumber: 2, Start: 90, Duration: 110},
+    }
+    _ = p.SetQueue(parts, 0)
+    p.UpdatePosition(30, 200)
+    if st := p.Status(); st.Position != 30 || st.Duration != 90 || st.Offset != 0 {
+        t.Fatalf("unexpected status %+v", st)
+    }
+    // the file keeps playing past the end of the first track
+    p.UpdatePosition(90.5, 200)
+    if st := p.Status(); st.Current != 1 || st.Offset != 90 {
+        t.Fatalf("expected the second track, got %+v", st)
+    }
+    p.UpdatePosition(100, 200)
+    if st := p.Status(); st.Position != 10 || st.Duration != 110 {
+        t.Fatalf("expected a position within the track, got %+v", st)
+    }
+}
+
+func TestRemoveAndMove(t *testing.T) {
+    p := New(context.Background(), nil)
+    _ = p.SetQueue(queue(4), 2)
+    ids := func() string {
+        s := ""
+        for _, t := range p.Status().Queue {
+            s += t.ID
+        }
+        return s
+    }
+    if err := p.Move(3, 0); err != nil || ids() != "dabc" || p.Current().ID != "c" {
+        t.Fatalf("Move: %v %s current %+v", err, ids(), p.Status())
+    }
+    if err := p.Move(3, 1); err != nil || ids() != "dcab" || p.Current().ID != "c" {
+        t.Fatalf("Move current: %v %s current %+v", err, ids(), p.Status())
+    }
+    _ = p.Remove(0)
+    if ids() != "cab" || p.Status().Current != 0 {
+        t.Fatalf("Remove before current: %s %+v", ids(), p.Status())
+    }
+    // removing the current entry selects the next one
+    _ = p.Remove(0)
+    if p.Current().ID != "a" || p.Status().State != Playing {
+        t.Fatalf("Remove current: %+v", p.Status())
+    }
+    _ = p.Remove(1)
+    _ = p.Remove(0)
+    if st := p.Status(); st.Current != -1 || st.State != Stopped {
+        t.Fatalf("expected stop after emptying the queue, got %+v", st)
+    }
+    if p.Remove(0) == nil || p.Move(0, 0) == nil {
+        t.Fatalf("expected out of range errors")
+    }
+}
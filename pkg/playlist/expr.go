@@ -0,0 +1,277 @@
+package playlist
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseWhere reads a filter expression into a rule group, for typing queries
+// instead of building them rule by rule. Terms are "field op value" and are
+// joined by "and" (also implied between terms) and "or", with parentheses
+// for grouping:
+//
+//	artist = "The Band" and year >= 2000
+//	(genre ~ jazz or genre ~ blues) rating >= 4 loved
+//
+// Symbols map to the operator of the field's kind: = != for "is"/"is_not"
+// or numbers, ~ !~ for contains, ^= $= for starts/ends with, and < > also
+// mean before/after on dates. Operator names such as in_last work as well.
+// A boolean field alone means is_true, and operators that take no value
+// (is_false, never) are written without one. An empty expression matches
+// everything.
+func ParseWhere(expr string) (Group, error) {
+    toks, err := tokenize(expr)
+    if err != nil {
+        return Group{}, err
+    }
+    p := &exprParser{toks: toks}
+    if len(toks) == 0 {
+        return Group{}, nil
+    }
+    g, err := p.or()
+    if err != nil {
+        return Group{}, err
+    }
+    if p.i < len(p.toks) {
+        return Group{}, fmt.Errorf("unexpected %q", p.toks[p.i].text)
+    }
+    if err := g.validate(); err != nil {
+        return Group{}, err
+    }
+    return g, nil
+}
+
+// ParseSort reads comma separated sort fields; a leading "-" sorts descending
+func ParseSort(s string) ([]SortKey, error) {
+    var keys []SortKey
+    for _, f := range strings.Split(s, ",") {
+        f = strings.TrimSpace(f)
+        if f == "" {
+            continue
+        }
+        k := SortKey{Field: strings.TrimPrefix(f, "-"), Desc: strings.HasPrefix(f, "-")}
+        if _, ok := fields[k.Field]; !ok && k.Field != "random" {
+            return nil, fmt.Errorf("unknown sort field %q", k.Field)
+        }
+        keys = append(keys, k)
+    }
+    return keys, nil
+}
+
+type token struct {
+    text   string
+    quoted bool
+}
+
+// symbols are the operator spellings, longest first
+var symbols = []string{"==", "!=", "<=", ">=", "!~", "^=", "$=", "=", "<", ">", "~"}
+
+func tokenize(s string) ([]token, error) {
+    var toks []token
+    for i := 0; i < len(s); {
+        c := s[i]
+        switch {
+        case c == ' ' || c == '\t' || c == '\n':
+            i++
+        case c == '(' || c == ')':
+            toks = append(toks, token{text: string(c)})
+            i++
+        case c == '"' || c == '\'':
+            var b strings.Builder
+            j := i + 1
+            for ; j < len(s) && s[j] != c; j++ {
+                if s[j] == '\\' && j+1 < len(s) {
+                    j++
+                }
+                b.WriteByte(s[j])
+            }
+            if j >= len(s) {
+                return nil, fmt.Errorf("unterminated quote")
+            }
+            toks = append(toks, token{text: b.String(), quoted: true})
+            i = j + 1
+        default:
+            if sym := symbolAt(s[i:]); sym != "" {
+                toks = append(toks, token{text: sym})
+                i += len(sym)
+                continue
+            }
+            j := i
+            for j < len(s) && !strings.ContainsRune(" \t\n()\"'", rune(s[j])) && symbolAt(s[j:]) == "" {
+                j++
+            }
+            toks = append(toks, token{text: s[i:j]})
+            i = j
+        }
+    }
+    return toks, nil
+}
+
+func symbolAt(s string) string {
+    for _, sym := range symbols {
+        if strings.HasPrefix(s, sym) {
+            return sym
+        }
+    }
+    return ""
+}
+
+type exprParser struct {
+    toks []token
+    i    int
+}
+
+func (p *exprParser) peek() (token, bool) {
+    if p.i >= len(p.toks) {
+        return token{}, false
+    }
+    return p.toks[p.i], true
+}
+
+func (p *exprParser) keyword(word string) bool {
+    t, ok := p.peek()
+    if ok && !t.quoted && strings.EqualFold(t.text, word) {
+        p.i++
+        return true
+    }
+    return false
+}
+
+func (p *exprParser) or() (Group, error) {
+    first, err := p.and()
+    if err != nil {
+        return Group{}, err
+    }
+    alts := []Group{first}
+    for p.keyword("or") {
+        g, err := p.and()
+        if err != nil {
+            return Group{}, err
+        }
+        alts = append(alts, g)
+    }
+    if len(alts) == 1 {
+        return first, nil
+    }
+    return Group{Match: "or", Groups: alts}, nil
+}
+
+func (p *exprParser) and() (Group, error) {
+    g := Group{Match: "and"}
+    for {
+        t, ok := p.peek()
+        if !ok || (!t.quoted && (t.text == ")" || strings.EqualFold(t.text, "or"))) {
+            break
+        }
+        if p.keyword("and") {
+            continue
+        }
+        if p.keyword("(") {
+            sub, err := p.or()
+            if err != nil {
+                return Group{}, err
+            }
+            if !p.keyword(")") {
+                return Group{}, fmt.Errorf("missing )")
+            }
+            if sub.Match == "or" {
+                g.Groups = append(g.Groups, sub)
+            } else {
+                g.Rules = append(g.Rules, sub.Rules...)
+                g.Groups = append(g.Groups, sub.Groups...)
+            }
+            continue
+        }
+        r, err := p.rule()
+        if err != nil {
+            return Group{}, err
+        }
+        g.Rules = append(g.Rules, r)
+    }
+    if len(g.Rules) == 0 && len(g.Groups) == 0 {
+        return Group{}, fmt.Errorf("expected a term")
+    }
+    return g, nil
+}
+
+// rule reads "field op value", or a bare boolean field
+func (p *exprParser) rule() (Rule, error) {
+    t, _ := p.peek()
+    p.i++
+    name := strings.ToLower(t.text)
+    f, ok := fields[name]
+    if t.quoted || !ok {
+        return Rule{}, fmt.Errorf("unknown field %q", t.text)
+    }
+    r := Rule{Field: name}
+    opTok, ok := p.peek()
+    if f.kind == boolField && (!ok || opTok.quoted || !isOperator(f.kind, opTok.text)) {
+        r.Operator = "is_true"
+        return r, nil
+    }
+    if !ok || opTok.quoted {
+        return Rule{}, fmt.Errorf("expected an operator after %s", name)
+    }
+    p.i++
+    op, err := operatorFor(f.kind, opTok.text)
+    if err != nil {
+        return Rule{}, fmt.Errorf("%s: %w", name, err)
+    }
+    r.Operator = op
+    // only the symbols of boolean fields take a value
+    if op == "never" || f.kind == boolField && symbolAt(opTok.text) == "" {
+        return r, nil
+    }
+    v, ok := p.peek()
+    if !ok || (!v.quoted && (v.text == "(" || v.text == ")")) {
+        return Rule{}, fmt.Errorf("expected a value after %s %s", name, opTok.text)
+    }
+    p.i++
+    r.Value = v.text
+    if f.kind == numberField || op == "in_last" || op == "not_in_last" {
+        n, err := strconv.ParseFloat(v.text, 64)
+        if err != nil {
+            return Rule{}, fmt.Errorf("%s: expected a number, got %q", name, v.text)
+        }
+        r.Value = n
+    }
+    if f.kind == boolField {
+        // "loved = false" reads as is_false
+        switch strings.ToLower(v.text) {
+        case "true", "yes", "1":
+        case "false", "no", "0":
+            r.Operator = map[string]string{"is_true": "is_false", "is_false": "is_true"}[r.Operator]
+        default:
+            return Rule{}, fmt.Errorf("%s: expected true or false, got %q", name, v.text)
+        }
+        r.Value = nil
+    }
+    return r, nil
+}
+
+func isOperator(kind fieldKind, s string) bool {
+    _, err := operatorFor(kind, s)
+    return err == nil
+}
+
+// operatorFor maps a symbol or operator name to the operator of kind
+func operatorFor(kind fieldKind, s string) (string, error) {
+    s = strings.ToLower(s)
+    for _, op := range operators[kind] {
+        if op == s {
+            return op, nil
+        }
+    }
+    aliases := map[fieldKind]map[string]string{
+        stringField: {"=": "is", "==": "is", "!=": "is_not", "~": "contains", "!~": "not_contains", "^=": "starts_with", "$=": "ends_with"},
+        numberField: {"==": "="},
+        dateField:   {"<": "before", ">": "after"},
+        // a value follows these, saying which way round
+        boolField: {"=": "is_true", "==": "is_true", "!=": "is_false"},
+    }
+    if op, ok := aliases[kind][s]; ok {
+        return op, nil
+    }
+    return "", fmt.Errorf("operator %q not valid here", s)
+}
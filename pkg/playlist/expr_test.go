@@ -0,0 +1,47 @@
+package playlist
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseWhere(t *testing.T) {
+    cases := map[string]string{
+        ``:                                            "1,2,3,4,5",
+        `artist = "miles davis" year>=1980`:           "4",
+        `artist ~ miles and rating <= 2 or year=1970`: "3,5",
+        `(genre = metal or loved) title !~ paranoid`:  "1",
+        `loved = false and genre ^= ja`:               "2,3,4",
+        `not_a_field = 1`:                             "",
+        `title ~ "Five" and (rating > 3`:              "",
+        `year ~ 19`:                                   "",
+        `title = 'unterminated`:                       "",
+        `last_played never`:                           "1,2,3,4,5",
+    }
+    for expr, want := range cases {
+        g, err := ParseWhere(expr)
+        if want == "" {
+            if err == nil {
+                t.Fatalf("%q: expected an error", expr)
+            }
+            continue
+        }
+        if err != nil {
+            t.Fatalf("%q: %v", expr, err)
+        }
+        got := strings.Join(trackIDs(Evaluate(Query{Where: g}, library(), nil, 0)), ",")
+        if got != want {
+            t.Fatalf("%q: expected %s, got %s (%+v)", expr, want, got, g)
+        }
+    }
+}
+
+func TestParseSort(t *testing.T) {
+    keys, err := ParseSort("year, -rating")
+    if err != nil || len(keys) != 2 || keys[0] != (SortKey{Field: "year"}) || keys[1] != (SortKey{Field: "rating", Desc: true}) {
+        t.Fatalf("unexpected sort keys %+v (%v)", keys, err)
+    }
+    if _, err := ParseSort("mood"); err == nil {
+        t.Fatalf("expected an unknown field error")
+    }
+}
@@ -0,0 +1,341 @@
+package playlist
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	cfg "penguin-tunes/pkg/config"
+)
+
+// Format identifies a playlist file format
+type Format string
+
+const (
+    M3U  Format = "m3u"
+    M3U8 Format = "m3u8"
+    PLS  Format = "pls"
+    XSPF Format = "xspf"
+)
+
+// FileEntry is one item of a playlist file; Duration is in seconds, -1 when unknown
+type FileEntry struct {
+    Location string `json:"location"`
+    Title    string `json:"title"`
+    Duration int    `json:"duration"`
+}
+
+// FormatFromPath derives the playlist format from a file extension
+func FormatFromPath(path string) (Format, bool) {
+    switch strings.ToLower(filepath.Ext(path)) {
+    case ".m3u":
+        return M3U, true
+    case ".m3u8":
+        return M3U8, true
+    case ".pls":
+        return PLS, true
+    case ".xspf":
+        return XSPF, true
+    }
+    return "", false
+}
+
+// IsPlaylistFile reports whether path has a supported playlist extension
+func IsPlaylistFile(path string) bool {
+    _, ok := FormatFromPath(path)
+    return ok
+}
+
+// ReadFile parses a playlist file and returns its title (if it declares one) and entries
+func ReadFile(path string) (string, []FileEntry, error) {
+    format, ok := FormatFromPath(path)
+    if !ok {
+        return "", nil, fmt.Errorf("unsupported playlist format: %s", path)
+    }
+    b, err := os.ReadFile(path)
+    if err != nil {
+        return "", nil, fmt.Errorf("read playlist: %w", err)
+    }
+    b = bytes.TrimPrefix(b, []byte("\xef\xbb\xbf"))
+    switch format {
+    case M3U, M3U8:
+        // plain .m3u files written by older players are usually Latin-1
+        if !utf8.Valid(b) {
+            b = latin1ToUTF8(b)
+        }
+        title, entries := parseM3U(bytes.NewReader(b))
+        return title, entries, nil
+    case PLS:
+        return "", parsePLS(bytes.NewReader(b)), nil
+    default:
+        return parseXSPF(bytes.NewReader(b))
+    }
+}
+
+// ReadPaths returns the local files a playlist file lists, as absolute
+// paths in playlist order; remote entries are left out
+func ReadPaths(path string) ([]string, error) {
+    _, entries, err := ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    var out []string
+    for _, e := range entries {
+        if p, ok := locationToPath(e.Location, filepath.Dir(path)); ok {
+            out = append(out, p)
+        }
+    }
+    return out, nil
+}
+
+// WriteFile writes entries to path in the given format through a temp file
+func WriteFile(path string, format Format, title string, entries []FileEntry) error {
+    var buf bytes.Buffer
+    switch format {
+    case M3U, M3U8:
+        writeM3U(&buf, title, entries)
+    case PLS:
+        writePLS(&buf, entries)
+    case XSPF:
+        if err := writeXSPF(&buf, title, entries); err != nil {
+            return err
+        }
+    default:
+        return fmt.Errorf("unsupported playlist format %q", format)
+    }
+    if err := cfg.WriteFileAtomic(path, buf.Bytes()); err != nil {
+        return fmt.Errorf("write playlist: %w", err)
+    }
+    return nil
+}
+
+var extinfRe = regexp.MustCompile(`^#EXTINF:\s*(-?\d+)[^,]*,(.*)$`)
+
+func parseM3U(r io.Reader) (string, []FileEntry) {
+    var (
+        title   string
+        entries []FileEntry
+        pending = FileEntry{Duration: -1}
+    )
+    sc := bufio.NewScanner(r)
+    sc.Buffer(make([]byte, 64*1024), 1024*1024)
+    for sc.Scan() {
+        line := strings.TrimSpace(sc.Text())
+        switch {
+        case line == "":
+        case strings.HasPrefix(line, "#EXTINF:"):
+            if m := extinfRe.FindStringSubmatch(line); m != nil {
+                pending.Duration, _ = strconv.Atoi(m[1])
+                pending.Title = strings.TrimSpace(m[2])
+            }
+        case strings.HasPrefix(line, "#PLAYLIST:"):
+            title = strings.TrimSpace(strings.TrimPrefix(line, "#PLAYLIST:"))
+        case strings.HasPrefix(line, "#"):
+        default:
+            pending.Location = line
+            entries = append(entries, pending)
+            pending = FileEntry{Duration: -1}
+        }
+    }
+    return title, entries
+}
+
+func writeM3U(w io.Writer, title string, entries []FileEntry) {
+    fmt.Fprintln(w, "#EXTM3U")
+    if title != "" {
+        fmt.Fprintf(w, "#PLAYLIST:%s\n", title)
+    }
+    for _, e := range entries {
+        if e.Title != "" || e.Duration >= 0 {
+            fmt.Fprintf(w, "#EXTINF:%d,%s\n", e.Duration, e.Title)
+        }
+        fmt.Fprintln(w, e.Location)
+    }
+}
+
+func parsePLS(r io.Reader) []FileEntry {
+    byNum := make(map[int]*FileEntry)
+    sc := bufio.NewScanner(r)
+    for sc.Scan() {
+        line := strings.TrimSpace(sc.Text())
+        k, v, ok := strings.Cut(line, "=")
+        if !ok {
+            continue
+        }
+        k = strings.ToLower(strings.TrimSpace(k))
+        var field string
+        for _, f := range []string{"file", "title", "length"} {
+            if strings.HasPrefix(k, f) {
+                field = f
+                break
+            }
+        }
+        if field == "" {
+            continue
+        }
+        n, err := strconv.Atoi(k[len(field):])
+        if err != nil {
+            continue
+        }
+        e, ok := byNum[n]
+        if !ok {
+            e = &FileEntry{Duration: -1}
+            byNum[n] = e
+        }
+        switch field {
+        case "file":
+            e.Location = v
+        case "title":
+            e.Title = v
+        case "length":
+            e.Duration, _ = strconv.Atoi(strings.TrimSpace(v))
+        }
+    }
+    nums := make([]int, 0, len(byNum))
+    for n := range byNum {
+        nums = append(nums, n)
+    }
+    sort.Ints(nums)
+    entries := make([]FileEntry, 0, len(nums))
+    for _, n := range nums {
+        if byNum[n].Location != "" {
+            entries = append(entries, *byNum[n])
+        }
+    }
+    return entries
+}
+
+func writePLS(w io.Writer, entries []FileEntry) {
+    fmt.Fprintln(w, "[playlist]")
+    for i, e := range entries {
+        fmt.Fprintf(w, "File%d=%s\n", i+1, e.Location)
+        if e.Title != "" {
+            fmt.Fprintf(w, "Title%d=%s\n", i+1, e.Title)
+        }
+        fmt.Fprintf(w, "Length%d=%d\n", i+1, e.Duration)
+    }
+    fmt.Fprintf(w, "NumberOfEntries=%d\n", len(entries))
+    fmt.Fprintln(w, "Version=2")
+}
+
+type xspfDoc struct {
+    XMLName xml.Name    `xml:"http://xspf.org/ns/0/ playlist"`
+    Version string      `xml:"version,attr"`
+    Title   string      `xml:"title,omitempty"`
+    Tracks  []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+    Location string `xml:"location"`
+    Title    string `xml:"title,omitempty"`
+    Duration int    `xml:"duration,omitempty"`
+}
+
+func parseXSPF(r io.Reader) (string, []FileEntry, error) {
+    var doc xspfDoc
+    if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+        return "", nil, fmt.Errorf("parse xspf: %w", err)
+    }
+    entries := make([]FileEntry, 0, len(doc.Tracks))
+    for _, t := range doc.Tracks {
+        e := FileEntry{Location: strings.TrimSpace(t.Location), Title: t.Title, Duration: -1}
+        // relative locations are URI references with percent escapes
+        if !strings.Contains(e.Location, "://") {
+            if p, err := url.PathUnescape(e.Location); err == nil {
+                e.Location = p
+            }
+        }
+        if t.Duration > 0 {
+            // XSPF durations are milliseconds
+            e.Duration = t.Duration / 1000
+        }
+        entries = append(entries, e)
+    }
+    return doc.Title, entries, nil
+}
+
+func writeXSPF(w io.Writer, title string, entries []FileEntry) error {
+    doc := xspfDoc{Version: "1", Title: title}
+    for _, e := range entries {
+        t := xspfTrack{Location: e.Location, Title: e.Title}
+        if e.Duration > 0 {
+            t.Duration = e.Duration * 1000
+        }
+        doc.Tracks = append(doc.Tracks, t)
+    }
+    io.WriteString(w, xml.Header)
+    enc := xml.NewEncoder(w)
+    enc.Indent("", "  ")
+    if err := enc.Encode(doc); err != nil {
+        return fmt.Errorf("encode xspf: %w", err)
+    }
+    _, err := io.WriteString(w, "\n")
+    return err
+}
+
+func latin1ToUTF8(b []byte) []byte {
+    rs := make([]rune, len(b))
+    for i, c := range b {
+        rs[i] = rune(c)
+    }
+    return []byte(string(rs))
+}
+
+// locationToPath turns a playlist location into an absolute local path relative to baseDir.
+// Remote URLs are reported with ok=false.
+func locationToPath(loc, baseDir string) (string, bool) {
+    if strings.Contains(loc, "://") {
+        u, err := url.Parse(loc)
+        if err != nil || u.Scheme != "file" {
+            return "", false
+        }
+        loc = u.Path
+        if u.Host != "" && u.Host != "localhost" {
+            loc = "//" + u.Host + loc
+        }
+    }
+    // playlists written on Windows use backslashes and drive letters
+    if filepath.Separator == '/' {
+        loc = strings.ReplaceAll(loc, "\\", "/")
+    }
+    if !filepath.IsAbs(loc) && !isWindowsAbs(loc) {
+        loc = filepath.Join(baseDir, loc)
+    }
+    return filepath.Clean(loc), true
+}
+
+func isWindowsAbs(p string) bool {
+    return len(p) >= 3 && p[1] == ':' && (p[2] == '/' || p[2] == '\\')
+}
+
+// pathToLocation formats an absolute track path for a playlist written to dir
+func pathToLocation(path, dir string, format Format, relative bool) string {
+    loc := path
+    if relative {
+        if rel, err := filepath.Rel(dir, path); err == nil {
+            loc = rel
+        }
+    }
+    if format != XSPF {
+        return loc
+    }
+    if filepath.IsAbs(loc) {
+        u := url.URL{Scheme: "file", Path: filepath.ToSlash(loc)}
+        return u.String()
+    }
+    parts := strings.Split(filepath.ToSlash(loc), "/")
+    for i, p := range parts {
+        parts[i] = url.PathEscape(p)
+    }
+    return strings.Join(parts, "/")
+}
@@ -0,0 +1,162 @@
+package playlist
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"penguin-tunes/pkg/indexer"
+)
+
+func writeFile(t *testing.T, path, content string) {
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+        t.Fatalf("mkdir: %v", err)
+    }
+    if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+}
+
+func TestReadFormats(t *testing.T) {
+    dir := t.TempDir()
+    m3u := filepath.Join(dir, "list.m3u8")
+    writeFile(t, m3u, "\xef\xbb\xbf#EXTM3U\n#PLAYLIST:Road Trip\n#EXTINF:215,Band - One\nArtist/One.mp3\n\n# comment\n/abs/two.flac\n")
+    title, entries, err := ReadFile(m3u)
+    if err != nil {
+        t.Fatalf("ReadFile m3u8: %v", err)
+    }
+    if title != "Road Trip" || len(entries) != 2 {
+        t.Fatalf("unexpected m3u8 parse: %q %+v", title, entries)
+    }
+    if entries[0].Duration != 215 || entries[0].Title != "Band - One" || entries[1].Duration != -1 {
+        t.Fatalf("unexpected EXTINF parse: %+v", entries)
+    }
+
+    latin := filepath.Join(dir, "old.m3u")
+    writeFile(t, latin, "Caf\xe9/Song.mp3\n")
+    _, entries, _ = ReadFile(latin)
+    if entries[0].Location != "Café/Song.mp3" {
+        t.Fatalf("expected latin-1 fallback, got %q", entries[0].Location)
+    }
+
+    pls := filepath.Join(dir, "list.pls")
+    writeFile(t, pls, "[playlist]\nFile2=b.mp3\nTitle2=B\nFile1=a.mp3\nLength1=12\nNumberOfEntries=2\nVersion=2\n")
+    _, entries, _ = ReadFile(pls)
+    if len(entries) != 2 || entries[0].Location != "a.mp3" || entries[0].Duration != 12 || entries[1].Title != "B" {
+        t.Fatalf("unexpected pls parse: %+v", entries)
+    }
+
+    xspf := filepath.Join(dir, "list.xspf")
+    writeFile(t, xspf, `<?xml version="1.0" encoding="UTF-8"?>
+<playlist version="1" xmlns="http://xspf.org/ns/0/">
+  <title>Mix</title>
+  <trackList>
+    <track><location>file:///music/a%20b.flac</location><title>AB</title><duration>61000</duration></track>
+    <track><location>sub/c%23d.mp3</location></track>
+  </trackList>
+</playlist>`)
+    title, entries, err = ReadFile(xspf)
+    if err != nil {
+        t.Fatalf("ReadFile xspf: %v", err)
+    }
+    if title != "Mix" || len(entries) != 2 || entries[0].Duration != 61 || entries[1].Location != "sub/c#d.mp3" {
+        t.Fatalf("unexpected xspf parse: %q %+v", title, entries)
+    }
+    if p, ok := locationToPath(entries[0].Location, dir); !ok || p != "/music/a b.flac" {
+        t.Fatalf("unexpected file URL resolution: %q", p)
+    }
+    if _, ok := locationToPath("http://radio.example/stream", dir); ok {
+        t.Fatalf("remote locations must not resolve to paths")
+    }
+}
+
+func TestImportResolvesAgainstIndex(t *testing.T) {
+    base := t.TempDir()
+    music := filepath.Join(base, "music")
+    idx := indexer.NewIndexAtBase(base)
+    one := &indexer.Track{ID: "1", Path: filepath.Join(music, "Artist", "Album", "01 One.mp3"), Title: "One", Artist: "Artist"}
+    two := &indexer.Track{ID: "2", Path: filepath.Join(music, "Artist", "Album", "02 Two.mp3"), Title: "Two", Artist: "Artist"}
+    idx.AddOrUpdateTrack(one)
+    idx.AddOrUpdateTrack(two)
+
+    pl := filepath.Join(music, "lists", "fav.m3u")
+    writeFile(t, pl, strings.Join([]string{
+        "#EXTM3U",
+        "../Artist/Album/01 One.mp3",
+        `D:\Old Library\Artist\Album\02 Two.mp3`,
+        "../Artist/Album/03 Gone.mp3",
+        "http://radio.example/stream",
+    }, "\n"))
+
+    s := NewStoreAtBase(base)
+    res, err := s.Import(pl, idx)
+    if err != nil {
+        t.Fatalf("Import: %v", err)
+    }
+    if res.Resolved != 2 || len(res.Unresolved) != 2 {
+        t.Fatalf("expected 2 resolved / 2 unresolved, got %d / %+v", res.Resolved, res.Unresolved)
+    }
+    p := res.Playlist
+    if p.Name != "fav" || len(p.Entries) != 4 || p.Entries[1].TrackID != "2" || !p.Entries[2].Missing {
+        t.Fatalf("unexpected imported playlist %+v", p)
+    }
+
+    // unchanged file is not imported twice
+    if _, wrote, err := s.ImportOrUpdate(pl, idx); err != nil || wrote {
+        t.Fatalf("expected up-to-date playlist to be skipped: wrote=%v err=%v", wrote, err)
+    }
+    if len(s.List()) != 1 {
+        t.Fatalf("expected a single playlist")
+    }
+
+    for _, name := range []string{"out.m3u8", "out.pls", "out.xspf"} {
+        out := filepath.Join(music, "export", name)
+        if err := os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
+            t.Fatalf("mkdir: %v", err)
+        }
+        if err := s.Export(p.ID, out, true, idx); err != nil {
+            t.Fatalf("Export %s: %v", name, err)
+        }
+        _, entries, err := ReadFile(out)
+        if err != nil {
+            t.Fatalf("ReadFile %s: %v", name, err)
+        }
+        if len(entries) != 4 {
+            t.Fatalf("%s: expected 4 entries, got %d", name, len(entries))
+        }
+        if filepath.IsAbs(entries[0].Location) || strings.Contains(entries[0].Location, "://") {
+            t.Fatalf("%s: expected relative location, got %q", name, entries[0].Location)
+        }
+        reimported, err := NewStoreAtBase(t.TempDir()).Import(out, idx)
+        if err != nil {
+            t.Fatalf("reimport %s: %v", name, err)
+        }
+        if reimported.Resolved != 2 {
+            t.Fatalf("%s: expected round trip to resolve 2 tracks, got %d", name, reimported.Resolved)
+        }
+    }
+
+    abs := filepath.Join(music, "abs.m3u")
+    if err := s.Export(p.ID, abs, false, idx); err != nil {
+        t.Fatalf("Export abs: %v", err)
+    }
+    _, entries, _ := ReadFile(abs)
+    if entries[0].Location != one.Path || entries[0].Title != "Artist - One" {
+        t.Fatalf("expected absolute path, got %+v", entries[0])
+    }
+}
+
+func TestReadPaths(t *testing.T) {
+    dir := t.TempDir()
+    p := filepath.Join(dir, "mix.m3u8")
+    writeFile(t, p, "#EXTM3U\n#EXTINF:10,A\nsub/a.mp3\nhttp://radio.example/stream\n/abs/b.flac\nfile:///abs/c%20d.ogg\n")
+    paths, err := ReadPaths(p)
+    if err != nil {
+        t.Fatalf("ReadPaths: %v", err)
+    }
+    want := []string{filepath.Join(dir, "sub", "a.mp3"), "/abs/b.flac", "/abs/c d.ogg"}
+    if strings.Join(paths, "|") != strings.Join(want, "|") {
+        t.Fatalf("expected %v, got %v", want, paths)
+    }
+}
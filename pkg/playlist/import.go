@@ -0,0 +1,203 @@
+package playlist
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"penguin-tunes/pkg/indexer"
+)
+
+// ImportResult describes the outcome of importing a playlist file
+type ImportResult struct {
+    Playlist   Playlist    `json:"playlist"`
+    Resolved   int         `json:"resolved"`
+    Unresolved []FileEntry `json:"unresolved"`
+}
+
+// resolver matches playlist locations against indexed track paths. Besides exact
+// matches it tries case-insensitive paths and, for playlists written on other
+// machines, unique matches on the trailing artist/album/file components.
+type resolver struct {
+    exact  map[string]*indexer.Track
+    folded map[string]*indexer.Track
+    tails  [2]map[string][]*indexer.Track
+}
+
+func newResolver(idx *indexer.Index) *resolver {
+    r := &resolver{
+        exact:  make(map[string]*indexer.Track),
+        folded: make(map[string]*indexer.Track),
+        tails:  [2]map[string][]*indexer.Track{make(map[string][]*indexer.Track), make(map[string][]*indexer.Track)},
+    }
+    for _, t := range idx.GetAll() {
+        p := filepath.Clean(t.Path)
+        r.exact[p] = t
+        r.folded[strings.ToLower(p)] = t
+        for i, n := range []int{3, 2} {
+            if k := tailKey(p, n); k != "" {
+                r.tails[i][k] = append(r.tails[i][k], t)
+            }
+        }
+    }
+    return r
+}
+
+func (r *resolver) resolve(path string) *indexer.Track {
+    if t, ok := r.exact[path]; ok {
+        return t
+    }
+    if t, ok := r.folded[strings.ToLower(path)]; ok {
+        return t
+    }
+    for i, n := range []int{3, 2} {
+        if ts := r.tails[i][tailKey(path, n)]; len(ts) == 1 {
+            return ts[0]
+        }
+    }
+    return nil
+}
+
+// tailKey joins the last n path components in lower case, or returns "" if there are fewer
+func tailKey(path string, n int) string {
+    parts := strings.FieldsFunc(filepath.ToSlash(path), func(r rune) bool { return r == '/' })
+    if len(parts) < n {
+        return ""
+    }
+    return strings.ToLower(strings.Join(parts[len(parts)-n:], "/"))
+}
+
+// readEntries parses a playlist file and resolves its locations against idx
+func readEntries(path string, idx *indexer.Index) (string, []Entry, ImportResult, error) {
+    title, files, err := ReadFile(path)
+    if err != nil {
+        return "", nil, ImportResult{}, err
+    }
+    if title == "" {
+        title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+    }
+    r := newResolver(idx)
+    res := ImportResult{Unresolved: []FileEntry{}}
+    entries := make([]Entry, 0, len(files))
+    for _, f := range files {
+        p, ok := locationToPath(f.Location, filepath.Dir(path))
+        var t *indexer.Track
+        if ok {
+            t = r.resolve(p)
+        } else {
+            p = f.Location
+        }
+        if t == nil {
+            // unresolved entries are kept as missing so they relink once the file is indexed
+            entries = append(entries, Entry{Path: p, Title: f.Title, Missing: true})
+            res.Unresolved = append(res.Unresolved, f)
+            continue
+        }
+        entries = append(entries, entryFor(t))
+        res.Resolved++
+    }
+    return title, entries, res, nil
+}
+
+// Import reads a playlist file and stores it as a new playlist
+func (s *Store) Import(path string, idx *indexer.Index) (ImportResult, error) {
+    title, entries, res, err := readEntries(path, idx)
+    if err != nil {
+        return ImportResult{}, err
+    }
+    fi, err := os.Stat(path)
+    if err != nil {
+        return ImportResult{}, err
+    }
+    s.mtx.Lock()
+    defer s.mtx.Unlock()
+    now := time.Now()
+    p := &Playlist{
+        ID: newID(), Name: title, Entries: entries, Created: now, Updated: now,
+        Source: path, SourceModTime: fi.ModTime(),
+    }
+    s.playlists[p.ID] = p
+    if err := s.saveLocked(); err != nil {
+        delete(s.playlists, p.ID)
+        return ImportResult{}, err
+    }
+    res.Playlist = p.clone()
+    return res, nil
+}
+
+// ImportOrUpdate imports path unless a playlist imported from the same file is up to
+// date; a changed file replaces the entries of its existing playlist. The bool result
+// reports whether anything was written.
+func (s *Store) ImportOrUpdate(path string, idx *indexer.Index) (ImportResult, bool, error) {
+    fi, err := os.Stat(path)
+    if err != nil {
+        return ImportResult{}, false, err
+    }
+    var existing string
+    s.mtx.RLock()
+    for id, p := range s.playlists {
+        if p.Source == path {
+            existing = id
+            if p.SourceModTime.Equal(fi.ModTime()) {
+                s.mtx.RUnlock()
+                return ImportResult{Playlist: p.clone()}, false, nil
+            }
+        }
+    }
+    s.mtx.RUnlock()
+    if existing == "" {
+        res, err := s.Import(path, idx)
+        return res, err == nil, err
+    }
+    _, entries, res, err := readEntries(path, idx)
+    if err != nil {
+        return ImportResult{}, false, err
+    }
+    err = s.update(existing, func(p *Playlist) error {
+        p.Entries = entries
+        p.SourceModTime = fi.ModTime()
+        return nil
+    })
+    if err != nil {
+        return ImportResult{}, false, err
+    }
+    res.Playlist, _ = s.Get(existing)
+    return res, true, nil
+}
+
+// Export writes playlist id to path in the format given by its extension, with
+// track locations relative to the playlist's folder or absolute
+func (s *Store) Export(id, path string, relative bool, idx *indexer.Index) error {
+    format, ok := FormatFromPath(path)
+    if !ok {
+        return fmt.Errorf("unsupported playlist format: %s", path)
+    }
+    p, err := s.Get(id)
+    if err != nil {
+        return err
+    }
+    dir := filepath.Dir(path)
+    if abs, err := filepath.Abs(dir); err == nil {
+        dir = abs
+    }
+    files := make([]FileEntry, 0, len(p.Entries))
+    for _, e := range p.Entries {
+        f := FileEntry{Duration: -1, Title: e.Title}
+        loc := e.Path
+        if e.Artist != "" {
+            f.Title = e.Artist + " - " + e.Title
+        }
+        if t := idx.GetByID(e.TrackID); t != nil && !e.Missing {
+            loc = t.Path
+        }
+        if strings.Contains(loc, "://") {
+            f.Location = loc
+        } else {
+            f.Location = pathToLocation(loc, dir, format, relative)
+        }
+        files = append(files, f)
+    }
+    return WriteFile(path, format, p.Name, files)
+}
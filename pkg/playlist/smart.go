@@ -0,0 +1,353 @@
+package playlist
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"penguin-tunes/pkg/indexer"
+)
+
+// Rule compares one track field with a value
+type Rule struct {
+    Field    string `json:"field"`
+    Operator string `json:"op"`
+    Value    any    `json:"value"`
+}
+
+// Group combines rules and nested groups; Match is "and" (default) or "or"
+type Group struct {
+    Match  string  `json:"match"`
+    Rules  []Rule  `json:"rules"`
+    Groups []Group `json:"groups"`
+}
+
+// SortKey orders results by a field; the pseudo field "random" shuffles
+type SortKey struct {
+    Field string `json:"field"`
+    Desc  bool   `json:"desc"`
+}
+
+// Query selects, orders and limits tracks; Limit 0 means unlimited
+type Query struct {
+    Where Group     `json:"where"`
+    Sort  []SortKey `json:"sort"`
+    Limit int       `json:"limit"`
+}
+
+// SmartPlaylist is a named query evaluated against the index
+type SmartPlaylist struct {
+    ID      string    `json:"id"`
+    Name    string    `json:"name"`
+    Query   Query     `json:"query"`
+    Created time.Time `json:"created"`
+    Updated time.Time `json:"updated"`
+}
+
+// Usage holds per-track listening data that rules can refer to
+type Usage struct {
+    PlayCount   int       `json:"playCount"`
+    SkipCount   int       `json:"skipCount"`
+    FirstPlayed time.Time `json:"firstPlayed"`
+    LastPlayed  time.Time `json:"lastPlayed"`
+}
+
+// UsageSource looks up usage data by track ID
+type UsageSource interface {
+    Usage(trackID string) Usage
+}
+
+type fieldKind int
+
+const (
+    stringField fieldKind = iota
+    numberField
+    dateField
+    boolField
+)
+
+type field struct {
+    kind fieldKind
+    get  func(t *indexer.Track, u Usage) any
+}
+
+var fields = map[string]field{
+    "title":          {stringField, func(t *indexer.Track, u Usage) any { return t.Title }},
+    "album":          {stringField, func(t *indexer.Track, u Usage) any { return t.Album }},
+    "artist":         {stringField, func(t *indexer.Track, u Usage) any { return t.Artist }},
+    "album_artist":   {stringField, func(t *indexer.Track, u Usage) any { return t.AlbumArtist }},
+    "composer":       {stringField, func(t *indexer.Track, u Usage) any { return t.Composer }},
+    "genre":          {stringField, func(t *indexer.Track, u Usage) any { return t.Genre }},
+    "path":           {stringField, func(t *indexer.Track, u Usage) any { return t.Path }},
+    "year":           {numberField, func(t *indexer.Track, u Usage) any { return float64(t.Year) }},
+    "track_number":   {numberField, func(t *indexer.Track, u Usage) any { return float64(t.TrackNumber) }},
+    "disc_number":    {numberField, func(t *indexer.Track, u Usage) any { return float64(t.DiscNumber) }},
+    "play_count":     {numberField, func(t *indexer.Track, u Usage) any { return float64(u.PlayCount) }},
+    "skip_count":     {numberField, func(t *indexer.Track, u Usage) any { return float64(u.SkipCount) }},
+    "rating":         {numberField, func(t *indexer.Track, u Usage) any { return float64(t.Rating) }},
+    "loved":          {boolField, func(t *indexer.Track, u Usage) any { return t.Loved }},
+    "bpm":            {numberField, func(t *indexer.Track, u Usage) any { return t.BPM }},
+    "bpm_confidence": {numberField, func(t *indexer.Track, u Usage) any { return t.BPMConfidence }},
+    "key":            {stringField, func(t *indexer.Track, u Usage) any { return t.Key }},
+    "key_confidence": {numberField, func(t *indexer.Track, u Usage) any { return t.KeyConfidence }},
+    "has_lyrics":     {boolField, func(t *indexer.Track, u Usage) any { return t.HasLyrics }},
+    "first_played":   {dateField, func(t *indexer.Track, u Usage) any { return u.FirstPlayed }},
+    "last_played":    {dateField, func(t *indexer.Track, u Usage) any { return u.LastPlayed }},
+}
+
+var operators = map[fieldKind][]string{
+    stringField: {"is", "is_not", "contains", "not_contains", "starts_with", "ends_with"},
+    numberField: {"=", "!=", "<", "<=", ">", ">="},
+    dateField:   {"before", "after", "in_last", "not_in_last", "never"},
+    boolField:   {"is_true", "is_false"},
+}
+
+// Fields returns the names of fields usable in rules and sort keys
+func Fields() []string {
+    out := make([]string, 0, len(fields))
+    for k := range fields {
+        out = append(out, k)
+    }
+    sort.Strings(out)
+    return out
+}
+
+// Validate checks fields, operators and values of q
+func (q Query) Validate() error {
+    if q.Limit < 0 {
+        return fmt.Errorf("limit must not be negative")
+    }
+    for _, k := range q.Sort {
+        if _, ok := fields[k.Field]; !ok && k.Field != "random" {
+            return fmt.Errorf("unknown sort field %q", k.Field)
+        }
+    }
+    return q.Where.validate()
+}
+
+func (g Group) validate() error {
+    if g.Match != "" && g.Match != "and" && g.Match != "or" {
+        return fmt.Errorf("unknown match %q", g.Match)
+    }
+    for _, r := range g.Rules {
+        f, ok := fields[r.Field]
+        if !ok {
+            return fmt.Errorf("unknown field %q", r.Field)
+        }
+        known := false
+        for _, op := range operators[f.kind] {
+            known = known || op == r.Operator
+        }
+        if !known {
+            return fmt.Errorf("operator %q not valid for %s", r.Operator, r.Field)
+        }
+        if _, err := ruleOperand(f.kind, r); err != nil {
+            return fmt.Errorf("%s %s: %w", r.Field, r.Operator, err)
+        }
+    }
+    for _, sub := range g.Groups {
+        if err := sub.validate(); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// ruleOperand converts a rule's JSON value to the type its operator compares against
+func ruleOperand(kind fieldKind, r Rule) (any, error) {
+    switch {
+    case kind == stringField:
+        return strings.ToLower(fmt.Sprint(r.Value)), nil
+    case kind == numberField, r.Operator == "in_last", r.Operator == "not_in_last":
+        return toNumber(r.Value)
+    case kind == boolField, r.Operator == "never":
+        return nil, nil
+    }
+    s, ok := r.Value.(string)
+    if !ok {
+        return nil, fmt.Errorf("expected a date string")
+    }
+    for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+        if d, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+            return d, nil
+        }
+    }
+    return nil, fmt.Errorf("invalid date %q", s)
+}
+
+func toNumber(v any) (float64, error) {
+    switch n := v.(type) {
+    case float64:
+        return n, nil
+    case int:
+        return float64(n), nil
+    case string:
+        return strconv.ParseFloat(strings.TrimSpace(n), 64)
+    }
+    return 0, fmt.Errorf("expected a number, got %v", v)
+}
+
+func (g Group) match(t *indexer.Track, u Usage, now time.Time) bool {
+    or := g.Match == "or"
+    empty := true
+    for _, r := range g.Rules {
+        empty = false
+        if ok := r.match(t, u, now); ok == or {
+            return or
+        }
+    }
+    for _, sub := range g.Groups {
+        empty = false
+        if ok := sub.match(t, u, now); ok == or {
+            return or
+        }
+    }
+    // an empty group matches everything
+    return !or || empty
+}
+
+func (r Rule) match(t *indexer.Track, u Usage, now time.Time) bool {
+    f := fields[r.Field]
+    operand, err := ruleOperand(f.kind, r)
+    if err != nil {
+        return false
+    }
+    v := f.get(t, u)
+    switch f.kind {
+    case stringField:
+        s, want := strings.ToLower(v.(string)), operand.(string)
+        switch r.Operator {
+        case "is":
+            return s == want
+        case "is_not":
+            return s != want
+        case "contains":
+            return strings.Contains(s, want)
+        case "not_contains":
+            return !strings.Contains(s, want)
+        case "starts_with":
+            return strings.HasPrefix(s, want)
+        case "ends_with":
+            return strings.HasSuffix(s, want)
+        }
+    case numberField:
+        n, want := v.(float64), operand.(float64)
+        switch r.Operator {
+        case "=":
+            return n == want
+        case "!=":
+            return n != want
+        case "<":
+            return n < want
+        case "<=":
+            return n <= want
+        case ">":
+            return n > want
+        case ">=":
+            return n >= want
+        }
+    case dateField:
+        d := v.(time.Time)
+        switch r.Operator {
+        case "never":
+            return d.IsZero()
+        case "before":
+            return !d.IsZero() && d.Before(operand.(time.Time))
+        case "after":
+            return !d.IsZero() && d.After(operand.(time.Time))
+        case "in_last", "not_in_last":
+            days := operand.(float64)
+            in := !d.IsZero() && now.Sub(d) <= time.Duration(days*24*float64(time.Hour))
+            return in == (r.Operator == "in_last")
+        }
+    case boolField:
+        return v.(bool) == (r.Operator == "is_true")
+    }
+    return false
+}
+
+// Evaluate returns the tracks matching q in q's order. usage may be nil. seed fixes
+// the shuffle of random ordering so repeated evaluations stay stable.
+func Evaluate(q Query, tracks []*indexer.Track, usage UsageSource, seed int64) []*indexer.Track {
+    now := time.Now()
+    type row struct {
+        t *indexer.Track
+        u Usage
+    }
+    rows := make([]row, 0, len(tracks))
+    for _, t := range tracks {
+        var u Usage
+        if usage != nil {
+            u = usage.Usage(t.ID)
+        }
+        if q.Where.match(t, u, now) {
+            rows = append(rows, row{t, u})
+        }
+    }
+    // start from a deterministic order so sorts and shuffles are reproducible
+    sort.Slice(rows, func(i, j int) bool { return rows[i].t.Path < rows[j].t.Path })
+    for i := len(q.Sort) - 1; i >= 0; i-- {
+        k := q.Sort[i]
+        if k.Field == "random" {
+            rnd := rand.New(rand.NewSource(seed))
+            rnd.Shuffle(len(rows), func(a, b int) { rows[a], rows[b] = rows[b], rows[a] })
+            continue
+        }
+        f, ok := fields[k.Field]
+        if !ok {
+            continue
+        }
+        sort.SliceStable(rows, func(a, b int) bool {
+            c := compareValues(f.get(rows[a].t, rows[a].u), f.get(rows[b].t, rows[b].u))
+            if k.Desc {
+                return c > 0
+            }
+            return c < 0
+        })
+    }
+    if q.Limit > 0 && len(rows) > q.Limit {
+        rows = rows[:q.Limit]
+    }
+    out := make([]*indexer.Track, len(rows))
+    for i, r := range rows {
+        out[i] = r.t
+    }
+    return out
+}
+
+func compareValues(a, b any) int {
+    switch x := a.(type) {
+    case string:
+        return strings.Compare(strings.ToLower(x), strings.ToLower(b.(string)))
+    case float64:
+        y := b.(float64)
+        switch {
+        case x < y:
+            return -1
+        case x > y:
+            return 1
+        }
+    case time.Time:
+        return x.Compare(b.(time.Time))
+    case bool:
+        switch {
+        case !x && b.(bool):
+            return -1
+        case x && !b.(bool):
+            return 1
+        }
+    }
+    return 0
+}
+
+// Seed derives a stable shuffle seed from a smart playlist
+func (sp SmartPlaylist) Seed() int64 {
+    h := fnv.New64a()
+    h.Write([]byte(sp.ID))
+    h.Write([]byte(sp.Updated.String()))
+    return int64(h.Sum64())
+}
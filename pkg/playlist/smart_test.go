@@ -0,0 +1,165 @@
+package playlist
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"penguin-tunes/pkg/indexer"
+)
+
+type fakeUsage map[string]Usage
+
+func (f fakeUsage) Usage(id string) Usage { return f[id] }
+
+func library() []*indexer.Track {
+    return []*indexer.Track{
+        {ID: "1", Path: "/m/1", Title: "So What", Artist: "Miles Davis", Genre: "Jazz", Year: 1959, Rating: 5, Loved: true},
+        {ID: "2", Path: "/m/2", Title: "Take Five", Artist: "Dave Brubeck", Genre: "jazz", Year: 1959, Rating: 4},
+        {ID: "3", Path: "/m/3", Title: "Blue in Green", Artist: "Miles Davis", Genre: "Jazz", Year: 1959, Rating: 2},
+        {ID: "4", Path: "/m/4", Title: "Tutu", Artist: "Miles Davis", Genre: "Jazz", Year: 1986, Rating: 5},
+        {ID: "5", Path: "/m/5", Title: "Paranoid", Artist: "Black Sabbath", Genre: "Metal", Year: 1970},
+    }
+}
+
+func trackIDs(ts []*indexer.Track) []string {
+    out := make([]string, len(ts))
+    for i, t := range ts {
+        out[i] = t.ID
+    }
+    return out
+}
+
+func TestEvaluateRules(t *testing.T) {
+    now := time.Now()
+    usage := fakeUsage{
+        "1": {PlayCount: 10, LastPlayed: now.Add(-2 * 24 * time.Hour)},
+        "2": {PlayCount: 3, LastPlayed: now.Add(-40 * 24 * time.Hour)},
+        "4": {PlayCount: 1},
+    }
+    // genre is Jazz AND year < 1970 AND rating >= 4, order by random, limit 50
+    var q Query
+    err := json.Unmarshal([]byte(`{
+        "where": {"match": "and", "rules": [
+            {"field": "genre", "op": "is", "value": "Jazz"},
+            {"field": "year", "op": "<", "value": 1970},
+            {"field": "rating", "op": ">=", "value": 4}
+        ]},
+        "sort": [{"field": "random"}],
+        "limit": 50
+    }`), &q)
+    if err != nil {
+        t.Fatalf("unmarshal: %v", err)
+    }
+    if err := q.Validate(); err != nil {
+        t.Fatalf("Validate: %v", err)
+    }
+    got := Evaluate(q, library(), usage, 42)
+    if len(got) != 2 {
+        t.Fatalf("expected 2 matches, got %v", trackIDs(got))
+    }
+    again := Evaluate(q, library(), usage, 42)
+    if trackIDs(got)[0] != trackIDs(again)[0] {
+        t.Fatalf("same seed should give the same order")
+    }
+
+    // nested groups: artist is Miles Davis AND (year >= 1980 OR title contains "green")
+    q = Query{
+        Where: Group{Rules: []Rule{{Field: "artist", Operator: "is", Value: "miles davis"}}, Groups: []Group{
+            {Match: "or", Rules: []Rule{
+                {Field: "year", Operator: ">=", Value: 1980.0},
+                {Field: "title", Operator: "contains", Value: "GREEN"},
+            }},
+        }},
+        Sort: []SortKey{{Field: "year", Desc: true}},
+    }
+    if ids := trackIDs(Evaluate(q, library(), usage, 0)); len(ids) != 2 || ids[0] != "4" || ids[1] != "3" {
+        t.Fatalf("unexpected nested result %v", ids)
+    }
+
+    q = Query{Where: Group{Rules: []Rule{{Field: "last_played", Operator: "in_last", Value: 7.0}}}}
+    if ids := trackIDs(Evaluate(q, library(), usage, 0)); len(ids) != 1 || ids[0] != "1" {
+        t.Fatalf("unexpected in_last result %v", ids)
+    }
+    q = Query{Where: Group{Rules: []Rule{{Field: "last_played", Operator: "never"}}}, Sort: []SortKey{{Field: "title"}}, Limit: 2}
+    if ids := trackIDs(Evaluate(q, library(), usage, 0)); len(ids) != 2 || ids[0] != "3" || ids[1] != "5" {
+        t.Fatalf("unexpected never/sort/limit result %v", ids)
+    }
+    q = Query{Where: Group{Rules: []Rule{{Field: "last_played", Operator: "before", Value: now.Add(-30 * 24 * time.Hour).Format("2006-01-02")}}}}
+    if ids := trackIDs(Evaluate(q, library(), usage, 0)); len(ids) != 1 || ids[0] != "2" {
+        t.Fatalf("unexpected before result %v", ids)
+    }
+    q = Query{Where: Group{Rules: []Rule{{Field: "loved", Operator: "is_true"}}}}
+    if ids := trackIDs(Evaluate(q, library(), nil, 0)); len(ids) != 1 || ids[0] != "1" {
+        t.Fatalf("unexpected loved result %v", ids)
+    }
+    if n := len(Evaluate(Query{}, library(), nil, 0)); n != 5 {
+        t.Fatalf("empty query should match everything, got %d", n)
+    }
+
+    // tempo range in a key, fastest first
+    tracks := library()
+    tracks[0].BPM, tracks[0].Key = 126, "Am"
+    tracks[1].BPM, tracks[1].Key = 174, "Am"
+    tracks[2].BPM, tracks[2].Key = 128, "Am"
+    tracks[3].BPM, tracks[3].Key = 128, "C"
+    q = Query{
+        Where: Group{Rules: []Rule{
+            {Field: "bpm", Operator: ">=", Value: 120.0},
+            {Field: "bpm", Operator: "<=", Value: 130.0},
+            {Field: "key", Operator: "is", Value: "am"},
+        }},
+        Sort: []SortKey{{Field: "bpm", Desc: true}},
+    }
+    if ids := trackIDs(Evaluate(q, tracks, nil, 0)); len(ids) != 2 || ids[0] != "3" || ids[1] != "1" {
+        t.Fatalf("unexpected bpm/key result %v", ids)
+    }
+}
+
+func TestValidateRejectsBadRules(t *testing.T) {
+    bad := []Query{
+        {Where: Group{Rules: []Rule{{Field: "mood", Operator: "is", Value: "happy"}}}},
+        {Where: Group{Rules: []Rule{{Field: "year", Operator: "contains", Value: 1}}}},
+        {Where: Group{Rules: []Rule{{Field: "year", Operator: "<", Value: "soon"}}}},
+        {Where: Group{Rules: []Rule{{Field: "last_played", Operator: "after", Value: "yesterday"}}}},
+        {Where: Group{Match: "xor"}},
+        {Sort: []SortKey{{Field: "mood"}}},
+        {Limit: -1},
+    }
+    for i, q := range bad {
+        if err := q.Validate(); err == nil {
+            t.Fatalf("case %d: expected validation error", i)
+        }
+    }
+}
+
+func TestSmartPlaylistsPersist(t *testing.T) {
+    base := t.TempDir()
+    s := NewStoreAtBase(base)
+    q := Query{Where: Group{Rules: []Rule{{Field: "genre", Operator: "is", Value: "Jazz"}}}, Limit: 10}
+    sp, err := s.CreateSmart("Jazz", q)
+    if err != nil {
+        t.Fatalf("CreateSmart: %v", err)
+    }
+    if _, err := s.CreateSmart("Broken", Query{Limit: -1}); err == nil {
+        t.Fatalf("expected invalid query to be rejected")
+    }
+    q.Limit = 3
+    if err := s.UpdateSmart(sp.ID, "Jazz Top", q); err != nil {
+        t.Fatalf("UpdateSmart: %v", err)
+    }
+    reloaded := NewStoreAtBase(base)
+    if err := reloaded.LoadFromFile(); err != nil {
+        t.Fatalf("LoadFromFile: %v", err)
+    }
+    got, err := reloaded.GetSmart(sp.ID)
+    if err != nil {
+        t.Fatalf("GetSmart: %v", err)
+    }
+    if got.Name != "Jazz Top" || got.Query.Limit != 3 || len(Evaluate(got.Query, library(), nil, got.Seed())) != 3 {
+        t.Fatalf("unexpected reloaded smart playlist %+v", got)
+    }
+    if err := reloaded.DeleteSmart(sp.ID); err != nil || len(reloaded.ListSmart()) != 0 {
+        t.Fatalf("DeleteSmart: %v", err)
+    }
+}
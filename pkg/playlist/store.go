@@ -0,0 +1,405 @@
+package playlist
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	cfg "penguin-tunes/pkg/config"
+	"penguin-tunes/pkg/indexer"
+)
+
+// Entry is one position in a playlist; the same track may appear many times
+type Entry struct {
+    TrackID string `json:"trackId"`
+    // Path, Title and Artist are remembered so missing entries can still be shown
+    Path    string `json:"path"`
+    Title   string `json:"title"`
+    Artist  string `json:"artist"`
+    Missing bool   `json:"missing"`
+}
+
+// Playlist is a named, ordered list of entries
+type Playlist struct {
+    ID      string    `json:"id"`
+    Name    string    `json:"name"`
+    Entries []Entry   `json:"entries"`
+    Created time.Time `json:"created"`
+    Updated time.Time `json:"updated"`
+    // Source is the file the playlist was imported from, if any
+    Source        string    `json:"source,omitempty"`
+    SourceModTime time.Time `json:"sourceModTime"`
+}
+
+// Item pairs an entry with its current track, which is nil for missing entries
+type Item struct {
+    Position int            `json:"position"`
+    Entry    Entry          `json:"entry"`
+    Track    *indexer.Track `json:"track"`
+}
+
+// Store keeps playlists and smart playlists keyed by ID and persists them as JSON
+type Store struct {
+    mtx       sync.RWMutex
+    path      string
+    playlists map[string]*Playlist
+    smart     map[string]*SmartPlaylist
+    readOnly  bool
+}
+
+type storeFile struct {
+    Playlists map[string]*Playlist      `json:"playlists"`
+    Smart     map[string]*SmartPlaylist `json:"smart"`
+}
+
+// NewStore creates a store persisted at path
+func NewStore(path string) *Store {
+    return &Store{path: path, playlists: make(map[string]*Playlist), smart: make(map[string]*SmartPlaylist)}
+}
+
+// NewStoreAtBase stores playlists in baseDir/playlists.json, next to index.json
+func NewStoreAtBase(baseDir string) *Store {
+    return NewStore(filepath.Join(baseDir, "playlists.json"))
+}
+
+// LoadFromFile loads playlists from disk if the file exists
+func (s *Store) LoadFromFile() error {
+    s.mtx.Lock()
+    defer s.mtx.Unlock()
+    if _, err := os.Stat(s.path); err != nil {
+        return nil
+    }
+    b, err := os.ReadFile(s.path)
+    if err != nil {
+        return fmt.Errorf("read playlists: %w", err)
+    }
+    var wrapper storeFile
+    if err := json.Unmarshal(b, &wrapper); err != nil {
+        return fmt.Errorf("unmarshal playlists: %w", err)
+    }
+    s.playlists = wrapper.Playlists
+    if s.playlists == nil {
+        s.playlists = make(map[string]*Playlist)
+    }
+    s.smart = wrapper.Smart
+    if s.smart == nil {
+        s.smart = make(map[string]*SmartPlaylist)
+    }
+    return nil
+}
+
+// SetReadOnly makes changes fail with cfg.ErrReadOnly, for processes that
+// don't hold the data dir lock
+func (s *Store) SetReadOnly(ro bool) {
+    s.mtx.Lock()
+    defer s.mtx.Unlock()
+    s.readOnly = ro
+}
+
+func (s *Store) saveLocked() error {
+    if s.readOnly {
+        return fmt.Errorf("save playlists: %w", cfg.ErrReadOnly)
+    }
+    wrapper := storeFile{Playlists: s.playlists, Smart: s.smart}
+    b, err := json.MarshalIndent(wrapper, "", "  ")
+    if err != nil {
+        return fmt.Errorf("marshal playlists: %w", err)
+    }
+    if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+        return fmt.Errorf("mkdir playlists dir: %w", err)
+    }
+    if err := cfg.WriteFileAtomic(s.path, b); err != nil {
+        return fmt.Errorf("write playlists: %w", err)
+    }
+    return nil
+}
+
+// List returns copies of all playlists sorted by name
+func (s *Store) List() []Playlist {
+    s.mtx.RLock()
+    defer s.mtx.RUnlock()
+    out := make([]Playlist, 0, len(s.playlists))
+    for _, p := range s.playlists {
+        out = append(out, p.clone())
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+    return out
+}
+
+// Get returns a copy of the playlist with id
+func (s *Store) Get(id string) (Playlist, error) {
+    s.mtx.RLock()
+    defer s.mtx.RUnlock()
+    p, ok := s.playlists[id]
+    if !ok {
+        return Playlist{}, fmt.Errorf("playlist %s not found", id)
+    }
+    return p.clone(), nil
+}
+
+// Create adds an empty playlist named name
+func (s *Store) Create(name string) (Playlist, error) {
+    if name == "" {
+        return Playlist{}, fmt.Errorf("playlist name is required")
+    }
+    s.mtx.Lock()
+    defer s.mtx.Unlock()
+    now := time.Now()
+    p := &Playlist{ID: newID(), Name: name, Entries: []Entry{}, Created: now, Updated: now}
+    s.playlists[p.ID] = p
+    if err := s.saveLocked(); err != nil {
+        delete(s.playlists, p.ID)
+        return Playlist{}, err
+    }
+    return p.clone(), nil
+}
+
+// Rename changes a playlist's name
+func (s *Store) Rename(id, name string) error {
+    if name == "" {
+        return fmt.Errorf("playlist name is required")
+    }
+    return s.update(id, func(p *Playlist) error {
+        p.Name = name
+        return nil
+    })
+}
+
+// Delete removes a playlist
+func (s *Store) Delete(id string) error {
+    s.mtx.Lock()
+    defer s.mtx.Unlock()
+    if _, ok := s.playlists[id]; !ok {
+        return fmt.Errorf("playlist %s not found", id)
+    }
+    if s.readOnly {
+        return fmt.Errorf("delete playlist: %w", cfg.ErrReadOnly)
+    }
+    delete(s.playlists, id)
+    return s.saveLocked()
+}
+
+// Add inserts tracks at position at; a negative or out of range position appends
+func (s *Store) Add(id string, tracks []*indexer.Track, at int) error {
+    return s.update(id, func(p *Playlist) error {
+        entries := make([]Entry, 0, len(tracks))
+        for _, t := range tracks {
+            entries = append(entries, entryFor(t))
+        }
+        if at < 0 || at > len(p.Entries) {
+            at = len(p.Entries)
+        }
+        p.Entries = append(p.Entries[:at], append(entries, p.Entries[at:]...)...)
+        return nil
+    })
+}
+
+// Remove deletes the entries at the given positions
+func (s *Store) Remove(id string, positions []int) error {
+    return s.update(id, func(p *Playlist) error {
+        drop := make(map[int]bool, len(positions))
+        for _, pos := range positions {
+            if pos < 0 || pos >= len(p.Entries) {
+                return fmt.Errorf("position %d out of range", pos)
+            }
+            drop[pos] = true
+        }
+        n := make([]Entry, 0, len(p.Entries))
+        for i, e := range p.Entries {
+            if drop[i] {
+                continue
+            }
+            n = append(n, e)
+        }
+        p.Entries = n
+        return nil
+    })
+}
+
+// Move relocates the entry at from so that it ends up at position to
+func (s *Store) Move(id string, from, to int) error {
+    return s.update(id, func(p *Playlist) error {
+        if from < 0 || from >= len(p.Entries) || to < 0 || to >= len(p.Entries) {
+            return fmt.Errorf("move %d -> %d out of range", from, to)
+        }
+        e := p.Entries[from]
+        p.Entries = append(p.Entries[:from], p.Entries[from+1:]...)
+        p.Entries = append(p.Entries[:to], append([]Entry{e}, p.Entries[to:]...)...)
+        return nil
+    })
+}
+
+// Reconcile marks entries whose tracks left the index as missing instead of dropping
+// them, and restores entries whose tracks came back. It reports whether anything changed.
+func (s *Store) Reconcile(idx *indexer.Index) (bool, error) {
+    byID := make(map[string]*indexer.Track)
+    byPath := make(map[string]*indexer.Track)
+    for _, t := range idx.GetAll() {
+        byID[t.ID] = t
+        // tracks cut from one file share its path
+        if !t.Virtual() {
+            byPath[t.Path] = t
+        }
+    }
+    s.mtx.Lock()
+    defer s.mtx.Unlock()
+    changed := false
+    for _, p := range s.playlists {
+        for i, e := range p.Entries {
+            t, ok := byID[e.TrackID]
+            if !ok {
+                // a file that came back at its old path is relinked even if its ID changed
+                t, ok = byPath[e.Path]
+            }
+            var n Entry
+            if ok {
+                n = entryFor(t)
+            } else {
+                n = e
+                n.Missing = true
+            }
+            if n != e {
+                p.Entries[i] = n
+                changed = true
+            }
+        }
+    }
+    if !changed || s.readOnly {
+        // a read-only store only shows the links; the writer saves them
+        return changed, nil
+    }
+    return true, s.saveLocked()
+}
+
+// Resolve pairs each entry of p with its track from idx
+func Resolve(p Playlist, idx *indexer.Index) []Item {
+    items := make([]Item, len(p.Entries))
+    for i, e := range p.Entries {
+        items[i] = Item{Position: i, Entry: e}
+        if !e.Missing {
+            items[i].Track = idx.GetByID(e.TrackID)
+        }
+    }
+    return items
+}
+
+// ListSmart returns all smart playlists sorted by name
+func (s *Store) ListSmart() []SmartPlaylist {
+    s.mtx.RLock()
+    defer s.mtx.RUnlock()
+    out := make([]SmartPlaylist, 0, len(s.smart))
+    for _, sp := range s.smart {
+        out = append(out, *sp)
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+    return out
+}
+
+// GetSmart returns the smart playlist with id
+func (s *Store) GetSmart(id string) (SmartPlaylist, error) {
+    s.mtx.RLock()
+    defer s.mtx.RUnlock()
+    sp, ok := s.smart[id]
+    if !ok {
+        return SmartPlaylist{}, fmt.Errorf("smart playlist %s not found", id)
+    }
+    return *sp, nil
+}
+
+// CreateSmart stores a new smart playlist after validating its query
+func (s *Store) CreateSmart(name string, q Query) (SmartPlaylist, error) {
+    if name == "" {
+        return SmartPlaylist{}, fmt.Errorf("playlist name is required")
+    }
+    if err := q.Validate(); err != nil {
+        return SmartPlaylist{}, err
+    }
+    s.mtx.Lock()
+    defer s.mtx.Unlock()
+    now := time.Now()
+    sp := &SmartPlaylist{ID: newID(), Name: name, Query: q, Created: now, Updated: now}
+    s.smart[sp.ID] = sp
+    if err := s.saveLocked(); err != nil {
+        delete(s.smart, sp.ID)
+        return SmartPlaylist{}, err
+    }
+    return *sp, nil
+}
+
+// UpdateSmart replaces the name and query of a smart playlist
+func (s *Store) UpdateSmart(id, name string, q Query) error {
+    if name == "" {
+        return fmt.Errorf("playlist name is required")
+    }
+    if err := q.Validate(); err != nil {
+        return err
+    }
+    s.mtx.Lock()
+    defer s.mtx.Unlock()
+    sp, ok := s.smart[id]
+    if !ok {
+        return fmt.Errorf("smart playlist %s not found", id)
+    }
+    backup := *sp
+    sp.Name, sp.Query, sp.Updated = name, q, time.Now()
+    if err := s.saveLocked(); err != nil {
+        *sp = backup
+        return err
+    }
+    return nil
+}
+
+// DeleteSmart removes a smart playlist
+func (s *Store) DeleteSmart(id string) error {
+    s.mtx.Lock()
+    defer s.mtx.Unlock()
+    if _, ok := s.smart[id]; !ok {
+        return fmt.Errorf("smart playlist %s not found", id)
+    }
+    if s.readOnly {
+        return fmt.Errorf("delete smart playlist: %w", cfg.ErrReadOnly)
+    }
+    delete(s.smart, id)
+    return s.saveLocked()
+}
+
+func (s *Store) update(id string, fn func(p *Playlist) error) error {
+    s.mtx.Lock()
+    defer s.mtx.Unlock()
+    p, ok := s.playlists[id]
+    if !ok {
+        return fmt.Errorf("playlist %s not found", id)
+    }
+    backup := p.clone()
+    if err := fn(p); err != nil {
+        return err
+    }
+    p.Updated = time.Now()
+    if err := s.saveLocked(); err != nil {
+        *p = backup
+        return err
+    }
+    return nil
+}
+
+func (p *Playlist) clone() Playlist {
+    c := *p
+    c.Entries = append([]Entry{}, p.Entries...)
+    return c
+}
+
+func entryFor(t *indexer.Track) Entry {
+    return Entry{TrackID: t.ID, Path: t.Path, Title: t.Title, Artist: t.Artist}
+}
+
+func newID() string {
+    b := make([]byte, 8)
+    _, _ = rand.Read(b)
+    return hex.EncodeToString(b)
+}
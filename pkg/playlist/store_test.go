@@ -0,0 +1,157 @@
+package playlist
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cfg "penguin-tunes/pkg/config"
+	"penguin-tunes/pkg/indexer"
+)
+
+func ids(p Playlist) []string {
+    out := make([]string, len(p.Entries))
+    for i, e := range p.Entries {
+        out[i] = e.TrackID
+    }
+    return out
+}
+
+func equal(a, b []string) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    for i := range a {
+        if a[i] != b[i] {
+            return false
+        }
+    }
+    return true
+}
+
+func TestStoreOperationsPersist(t *testing.T) {
+    base := t.TempDir()
+    s := NewStoreAtBase(base)
+    a := &indexer.Track{ID: "a", Path: "/m/a.mp3", Title: "A"}
+    b := &indexer.Track{ID: "b", Path: "/m/b.mp3", Title: "B"}
+    c := &indexer.Track{ID: "c", Path: "/m/c.mp3", Title: "C"}
+
+    p, err := s.Create("Mix")
+    if err != nil {
+        t.Fatalf("Create: %v", err)
+    }
+    if err := s.Add(p.ID, []*indexer.Track{a, b, a}, -1); err != nil {
+        t.Fatalf("Add: %v", err)
+    }
+    if err := s.Add(p.ID, []*indexer.Track{c}, 1); err != nil {
+        t.Fatalf("Add at: %v", err)
+    }
+    got, _ := s.Get(p.ID)
+    if !equal(ids(got), []string{"a", "c", "b", "a"}) {
+        t.Fatalf("unexpected entries %v", ids(got))
+    }
+    if err := s.Move(p.ID, 0, 3); err != nil {
+        t.Fatalf("Move: %v", err)
+    }
+    got, _ = s.Get(p.ID)
+    if !equal(ids(got), []string{"c", "b", "a", "a"}) {
+        t.Fatalf("unexpected entries after move %v", ids(got))
+    }
+    if err := s.Remove(p.ID, []int{1, 3}); err != nil {
+        t.Fatalf("Remove: %v", err)
+    }
+    if err := s.Rename(p.ID, "Renamed"); err != nil {
+        t.Fatalf("Rename: %v", err)
+    }
+    if err := s.Move(p.ID, 0, 5); err == nil {
+        t.Fatalf("expected out of range move to fail")
+    }
+
+    reloaded := NewStoreAtBase(base)
+    if err := reloaded.LoadFromFile(); err != nil {
+        t.Fatalf("LoadFromFile: %v", err)
+    }
+    got, err = reloaded.Get(p.ID)
+    if err != nil {
+        t.Fatalf("Get after reload: %v", err)
+    }
+    if got.Name != "Renamed" || !equal(ids(got), []string{"c", "a"}) {
+        t.Fatalf("unexpected reloaded playlist %s %v", got.Name, ids(got))
+    }
+    if err := reloaded.Delete(p.ID); err != nil {
+        t.Fatalf("Delete: %v", err)
+    }
+    if len(reloaded.List()) != 0 {
+        t.Fatalf("expected no playlists after delete")
+    }
+}
+
+func TestReconcileMarksMissing(t *testing.T) {
+    base := t.TempDir()
+    idx := indexer.NewIndexAtBase(base)
+    a := &indexer.Track{ID: "a", Path: "/m/a.mp3", Title: "A"}
+    b := &indexer.Track{ID: "b", Path: "/m/b.mp3", Title: "B"}
+    idx.AddOrUpdateTrack(a)
+    idx.AddOrUpdateTrack(b)
+
+    s := NewStoreAtBase(base)
+    p, _ := s.Create("Mix")
+    _ = s.Add(p.ID, []*indexer.Track{a, b}, -1)
+
+    idx.RemoveTrack(b.Path)
+    changed, err := s.Reconcile(idx)
+    if err != nil || !changed {
+        t.Fatalf("Reconcile: changed=%v err=%v", changed, err)
+    }
+    got, _ := s.Get(p.ID)
+    if len(got.Entries) != 2 || !got.Entries[1].Missing || got.Entries[1].Title != "B" {
+        t.Fatalf("expected missing entry to be kept, got %+v", got.Entries)
+    }
+    items := Resolve(got, idx)
+    if items[0].Track == nil || items[1].Track != nil {
+        t.Fatalf("unexpected resolved items %+v", items)
+    }
+
+    // the file comes back under a new ID at the same path
+    idx.AddOrUpdateTrack(&indexer.Track{ID: "b2", Path: "/m/b.mp3", Title: "B"})
+    if changed, _ := s.Reconcile(idx); !changed {
+        t.Fatalf("expected entry to be restored")
+    }
+    got, _ = s.Get(p.ID)
+    if got.Entries[1].Missing || got.Entries[1].TrackID != "b2" {
+        t.Fatalf("expected relinked entry, got %+v", got.Entries[1])
+    }
+    if changed, _ := s.Reconcile(idx); changed {
+        t.Fatalf("expected no change on second reconcile")
+    }
+}
+
+func TestReadOnlyStore(t *testing.T) {
+    base := t.TempDir()
+    s := NewStoreAtBase(base)
+    p, err := s.Create("Mix")
+    if err != nil {
+        t.Fatalf("Create: %v", err)
+    }
+    s.SetReadOnly(true)
+    if _, err := s.Create("Other"); !errors.Is(err, cfg.ErrReadOnly) {
+        t.Fatalf("expected ErrReadOnly from Create, got %v", err)
+    }
+    if err := s.Rename(p.ID, "Renamed"); !errors.Is(err, cfg.ErrReadOnly) {
+        t.Fatalf("expected ErrReadOnly from Rename, got %v", err)
+    }
+    if err := s.Delete(p.ID); !errors.Is(err, cfg.ErrReadOnly) {
+        t.Fatalf("expected ErrReadOnly from Delete, got %v", err)
+    }
+    if got := s.List(); len(got) != 1 || got[0].Name != "Mix" {
+        t.Fatalf("a refused change must not show, got %+v", got)
+    }
+    stale, _ := filepath.Glob(filepath.Join(base, "*.tmp"))
+    if len(stale) > 0 {
+        t.Fatalf("unexpected temp files %v", stale)
+    }
+    if _, err := os.Stat(filepath.Join(base, "playlists.json")); err != nil {
+        t.Fatalf("playlists.json: %v", err)
+    }
+}
@@ -0,0 +1,405 @@
+package stats
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	cfg "penguin-tunes/pkg/config"
+	"penguin-tunes/pkg/indexer"
+	"penguin-tunes/pkg/playlist"
+)
+
+// Event types recorded in the history
+const (
+    Play = "play"
+    Skip = "skip"
+)
+
+// Event is one entry of the listening history; Listened is in seconds
+type Event struct {
+    TrackID  string    `json:"trackId"`
+    Type     string    `json:"type"`
+    Time     time.Time `json:"time"`
+    Listened float64   `json:"listened"`
+}
+
+// TrackStats aggregates the history of a single track
+type TrackStats struct {
+    PlayCount   int       `json:"playCount"`
+    SkipCount   int       `json:"skipCount"`
+    FirstPlayed time.Time `json:"firstPlayed"`
+    LastPlayed  time.Time `json:"lastPlayed"`
+}
+
+// TopItem is a ranked track, artist or album; Artist and TrackID are set where they apply
+type TopItem struct {
+    Name    string `json:"name"`
+    Artist  string `json:"artist"`
+    TrackID string `json:"trackId"`
+    Plays   int    `json:"plays"`
+}
+
+// saveDelay is how long changed counters wait before they are saved, so a
+// burst of events is written once
+const saveDelay = 5 * time.Second
+
+// Store keeps per-track stats and the full history keyed by track ID, which
+// survives rescans and moves made by the organizer. Events are appended to
+// a log as they are recorded; the counters are saved apart from it, a while
+// after they changed.
+type Store struct {
+    mtx      sync.RWMutex
+    path     string
+    logPath  string
+    tracks   map[string]*TrackStats
+    history  []Event
+    readOnly bool
+    log      *slog.Logger
+    // dirty is set while the counters have changes not yet saved
+    dirty     bool
+    saveTimer *time.Timer
+    // legacy is set when the history came from stats.json and has yet to
+    // move to the log
+    legacy bool
+}
+
+// NewStore creates a store saving counters at path and the history beside
+// it in history.jsonl
+func NewStore(path string) *Store {
+    return &Store{
+        path:    path,
+        logPath: filepath.Join(filepath.Dir(path), "history.jsonl"),
+        tracks:  make(map[string]*TrackStats),
+        log:     slog.Default(),
+    }
+}
+
+// NewStoreAtBase stores stats in baseDir/stats.json and baseDir/history.jsonl
+func NewStoreAtBase(baseDir string) *Store {
+    return NewStore(filepath.Join(baseDir, "stats.json"))
+}
+
+// SetLogger sets where failed delayed saves are logged
+func (s *Store) SetLogger(l *slog.Logger) {
+    s.mtx.Lock()
+    defer s.mtx.Unlock()
+    s.log = l
+}
+
+type storeFile struct {
+    Tracks map[string]*TrackStats `json:"tracks"`
+    // Events is how many events of the log the counters include
+    Events int `json:"events"`
+    // History is where older versions kept the history
+    History []Event `json:"history,omitempty"`
+}
+
+// LoadFromFile loads stats from disk if the files exist. Events logged
+// after the counters were last saved are counted again.
+func (s *Store) LoadFromFile() error {
+    s.mtx.Lock()
+    defer s.mtx.Unlock()
+    var wrapper storeFile
+    b, err := os.ReadFile(s.path)
+    switch {
+    case err == nil:
+        if err := json.Unmarshal(b, &wrapper); err != nil {
+            return fmt.Errorf("unmarshal stats: %w", err)
+        }
+    case !os.IsNotExist(err):
+        return fmt.Errorf("read stats: %w", err)
+    }
+    logged, err := s.readLog()
+    if err != nil {
+        return err
+    }
+    s.tracks = wrapper.Tracks
+    if s.tracks == nil {
+        s.tracks = make(map[string]*TrackStats)
+    }
+    counted := wrapper.Events
+    s.legacy = len(wrapper.History) > 0
+    if s.legacy {
+        // the old counters include the old history and nothing logged
+        counted = len(wrapper.History)
+    }
+    s.history = append(wrapper.History, logged...)
+    if counted > len(s.history) {
+        counted = len(s.history)
+    }
+    for _, ev := range s.history[counted:] {
+        s.countLocked(ev)
+    }
+    s.dirty = s.legacy || counted < len(s.history)
+    return nil
+}
+
+// readLog reads the events of the history log; a line cut short by a crash
+// is skipped
+func (s *Store) readLog() ([]Event, error) {
+    f, err := os.Open(s.logPath)
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("read history: %w", err)
+    }
+    defer f.Close()
+    var events []Event
+    sc := bufio.NewScanner(f)
+    for sc.Scan() {
+        var ev Event
+        if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+            s.log.Warn("skipping damaged history entry", "path", s.logPath, "err", err)
+            continue
+        }
+        events = append(events, ev)
+    }
+    if err := sc.Err(); err != nil {
+        return nil, fmt.Errorf("read history: %w", err)
+    }
+    return events, nil
+}
+
+// SetReadOnly makes Record fail with cfg.ErrReadOnly, for processes that
+// don't hold the data dir lock
+func (s *Store) SetReadOnly(ro bool) {
+    s.mtx.Lock()
+    defer s.mtx.Unlock()
+    s.readOnly = ro
+}
+
+// appendLocked adds ev to the history log
+func (s *Store) appendLocked(ev Event) error {
+    b, err := json.Marshal(ev)
+    if err != nil {
+        return fmt.Errorf("marshal event: %w", err)
+    }
+    f, err := os.OpenFile(s.logPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+    if err != nil {
+        return fmt.Errorf("append history: %w", err)
+    }
+    if _, err := f.Write(append(b, '\n')); err != nil {
+        f.Close()
+        return fmt.Errorf("append history: %w", err)
+    }
+    if err := f.Close(); err != nil {
+        return fmt.Errorf("append history: %w", err)
+    }
+    return nil
+}
+
+// saveLocked writes the counters, first moving a history loaded from an
+// old stats.json to the log
+func (s *Store) saveLocked() error {
+    if s.readOnly {
+        return fmt.Errorf("save stats: %w", cfg.ErrReadOnly)
+    }
+    if s.legacy {
+        var buf bytes.Buffer
+        enc := json.NewEncoder(&buf)
+        for _, ev := range s.history {
+            if err := enc.Encode(ev); err != nil {
+                return fmt.Errorf("marshal history: %w", err)
+            }
+        }
+        if err := cfg.WriteFileAtomic(s.logPath, buf.Bytes()); err != nil {
+            return fmt.Errorf("write history: %w", err)
+        }
+        s.legacy = false
+    }
+    b, err := json.Marshal(storeFile{Tracks: s.tracks, Events: len(s.history)})
+    if err != nil {
+        return fmt.Errorf("marshal stats: %w", err)
+    }
+    if err := cfg.WriteFileAtomic(s.path, b); err != nil {
+        return fmt.Errorf("write stats: %w", err)
+    }
+    s.dirty = false
+    return nil
+}
+
+// Flush saves counters still waiting for their delayed save
+func (s *Store) Flush() error {
+    s.mtx.Lock()
+    defer s.mtx.Unlock()
+    if s.saveTimer != nil {
+        s.saveTimer.Stop()
+        s.saveTimer = nil
+    }
+    if !s.dirty || s.readOnly {
+        return nil
+    }
+    return s.saveLocked()
+}
+
+// Record appends ev to the history log and updates the track's counters,
+// which are saved shortly after
+func (s *Store) Record(ev Event) error {
+    if ev.Type != Play && ev.Type != Skip {
+        return fmt.Errorf("unknown event type %q", ev.Type)
+    }
+    if ev.Time.IsZero() {
+        ev.Time = time.Now()
+    }
+    s.mtx.Lock()
+    defer s.mtx.Unlock()
+    if s.readOnly {
+        return fmt.Errorf("record %s: %w", ev.Type, cfg.ErrReadOnly)
+    }
+    if err := s.appendLocked(ev); err != nil {
+        return err
+    }
+    s.history = append(s.history, ev)
+    s.countLocked(ev)
+    s.dirty = true
+    if s.saveTimer == nil {
+        s.saveTimer = time.AfterFunc(saveDelay, func() {
+            if err := s.Flush(); err != nil {
+                s.log.Error("save stats failed", "err", err)
+            }
+        })
+    }
+    return nil
+}
+
+// countLocked adds ev to the counters of its track
+func (s *Store) countLocked(ev Event) {
+    ts, ok := s.tracks[ev.TrackID]
+    if !ok {
+        ts = &TrackStats{}
+        s.tracks[ev.TrackID] = ts
+    }
+    if ev.Type == Play {
+        ts.PlayCount++
+        if ts.FirstPlayed.IsZero() || ev.Time.Before(ts.FirstPlayed) {
+            ts.FirstPlayed = ev.Time
+        }
+        if ev.Time.After(ts.LastPlayed) {
+            ts.LastPlayed = ev.Time
+        }
+    } else {
+        ts.SkipCount++
+    }
+}
+
+// Get returns the stats of a track; unknown tracks have zero stats
+func (s *Store) Get(trackID string) TrackStats {
+    s.mtx.RLock()
+    defer s.mtx.RUnlock()
+    if ts, ok := s.tracks[trackID]; ok {
+        return *ts
+    }
+    return TrackStats{}
+}
+
+// Usage returns the stats of a track for smart playlist rules, making the
+// store a playlist.UsageSource
+func (s *Store) Usage(trackID string) playlist.Usage {
+    ts := s.Get(trackID)
+    return playlist.Usage{
+        PlayCount:   ts.PlayCount,
+        SkipCount:   ts.SkipCount,
+        FirstPlayed: ts.FirstPlayed,
+        LastPlayed:  ts.LastPlayed,
+    }
+}
+
+// History returns events in [from, to), newest first; zero bounds are open and limit 0 means all
+func (s *Store) History(from, to time.Time, limit int) []Event {
+    s.mtx.RLock()
+    defer s.mtx.RUnlock()
+    out := []Event{}
+    for i := len(s.history) - 1; i >= 0; i-- {
+        ev := s.history[i]
+        if !inRange(ev.Time, from, to) {
+            continue
+        }
+        out = append(out, ev)
+        if limit > 0 && len(out) == limit {
+            break
+        }
+    }
+    return out
+}
+
+// Top ranks plays in [from, to) grouped by "track", "artist" or "album". lookup
+// resolves track IDs to metadata; plays of tracks it can't resolve are skipped for
+// artist and album rankings.
+func (s *Store) Top(by string, from, to time.Time, limit int, lookup func(id string) *indexer.Track) ([]TopItem, error) {
+    if by != "track" && by != "artist" && by != "album" {
+        return nil, fmt.Errorf("unknown grouping %q", by)
+    }
+    s.mtx.RLock()
+    counts := make(map[string]int)
+    for _, ev := range s.history {
+        if ev.Type == Play && inRange(ev.Time, from, to) {
+            counts[ev.TrackID]++
+        }
+    }
+    s.mtx.RUnlock()
+
+    items := make(map[string]*TopItem)
+    for id, n := range counts {
+        t := lookup(id)
+        var key string
+        var item TopItem
+        switch {
+        case by == "track":
+            key = id
+            item = TopItem{TrackID: id}
+            if t != nil {
+                item.Name, item.Artist = t.Title, t.Artist
+            }
+        case t == nil:
+            continue
+        case by == "artist":
+            key = t.Artist
+            item = TopItem{Name: t.Artist}
+        default:
+            key = t.Artist + "\x00" + t.Album
+            item = TopItem{Name: t.Album, Artist: t.Artist}
+        }
+        if existing, ok := items[key]; ok {
+            existing.Plays += n
+            continue
+        }
+        item.Plays = n
+        items[key] = &item
+    }
+    out := make([]TopItem, 0, len(items))
+    for _, it := range items {
+        out = append(out, *it)
+    }
+    sort.Slice(out, func(i, j int) bool {
+        if out[i].Plays != out[j].Plays {
+            return out[i].Plays > out[j].Plays
+        }
+        if out[i].Name != out[j].Name {
+            return out[i].Name < out[j].Name
+        }
+        return out[i].TrackID < out[j].TrackID
+    })
+    if limit > 0 && len(out) > limit {
+        out = out[:limit]
+    }
+    return out, nil
+}
+
+func inRange(t, from, to time.Time) bool {
+    if !from.IsZero() && t.Before(from) {
+        return false
+    }
+    if !to.IsZero() && !t.Before(to) {
+        return false
+    }
+    return true
+}
@@ -0,0 +1,190 @@
+package stats
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"penguin-tunes/pkg/indexer"
+)
+
+// play feeds position reports of one second each from 0 to secs
+func play(t *testing.T, tr *Tracker, secs int, duration float64) {
+    t.Helper()
+    for i := 1; i <= secs; i++ {
+        if err := tr.Progress(float64(i), duration); err != nil {
+            t.Fatalf("Progress: %v", err)
+        }
+    }
+}
+
+func TestTrackerThresholds(t *testing.T) {
+    s := NewStoreAtBase(t.TempDir())
+    defer s.Flush()
+    tr := NewTracker(s, DefaultThreshold)
+    var recorded []Event
+    tr.OnRecord = func(ev Event) { recorded = append(recorded, ev) }
+
+    // short track: half of 100s counts as a listen
+    tr.Start("short")
+    play(t, tr, 49, 100)
+    if s.Get("short").PlayCount != 0 {
+        t.Fatalf("listen recorded before threshold")
+    }
+    play(t, tr, 50, 100)
+    // long track: four minutes count before half of 600s
+    tr.Start("long")
+    play(t, tr, 240, 600)
+    // skipped track: seeking ahead doesn't count as listening
+    tr.Start("skipped")
+    play(t, tr, 10, 100)
+    tr.Seek(90)
+    tr.Progress(91, 100)
+    if err := tr.Finish(); err != nil {
+        t.Fatalf("Finish: %v", err)
+    }
+
+    if got := s.Get("short"); got.PlayCount != 1 || got.SkipCount != 0 || got.FirstPlayed.IsZero() {
+        t.Fatalf("unexpected short stats %+v", got)
+    }
+    if got := s.Get("long"); got.PlayCount != 1 {
+        t.Fatalf("unexpected long stats %+v", got)
+    }
+    if got := s.Get("skipped"); got.PlayCount != 0 || got.SkipCount != 1 {
+        t.Fatalf("unexpected skipped stats %+v", got)
+    }
+    if len(recorded) != 3 || recorded[2].Type != Skip || recorded[2].Listened != 11 {
+        t.Fatalf("unexpected recorded events %+v", recorded)
+    }
+    // finishing between sessions records nothing
+    if err := tr.Finish(); err != nil || len(s.History(time.Time{}, time.Time{}, 0)) != 3 {
+        t.Fatalf("unexpected history after idle Finish: %v", err)
+    }
+}
+
+func TestTopAndHistory(t *testing.T) {
+    base := t.TempDir()
+    s := NewStoreAtBase(base)
+    day := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+    events := []Event{
+        {TrackID: "a1", Type: Play, Time: day},
+        {TrackID: "a1", Type: Play, Time: day.Add(24 * time.Hour)},
+        {TrackID: "a2", Type: Play, Time: day.Add(24 * time.Hour)},
+        {TrackID: "b1", Type: Play, Time: day.Add(48 * time.Hour)},
+        {TrackID: "b1", Type: Skip, Time: day.Add(48 * time.Hour)},
+        {TrackID: "gone", Type: Play, Time: day.Add(48 * time.Hour)},
+    }
+    for _, ev := range events {
+        if err := s.Record(ev); err != nil {
+            t.Fatalf("Record: %v", err)
+        }
+    }
+    if err := s.Record(Event{TrackID: "a1", Type: "pause"}); err == nil {
+        t.Fatalf("expected unknown event type to be rejected")
+    }
+    if err := s.Flush(); err != nil {
+        t.Fatalf("Flush: %v", err)
+    }
+
+    reloaded := NewStoreAtBase(base)
+    if err := reloaded.LoadFromFile(); err != nil {
+        t.Fatalf("LoadFromFile: %v", err)
+    }
+    if got := reloaded.Get("a1"); got.PlayCount != 2 || !got.FirstPlayed.Equal(day) || !got.LastPlayed.Equal(day.Add(24*time.Hour)) {
+        t.Fatalf("unexpected reloaded stats %+v", got)
+    }
+
+    lib := map[string]*indexer.Track{
+        "a1": {ID: "a1", Title: "One", Artist: "A", Album: "First"},
+        "a2": {ID: "a2", Title: "Two", Artist: "A", Album: "Second"},
+        "b1": {ID: "b1", Title: "Three", Artist: "B", Album: "First"},
+    }
+    lookup := func(id string) *indexer.Track { return lib[id] }
+
+    artists, err := reloaded.Top("artist", time.Time{}, time.Time{}, 0, lookup)
+    if err != nil {
+        t.Fatalf("Top: %v", err)
+    }
+    if len(artists) != 2 || artists[0].Name != "A" || artists[0].Plays != 3 || artists[1].Plays != 1 {
+        t.Fatalf("unexpected top artists %+v", artists)
+    }
+    // albums with the same title by different artists stay apart
+    albums, _ := reloaded.Top("album", time.Time{}, time.Time{}, 0, lookup)
+    if len(albums) != 3 {
+        t.Fatalf("unexpected top albums %+v", albums)
+    }
+    // ranges are half-open and unknown tracks still rank by ID
+    tracks, _ := reloaded.Top("track", day.Add(24*time.Hour), day.Add(72*time.Hour), 2, lookup)
+    if len(tracks) != 2 || tracks[0].TrackID != "gone" || tracks[1].Name != "One" {
+        t.Fatalf("unexpected top tracks %+v", tracks)
+    }
+    if _, err := reloaded.Top("genre", time.Time{}, time.Time{}, 0, lookup); err == nil {
+        t.Fatalf("expected unknown grouping to be rejected")
+    }
+
+    hist := reloaded.History(day, day.Add(48*time.Hour), 0)
+    if len(hist) != 3 || hist[0].TrackID != "a2" || hist[2].TrackID != "a1" {
+        t.Fatalf("unexpected history %+v", hist)
+    }
+}
+
+func TestCountersCatchUpWithLog(t *testing.T) {
+    base := t.TempDir()
+    s := NewStoreAtBase(base)
+    defer s.Flush()
+    s.Record(Event{TrackID: "a", Type: Play})
+    if err := s.Flush(); err != nil {
+        t.Fatalf("Flush: %v", err)
+    }
+    // events logged after the last save of the counters, as after a crash
+    s.Record(Event{TrackID: "a", Type: Play})
+    s.Record(Event{TrackID: "a", Type: Skip})
+    b, _ := os.ReadFile(filepath.Join(base, "stats.json"))
+    if strings.Contains(string(b), "history") {
+        t.Fatalf("history must not be kept with the counters: %s", b)
+    }
+
+    reloaded := NewStoreAtBase(base)
+    if err := reloaded.LoadFromFile(); err != nil {
+        t.Fatalf("LoadFromFile: %v", err)
+    }
+    if got := reloaded.Get("a"); got.PlayCount != 2 || got.SkipCount != 1 {
+        t.Fatalf("expected logged events counted once, got %+v", got)
+    }
+    if n := len(reloaded.History(time.Time{}, time.Time{}, 0)); n != 3 {
+        t.Fatalf("expected 3 events, got %d", n)
+    }
+}
+
+func TestLegacyHistoryMovesToLog(t *testing.T) {
+    base := t.TempDir()
+    old := `{"tracks":{"a":{"playCount":1}},"history":[{"trackId":"a","type":"play","time":"2024-05-01T12:00:00Z"}]}`
+    if err := os.WriteFile(filepath.Join(base, "stats.json"), []byte(old), 0644); err != nil {
+        t.Fatal(err)
+    }
+    s := NewStoreAtBase(base)
+    if err := s.LoadFromFile(); err != nil {
+        t.Fatalf("LoadFromFile: %v", err)
+    }
+    s.Record(Event{TrackID: "a", Type: Play})
+    if err := s.Flush(); err != nil {
+        t.Fatalf("Flush: %v", err)
+    }
+    b, _ := os.ReadFile(filepath.Join(base, "history.jsonl"))
+    if lines := strings.Count(string(b), "\n"); lines != 2 {
+        t.Fatalf("expected both events in the log, got %q", b)
+    }
+
+    reloaded := NewStoreAtBase(base)
+    if err := reloaded.LoadFromFile(); err != nil {
+        t.Fatalf("LoadFromFile: %v", err)
+    }
+    if got := reloaded.Get("a"); got.PlayCount != 2 {
+        t.Fatalf("unexpected counters after migration %+v", got)
+    }
+    if n := len(reloaded.History(time.Time{}, time.Time{}, 0)); n != 2 {
+        t.Fatalf("expected 2 events, got %d", n)
+    }
+}
@@ -0,0 +1,123 @@
+package stats
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Threshold decides when playback counts as a listen: after Fraction of the
+// track or Seconds of listening, whichever comes first
+type Threshold struct {
+    Fraction float64
+    Seconds  float64
+}
+
+// DefaultThreshold counts a listen at half the track or four minutes
+var DefaultThreshold = Threshold{Fraction: 0.5, Seconds: 240}
+
+// maxProgressStep is the largest position jump still counted as listening; bigger jumps are seeks
+const maxProgressStep = 5.0
+
+// Tracker follows playback of one track at a time and records a listen once the
+// threshold is passed, or a skip if playback ends before that
+type Tracker struct {
+    // OnRecord, if set, is called after every event written to the store
+    OnRecord func(Event)
+
+    mtx       sync.Mutex
+    store     *Store
+    threshold Threshold
+    now       func() time.Time
+
+    trackID  string
+    duration float64
+    listened float64
+    lastPos  float64
+    recorded bool
+}
+
+// NewTracker creates a tracker recording into store
+func NewTracker(store *Store, threshold Threshold) *Tracker {
+    return &Tracker{store: store, threshold: threshold, now: time.Now}
+}
+
+// Start ends the current session, if any, and begins following trackID
+func (t *Tracker) Start(trackID string) error {
+    t.mtx.Lock()
+    defer t.mtx.Unlock()
+    err := t.finishLocked()
+    t.trackID = trackID
+    t.duration, t.listened, t.lastPos, t.recorded = 0, 0, 0, false
+    return err
+}
+
+// Following returns the ID of the track being followed, or "" between sessions
+func (t *Tracker) Following() string {
+    t.mtx.Lock()
+    defer t.mtx.Unlock()
+    return t.trackID
+}
+
+// Progress accumulates listening time from a position report in seconds
+func (t *Tracker) Progress(pos, duration float64) error {
+    t.mtx.Lock()
+    defer t.mtx.Unlock()
+    if t.trackID == "" {
+        return nil
+    }
+    if duration > 0 {
+        t.duration = duration
+    }
+    if d := pos - t.lastPos; d > 0 && d <= maxProgressStep {
+        t.listened += d
+    }
+    t.lastPos = pos
+    if t.recorded || !t.passed() {
+        return nil
+    }
+    return t.recordLocked(Play)
+}
+
+// Seek moves the reference position without counting the jump as listening
+func (t *Tracker) Seek(pos float64) {
+    t.mtx.Lock()
+    defer t.mtx.Unlock()
+    t.lastPos = pos
+}
+
+// Finish ends the current session, recording a skip if it never reached the threshold
+func (t *Tracker) Finish() error {
+    t.mtx.Lock()
+    defer t.mtx.Unlock()
+    err := t.finishLocked()
+    t.trackID = ""
+    return err
+}
+
+func (t *Tracker) passed() bool {
+    need := t.threshold.Seconds
+    if t.duration > 0 && t.threshold.Fraction > 0 {
+        need = math.Min(need, t.duration*t.threshold.Fraction)
+    }
+    return need > 0 && t.listened >= need
+}
+
+func (t *Tracker) finishLocked() error {
+    if t.trackID == "" || t.recorded {
+        return nil
+    }
+    return t.recordLocked(Skip)
+}
+
+func (t *Tracker) recordLocked(typ string) error {
+    t.recorded = true
+    ev := Event{TrackID: t.trackID, Type: typ, Time: t.now(), Listened: t.listened}
+    if err := t.store.Record(ev); err != nil {
+        return err
+    }
+    if t.OnRecord != nil {
+        t.OnRecord(ev)
+    }
+    return nil
+}
@@ -0,0 +1,343 @@
+package subsonic
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"penguin-tunes/pkg/audio"
+	"penguin-tunes/pkg/indexer"
+)
+
+// ignoredArticles are skipped when artists are sorted and indexed
+var ignoredArticles = []string{"The", "El", "La", "Los", "Las", "Le", "Les"}
+
+// library groups a snapshot of the index into the artists and albums the
+// ID3 browsing calls expose. Artists are album artists, falling back to the
+// track artist, so compilations stay together.
+type library struct {
+    artists []*artist
+    byID    map[string]*artist
+    albums  map[string]*album
+}
+
+type artist struct {
+    id, name string
+    albums   []*album
+}
+
+type album struct {
+    id, name string
+    artist   *artist
+    songs    []*indexer.Track
+}
+
+// artistID and albumID derive stable IDs from names, with a prefix that keeps
+// them apart from track IDs
+func artistID(name string) string {
+    return "ar-" + shortHash(strings.ToLower(name))
+}
+
+func albumID(artist, name string) string {
+    return "al-" + shortHash(strings.ToLower(artist)+"\x00"+strings.ToLower(name))
+}
+
+func shortHash(s string) string {
+    h := sha1.Sum([]byte(s))
+    return hex.EncodeToString(h[:10])
+}
+
+func albumArtist(t *indexer.Track) string {
+    if t.AlbumArtist != "" {
+        return t.AlbumArtist
+    }
+    return t.Artist
+}
+
+func (s *Server) library() *library {
+    lib := &library{byID: make(map[string]*artist), albums: make(map[string]*album)}
+    for _, t := range s.idx.GetAll() {
+        name := albumArtist(t)
+        aid := artistID(name)
+        ar, ok := lib.byID[aid]
+        if !ok {
+            ar = &artist{id: aid, name: name}
+            lib.byID[aid] = ar
+            lib.artists = append(lib.artists, ar)
+        }
+        alid := albumID(name, t.Album)
+        al, ok := lib.albums[alid]
+        if !ok {
+            al = &album{id: alid, name: t.Album, artist: ar}
+            lib.albums[alid] = al
+            ar.albums = append(ar.albums, al)
+        }
+        al.songs = append(al.songs, t)
+    }
+    sort.Slice(lib.artists, func(i, j int) bool { return sortName(lib.artists[i].name) < sortName(lib.artists[j].name) })
+    for _, ar := range lib.artists {
+        sort.Slice(ar.albums, func(i, j int) bool { return strings.ToLower(ar.albums[i].name) < strings.ToLower(ar.albums[j].name) })
+    }
+    for _, al := range lib.albums {
+        sortSongs(al.songs)
+    }
+    return lib
+}
+
+// sortSongs orders album tracks by disc, track number and title
+func sortSongs(songs []*indexer.Track) {
+    sort.Slice(songs, func(i, j int) bool {
+        a, b := songs[i], songs[j]
+        if a.DiscNumber != b.DiscNumber {
+            return a.DiscNumber < b.DiscNumber
+        }
+        if a.TrackNumber != b.TrackNumber {
+            return a.TrackNumber < b.TrackNumber
+        }
+        return a.Title < b.Title
+    })
+}
+
+// sortName lower-cases name and drops a leading article
+func sortName(name string) string {
+    for _, a := range ignoredArticles {
+        if len(name) > len(a)+1 && strings.EqualFold(name[:len(a)+1], a+" ") {
+            name = name[len(a)+1:]
+            break
+        }
+    }
+    return strings.ToLower(name)
+}
+
+func (s *Server) artistBody(ar *artist) artistID3 {
+    out := artistID3{ID: ar.id, Name: ar.name, AlbumCount: len(ar.albums)}
+    if len(ar.albums) > 0 {
+        out.CoverArt = ar.albums[0].coverArt()
+    }
+    return out
+}
+
+// coverArt is the album ID when one of its songs has a cover
+func (al *album) coverArt() string {
+    for _, t := range al.songs {
+        if t.Cover != "" {
+            return al.id
+        }
+    }
+    return ""
+}
+
+func (s *Server) albumBody(al *album) albumID3 {
+    out := albumID3{ID: al.id, Name: al.name, Artist: al.artist.name, ArtistID: al.artist.id, CoverArt: al.coverArt(), SongCount: len(al.songs)}
+    var created time.Time
+    for _, t := range al.songs {
+        out.Duration += int(t.Duration)
+        out.Year = max(out.Year, t.Year)
+        if out.Genre == "" {
+            out.Genre = t.Genre
+        }
+        if fi, err := os.Stat(t.Path); err == nil && (created.IsZero() || fi.ModTime().Before(created)) {
+            created = fi.ModTime()
+        }
+    }
+    out.Created = created.UTC().Format(time.RFC3339)
+    return out
+}
+
+// contentTypes maps file extensions to the MIME types clients expect
+var contentTypes = map[string]string{
+    "mp3": "audio/mpeg", "flac": "audio/flac", "ogg": "audio/ogg", "opus": "audio/ogg",
+    "m4a": "audio/mp4", "aac": "audio/aac", "wav": "audio/wav", "wma": "audio/x-ms-wma",
+}
+
+func (s *Server) songBody(t *indexer.Track) child {
+    name := albumArtist(t)
+    suffix := strings.TrimPrefix(strings.ToLower(filepath.Ext(t.Path)), ".")
+    c := child{
+        ID:          t.ID,
+        Parent:      albumID(name, t.Album),
+        Title:       t.Title,
+        Album:       t.Album,
+        Artist:      t.Artist,
+        Track:       t.TrackNumber,
+        Year:        t.Year,
+        Genre:       t.Genre,
+        Suffix:      suffix,
+        ContentType: contentTypes[suffix],
+        Duration:    int(t.Duration),
+        BitRate:     t.Bitrate,
+        Path:        filepath.ToSlash(filepath.Join(name, t.Album, filepath.Base(t.Path))),
+        DiscNumber:  t.DiscNumber,
+        AlbumID:     albumID(name, t.Album),
+        ArtistID:    artistID(name),
+        Type:        "music",
+        UserRating:  t.Rating,
+        BPM:         int(t.BPM + 0.5),
+    }
+    if t.Cover != "" {
+        c.CoverArt = t.ID
+    }
+    if fi, err := os.Stat(t.Path); err == nil {
+        c.Size = fi.Size()
+    }
+    if t.Virtual() && audio.Supported(t.Path) {
+        // parts of a file are cut out and sent as PCM
+        c.TranscodedSuffix, c.TranscodedContentType = "wav", contentTypes["wav"]
+    }
+    if s.opts.Stats != nil {
+        c.PlayCount = s.opts.Stats.Get(t.ID).PlayCount
+    }
+    return c
+}
+
+func (s *Server) getArtists(w http.ResponseWriter, r *http.Request) (*response, error) {
+    lib := s.library()
+    body := &artistsID3{IgnoredArticles: strings.Join(ignoredArticles, " "), Index: []artistIndex{}}
+    // names not starting with a letter share "#", listed first
+    pos := make(map[string]int)
+    for _, ar := range lib.artists {
+        key := "#"
+        if name := []rune(sortName(ar.name)); len(name) > 0 && unicode.IsLetter(name[0]) {
+            key = strings.ToUpper(string(name[0]))
+        }
+        i, ok := pos[key]
+        if !ok {
+            i = len(body.Index)
+            pos[key] = i
+            body.Index = append(body.Index, artistIndex{Name: key})
+        }
+        body.Index[i].Artists = append(body.Index[i].Artists, s.artistBody(ar))
+    }
+    sort.SliceStable(body.Index, func(i, j int) bool {
+        a, b := body.Index[i].Name, body.Index[j].Name
+        return a != b && (a == "#" || (b != "#" && a < b))
+    })
+    resp := s.ok()
+    resp.Artists = body
+    return resp, nil
+}
+
+func (s *Server) getArtist(w http.ResponseWriter, r *http.Request) (*response, error) {
+    id := r.Form.Get("id")
+    if id == "" {
+        return nil, missing("id")
+    }
+    ar, ok := s.library().byID[id]
+    if !ok {
+        return nil, notFound("artist")
+    }
+    body := s.artistBody(ar)
+    body.Albums = []albumID3{}
+    for _, al := range ar.albums {
+        body.Albums = append(body.Albums, s.albumBody(al))
+    }
+    resp := s.ok()
+    resp.Artist = &body
+    return resp, nil
+}
+
+func (s *Server) getAlbum(w http.ResponseWriter, r *http.Request) (*response, error) {
+    id := r.Form.Get("id")
+    if id == "" {
+        return nil, missing("id")
+    }
+    al, ok := s.library().albums[id]
+    if !ok {
+        return nil, notFound("album")
+    }
+    body := s.albumBody(al)
+    for _, t := range al.songs {
+        body.Songs = append(body.Songs, s.songBody(t))
+    }
+    resp := s.ok()
+    resp.Album = &body
+    return resp, nil
+}
+
+func (s *Server) getSong(w http.ResponseWriter, r *http.Request) (*response, error) {
+    id := r.Form.Get("id")
+    if id == "" {
+        return nil, missing("id")
+    }
+    t := s.idx.GetByID(id)
+    if t == nil {
+        return nil, notFound("song")
+    }
+    body := s.songBody(t)
+    resp := s.ok()
+    resp.Song = &body
+    return resp, nil
+}
+
+// search3 matches every word of query against names; an empty query (or
+// "" as clients send it when syncing) lists everything page by page
+func (s *Server) search3(w http.ResponseWriter, r *http.Request) (*response, error) {
+    query := strings.Trim(strings.TrimSpace(r.Form.Get("query")), `"`)
+    var page [6]int
+    for i, p := range []struct {
+        name string
+        def  int
+    }{{"artistCount", 20}, {"artistOffset", 0}, {"albumCount", 20}, {"albumOffset", 0}, {"songCount", 20}, {"songOffset", 0}} {
+        n, err := intParam(r, p.name, p.def)
+        if err != nil {
+            return nil, err
+        }
+        page[i] = max(0, n)
+    }
+    words := strings.Fields(strings.ToLower(query))
+    matches := func(fields ...string) bool {
+        text := strings.ToLower(strings.Join(fields, " "))
+        for _, w := range words {
+            if !strings.Contains(text, w) {
+                return false
+            }
+        }
+        return true
+    }
+    lib := s.library()
+    body := &searchResult3{Artists: []artistID3{}, Albums: []albumID3{}, Songs: []child{}}
+    var artists []*artist
+    var albums []*album
+    var songs []*indexer.Track
+    for _, ar := range lib.artists {
+        if matches(ar.name) {
+            artists = append(artists, ar)
+        }
+        for _, al := range ar.albums {
+            if matches(al.name, ar.name) {
+                albums = append(albums, al)
+            }
+            for _, t := range al.songs {
+                if matches(t.Title, t.Artist, t.Album) {
+                    songs = append(songs, t)
+                }
+            }
+        }
+    }
+    for _, ar := range window(artists, page[1], page[0]) {
+        body.Artists = append(body.Artists, s.artistBody(ar))
+    }
+    for _, al := range window(albums, page[3], page[2]) {
+        body.Albums = append(body.Albums, s.albumBody(al))
+    }
+    for _, t := range window(songs, page[5], page[4]) {
+        body.Songs = append(body.Songs, s.songBody(t))
+    }
+    resp := s.ok()
+    resp.SearchResult3 = body
+    return resp, nil
+}
+
+// window returns up to count items of list starting at offset
+func window[T any](list []T, offset, count int) []T {
+    if offset >= len(list) {
+        return nil
+    }
+    return list[offset:min(len(list), offset+count)]
+}
@@ -0,0 +1,155 @@
+package subsonic
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"penguin-tunes/pkg/audio"
+	"penguin-tunes/pkg/indexer"
+)
+
+// stream sends the file of a song as it is, with range support; there is no
+// transcoding. Tracks cut from a larger file by a CUE sheet are decoded and
+// sent as 16-bit WAV.
+func (s *Server) stream(w http.ResponseWriter, r *http.Request) (*response, error) {
+    id := r.Form.Get("id")
+    if id == "" {
+        return nil, missing("id")
+    }
+    t := s.idx.GetByID(id)
+    if t == nil {
+        return nil, notFound("song")
+    }
+    if t.Virtual() {
+        return nil, s.streamSection(w, t)
+    }
+    f, err := os.Open(t.Path)
+    if err != nil {
+        return nil, notFound("file")
+    }
+    defer f.Close()
+    fi, err := f.Stat()
+    if err != nil {
+        return nil, err
+    }
+    suffix := strings.TrimPrefix(strings.ToLower(filepath.Ext(t.Path)), ".")
+    if ct := contentTypes[suffix]; ct != "" {
+        w.Header().Set("Content-Type", ct)
+    }
+    http.ServeContent(w, r, filepath.Base(t.Path), fi.ModTime(), f)
+    return nil, nil
+}
+
+// streamSection decodes the range of a virtual track and writes it as WAV
+func (s *Server) streamSection(w http.ResponseWriter, t *indexer.Track) error {
+    if !audio.Supported(t.Path) {
+        return &apiErr{errGeneric, "this part of a CUE sheet can't be cut from its file"}
+    }
+    st, err := audio.Open(t.Path)
+    if err != nil {
+        return notFound("file")
+    }
+    defer st.Close()
+    frames := int(math.Round(t.Duration * float64(st.SampleRate())))
+    if t.End > 0 {
+        frames = int(t.End*float64(st.SampleRate())) - int(t.Start*float64(st.SampleRate()))
+    }
+    size := wavHeaderSize + frames*st.Channels()*2
+    w.Header().Set("Content-Type", contentTypes["wav"])
+    w.Header().Set("Content-Length", strconv.Itoa(size))
+    // once the header is out, a decoding error can only cut the body short
+    writeWAV(w, audio.Section(st, t.Start, t.End), frames)
+    return nil
+}
+
+const wavHeaderSize = 44
+
+// writeWAV writes exactly frames frames of s as 16-bit PCM, padding with
+// silence when the stream ends early
+func writeWAV(w io.Writer, s audio.Stream, frames int) error {
+    ch, rate := s.Channels(), s.SampleRate()
+    data := frames * ch * 2
+    bw := bufio.NewWriter(w)
+    bw.WriteString("RIFF")
+    binary.Write(bw, binary.LittleEndian, uint32(36+data))
+    bw.WriteString("WAVEfmt ")
+    binary.Write(bw, binary.LittleEndian, []uint32{16})
+    binary.Write(bw, binary.LittleEndian, []uint16{1, uint16(ch)})
+    binary.Write(bw, binary.LittleEndian, []uint32{uint32(rate), uint32(rate * ch * 2)})
+    binary.Write(bw, binary.LittleEndian, []uint16{uint16(ch * 2), 16})
+    bw.WriteString("data")
+    binary.Write(bw, binary.LittleEndian, uint32(data))
+    buf := make([]float32, 4096*ch)
+    out := make([]byte, 2*len(buf))
+    left := frames * ch
+    var err error
+    for left > 0 && err == nil {
+        var n int
+        n, err = s.Read(buf[:min(len(buf), left)])
+        for i, v := range buf[:n] {
+            v = max(-1, min(1, v))
+            binary.LittleEndian.PutUint16(out[2*i:], uint16(int16(v*32767)))
+        }
+        if _, werr := bw.Write(out[:2*n]); werr != nil {
+            return werr
+        }
+        left -= n
+    }
+    if err != nil && err != io.EOF {
+        return err
+    }
+    for i := range out {
+        out[i] = 0
+    }
+    for left > 0 {
+        n := min(left, len(out)/2)
+        if _, err := bw.Write(out[:2*n]); err != nil {
+            return err
+        }
+        left -= n
+    }
+    return bw.Flush()
+}
+
+// getCoverArt serves the cover of a song, or of the first song of an album
+// or artist that has one; size is ignored
+func (s *Server) getCoverArt(w http.ResponseWriter, r *http.Request) (*response, error) {
+    id := r.Form.Get("id")
+    if id == "" {
+        return nil, missing("id")
+    }
+    var songs []*indexer.Track
+    switch {
+    case strings.HasPrefix(id, "al-"):
+        if al, ok := s.library().albums[id]; ok {
+            songs = al.songs
+        }
+    case strings.HasPrefix(id, "ar-"):
+        if ar, ok := s.library().byID[id]; ok {
+            for _, al := range ar.albums {
+                songs = append(songs, al.songs...)
+            }
+        }
+    default:
+        if t := s.idx.GetByID(id); t != nil {
+            songs = []*indexer.Track{t}
+        }
+    }
+    for _, t := range songs {
+        if t.Cover == "" {
+            continue
+        }
+        if _, err := os.Stat(t.Cover); err == nil {
+            http.ServeFile(w, r, t.Cover)
+            return nil, nil
+        }
+    }
+    return nil, notFound("cover art")
+}
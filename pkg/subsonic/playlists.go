@@ -0,0 +1,229 @@
+package subsonic
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"penguin-tunes/pkg/indexer"
+	"penguin-tunes/pkg/playlist"
+	"penguin-tunes/pkg/stats"
+)
+
+var errNoPlaylists = &apiErr{errGeneric, "playlists are not available"}
+
+func (s *Server) playlistBody(p playlist.Playlist, entries bool) playlistBody {
+    body := playlistBody{
+        ID:      p.ID,
+        Name:    p.Name,
+        Owner:   s.opts.Username,
+        Created: p.Created.UTC().Format(time.RFC3339),
+        Changed: p.Updated.UTC().Format(time.RFC3339),
+    }
+    // missing entries are left out, as clients can't play them
+    for _, it := range playlist.Resolve(p, s.idx) {
+        if it.Track == nil {
+            continue
+        }
+        body.SongCount++
+        body.Duration += int(it.Track.Duration)
+        if entries {
+            body.Entries = append(body.Entries, s.songBody(it.Track))
+        }
+    }
+    return body
+}
+
+func (s *Server) getPlaylists(w http.ResponseWriter, r *http.Request) (*response, error) {
+    body := &playlists{Playlists: []playlistBody{}}
+    if s.opts.Playlists != nil {
+        for _, p := range s.opts.Playlists.List() {
+            body.Playlists = append(body.Playlists, s.playlistBody(p, false))
+        }
+    }
+    resp := s.ok()
+    resp.Playlists = body
+    return resp, nil
+}
+
+func (s *Server) getPlaylist(w http.ResponseWriter, r *http.Request) (*response, error) {
+    if s.opts.Playlists == nil {
+        return nil, errNoPlaylists
+    }
+    id := r.Form.Get("id")
+    if id == "" {
+        return nil, missing("id")
+    }
+    return s.playlistResponse(id)
+}
+
+func (s *Server) playlistResponse(id string) (*response, error) {
+    p, err := s.opts.Playlists.Get(id)
+    if err != nil {
+        return nil, notFound("playlist")
+    }
+    body := s.playlistBody(p, true)
+    resp := s.ok()
+    resp.Playlist = &body
+    return resp, nil
+}
+
+// songs looks up the tracks named by every value of param
+func (s *Server) songs(r *http.Request, param string) ([]*indexer.Track, error) {
+    var out []*indexer.Track
+    for _, id := range r.Form[param] {
+        t := s.idx.GetByID(id)
+        if t == nil {
+            return nil, notFound("song " + id)
+        }
+        out = append(out, t)
+    }
+    return out, nil
+}
+
+// createPlaylist makes a playlist of songId, or replaces the songs of playlistId
+func (s *Server) createPlaylist(w http.ResponseWriter, r *http.Request) (*response, error) {
+    if s.opts.Playlists == nil {
+        return nil, errNoPlaylists
+    }
+    tracks, err := s.songs(r, "songId")
+    if err != nil {
+        return nil, err
+    }
+    id := r.Form.Get("playlistId")
+    if id == "" {
+        name := r.Form.Get("name")
+        if name == "" {
+            return nil, missing("name")
+        }
+        p, err := s.opts.Playlists.Create(name)
+        if err != nil {
+            return nil, err
+        }
+        id = p.ID
+    } else {
+        p, err := s.opts.Playlists.Get(id)
+        if err != nil {
+            return nil, notFound("playlist")
+        }
+        if name := r.Form.Get("name"); name != "" && name != p.Name {
+            if err := s.opts.Playlists.Rename(id, name); err != nil {
+                return nil, err
+            }
+        }
+        all := make([]int, len(p.Entries))
+        for i := range all {
+            all[i] = i
+        }
+        if err := s.opts.Playlists.Remove(id, all); err != nil {
+            return nil, err
+        }
+    }
+    if len(tracks) > 0 {
+        if err := s.opts.Playlists.Add(id, tracks, -1); err != nil {
+            return nil, err
+        }
+    }
+    s.playlistsChanged()
+    return s.playlistResponse(id)
+}
+
+// updatePlaylist renames a playlist, removes songIndexToRemove positions and
+// appends songIdToAdd; removals refer to positions before anything is added
+func (s *Server) updatePlaylist(w http.ResponseWriter, r *http.Request) (*response, error) {
+    if s.opts.Playlists == nil {
+        return nil, errNoPlaylists
+    }
+    id := r.Form.Get("playlistId")
+    if id == "" {
+        return nil, missing("playlistId")
+    }
+    if _, err := s.opts.Playlists.Get(id); err != nil {
+        return nil, notFound("playlist")
+    }
+    tracks, err := s.songs(r, "songIdToAdd")
+    if err != nil {
+        return nil, err
+    }
+    var remove []int
+    for _, v := range r.Form["songIndexToRemove"] {
+        n, err := strconv.Atoi(v)
+        if err != nil {
+            return nil, &apiErr{errGeneric, "invalid songIndexToRemove: " + v}
+        }
+        remove = append(remove, n)
+    }
+    if name := r.Form.Get("name"); name != "" {
+        if err := s.opts.Playlists.Rename(id, name); err != nil {
+            return nil, err
+        }
+    }
+    if len(remove) > 0 {
+        if err := s.opts.Playlists.Remove(id, remove); err != nil {
+            return nil, err
+        }
+    }
+    if len(tracks) > 0 {
+        if err := s.opts.Playlists.Add(id, tracks, -1); err != nil {
+            return nil, err
+        }
+    }
+    s.playlistsChanged()
+    return s.ok(), nil
+}
+
+func (s *Server) deletePlaylist(w http.ResponseWriter, r *http.Request) (*response, error) {
+    if s.opts.Playlists == nil {
+        return nil, errNoPlaylists
+    }
+    id := r.Form.Get("id")
+    if id == "" {
+        return nil, missing("id")
+    }
+    if err := s.opts.Playlists.Delete(id); err != nil {
+        return nil, notFound("playlist")
+    }
+    s.playlistsChanged()
+    return s.ok(), nil
+}
+
+func (s *Server) playlistsChanged() {
+    if s.opts.OnPlaylistsChanged != nil {
+        s.opts.OnPlaylistsChanged()
+    }
+}
+
+// scrobble records plays of id at time (milliseconds since the epoch, now
+// when absent). Now-playing notifications (submission=false) are accepted
+// and ignored.
+func (s *Server) scrobble(w http.ResponseWriter, r *http.Request) (*response, error) {
+    ids := r.Form["id"]
+    if len(ids) == 0 {
+        return nil, missing("id")
+    }
+    if r.Form.Get("submission") == "false" || s.opts.Stats == nil {
+        return s.ok(), nil
+    }
+    times := r.Form["time"]
+    for i, id := range ids {
+        t := s.idx.GetByID(id)
+        if t == nil {
+            return nil, notFound("song " + id)
+        }
+        ev := stats.Event{TrackID: id, Type: stats.Play, Listened: t.Duration}
+        if i < len(times) {
+            ms, err := strconv.ParseInt(times[i], 10, 64)
+            if err != nil {
+                return nil, &apiErr{errGeneric, "invalid time: " + times[i]}
+            }
+            ev.Time = time.UnixMilli(ms)
+        }
+        if err := s.opts.Stats.Record(ev); err != nil {
+            return nil, err
+        }
+    }
+    if s.opts.OnScrobble != nil {
+        s.opts.OnScrobble()
+    }
+    return s.ok(), nil
+}
@@ -0,0 +1,140 @@
+package subsonic
+
+import "encoding/xml"
+
+// response is the subsonic-response envelope; exactly one payload is set.
+// Fields carry both tags so one value renders as XML or JSON.
+type response struct {
+    XMLName       xml.Name `xml:"http://subsonic.org/restapi subsonic-response" json:"-"`
+    Status        string   `xml:"status,attr" json:"status"`
+    Version       string   `xml:"version,attr" json:"version"`
+    Type          string   `xml:"type,attr" json:"type"`
+    ServerVersion string   `xml:"serverVersion,attr" json:"serverVersion"`
+    OpenSubsonic  bool     `xml:"openSubsonic,attr" json:"openSubsonic"`
+
+    Error              *apiError      `xml:"error,omitempty" json:"error,omitempty"`
+    License            *license       `xml:"license,omitempty" json:"license,omitempty"`
+    Extensions         *[]extension   `xml:"openSubsonicExtensions,omitempty" json:"openSubsonicExtensions,omitempty"`
+    MusicFolders       *musicFolders  `xml:"musicFolders,omitempty" json:"musicFolders,omitempty"`
+    Artists            *artistsID3    `xml:"artists,omitempty" json:"artists,omitempty"`
+    Artist             *artistID3     `xml:"artist,omitempty" json:"artist,omitempty"`
+    Album              *albumID3      `xml:"album,omitempty" json:"album,omitempty"`
+    Song               *child         `xml:"song,omitempty" json:"song,omitempty"`
+    SearchResult3      *searchResult3 `xml:"searchResult3,omitempty" json:"searchResult3,omitempty"`
+    Playlists          *playlists     `xml:"playlists,omitempty" json:"playlists,omitempty"`
+    Playlist           *playlistBody  `xml:"playlist,omitempty" json:"playlist,omitempty"`
+}
+
+// Error codes from the Subsonic API
+const (
+    errGeneric      = 0
+    errMissingParam = 10
+    errAuth         = 40
+    errNotFound     = 70
+)
+
+type apiError struct {
+    Code    int    `xml:"code,attr" json:"code"`
+    Message string `xml:"message,attr" json:"message"`
+}
+
+type license struct {
+    Valid bool `xml:"valid,attr" json:"valid"`
+}
+
+type extension struct {
+    Name     string `xml:"name,attr" json:"name"`
+    Versions []int  `xml:"versions" json:"versions"`
+}
+
+type musicFolders struct {
+    Folders []musicFolder `xml:"musicFolder" json:"musicFolder"`
+}
+
+type musicFolder struct {
+    ID   int    `xml:"id,attr" json:"id"`
+    Name string `xml:"name,attr" json:"name"`
+}
+
+type artistsID3 struct {
+    IgnoredArticles string       `xml:"ignoredArticles,attr" json:"ignoredArticles"`
+    Index           []artistIndex `xml:"index" json:"index"`
+}
+
+type artistIndex struct {
+    Name    string      `xml:"name,attr" json:"name"`
+    Artists []artistID3 `xml:"artist" json:"artist"`
+}
+
+type artistID3 struct {
+    ID         string     `xml:"id,attr" json:"id"`
+    Name       string     `xml:"name,attr" json:"name"`
+    CoverArt   string     `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+    AlbumCount int        `xml:"albumCount,attr" json:"albumCount"`
+    Albums     []albumID3 `xml:"album,omitempty" json:"album,omitempty"`
+}
+
+type albumID3 struct {
+    ID        string  `xml:"id,attr" json:"id"`
+    Name      string  `xml:"name,attr" json:"name"`
+    Artist    string  `xml:"artist,attr,omitempty" json:"artist,omitempty"`
+    ArtistID  string  `xml:"artistId,attr,omitempty" json:"artistId,omitempty"`
+    CoverArt  string  `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+    SongCount int     `xml:"songCount,attr" json:"songCount"`
+    Duration  int     `xml:"duration,attr" json:"duration"`
+    Created   string  `xml:"created,attr" json:"created"`
+    Year      int     `xml:"year,attr,omitempty" json:"year,omitempty"`
+    Genre     string  `xml:"genre,attr,omitempty" json:"genre,omitempty"`
+    Songs     []child `xml:"song,omitempty" json:"song,omitempty"`
+}
+
+// child is a song in the Subsonic schema
+type child struct {
+    ID                    string `xml:"id,attr" json:"id"`
+    Parent                string `xml:"parent,attr,omitempty" json:"parent,omitempty"`
+    IsDir                 bool   `xml:"isDir,attr" json:"isDir"`
+    Title                 string `xml:"title,attr" json:"title"`
+    Album                 string `xml:"album,attr,omitempty" json:"album,omitempty"`
+    Artist                string `xml:"artist,attr,omitempty" json:"artist,omitempty"`
+    Track                 int    `xml:"track,attr,omitempty" json:"track,omitempty"`
+    Year                  int    `xml:"year,attr,omitempty" json:"year,omitempty"`
+    Genre                 string `xml:"genre,attr,omitempty" json:"genre,omitempty"`
+    CoverArt              string `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+    Size                  int64  `xml:"size,attr,omitempty" json:"size,omitempty"`
+    ContentType           string `xml:"contentType,attr,omitempty" json:"contentType,omitempty"`
+    Suffix                string `xml:"suffix,attr,omitempty" json:"suffix,omitempty"`
+    TranscodedContentType string `xml:"transcodedContentType,attr,omitempty" json:"transcodedContentType,omitempty"`
+    TranscodedSuffix      string `xml:"transcodedSuffix,attr,omitempty" json:"transcodedSuffix,omitempty"`
+    Duration              int    `xml:"duration,attr,omitempty" json:"duration,omitempty"`
+    BitRate               int    `xml:"bitRate,attr,omitempty" json:"bitRate,omitempty"`
+    Path                  string `xml:"path,attr,omitempty" json:"path,omitempty"`
+    PlayCount             int    `xml:"playCount,attr,omitempty" json:"playCount,omitempty"`
+    DiscNumber            int    `xml:"discNumber,attr,omitempty" json:"discNumber,omitempty"`
+    AlbumID               string `xml:"albumId,attr,omitempty" json:"albumId,omitempty"`
+    ArtistID              string `xml:"artistId,attr,omitempty" json:"artistId,omitempty"`
+    Type                  string `xml:"type,attr" json:"type"`
+    UserRating            int    `xml:"userRating,attr,omitempty" json:"userRating,omitempty"`
+    BPM                   int    `xml:"bpm,attr,omitempty" json:"bpm,omitempty"`
+}
+
+type searchResult3 struct {
+    Artists []artistID3 `xml:"artist" json:"artist"`
+    Albums  []albumID3  `xml:"album" json:"album"`
+    Songs   []child     `xml:"song" json:"song"`
+}
+
+type playlists struct {
+    Playlists []playlistBody `xml:"playlist" json:"playlist"`
+}
+
+type playlistBody struct {
+    ID        string  `xml:"id,attr" json:"id"`
+    Name      string  `xml:"name,attr" json:"name"`
+    Owner     string  `xml:"owner,attr,omitempty" json:"owner,omitempty"`
+    Public    bool    `xml:"public,attr" json:"public"`
+    SongCount int     `xml:"songCount,attr" json:"songCount"`
+    Duration  int     `xml:"duration,attr" json:"duration"`
+    Created   string  `xml:"created,attr" json:"created"`
+    Changed   string  `xml:"changed,attr" json:"changed"`
+    Entries   []child `xml:"entry,omitempty" json:"entry,omitempty"`
+}
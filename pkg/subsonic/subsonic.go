@@ -0,0 +1,215 @@
+package subsonic
+
+import (
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"penguin-tunes/pkg/indexer"
+	"penguin-tunes/pkg/playlist"
+	"penguin-tunes/pkg/stats"
+)
+
+// APIVersion is the Subsonic REST API version implemented
+const APIVersion = "1.16.1"
+
+// Options configures the server; Playlists and Stats may be nil, which
+// disables playlists and scrobbling
+type Options struct {
+    Username  string
+    Password  string
+    Playlists *playlist.Store
+    Stats     *stats.Store
+    // ServerVersion is reported to clients alongside the API version
+    ServerVersion string
+    // OnPlaylistsChanged and OnScrobble, when set, are called after clients
+    // change playlists or record plays
+    OnPlaylistsChanged func()
+    OnScrobble         func()
+}
+
+// Server answers Subsonic REST calls under /rest/ from the index
+type Server struct {
+    idx      *indexer.Index
+    opts     Options
+    handlers map[string]handler
+}
+
+// handler serves one API method; it either writes a raw body and returns
+// nil, or returns the response to encode
+type handler func(w http.ResponseWriter, r *http.Request) (*response, error)
+
+// New creates a server for idx
+func New(idx *indexer.Index, opts Options) *Server {
+    s := &Server{idx: idx, opts: opts}
+    s.handlers = map[string]handler{
+        "ping":                      s.ping,
+        "getLicense":                s.getLicense,
+        "getOpenSubsonicExtensions": s.getExtensions,
+        "getMusicFolders":           s.getMusicFolders,
+        "getArtists":                s.getArtists,
+        "getArtist":                 s.getArtist,
+        "getAlbum":                  s.getAlbum,
+        "getSong":                   s.getSong,
+        "search3":                   s.search3,
+        "stream":                    s.stream,
+        "download":                  s.stream,
+        "getCoverArt":               s.getCoverArt,
+        "getPlaylists":              s.getPlaylists,
+        "getPlaylist":               s.getPlaylist,
+        "createPlaylist":            s.createPlaylist,
+        "updatePlaylist":            s.updatePlaylist,
+        "deletePlaylist":            s.deletePlaylist,
+        "scrobble":                  s.scrobble,
+    }
+    return s
+}
+
+// apiErr is an error reported to the client with a Subsonic error code
+type apiErr struct {
+    code int
+    msg  string
+}
+
+func (e *apiErr) Error() string { return e.msg }
+
+func missing(name string) error {
+    return &apiErr{errMissingParam, "required parameter is missing: " + name}
+}
+
+func notFound(what string) error {
+    return &apiErr{errNotFound, what + " not found"}
+}
+
+// ServeHTTP dispatches /rest/<method> and /rest/<method>.view
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/rest/"), ".view")
+    if err := r.ParseForm(); err != nil {
+        s.fail(w, r, &apiErr{errGeneric, err.Error()})
+        return
+    }
+    h, ok := s.handlers[name]
+    if !ok || !strings.HasPrefix(r.URL.Path, "/rest/") {
+        http.NotFound(w, r)
+        return
+    }
+    if err := s.authenticate(r); err != nil {
+        s.fail(w, r, err)
+        return
+    }
+    resp, err := h(w, r)
+    if err != nil {
+        s.fail(w, r, err)
+        return
+    }
+    if resp != nil {
+        s.write(w, r, resp)
+    }
+}
+
+// authenticate checks u with either the salted token (t, s) or the password
+// (p, optionally hex encoded behind "enc:")
+func (s *Server) authenticate(r *http.Request) error {
+    user := r.Form.Get("u")
+    if user == "" {
+        return missing("u")
+    }
+    var ok bool
+    switch token, salt, pass := r.Form.Get("t"), r.Form.Get("s"), r.Form.Get("p"); {
+    case token != "" && salt != "":
+        sum := md5.Sum([]byte(s.opts.Password + salt))
+        ok = equal(strings.ToLower(token), hex.EncodeToString(sum[:]))
+    case pass != "":
+        if enc, found := strings.CutPrefix(pass, "enc:"); found {
+            b, err := hex.DecodeString(enc)
+            if err != nil {
+                return &apiErr{errAuth, "wrong username or password"}
+            }
+            pass = string(b)
+        }
+        ok = equal(pass, s.opts.Password)
+    default:
+        return missing("t")
+    }
+    if !ok || !equal(user, s.opts.Username) || s.opts.Password == "" {
+        return &apiErr{errAuth, "wrong username or password"}
+    }
+    return nil
+}
+
+func equal(a, b string) bool {
+    return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func (s *Server) ok() *response {
+    v := s.opts.ServerVersion
+    if v == "" {
+        v = "dev"
+    }
+    return &response{Status: "ok", Version: APIVersion, Type: "penguin-tunes", ServerVersion: v, OpenSubsonic: true}
+}
+
+func (s *Server) fail(w http.ResponseWriter, r *http.Request, err error) {
+    e, ok := err.(*apiErr)
+    if !ok {
+        e = &apiErr{errGeneric, err.Error()}
+    }
+    resp := s.ok()
+    resp.Status = "failed"
+    resp.Error = &apiError{Code: e.code, Message: e.msg}
+    s.write(w, r, resp)
+}
+
+// write encodes resp in the format asked for by f; errors are reported in
+// the body too, so the status is always 200
+func (s *Server) write(w http.ResponseWriter, r *http.Request, resp *response) {
+    switch r.Form.Get("f") {
+    case "json":
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(map[string]*response{"subsonic-response": resp})
+    default:
+        w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+        w.Write([]byte(xml.Header))
+        xml.NewEncoder(w).Encode(resp)
+    }
+}
+
+// intParam reads an integer parameter, def when it is absent
+func intParam(r *http.Request, name string, def int) (int, error) {
+    v := r.Form.Get(name)
+    if v == "" {
+        return def, nil
+    }
+    n, err := strconv.Atoi(v)
+    if err != nil {
+        return 0, &apiErr{errGeneric, "invalid " + name + ": " + v}
+    }
+    return n, nil
+}
+
+func (s *Server) ping(w http.ResponseWriter, r *http.Request) (*response, error) {
+    return s.ok(), nil
+}
+
+func (s *Server) getLicense(w http.ResponseWriter, r *http.Request) (*response, error) {
+    resp := s.ok()
+    resp.License = &license{Valid: true}
+    return resp, nil
+}
+
+func (s *Server) getExtensions(w http.ResponseWriter, r *http.Request) (*response, error) {
+    resp := s.ok()
+    resp.Extensions = &[]extension{}
+    return resp, nil
+}
+
+func (s *Server) getMusicFolders(w http.ResponseWriter, r *http.Request) (*response, error) {
+    resp := s.ok()
+    resp.MusicFolders = &musicFolders{Folders: []musicFolder{{ID: 1, Name: "Music"}}}
+    return resp, nil
+}
@@ -0,0 +1,208 @@
+package subsonic
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"penguin-tunes/pkg/indexer"
+	"penguin-tunes/pkg/playlist"
+	"penguin-tunes/pkg/stats"
+)
+
+func newTestServer(t *testing.T) (*httptest.Server, *Server) {
+    base := t.TempDir()
+    idx := indexer.NewIndexAtBase(base)
+    song := filepath.Join(base, "one.mp3")
+    if err := os.WriteFile(song, []byte("0123456789"), 0644); err != nil {
+        t.Fatalf("write: %v", err)
+    }
+    idx.AddOrUpdateTrack(&indexer.Track{ID: "t1", Path: song, Title: "One", Artist: "The Band", Album: "First", TrackNumber: 1, Duration: 180})
+    idx.AddOrUpdateTrack(&indexer.Track{ID: "t2", Path: filepath.Join(base, "two.mp3"), Title: "Two", Artist: "The Band", Album: "First", TrackNumber: 2, Duration: 200})
+    idx.AddOrUpdateTrack(&indexer.Track{ID: "t3", Path: filepath.Join(base, "solo.mp3"), Title: "Solo", Artist: "4 Hands", Album: "Numbers"})
+    st := stats.NewStoreAtBase(base)
+    // don't leave a delayed save running past the temp dir
+    t.Cleanup(func() { st.Flush() })
+    s := New(idx, Options{
+        Username:  "alice",
+        Password:  "sesame",
+        Playlists: playlist.NewStoreAtBase(base),
+        Stats:     st,
+    })
+    ts := httptest.NewServer(s)
+    t.Cleanup(ts.Close)
+    return ts, s
+}
+
+// call requests method with token auth and decodes the JSON response
+func call(t *testing.T, ts *httptest.Server, method string, params url.Values) response {
+    t.Helper()
+    if params == nil {
+        params = url.Values{}
+    }
+    sum := md5.Sum([]byte("sesame" + "c19b2d"))
+    params.Set("u", "alice")
+    params.Set("t", hex.EncodeToString(sum[:]))
+    params.Set("s", "c19b2d")
+    params.Set("f", "json")
+    res, err := http.Get(ts.URL + "/rest/" + method + ".view?" + params.Encode())
+    if err != nil {
+        t.Fatalf("%s: %v", method, err)
+    }
+    defer res.Body.Close()
+    var body map[string]response
+    if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+        t.Fatalf("%s: decode: %v", method, err)
+    }
+    return body["subsonic-response"]
+}
+
+func TestAuthentication(t *testing.T) {
+    ts, _ := newTestServer(t)
+    if resp := call(t, ts, "ping", nil); resp.Status != "ok" || resp.Version != APIVersion {
+        t.Fatalf("expected ok ping, got %+v", resp)
+    }
+
+    for _, q := range []string{
+        "u=alice&p=wrong",
+        "u=bob&p=sesame",
+        "u=alice&t=00&s=c19b2d",
+        "u=alice",
+    } {
+        res, err := http.Get(ts.URL + "/rest/ping?" + q)
+        if err != nil {
+            t.Fatalf("ping: %v", err)
+        }
+        var resp response
+        err = xml.NewDecoder(res.Body).Decode(&resp)
+        res.Body.Close()
+        if err != nil {
+            t.Fatalf("decode %q: %v", q, err)
+        }
+        if resp.Status != "failed" || resp.Error == nil {
+            t.Fatalf("expected %q to fail, got %+v", q, resp)
+        }
+    }
+
+    // plain and hex encoded passwords
+    for _, q := range []string{"u=alice&p=sesame", "u=alice&p=enc:" + hex.EncodeToString([]byte("sesame"))} {
+        res, err := http.Get(ts.URL + "/rest/ping.view?" + q)
+        if err != nil {
+            t.Fatalf("ping: %v", err)
+        }
+        var resp response
+        xml.NewDecoder(res.Body).Decode(&resp)
+        res.Body.Close()
+        if resp.Status != "ok" {
+            t.Fatalf("expected %q to pass, got %+v", q, resp)
+        }
+    }
+}
+
+func TestBrowse(t *testing.T) {
+    ts, _ := newTestServer(t)
+    resp := call(t, ts, "getArtists", nil)
+    if resp.Artists == nil || len(resp.Artists.Index) != 2 {
+        t.Fatalf("expected two index entries, got %+v", resp.Artists)
+    }
+    if idx := resp.Artists.Index; idx[0].Name != "#" || idx[1].Name != "B" || idx[1].Artists[0].Name != "The Band" {
+        t.Fatalf("unexpected index %+v", idx)
+    }
+
+    band := resp.Artists.Index[1].Artists[0]
+    resp = call(t, ts, "getArtist", url.Values{"id": {band.ID}})
+    if resp.Artist == nil || len(resp.Artist.Albums) != 1 {
+        t.Fatalf("expected one album, got %+v", resp.Artist)
+    }
+    resp = call(t, ts, "getAlbum", url.Values{"id": {resp.Artist.Albums[0].ID}})
+    if resp.Album == nil || len(resp.Album.Songs) != 2 || resp.Album.Songs[0].ID != "t1" || resp.Album.Duration != 380 {
+        t.Fatalf("unexpected album %+v", resp.Album)
+    }
+
+    resp = call(t, ts, "getSong", url.Values{"id": {"t1"}})
+    if resp.Song == nil || resp.Song.Suffix != "mp3" || resp.Song.Size != 10 {
+        t.Fatalf("unexpected song %+v", resp.Song)
+    }
+    resp = call(t, ts, "getSong", url.Values{"id": {"nope"}})
+    if resp.Error == nil || resp.Error.Code != errNotFound {
+        t.Fatalf("expected not found, got %+v", resp)
+    }
+
+    resp = call(t, ts, "search3", url.Values{"query": {"band tw"}})
+    if r := resp.SearchResult3; r == nil || len(r.Songs) != 1 || r.Songs[0].ID != "t2" || len(r.Albums) != 0 {
+        t.Fatalf("unexpected search result %+v", resp.SearchResult3)
+    }
+    resp = call(t, ts, "search3", url.Values{"query": {`""`}, "songCount": {"2"}, "songOffset": {"1"}})
+    if r := resp.SearchResult3; r == nil || len(r.Songs) != 2 || len(r.Artists) != 2 {
+        t.Fatalf("unexpected listing %+v", resp.SearchResult3)
+    }
+}
+
+func TestStreamRange(t *testing.T) {
+    ts, _ := newTestServer(t)
+    sum := md5.Sum([]byte("sesame" + "x"))
+    req, _ := http.NewRequest("GET", ts.URL+"/rest/stream?u=alice&s=x&t="+hex.EncodeToString(sum[:])+"&id=t1", nil)
+    req.Header.Set("Range", "bytes=2-5")
+    res, err := http.DefaultClient.Do(req)
+    if err != nil {
+        t.Fatalf("stream: %v", err)
+    }
+    defer res.Body.Close()
+    b, _ := io.ReadAll(res.Body)
+    if res.StatusCode != http.StatusPartialContent || string(b) != "2345" {
+        t.Fatalf("expected partial content, got %d %q", res.StatusCode, b)
+    }
+    if ct := res.Header.Get("Content-Type"); ct != "audio/mpeg" {
+        t.Fatalf("unexpected content type %q", ct)
+    }
+}
+
+func TestPlaylistsAndScrobble(t *testing.T) {
+    ts, s := newTestServer(t)
+    changes := 0
+    s.opts.OnPlaylistsChanged = func() { changes++ }
+
+    resp := call(t, ts, "createPlaylist", url.Values{"name": {"Mix"}, "songId": {"t1", "t3"}})
+    if resp.Playlist == nil || resp.Playlist.SongCount != 2 || resp.Playlist.Owner != "alice" {
+        t.Fatalf("unexpected playlist %+v", resp.Playlist)
+    }
+    id := resp.Playlist.ID
+
+    resp = call(t, ts, "updatePlaylist", url.Values{"playlistId": {id}, "name": {"Renamed"}, "songIndexToRemove": {"0"}, "songIdToAdd": {"t2"}})
+    if resp.Status != "ok" {
+        t.Fatalf("update: %+v", resp.Error)
+    }
+    resp = call(t, ts, "getPlaylist", url.Values{"id": {id}})
+    if p := resp.Playlist; p == nil || p.Name != "Renamed" || len(p.Entries) != 2 || p.Entries[0].ID != "t3" || p.Entries[1].ID != "t2" {
+        t.Fatalf("unexpected playlist %+v", resp.Playlist)
+    }
+
+    resp = call(t, ts, "getPlaylists", nil)
+    if resp.Playlists == nil || len(resp.Playlists.Playlists) != 1 || resp.Playlists.Playlists[0].Entries != nil {
+        t.Fatalf("unexpected playlists %+v", resp.Playlists)
+    }
+    if resp = call(t, ts, "deletePlaylist", url.Values{"id": {id}}); resp.Status != "ok" {
+        t.Fatalf("delete: %+v", resp.Error)
+    }
+    if changes != 3 {
+        t.Fatalf("expected 3 change notifications, got %d", changes)
+    }
+
+    call(t, ts, "scrobble", url.Values{"id": {"t1"}, "submission": {"false"}})
+    call(t, ts, "scrobble", url.Values{"id": {"t1", "t2"}, "time": {"1700000000000", "1700000200000"}})
+    if n := s.opts.Stats.Get("t1").PlayCount; n != 1 {
+        t.Fatalf("expected one play, got %d", n)
+    }
+    resp = call(t, ts, "getSong", url.Values{"id": {"t2"}})
+    if resp.Song.PlayCount != 1 {
+        t.Fatalf("expected play count in song, got %+v", resp.Song)
+    }
+}
@@ -0,0 +1,132 @@
+package tagwriter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// FLAC metadata block types
+const (
+    flacStreamInfo    = 0
+    flacPadding       = 1
+    flacVorbisComment = 4
+    flacPicture       = 6
+)
+
+// flacPaddingSize is the padding block written after rewritten metadata
+const flacPaddingSize = 1024
+
+type flacBlock struct {
+    typ  byte
+    data []byte
+}
+
+// readFLAC returns anything before the fLaC marker (such as an ID3 tag), the
+// metadata blocks and the offset of the first audio frame
+func readFLAC(r io.ReadSeeker) ([]byte, []flacBlock, int64, error) {
+    var prefix []byte
+    head := make([]byte, 10)
+    if _, err := io.ReadFull(r, head[:4]); err != nil {
+        return nil, nil, 0, fmt.Errorf("read FLAC header: %w", err)
+    }
+    if string(head[:3]) == "ID3" {
+        if _, err := io.ReadFull(r, head[4:]); err != nil {
+            return nil, nil, 0, fmt.Errorf("read ID3 header: %w", err)
+        }
+        prefix = make([]byte, 10+syncsafe(head[6:10]))
+        copy(prefix, head)
+        if _, err := io.ReadFull(r, prefix[10:]); err != nil {
+            return nil, nil, 0, fmt.Errorf("read ID3 tag: %w", err)
+        }
+        if _, err := io.ReadFull(r, head[:4]); err != nil {
+            return nil, nil, 0, fmt.Errorf("read FLAC header: %w", err)
+        }
+    }
+    if string(head[:4]) != "fLaC" {
+        return nil, nil, 0, fmt.Errorf("not a FLAC file")
+    }
+    pos := int64(len(prefix) + 4)
+    var blocks []flacBlock
+    for {
+        h := make([]byte, 4)
+        if _, err := io.ReadFull(r, h); err != nil {
+            return nil, nil, 0, fmt.Errorf("read FLAC block header: %w", err)
+        }
+        n := int(h[1])<<16 | int(h[2])<<8 | int(h[3])
+        data := make([]byte, n)
+        if _, err := io.ReadFull(r, data); err != nil {
+            return nil, nil, 0, fmt.Errorf("read FLAC block: %w", err)
+        }
+        pos += int64(4 + n)
+        blocks = append(blocks, flacBlock{typ: h[0] & 0x7f, data: data})
+        if h[0]&0x80 != 0 {
+            break
+        }
+    }
+    if len(blocks) == 0 || blocks[0].typ != flacStreamInfo {
+        return nil, nil, 0, fmt.Errorf("FLAC file without STREAMINFO")
+    }
+    return prefix, blocks, pos, nil
+}
+
+func rewriteFLAC(src *os.File, dst io.Writer, u Update) error {
+    prefix, blocks, audio, err := readFLAC(src)
+    if err != nil {
+        return err
+    }
+    var comment *vorbisComment
+    out := make([]flacBlock, 0, len(blocks)+1)
+    at := -1
+    for _, b := range blocks {
+        switch b.typ {
+        case flacPadding:
+            continue
+        case flacPicture:
+            if u.Cover != nil {
+                continue
+            }
+        case flacVorbisComment:
+            if comment != nil {
+                continue // only one comment block is allowed
+            }
+            if comment, _, err = parseVorbisComment(b.data); err != nil {
+                return err
+            }
+            at = len(out)
+        }
+        out = append(out, b)
+    }
+    if comment == nil {
+        comment = &vorbisComment{vendor: vendorString}
+        out = append(out, flacBlock{typ: flacVorbisComment})
+        at = len(out) - 1
+    }
+    comment.apply(u)
+    out[at].data = comment.bytes()
+    if u.Cover != nil && len(u.Cover.Data) > 0 {
+        out = append(out, flacBlock{typ: flacPicture, data: pictureBlock(*u.Cover)})
+    }
+    out = append(out, flacBlock{typ: flacPadding, data: make([]byte, flacPaddingSize)})
+
+    var buf bytes.Buffer
+    buf.Write(prefix)
+    buf.WriteString("fLaC")
+    for i, b := range out {
+        if len(b.data) >= 1<<24 {
+            return fmt.Errorf("FLAC metadata block too large")
+        }
+        typ := b.typ
+        if i == len(out)-1 {
+            typ |= 0x80
+        }
+        n := len(b.data)
+        buf.Write([]byte{typ, byte(n >> 16), byte(n >> 8), byte(n)})
+        buf.Write(b.data)
+    }
+    if _, err := dst.Write(buf.Bytes()); err != nil {
+        return err
+    }
+    return copyFrom(dst, src, audio)
+}
@@ -0,0 +1,331 @@
+package tagwriter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf16"
+)
+
+// id3Padding is left after rewritten ID3v2 tags so players that edit in place have room
+const id3Padding = 1024
+
+// popmEmail identifies POPM frames we create; existing frames keep their owner
+const popmEmail = "no@email"
+
+type id3Frame struct {
+    id    string
+    flags [2]byte
+    data  []byte
+}
+
+// id3Tag is an ID3v2.3 or v2.4 tag; frames are kept raw unless they are changed
+type id3Tag struct {
+    version byte
+    frames  []id3Frame
+}
+
+func syncsafe(b []byte) int {
+    return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+func putSyncsafe(b []byte, n int) {
+    b[0], b[1], b[2], b[3] = byte(n>>21&0x7f), byte(n>>14&0x7f), byte(n>>7&0x7f), byte(n&0x7f)
+}
+
+// removeUnsync reverses ID3 unsynchronisation (0xFF 0x00 -> 0xFF)
+func removeUnsync(b []byte) []byte {
+    out := make([]byte, 0, len(b))
+    for i := 0; i < len(b); i++ {
+        out = append(out, b[i])
+        if b[i] == 0xff && i+1 < len(b) && b[i+1] == 0 {
+            i++
+        }
+    }
+    return out
+}
+
+// readID3 reads the ID3v2 tag at the start of r and returns it with the offset
+// where audio data begins; files without a tag get an empty v2.4 tag
+func readID3(r io.ReadSeeker) (*id3Tag, int64, error) {
+    hdr := make([]byte, 10)
+    if _, err := io.ReadFull(r, hdr); err != nil || string(hdr[:3]) != "ID3" {
+        return &id3Tag{version: 4}, 0, nil
+    }
+    version, flags := hdr[3], hdr[5]
+    if version != 3 && version != 4 {
+        return nil, 0, fmt.Errorf("ID3v2.%d: %w", version, ErrUnsupported)
+    }
+    size := syncsafe(hdr[6:10])
+    end := int64(10 + size)
+    if version == 4 && flags&0x10 != 0 {
+        end += 10 // footer
+    }
+    body := make([]byte, size)
+    if _, err := io.ReadFull(r, body); err != nil {
+        return nil, 0, fmt.Errorf("read ID3 tag: %w", err)
+    }
+    // v2.4 marks unsynchronisation per frame, which is kept with the raw frame
+    if version == 3 && flags&0x80 != 0 {
+        body = removeUnsync(body)
+    }
+    pos := 0
+    if flags&0x40 != 0 && len(body) >= 4 {
+        if version == 3 {
+            pos = 4 + int(binary.BigEndian.Uint32(body))
+        } else {
+            pos = syncsafe(body)
+        }
+    }
+    t := &id3Tag{version: version}
+    for pos+10 <= len(body) && body[pos] != 0 {
+        id := string(body[pos : pos+4])
+        var n int
+        if version == 4 {
+            n = syncsafe(body[pos+4 : pos+8])
+        } else {
+            n = int(binary.BigEndian.Uint32(body[pos+4 : pos+8]))
+        }
+        start := pos + 10
+        if n < 0 || start+n > len(body) {
+            return nil, 0, fmt.Errorf("ID3 frame %q overruns tag", id)
+        }
+        t.frames = append(t.frames, id3Frame{id: id, flags: [2]byte{body[pos+8], body[pos+9]}, data: body[start : start+n]})
+        pos = start + n
+    }
+    return t, end, nil
+}
+
+// content returns the frame payload with per-frame encodings removed; ok is false
+// for compressed or encrypted frames
+func (t *id3Tag) content(f id3Frame) ([]byte, bool) {
+    data := f.data
+    if t.version == 3 {
+        if f.flags[1]&0xc0 != 0 {
+            return nil, false
+        }
+        if f.flags[1]&0x20 != 0 && len(data) > 0 {
+            data = data[1:] // group id
+        }
+        return data, true
+    }
+    if f.flags[1]&0x0c != 0 {
+        return nil, false
+    }
+    if f.flags[1]&0x40 != 0 && len(data) > 0 {
+        data = data[1:] // group id
+    }
+    if f.flags[1]&0x01 != 0 && len(data) >= 4 {
+        data = data[4:] // data length indicator
+    }
+    if f.flags[1]&0x02 != 0 {
+        data = removeUnsync(data)
+    }
+    return data, true
+}
+
+// remove drops all frames with the given id
+func (t *id3Tag) remove(id string) {
+    kept := t.frames[:0]
+    for _, f := range t.frames {
+        if f.id != id {
+            kept = append(kept, f)
+        }
+    }
+    t.frames = kept
+}
+
+// popmRating maps stars to the POPM byte values used by Windows Media Player
+var popmRating = [6]byte{0, 1, 64, 128, 196, 255}
+
+// setRating updates every readable POPM frame, adding one if there is none
+func (t *id3Tag) setRating(stars int) {
+    found := false
+    for i, f := range t.frames {
+        if f.id != "POPM" {
+            continue
+        }
+        data, ok := t.content(f)
+        at := bytes.IndexByte(data, 0)
+        if !ok || at < 0 || at+1 >= len(data) {
+            continue
+        }
+        nd := append([]byte{}, data...)
+        nd[at+1] = popmRating[stars]
+        t.frames[i] = id3Frame{id: "POPM", data: nd}
+        found = true
+    }
+    if !found {
+        data := append([]byte(popmEmail), 0, popmRating[stars])
+        t.frames = append(t.frames, id3Frame{id: "POPM", data: data})
+    }
+}
+
+// decodeID3Text decodes a text frame payload
+func decodeID3Text(b []byte) string {
+    if len(b) == 0 {
+        return ""
+    }
+    enc, b := b[0], b[1:]
+    switch enc {
+    case 1, 2:
+        order := binary.ByteOrder(binary.BigEndian)
+        if enc == 1 && len(b) >= 2 {
+            if b[0] == 0xff && b[1] == 0xfe {
+                order = binary.LittleEndian
+            }
+            b = b[2:]
+        }
+        u := make([]uint16, 0, len(b)/2)
+        for i := 0; i+1 < len(b); i += 2 {
+            u = append(u, order.Uint16(b[i:]))
+        }
+        return strings.TrimRight(string(utf16.Decode(u)), "\x00")
+    case 3:
+        return strings.TrimRight(string(b), "\x00")
+    }
+    r := make([]rune, len(b))
+    for i, c := range b {
+        r[i] = rune(c)
+    }
+    return strings.TrimRight(string(r), "\x00")
+}
+
+// encodeID3Text builds a text payload: UTF-8 for v2.4, Latin-1 or UTF-16 for v2.3
+func (t *id3Tag) encodeID3Text(s string) []byte {
+    if t.version == 4 {
+        return append([]byte{3}, s...)
+    }
+    latin := []byte{0}
+    for _, r := range s {
+        if r > 0xff {
+            out := []byte{1, 0xff, 0xfe}
+            for _, c := range utf16.Encode([]rune(s)) {
+                out = binary.LittleEndian.AppendUint16(out, c)
+            }
+            return out
+        }
+        latin = append(latin, byte(r))
+    }
+    return latin
+}
+
+// text returns the decoded value of the first readable frame with id
+func (t *id3Tag) text(id string) string {
+    for _, f := range t.frames {
+        if f.id != id {
+            continue
+        }
+        if data, ok := t.content(f); ok {
+            return decodeID3Text(data)
+        }
+    }
+    return ""
+}
+
+// setText replaces the frame id in place; an empty value removes it
+func (t *id3Tag) setText(id, value string) {
+    if value == "" {
+        t.remove(id)
+        return
+    }
+    f := id3Frame{id: id, data: t.encodeID3Text(value)}
+    for i := range t.frames {
+        if t.frames[i].id == id {
+            // frames before i are untouched by remove, so i is still the first position
+            t.remove(id)
+            t.frames = slices.Insert(t.frames, i, f)
+            return
+        }
+    }
+    t.frames = append(t.frames, f)
+}
+
+// setPosition writes a track or disc number, keeping an existing "/total"
+func (t *id3Tag) setPosition(id string, n int) {
+    value := number(n)
+    if _, total, ok := strings.Cut(t.text(id), "/"); ok && value != "" && total != "" {
+        value += "/" + total
+    }
+    t.setText(id, value)
+}
+
+// setCover replaces all attached pictures with a single front cover
+func (t *id3Tag) setCover(c Cover) {
+    t.remove("APIC")
+    if len(c.Data) == 0 {
+        return
+    }
+    data := append([]byte{0}, c.MIME...)
+    data = append(data, 0, 3, 0) // front cover, empty description
+    t.frames = append(t.frames, id3Frame{id: "APIC", data: append(data, c.Data...)})
+}
+
+func (t *id3Tag) apply(u Update) {
+    for _, f := range u.textFields() {
+        if f.value != nil {
+            t.setText(f.id3, *f.value)
+        }
+    }
+    if u.TrackNumber != nil {
+        t.setPosition("TRCK", *u.TrackNumber)
+    }
+    if u.DiscNumber != nil {
+        t.setPosition("TPOS", *u.DiscNumber)
+    }
+    if u.Year != nil {
+        // v2.4 replaced TYER with the TDRC timestamp
+        t.remove("TYER")
+        t.remove("TDRC")
+        id := "TYER"
+        if t.version == 4 {
+            id = "TDRC"
+        }
+        t.setText(id, number(*u.Year))
+    }
+    if u.Rating != nil {
+        t.setRating(*u.Rating)
+    }
+    if u.Cover != nil {
+        t.setCover(*u.Cover)
+    }
+}
+
+// bytes serializes the tag without unsynchronisation or extended header
+func (t *id3Tag) bytes(padding int) []byte {
+    var body bytes.Buffer
+    for _, f := range t.frames {
+        hdr := make([]byte, 10)
+        copy(hdr, f.id)
+        if t.version == 4 {
+            putSyncsafe(hdr[4:8], len(f.data))
+        } else {
+            binary.BigEndian.PutUint32(hdr[4:8], uint32(len(f.data)))
+        }
+        hdr[8], hdr[9] = f.flags[0], f.flags[1]
+        body.Write(hdr)
+        body.Write(f.data)
+    }
+    body.Write(make([]byte, padding))
+    out := make([]byte, 10, 10+body.Len())
+    copy(out, "ID3")
+    out[3] = t.version
+    putSyncsafe(out[6:10], body.Len())
+    return append(out, body.Bytes()...)
+}
+
+func rewriteID3(src *os.File, dst io.Writer, u Update) error {
+    t, audio, err := readID3(src)
+    if err != nil {
+        return err
+    }
+    t.apply(u)
+    if _, err := dst.Write(t.bytes(id3Padding)); err != nil {
+        return err
+    }
+    return copyFrom(dst, src, audio)
+}
@@ -0,0 +1,375 @@
+package tagwriter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// mp4Containers are the atoms parsed into children on the way to ilst and the chunk offset tables
+var mp4Containers = map[string]bool{
+    "moov": true, "trak": true, "mdia": true, "minf": true, "stbl": true, "udta": true, "meta": true, "ilst": true,
+}
+
+// mp4Atom is a parsed atom; containers hold children, everything else raw data.
+// prefix carries the version and flags of meta before its children.
+type mp4Atom struct {
+    name     string
+    prefix   []byte
+    data     []byte
+    children []*mp4Atom
+}
+
+func parseMP4Atoms(b []byte, parent string) ([]*mp4Atom, error) {
+    var out []*mp4Atom
+    for len(b) >= 8 {
+        size := uint64(binary.BigEndian.Uint32(b))
+        name := string(b[4:8])
+        hdr := uint64(8)
+        switch size {
+        case 0:
+            size = uint64(len(b))
+        case 1:
+            if len(b) < 16 {
+                return nil, fmt.Errorf("truncated atom %q", name)
+            }
+            size, hdr = binary.BigEndian.Uint64(b[8:]), 16
+        }
+        if size < hdr || size > uint64(len(b)) {
+            return nil, fmt.Errorf("atom %q overruns its parent", name)
+        }
+        a := &mp4Atom{name: name}
+        payload := b[hdr:size]
+        // items in ilst are kept whole; only their data children matter when rewriting
+        if mp4Containers[name] && parent != "ilst" {
+            // iTunes meta carries version and flags; QuickTime meta starts with hdlr
+            if name == "meta" && len(payload) >= 8 && string(payload[4:8]) != "hdlr" {
+                a.prefix, payload = payload[:4], payload[4:]
+            }
+            children, err := parseMP4Atoms(payload, name)
+            if err != nil {
+                return nil, err
+            }
+            a.children = children
+        } else {
+            a.data = payload
+        }
+        out = append(out, a)
+        b = b[size:]
+    }
+    return out, nil
+}
+
+func (a *mp4Atom) size() int {
+    n := 8 + len(a.prefix) + len(a.data)
+    for _, c := range a.children {
+        n += c.size()
+    }
+    return n
+}
+
+func (a *mp4Atom) write(buf *bytes.Buffer) {
+    var hdr [8]byte
+    binary.BigEndian.PutUint32(hdr[:], uint32(a.size()))
+    copy(hdr[4:], a.name)
+    buf.Write(hdr[:])
+    buf.Write(a.prefix)
+    buf.Write(a.data)
+    for _, c := range a.children {
+        c.write(buf)
+    }
+}
+
+func (a *mp4Atom) child(name string) *mp4Atom {
+    for _, c := range a.children {
+        if c.name == name {
+            return c
+        }
+    }
+    return nil
+}
+
+// ensure returns the named child, appending one made by mk if missing
+func (a *mp4Atom) ensure(name string, mk func() *mp4Atom) *mp4Atom {
+    if c := a.child(name); c != nil {
+        return c
+    }
+    c := mk()
+    a.children = append(a.children, c)
+    return c
+}
+
+// ilst finds or creates moov/udta/meta/ilst
+func ilstOf(moov *mp4Atom) *mp4Atom {
+    udta := moov.ensure("udta", func() *mp4Atom { return &mp4Atom{name: "udta"} })
+    meta := udta.ensure("meta", func() *mp4Atom {
+        hdlr := &mp4Atom{name: "hdlr", data: append(make([]byte, 8), "mdirappl\x00\x00\x00\x00\x00\x00\x00\x00\x00"...)}
+        return &mp4Atom{name: "meta", prefix: make([]byte, 4), children: []*mp4Atom{hdlr}}
+    })
+    return meta.ensure("ilst", func() *mp4Atom { return &mp4Atom{name: "ilst"} })
+}
+
+// mp4Data builds a data atom with the given well-known type
+func mp4Data(typ uint32, value []byte) *mp4Atom {
+    b := make([]byte, 8, 8+len(value))
+    binary.BigEndian.PutUint32(b, typ)
+    return &mp4Atom{name: "data", data: append(b, value...)}
+}
+
+// freeformName returns the mean and name of a "----" item
+func freeformName(item *mp4Atom) (string, string) {
+    children, err := parseMP4Atoms(item.data, "----")
+    if err != nil {
+        return "", ""
+    }
+    var mean, name string
+    for _, c := range children {
+        if len(c.data) < 4 {
+            continue
+        }
+        switch c.name {
+        case "mean":
+            mean = string(c.data[4:])
+        case "name":
+            name = string(c.data[4:])
+        }
+    }
+    return mean, name
+}
+
+// setFreeform replaces the iTunes freeform item name; an empty value removes it
+func setFreeform(ilst *mp4Atom, name, value string) {
+    const mean = "com.apple.iTunes"
+    kept := ilst.children[:0]
+    for _, c := range ilst.children {
+        if c.name == "----" {
+            if m, n := freeformName(c); m == mean && n == name {
+                continue
+            }
+        }
+        kept = append(kept, c)
+    }
+    ilst.children = kept
+    if value == "" {
+        return
+    }
+    var buf bytes.Buffer
+    (&mp4Atom{name: "mean", data: append(make([]byte, 4), mean...)}).write(&buf)
+    (&mp4Atom{name: "name", data: append(make([]byte, 4), name...)}).write(&buf)
+    mp4Data(1, []byte(value)).write(&buf)
+    ilst.children = append(ilst.children, &mp4Atom{name: "----", data: buf.Bytes()})
+}
+
+// setItem replaces the ilst item name in place, appending it if new; a nil data atom removes it
+func setItem(ilst *mp4Atom, name string, data *mp4Atom) {
+    var item *mp4Atom
+    if data != nil {
+        var buf bytes.Buffer
+        data.write(&buf)
+        item = &mp4Atom{name: name, data: buf.Bytes()}
+    }
+    out := ilst.children[:0]
+    placed := false
+    for _, c := range ilst.children {
+        if c.name != name {
+            out = append(out, c)
+            continue
+        }
+        if item != nil && !placed {
+            out = append(out, item)
+            placed = true
+        }
+    }
+    if item != nil && !placed {
+        out = append(out, item)
+    }
+    ilst.children = out
+}
+
+// itemData returns the value of the first data atom of an item
+func itemData(ilst *mp4Atom, name string) []byte {
+    item := ilst.child(name)
+    if item == nil {
+        return nil
+    }
+    children, err := parseMP4Atoms(item.data, name)
+    if err != nil {
+        return nil
+    }
+    for _, c := range children {
+        if c.name == "data" && len(c.data) >= 8 {
+            return c.data[8:]
+        }
+    }
+    return nil
+}
+
+// setPair writes trkn or disk, keeping the stored total
+func setPair(ilst *mp4Atom, name string, n int) {
+    if n == 0 {
+        setItem(ilst, name, nil)
+        return
+    }
+    size := 8
+    if name == "disk" {
+        size = 6
+    }
+    value := make([]byte, size)
+    if old := itemData(ilst, name); len(old) >= 6 {
+        copy(value[4:6], old[4:6])
+    }
+    binary.BigEndian.PutUint16(value[2:], uint16(n))
+    setItem(ilst, name, mp4Data(0, value))
+}
+
+func applyMP4(ilst *mp4Atom, u Update) {
+    for _, f := range u.textFields() {
+        switch {
+        case f.value == nil:
+        case *f.value == "":
+            setItem(ilst, f.mp4, nil)
+        default:
+            setItem(ilst, f.mp4, mp4Data(1, []byte(*f.value)))
+        }
+    }
+    if u.Genre != nil {
+        setItem(ilst, "gnre", nil) // numeric ID3 genre would shadow the text
+    }
+    if u.TrackNumber != nil {
+        setPair(ilst, "trkn", *u.TrackNumber)
+    }
+    if u.DiscNumber != nil {
+        setPair(ilst, "disk", *u.DiscNumber)
+    }
+    if u.Year != nil {
+        if *u.Year == 0 {
+            setItem(ilst, "\xa9day", nil)
+        } else {
+            setItem(ilst, "\xa9day", mp4Data(1, []byte(number(*u.Year))))
+        }
+    }
+    if u.Cover != nil {
+        if len(u.Cover.Data) == 0 {
+            setItem(ilst, "covr", nil)
+        } else {
+            typ := uint32(13)
+            if u.Cover.MIME == "image/png" {
+                typ = 14
+            }
+            setItem(ilst, "covr", mp4Data(typ, u.Cover.Data))
+        }
+    }
+    if u.Rating != nil {
+        value := ""
+        if *u.Rating > 0 {
+            value = strconv.Itoa(*u.Rating * 20)
+        }
+        setFreeform(ilst, "rate", value)
+    }
+}
+
+// shiftChunkOffsets moves stco/co64 entries at or past from by delta
+func shiftChunkOffsets(a *mp4Atom, from uint64, delta int64) error {
+    for _, c := range a.children {
+        if err := shiftChunkOffsets(c, from, delta); err != nil {
+            return err
+        }
+    }
+    if a.name != "stco" && a.name != "co64" {
+        return nil
+    }
+    if len(a.data) < 8 {
+        return fmt.Errorf("truncated %s", a.name)
+    }
+    n := int(binary.BigEndian.Uint32(a.data[4:]))
+    width := 4
+    if a.name == "co64" {
+        width = 8
+    }
+    if 8+n*width > len(a.data) {
+        return fmt.Errorf("truncated %s", a.name)
+    }
+    for i := 0; i < n; i++ {
+        at := a.data[8+i*width:]
+        if width == 4 {
+            off := uint64(binary.BigEndian.Uint32(at))
+            if off >= from {
+                moved := int64(off) + delta
+                if moved < 0 || moved > 0xffffffff {
+                    return fmt.Errorf("chunk offset out of range for stco")
+                }
+                binary.BigEndian.PutUint32(at, uint32(moved))
+            }
+        } else if off := binary.BigEndian.Uint64(at); off >= from {
+            binary.BigEndian.PutUint64(at, uint64(int64(off)+delta))
+        }
+    }
+    return nil
+}
+
+// findMoov walks top-level atoms and returns the offset and size of moov
+func findMoov(r io.ReadSeeker) (int64, int64, error) {
+    var pos int64
+    hdr := make([]byte, 16)
+    for {
+        if _, err := r.Seek(pos, io.SeekStart); err != nil {
+            return 0, 0, err
+        }
+        if _, err := io.ReadFull(r, hdr[:8]); err != nil {
+            return 0, 0, fmt.Errorf("no moov atom found")
+        }
+        size := int64(binary.BigEndian.Uint32(hdr))
+        if size == 1 {
+            if _, err := io.ReadFull(r, hdr[8:]); err != nil {
+                return 0, 0, err
+            }
+            size = int64(binary.BigEndian.Uint64(hdr[8:]))
+        }
+        if string(hdr[4:8]) == "moov" {
+            return pos, size, nil
+        }
+        if size < 8 {
+            return 0, 0, fmt.Errorf("no moov atom found")
+        }
+        pos += size
+    }
+}
+
+func rewriteMP4(src *os.File, dst io.Writer, u Update) error {
+    start, size, err := findMoov(src)
+    if err != nil {
+        return err
+    }
+    raw := make([]byte, size)
+    if _, err := src.ReadAt(raw, start); err != nil {
+        return fmt.Errorf("read moov: %w", err)
+    }
+    atoms, err := parseMP4Atoms(raw, "")
+    if err != nil {
+        return err
+    }
+    moov := atoms[0]
+    applyMP4(ilstOf(moov), u)
+    // media data after moov moves with its size change
+    end := start + size
+    if delta := int64(moov.size()) - size; delta != 0 {
+        if err := shiftChunkOffsets(moov, uint64(end), delta); err != nil {
+            return err
+        }
+    }
+    if _, err := src.Seek(0, io.SeekStart); err != nil {
+        return err
+    }
+    if _, err := io.CopyN(dst, src, start); err != nil {
+        return err
+    }
+    var buf bytes.Buffer
+    moov.write(&buf)
+    if _, err := dst.Write(buf.Bytes()); err != nil {
+        return err
+    }
+    return copyFrom(dst, src, end)
+}
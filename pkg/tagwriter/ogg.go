@@ -0,0 +1,223 @@
+package tagwriter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// oggMaxSegments is the largest lacing table a page can carry
+const oggMaxSegments = 255
+
+var oggCRCTable = func() (t [256]uint32) {
+    for i := range t {
+        r := uint32(i) << 24
+        for j := 0; j < 8; j++ {
+            if r&0x80000000 != 0 {
+                r = r<<1 ^ 0x04c11db7
+            } else {
+                r <<= 1
+            }
+        }
+        t[i] = r
+    }
+    return t
+}()
+
+func oggCRC(b []byte) uint32 {
+    var c uint32
+    for _, x := range b {
+        c = c<<8 ^ oggCRCTable[byte(c>>24)^x]
+    }
+    return c
+}
+
+type oggPage struct {
+    flags    byte
+    granule  uint64
+    serial   uint32
+    seq      uint32
+    segments []byte
+    body     []byte
+}
+
+func readOggPage(r io.Reader) (*oggPage, error) {
+    h := make([]byte, 27)
+    if _, err := io.ReadFull(r, h); err != nil {
+        return nil, err
+    }
+    if string(h[:4]) != "OggS" || h[4] != 0 {
+        return nil, fmt.Errorf("invalid Ogg page")
+    }
+    p := &oggPage{
+        flags:   h[5],
+        granule: binary.LittleEndian.Uint64(h[6:]),
+        serial:  binary.LittleEndian.Uint32(h[14:]),
+        seq:     binary.LittleEndian.Uint32(h[18:]),
+    }
+    p.segments = make([]byte, h[26])
+    if _, err := io.ReadFull(r, p.segments); err != nil {
+        return nil, err
+    }
+    n := 0
+    for _, s := range p.segments {
+        n += int(s)
+    }
+    p.body = make([]byte, n)
+    if _, err := io.ReadFull(r, p.body); err != nil {
+        return nil, err
+    }
+    return p, nil
+}
+
+func (p *oggPage) bytes() []byte {
+    out := make([]byte, 27, 27+len(p.segments)+len(p.body))
+    copy(out, "OggS")
+    out[5] = p.flags
+    binary.LittleEndian.PutUint64(out[6:], p.granule)
+    binary.LittleEndian.PutUint32(out[14:], p.serial)
+    binary.LittleEndian.PutUint32(out[18:], p.seq)
+    out[26] = byte(len(p.segments))
+    out = append(out, p.segments...)
+    out = append(out, p.body...)
+    binary.LittleEndian.PutUint32(out[22:], oggCRC(out))
+    return out
+}
+
+// paginate splits header packets into pages starting at sequence number seq; the
+// last packet ends its page as Vorbis and Opus require before audio data
+func paginate(packets [][]byte, serial, seq uint32) []*oggPage {
+    var pages []*oggPage
+    cur := &oggPage{serial: serial, seq: seq}
+    flush := func(continued bool) {
+        pages = append(pages, cur)
+        cur = &oggPage{serial: serial, seq: cur.seq + 1}
+        if continued {
+            cur.flags = 0x01
+        }
+    }
+    for _, pkt := range packets {
+        rest := pkt
+        for {
+            if len(cur.segments) == oggMaxSegments {
+                flush(true)
+            }
+            n := min(len(rest), 255)
+            cur.segments = append(cur.segments, byte(n))
+            cur.body = append(cur.body, rest[:n]...)
+            rest = rest[n:]
+            if n < 255 {
+                break
+            }
+        }
+    }
+    if len(cur.segments) > 0 {
+        flush(false)
+    }
+    return pages
+}
+
+// oggHeaders reads the pages holding the comment header and anything that shares
+// its pages, returning those packets and the number of pages read
+func oggHeaders(r io.Reader, serial uint32, want int) ([][]byte, int, error) {
+    var packets [][]byte
+    var cur []byte
+    pages := 0
+    for len(packets) < want {
+        p, err := readOggPage(r)
+        if err != nil {
+            return nil, 0, fmt.Errorf("read Ogg headers: %w", err)
+        }
+        if p.serial != serial {
+            return nil, 0, fmt.Errorf("multiplexed Ogg streams: %w", ErrUnsupported)
+        }
+        pages++
+        pos := 0
+        for _, s := range p.segments {
+            cur = append(cur, p.body[pos:pos+int(s)]...)
+            pos += int(s)
+            if s < 255 {
+                packets = append(packets, cur)
+                cur = nil
+            }
+        }
+        if len(packets) >= want && (cur != nil || len(packets) > want) {
+            return nil, 0, fmt.Errorf("audio data shares a page with Ogg headers")
+        }
+    }
+    return packets, pages, nil
+}
+
+func rewriteOgg(f *os.File, dst io.Writer, u Update) error {
+    src := bufio.NewReader(f)
+    first, err := readOggPage(src)
+    if err != nil {
+        return fmt.Errorf("read Ogg page: %w", err)
+    }
+    // the comment header follows the identification header; Vorbis adds a setup header
+    var magic []byte
+    want := 1
+    switch {
+    case bytes.HasPrefix(first.body, []byte("\x01vorbis")):
+        magic, want = []byte("\x03vorbis"), 2
+    case bytes.HasPrefix(first.body, []byte("OpusHead")):
+        magic = []byte("OpusTags")
+    default:
+        return fmt.Errorf("Ogg codec: %w", ErrUnsupported)
+    }
+    packets, oldPages, err := oggHeaders(src, first.serial, want)
+    if err != nil {
+        return err
+    }
+    if !bytes.HasPrefix(packets[0], magic) {
+        return fmt.Errorf("missing Ogg comment header")
+    }
+    comment, n, err := parseVorbisComment(packets[0][len(magic):])
+    if err != nil {
+        return err
+    }
+    // keep the Vorbis framing bit or Opus padding that follows the comments
+    trailer := packets[0][len(magic)+n:]
+    comment.apply(u)
+    if u.Cover != nil {
+        comment.set("COVERART")
+        if len(u.Cover.Data) > 0 {
+            comment.set("METADATA_BLOCK_PICTURE", base64.StdEncoding.EncodeToString(pictureBlock(*u.Cover)))
+        } else {
+            comment.set("METADATA_BLOCK_PICTURE")
+        }
+    }
+    pkt := append(append([]byte{}, magic...), comment.bytes()...)
+    packets[0] = append(pkt, trailer...)
+
+    if _, err := dst.Write(first.bytes()); err != nil {
+        return err
+    }
+    pages := paginate(packets, first.serial, first.seq+1)
+    for _, p := range pages {
+        if _, err := dst.Write(p.bytes()); err != nil {
+            return err
+        }
+    }
+    // renumber the remaining pages of the stream when the header page count changed
+    delta := uint32(len(pages) - oldPages)
+    for {
+        p, err := readOggPage(src)
+        if err == io.EOF {
+            return nil
+        }
+        if err != nil {
+            return fmt.Errorf("read Ogg page: %w", err)
+        }
+        if p.serial == first.serial {
+            p.seq += delta
+        }
+        if _, err := dst.Write(p.bytes()); err != nil {
+            return err
+        }
+    }
+}
@@ -0,0 +1,168 @@
+package tagwriter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupported is returned for files whose tags can't be written
+var ErrUnsupported = errors.New("unsupported file format")
+
+// Update lists tag changes; nil fields are left untouched, empty strings and
+// zero numbers remove the tag
+type Update struct {
+    Title       *string `json:"title,omitempty"`
+    Artist      *string `json:"artist,omitempty"`
+    Album       *string `json:"album,omitempty"`
+    AlbumArtist *string `json:"albumArtist,omitempty"`
+    Genre       *string `json:"genre,omitempty"`
+    Composer    *string `json:"composer,omitempty"`
+    TrackNumber *int    `json:"trackNumber,omitempty"`
+    DiscNumber  *int    `json:"discNumber,omitempty"`
+    Year        *int    `json:"year,omitempty"`
+    // Rating in stars from 0 (unrated) to 5
+    Rating *int `json:"rating,omitempty"`
+    // Cover replaces the embedded front cover; empty data removes it
+    Cover *Cover `json:"cover,omitempty"`
+}
+
+// Cover is an embedded picture; MIME is image/jpeg or image/png
+type Cover struct {
+    MIME string `json:"mime"`
+    Data []byte `json:"data"`
+}
+
+// textField pairs a text change with the tag names each format stores it under
+type textField struct {
+    value *string
+    id3   string
+    vorb  string
+    mp4   string
+}
+
+func (u Update) textFields() []textField {
+    return []textField{
+        {u.Title, "TIT2", "TITLE", "\xa9nam"},
+        {u.Artist, "TPE1", "ARTIST", "\xa9ART"},
+        {u.Album, "TALB", "ALBUM", "\xa9alb"},
+        {u.AlbumArtist, "TPE2", "ALBUMARTIST", "aART"},
+        {u.Genre, "TCON", "GENRE", "\xa9gen"},
+        {u.Composer, "TCOM", "COMPOSER", "\xa9wrt"},
+    }
+}
+
+func (u Update) validate() error {
+    if u.Rating != nil && (*u.Rating < 0 || *u.Rating > 5) {
+        return fmt.Errorf("rating %d out of range", *u.Rating)
+    }
+    for name, n := range map[string]*int{"track number": u.TrackNumber, "disc number": u.DiscNumber} {
+        if n != nil && (*n < 0 || *n > 0xffff) {
+            return fmt.Errorf("%s %d out of range", name, *n)
+        }
+    }
+    if u.Year != nil && (*u.Year < 0 || *u.Year > 9999) {
+        return fmt.Errorf("year %d out of range", *u.Year)
+    }
+    if u.Cover != nil && len(u.Cover.Data) > 0 && u.Cover.MIME != "image/jpeg" && u.Cover.MIME != "image/png" {
+        return fmt.Errorf("unsupported cover type %q", u.Cover.MIME)
+    }
+    return nil
+}
+
+// number formats n for text tags; zero means remove
+func number(n int) string {
+    if n == 0 {
+        return ""
+    }
+    return strconv.Itoa(n)
+}
+
+type rewriter func(src *os.File, dst io.Writer, u Update) error
+
+func rewriterFor(path string) rewriter {
+    switch strings.ToLower(filepath.Ext(path)) {
+    case ".mp3":
+        return rewriteID3
+    case ".flac":
+        return rewriteFLAC
+    case ".ogg", ".oga", ".opus":
+        return rewriteOgg
+    case ".m4a", ".m4b", ".mp4":
+        return rewriteMP4
+    }
+    return nil
+}
+
+// Supported reports whether tags of the file at path can be written
+func Supported(path string) bool {
+    return rewriterFor(path) != nil
+}
+
+// Write applies u to the file at path. The new file is written next to the old one
+// and renamed over it, so readers never see a half-written file.
+func Write(path string, u Update) error {
+    if err := u.validate(); err != nil {
+        return err
+    }
+    rw := rewriterFor(path)
+    if rw == nil {
+        return fmt.Errorf("%s: %w", path, ErrUnsupported)
+    }
+    src, err := os.Open(path)
+    if err != nil {
+        return err
+    }
+    defer src.Close()
+    fi, err := src.Stat()
+    if err != nil {
+        return err
+    }
+    tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+    if err != nil {
+        return fmt.Errorf("create tmp: %w", err)
+    }
+    done := false
+    defer func() {
+        if !done {
+            tmp.Close()
+            os.Remove(tmp.Name())
+        }
+    }()
+    bw := bufio.NewWriterSize(tmp, 1<<20)
+    if err := rw(src, bw, u); err != nil {
+        return fmt.Errorf("%s: %w", path, err)
+    }
+    if err := bw.Flush(); err != nil {
+        return fmt.Errorf("write tmp: %w", err)
+    }
+    if err := tmp.Chmod(fi.Mode().Perm()); err != nil {
+        return fmt.Errorf("chmod tmp: %w", err)
+    }
+    if err := tmp.Sync(); err != nil {
+        return fmt.Errorf("sync tmp: %w", err)
+    }
+    if err := tmp.Close(); err != nil {
+        return fmt.Errorf("close tmp: %w", err)
+    }
+    if err := os.Rename(tmp.Name(), path); err != nil {
+        os.Remove(tmp.Name())
+        return fmt.Errorf("rename tmp: %w", err)
+    }
+    done = true
+    return nil
+}
+
+// copyFrom copies src from offset to its end into dst
+func copyFrom(dst io.Writer, src *os.File, offset int64) error {
+    if _, err := src.Seek(offset, io.SeekStart); err != nil {
+        return err
+    }
+    _, err := io.Copy(dst, src)
+    return err
+}
@@ -0,0 +1,276 @@
+package tagwriter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tag "github.com/dhowden/tag"
+)
+
+var audio = []byte("\xff\xfbAUDIO-PAYLOAD")
+
+func writeFile(t *testing.T, name string, data []byte) string {
+    t.Helper()
+    p := filepath.Join(t.TempDir(), name)
+    if err := os.WriteFile(p, data, 0o644); err != nil {
+        t.Fatalf("write fixture: %v", err)
+    }
+    return p
+}
+
+func readTags(t *testing.T, path string) tag.Metadata {
+    t.Helper()
+    f, err := os.Open(path)
+    if err != nil {
+        t.Fatalf("open: %v", err)
+    }
+    defer f.Close()
+    m, err := tag.ReadFrom(f)
+    if err != nil {
+        t.Fatalf("read tags: %v", err)
+    }
+    return m
+}
+
+func rating(n int) Update { return Update{Rating: &n} }
+
+func mp3Fixture() []byte {
+    title := append([]byte{0}, "Song"...)
+    frame := append([]byte("TIT2\x00\x00\x00\x05\x00\x00"), title...)
+    hdr := []byte("ID3\x03\x00\x00\x00\x00\x00\x00")
+    putSyncsafe(hdr[6:], len(frame))
+    return append(append(hdr, frame...), audio...)
+}
+
+func TestWriteRatingID3(t *testing.T) {
+    p := writeFile(t, "a.mp3", mp3Fixture())
+    if err := Write(p, rating(4)); err != nil {
+        t.Fatalf("Write: %v", err)
+    }
+    m := readTags(t, p)
+    popm, _ := m.Raw()["POPM"].([]byte)
+    if m.Title() != "Song" || !bytes.Equal(popm, []byte("no@email\x00\xc4")) {
+        t.Fatalf("unexpected tags: title %q popm %q", m.Title(), popm)
+    }
+    b, _ := os.ReadFile(p)
+    if !bytes.HasSuffix(b, audio) {
+        t.Fatalf("audio data not preserved")
+    }
+    // existing POPM frames keep their owner and counter
+    if err := Write(p, rating(2)); err != nil {
+        t.Fatalf("Write: %v", err)
+    }
+    popm, _ = readTags(t, p).Raw()["POPM"].([]byte)
+    if !bytes.Equal(popm, []byte("no@email\x00\x40")) {
+        t.Fatalf("unexpected POPM after update %q", popm)
+    }
+}
+
+func flacFixture() []byte {
+    c := &vorbisComment{vendor: "test", fields: []string{"TITLE=Song", "RATING=3"}}
+    var b bytes.Buffer
+    b.WriteString("fLaC")
+    b.Write([]byte{flacStreamInfo, 0, 0, 34})
+    b.Write(make([]byte, 34))
+    cb := c.bytes()
+    b.Write([]byte{0x80 | flacVorbisComment, 0, 0, byte(len(cb))})
+    b.Write(cb)
+    b.Write(audio)
+    return b.Bytes()
+}
+
+func TestWriteRatingFLAC(t *testing.T) {
+    p := writeFile(t, "a.flac", flacFixture())
+    if err := Write(p, rating(5)); err != nil {
+        t.Fatalf("Write: %v", err)
+    }
+    m := readTags(t, p)
+    // the file's 1–5 scale is kept
+    if m.Title() != "Song" || m.Raw()["rating"] != "5" {
+        t.Fatalf("unexpected tags %v", m.Raw())
+    }
+    if err := Write(p, rating(0)); err != nil {
+        t.Fatalf("Write: %v", err)
+    }
+    if _, ok := readTags(t, p).Raw()["rating"]; ok {
+        t.Fatalf("expected rating to be removed")
+    }
+    b, _ := os.ReadFile(p)
+    if !bytes.HasSuffix(b, audio) {
+        t.Fatalf("audio data not preserved")
+    }
+}
+
+func oggFixture() []byte {
+    id := append([]byte("\x01vorbis"), make([]byte, 23)...)
+    c := &vorbisComment{vendor: "test", fields: []string{"TITLE=Song"}}
+    comment := append(append([]byte("\x03vorbis"), c.bytes()...), 1)
+    setup := []byte("\x05vorbisSETUP")
+    // comment and setup on separate pages so the rewrite packs them and renumbers
+    pages := []*oggPage{
+        {flags: 0x02, serial: 7, seq: 0, segments: []byte{byte(len(id))}, body: id},
+        {serial: 7, seq: 1, segments: []byte{byte(len(comment))}, body: comment},
+        {serial: 7, seq: 2, segments: []byte{byte(len(setup))}, body: setup},
+        {flags: 0x04, granule: 100, serial: 7, seq: 3, segments: []byte{byte(len(audio))}, body: audio},
+    }
+    var b bytes.Buffer
+    for _, p := range pages {
+        b.Write(p.bytes())
+    }
+    return b.Bytes()
+}
+
+func TestWriteRatingOgg(t *testing.T) {
+    p := writeFile(t, "a.ogg", oggFixture())
+    if err := Write(p, rating(3)); err != nil {
+        t.Fatalf("Write: %v", err)
+    }
+    m := readTags(t, p)
+    if m.Title() != "Song" || m.Raw()["rating"] != "60" {
+        t.Fatalf("unexpected tags %v", m.Raw())
+    }
+    f, err := os.Open(p)
+    if err != nil {
+        t.Fatalf("open: %v", err)
+    }
+    defer f.Close()
+    var pages []*oggPage
+    for {
+        pg, err := readOggPage(f)
+        if err != nil {
+            break
+        }
+        pages = append(pages, pg)
+    }
+    if len(pages) != 3 {
+        t.Fatalf("expected 3 pages after repagination, got %d", len(pages))
+    }
+    last := pages[2]
+    if last.seq != 2 || last.granule != 100 || !bytes.Equal(last.body, audio) {
+        t.Fatalf("unexpected audio page %+v", last)
+    }
+}
+
+func mp4Atom4(name string, payload ...[]byte) []byte {
+    body := bytes.Join(payload, nil)
+    out := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+    return append(append(out, name...), body...)
+}
+
+func mp4Fixture() []byte {
+    ftyp := mp4Atom4("ftyp", []byte("M4A \x00\x00\x00\x00"))
+    stco := func(off uint32) []byte {
+        return mp4Atom4("stco", []byte{0, 0, 0, 0, 0, 0, 0, 1}, binary.BigEndian.AppendUint32(nil, off))
+    }
+    moovLen := len(mp4Atom4("moov", mp4Atom4("mvhd", make([]byte, 100)),
+        mp4Atom4("trak", mp4Atom4("mdia", mp4Atom4("minf", mp4Atom4("stbl", stco(0)))))))
+    off := uint32(len(ftyp) + moovLen + 8)
+    moov := mp4Atom4("moov", mp4Atom4("mvhd", make([]byte, 100)),
+        mp4Atom4("trak", mp4Atom4("mdia", mp4Atom4("minf", mp4Atom4("stbl", stco(off))))))
+    return bytes.Join([][]byte{ftyp, moov, mp4Atom4("mdat", audio)}, nil)
+}
+
+func TestWriteRatingMP4(t *testing.T) {
+    p := writeFile(t, "a.m4a", mp4Fixture())
+    if err := Write(p, rating(4)); err != nil {
+        t.Fatalf("Write: %v", err)
+    }
+    m := readTags(t, p)
+    if rate, _ := m.Raw()["rate"].(string); strings.Trim(rate, "\x00") != "80" {
+        t.Fatalf("unexpected rate %q", rate)
+    }
+    // the chunk offset must follow the media data that moved behind the grown moov
+    b, _ := os.ReadFile(p)
+    at := bytes.Index(b, []byte("stco"))
+    off := binary.BigEndian.Uint32(b[at+12:])
+    if !bytes.HasPrefix(b[off:], audio) {
+        t.Fatalf("stco offset %d doesn't point at media data", off)
+    }
+}
+
+func str(s string) *string { return &s }
+func num(n int) *int        { return &n }
+
+func TestWriteAllFields(t *testing.T) {
+    var cover bytes.Buffer
+    if err := png.Encode(&cover, image.NewRGBA(image.Rect(0, 0, 2, 3))); err != nil {
+        t.Fatalf("encode cover: %v", err)
+    }
+    u := Update{
+        Title:       str("Ťitle"),
+        Artist:      str("Artist"),
+        Album:       str("Album"),
+        AlbumArtist: str("Various"),
+        Genre:       str("Jazz"),
+        Composer:    str("Composer"),
+        TrackNumber: num(3),
+        DiscNumber:  num(2),
+        Year:        num(1999),
+        Cover:       &Cover{MIME: "image/png", Data: cover.Bytes()},
+    }
+    fixtures := map[string][]byte{"a.mp3": mp3Fixture(), "a.flac": flacFixture(), "a.ogg": oggFixture(), "a.m4a": mp4Fixture()}
+    for name, data := range fixtures {
+        p := writeFile(t, name, data)
+        if err := Write(p, u); err != nil {
+            t.Fatalf("%s: Write: %v", name, err)
+        }
+        m := readTags(t, p)
+        track, _ := m.Track()
+        disc, _ := m.Disc()
+        if m.Title() != "Ťitle" || m.Artist() != "Artist" || m.Album() != "Album" || m.AlbumArtist() != "Various" ||
+            m.Genre() != "Jazz" || m.Composer() != "Composer" || track != 3 || disc != 2 || m.Year() != 1999 {
+            t.Fatalf("%s: unexpected tags %q %q %q %q %q %q %d %d %d", name, m.Title(), m.Artist(), m.Album(),
+                m.AlbumArtist(), m.Genre(), m.Composer(), track, disc, m.Year())
+        }
+        if pic := m.Picture(); pic == nil || !bytes.Equal(pic.Data, cover.Bytes()) {
+            t.Fatalf("%s: cover not written", name)
+        }
+        // clearing removes the tag and leaves the others alone
+        if err := Write(p, Update{Album: str(""), Cover: &Cover{}}); err != nil {
+            t.Fatalf("%s: Write: %v", name, err)
+        }
+        m = readTags(t, p)
+        if m.Album() != "" || m.Picture() != nil || m.Artist() != "Artist" {
+            t.Fatalf("%s: unexpected tags after clearing: album %q artist %q", name, m.Album(), m.Artist())
+        }
+    }
+}
+
+func TestWriteKeepsTotals(t *testing.T) {
+    tag := &id3Tag{version: 3}
+    tag.setText("TRCK", "1/12")
+    tag.apply(Update{TrackNumber: num(4)})
+    if got := tag.text("TRCK"); got != "4/12" {
+        t.Fatalf("unexpected TRCK %q", got)
+    }
+    c := &vorbisComment{fields: []string{"tracknumber=1/9"}}
+    c.apply(Update{TrackNumber: num(2)})
+    if got, _ := c.get("TRACKNUMBER"); got != "2/9" {
+        t.Fatalf("unexpected TRACKNUMBER %q", got)
+    }
+}
+
+func TestWriteRejects(t *testing.T) {
+    p := writeFile(t, "a.wav", []byte("RIFF"))
+    if err := Write(p, rating(1)); !errors.Is(err, ErrUnsupported) {
+        t.Fatalf("expected ErrUnsupported, got %v", err)
+    }
+    p = writeFile(t, "a.mp3", mp3Fixture())
+    if err := Write(p, rating(6)); err == nil {
+        t.Fatalf("expected out of range rating to be rejected")
+    }
+    if err := Write(p, Update{Cover: &Cover{MIME: "image/gif", Data: []byte("GIF89a")}}); err == nil {
+        t.Fatalf("expected unsupported cover type to be rejected")
+    }
+    entries, _ := os.ReadDir(filepath.Dir(p))
+    if len(entries) != 1 {
+        t.Fatalf("temp files left behind: %v", entries)
+    }
+}
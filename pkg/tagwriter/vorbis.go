@@ -0,0 +1,176 @@
+package tagwriter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"strconv"
+	"strings"
+)
+
+// vendorString is used for comment blocks we create from scratch
+const vendorString = "penguin-tunes"
+
+// vorbisComment is the comment structure shared by FLAC, Ogg Vorbis and Opus
+type vorbisComment struct {
+    vendor string
+    fields []string
+}
+
+// parseVorbisComment decodes a comment structure and returns the bytes consumed
+func parseVorbisComment(b []byte) (*vorbisComment, int, error) {
+    pos := 0
+    next := func() (string, error) {
+        if pos+4 > len(b) {
+            return "", fmt.Errorf("truncated vorbis comment")
+        }
+        n := int(binary.LittleEndian.Uint32(b[pos:]))
+        pos += 4
+        if n < 0 || pos+n > len(b) {
+            return "", fmt.Errorf("truncated vorbis comment")
+        }
+        s := string(b[pos : pos+n])
+        pos += n
+        return s, nil
+    }
+    vendor, err := next()
+    if err != nil {
+        return nil, 0, err
+    }
+    if pos+4 > len(b) {
+        return nil, 0, fmt.Errorf("truncated vorbis comment")
+    }
+    count := int(binary.LittleEndian.Uint32(b[pos:]))
+    pos += 4
+    c := &vorbisComment{vendor: vendor}
+    for i := 0; i < count; i++ {
+        f, err := next()
+        if err != nil {
+            return nil, 0, err
+        }
+        c.fields = append(c.fields, f)
+    }
+    return c, pos, nil
+}
+
+func (c *vorbisComment) bytes() []byte {
+    out := binary.LittleEndian.AppendUint32(nil, uint32(len(c.vendor)))
+    out = append(out, c.vendor...)
+    out = binary.LittleEndian.AppendUint32(out, uint32(len(c.fields)))
+    for _, f := range c.fields {
+        out = binary.LittleEndian.AppendUint32(out, uint32(len(f)))
+        out = append(out, f...)
+    }
+    return out
+}
+
+// get returns the first value of key, compared case-insensitively
+func (c *vorbisComment) get(key string) (string, bool) {
+    for _, f := range c.fields {
+        if k, v, ok := strings.Cut(f, "="); ok && strings.EqualFold(k, key) {
+            return v, true
+        }
+    }
+    return "", false
+}
+
+// set replaces all values of key; no values removes the key
+func (c *vorbisComment) set(key string, values ...string) {
+    kept := c.fields[:0]
+    for _, f := range c.fields {
+        if k, _, ok := strings.Cut(f, "="); ok && strings.EqualFold(k, key) {
+            continue
+        }
+        kept = append(kept, f)
+    }
+    c.fields = kept
+    for _, v := range values {
+        c.fields = append(c.fields, key+"="+v)
+    }
+}
+
+// setRating writes RATING on a 0–100 scale unless the file already uses 1–5, and
+// keeps FMPS_RATING in step when present
+func (c *vorbisComment) setRating(stars int) {
+    if stars == 0 {
+        c.set("RATING")
+        c.set("FMPS_RATING")
+        return
+    }
+    value := stars * 20
+    if old, ok := c.get("RATING"); ok {
+        if n, err := strconv.ParseFloat(strings.TrimSpace(old), 64); err == nil && n <= 5 {
+            value = stars
+        }
+    }
+    c.set("RATING", strconv.Itoa(value))
+    if _, ok := c.get("FMPS_RATING"); ok {
+        c.set("FMPS_RATING", strconv.FormatFloat(float64(stars)/5, 'f', 1, 64))
+    }
+}
+
+// setPosition writes TRACKNUMBER or DISCNUMBER, keeping a "/total" suffix if present
+func (c *vorbisComment) setPosition(key string, n int) {
+    value := number(n)
+    if old, ok := c.get(key); ok && value != "" {
+        if _, total, ok := strings.Cut(old, "/"); ok && total != "" {
+            value += "/" + total
+        }
+    }
+    if value == "" {
+        c.set(key)
+        return
+    }
+    c.set(key, value)
+}
+
+// apply writes u into the comment; covers are left to the container
+func (c *vorbisComment) apply(u Update) {
+    for _, f := range u.textFields() {
+        switch {
+        case f.value == nil:
+        case *f.value == "":
+            c.set(f.vorb)
+        default:
+            c.set(f.vorb, *f.value)
+        }
+    }
+    if u.TrackNumber != nil {
+        c.setPosition("TRACKNUMBER", *u.TrackNumber)
+    }
+    if u.DiscNumber != nil {
+        c.setPosition("DISCNUMBER", *u.DiscNumber)
+    }
+    if u.Year != nil {
+        if *u.Year == 0 {
+            c.set("DATE")
+        } else {
+            c.set("DATE", number(*u.Year))
+        }
+    }
+    if u.Rating != nil {
+        c.setRating(*u.Rating)
+    }
+}
+
+// pictureBlock encodes a front cover in the FLAC PICTURE layout, which Ogg
+// streams embed base64 encoded as METADATA_BLOCK_PICTURE
+func pictureBlock(c Cover) []byte {
+    var width, height int
+    if cfg, _, err := image.DecodeConfig(bytes.NewReader(c.Data)); err == nil {
+        width, height = cfg.Width, cfg.Height
+    }
+    out := binary.BigEndian.AppendUint32(nil, 3) // front cover
+    out = binary.BigEndian.AppendUint32(out, uint32(len(c.MIME)))
+    out = append(out, c.MIME...)
+    out = binary.BigEndian.AppendUint32(out, 0) // description
+    out = binary.BigEndian.AppendUint32(out, uint32(width))
+    out = binary.BigEndian.AppendUint32(out, uint32(height))
+    out = binary.BigEndian.AppendUint32(out, 24) // colour depth
+    out = binary.BigEndian.AppendUint32(out, 0)  // indexed colours
+    out = binary.BigEndian.AppendUint32(out, uint32(len(c.Data)))
+    return append(out, c.Data...)
+}
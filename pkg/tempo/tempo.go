@@ -0,0 +1,140 @@
+package tempo
+
+import (
+	"math"
+
+	"penguin-tunes/pkg/dsp"
+)
+
+// analysisRate is the rate audio is resampled to before onset detection
+const analysisRate = 11025
+
+const (
+    frameSize = 1024
+    hop       = 128
+    minBPM    = 60
+    maxBPM    = 200
+)
+
+// preferredBPM is the centre of the prior that settles half and double tempo ambiguity
+const preferredBPM = 120
+
+// Estimate returns the tempo of mono samples at rate in beats per minute and
+// a confidence from 0 to 1; both are 0 when no pulse is found
+func Estimate(samples []float32, rate int) (float64, float64) {
+    env := onsetEnvelope(dsp.Resample(samples, rate, analysisRate))
+    fps := float64(analysisRate) / hop
+    minLag := int(math.Floor(60 * fps / maxBPM))
+    maxLag := int(math.Ceil(60 * fps / minBPM))
+    if len(env) < 4*maxLag {
+        return 0, 0
+    }
+    // centred, so the correlation of unrelated frames averages out to 0
+    var mean float64
+    for _, v := range env {
+        mean += v
+    }
+    mean /= float64(len(env))
+    for i := range env {
+        env[i] -= mean
+    }
+    ac := autocorrelate(env, 4*maxLag+2)
+    if ac[0] <= 0 {
+        return 0, 0
+    }
+
+    // a beat period also lines up with its multiples, so those count towards it
+    score := func(lag float64) float64 {
+        var s float64
+        for k := 1; k <= 4; k++ {
+            s += interpolate(ac, lag*float64(k)) / float64(k)
+        }
+        return s
+    }
+    best, bestScore := 0, math.Inf(-1)
+    for lag := minLag; lag <= maxLag; lag++ {
+        bpm := 60 * fps / float64(lag)
+        octaves := math.Log2(bpm / preferredBPM)
+        s := score(float64(lag)) * math.Exp(-0.5*octaves*octaves)
+        if s > bestScore {
+            best, bestScore = lag, s
+        }
+    }
+    // refine the integer lag with a parabola through its neighbours
+    lag := float64(best)
+    if best > minLag && best < maxLag {
+        a, b, c := score(lag-1), score(lag), score(lag+1)
+        if d := a - 2*b + c; d < 0 {
+            lag += 0.5 * (a - c) / d
+        }
+    }
+    bpm := 60 * fps / lag
+    confidence := math.Max(0, math.Min(1, interpolate(ac, lag)/ac[0]))
+    return math.Round(bpm*10) / 10, confidence
+}
+
+// onsetEnvelope is the positive spectral flux of log magnitudes with its local mean removed
+func onsetEnvelope(x []float32) []float64 {
+    window := dsp.Hamming(frameSize)
+    var prev []float64
+    var flux []float64
+    for start := 0; start+frameSize <= len(x); start += hop {
+        power := dsp.PowerSpectrum(x[start:start+frameSize], window)
+        mag := make([]float64, len(power))
+        for i, p := range power {
+            mag[i] = math.Log1p(1000 * math.Sqrt(p))
+        }
+        var f float64
+        if prev != nil {
+            for i := range mag {
+                f += math.Max(0, mag[i]-prev[i])
+            }
+        }
+        flux = append(flux, f)
+        prev = mag
+    }
+    // about a third of a second either side
+    const half = 16
+    env := make([]float64, len(flux))
+    for i := range flux {
+        lo, hi := max(0, i-half), min(len(flux), i+half+1)
+        var sum float64
+        for _, v := range flux[lo:hi] {
+            sum += v
+        }
+        env[i] = math.Max(0, flux[i]-sum/float64(hi-lo))
+    }
+    return env
+}
+
+// autocorrelate returns the autocorrelation of x for lags below n, through the FFT
+func autocorrelate(x []float64, n int) []float64 {
+    size := 1
+    for size < 2*len(x) {
+        size <<= 1
+    }
+    re, im := make([]float64, size), make([]float64, size)
+    copy(re, x)
+    dsp.FFT(re, im)
+    for i := range re {
+        re[i], im[i] = re[i]*re[i]+im[i]*im[i], 0
+    }
+    // the inverse transform of a real, even spectrum is the forward one scaled
+    dsp.FFT(re, im)
+    out := make([]float64, min(n, len(x)))
+    for i := range out {
+        // unbiased, so long lags aren't penalized for overlapping less
+        out[i] = re[i] / float64(size) / float64(len(x)-i)
+    }
+    return out
+}
+
+// interpolate reads x at a fractional index, 0 past the end
+func interpolate(x []float64, pos float64) float64 {
+    i := int(pos)
+    if i+1 >= len(x) {
+        return 0
+    }
+    frac := pos - float64(i)
+    return x[i]*(1-frac) + x[i+1]*frac
+}
@@ -0,0 +1,54 @@
+package tempo
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// drums synthesizes a kick on every beat and a hi-hat between beats over a quiet pad
+func drums(bpm float64, seconds float64, rate int, seed int64) []float32 {
+    rng := rand.New(rand.NewSource(seed))
+    out := make([]float32, int(seconds*float64(rate)))
+    period := 60 / bpm * float64(rate)
+    for i := range out {
+        out[i] = float32(0.05 * math.Sin(2*math.Pi*220*float64(i)/float64(rate)))
+    }
+    for beat := 0.0; beat < float64(len(out)); beat += period / 2 {
+        start := int(beat)
+        kick := int(beat/period*2)%2 == 0
+        for j := 0; j < rate/10 && start+j < len(out); j++ {
+            decay := math.Exp(-float64(j) / float64(rate) * 40)
+            if kick {
+                out[start+j] += float32(0.8 * decay * math.Sin(2*math.Pi*60*float64(j)/float64(rate)))
+            } else {
+                out[start+j] += float32(0.3 * decay * (rng.Float64()*2 - 1))
+            }
+        }
+    }
+    return out
+}
+
+func TestEstimate(t *testing.T) {
+    for _, bpm := range []float64{90, 128, 174} {
+        got, conf := Estimate(drums(bpm, 30, 44100, 1), 44100)
+        if math.Abs(got-bpm) > 1 {
+            t.Fatalf("Estimate(%v) = %v", bpm, got)
+        }
+        if conf < 0.3 {
+            t.Fatalf("confidence for a steady %v BPM beat = %.2f", bpm, conf)
+        }
+    }
+
+    rng := rand.New(rand.NewSource(2))
+    noise := make([]float32, 30*22050)
+    for i := range noise {
+        noise[i] = float32(rng.Float64()*2-1) * 0.3
+    }
+    if _, conf := Estimate(noise, 22050); conf > 0.2 {
+        t.Fatalf("confidence for noise = %.2f", conf)
+    }
+    if bpm, conf := Estimate(make([]float32, 1000), 44100); bpm != 0 || conf != 0 {
+        t.Fatalf("Estimate of a short clip = %v, %v", bpm, conf)
+    }
+}
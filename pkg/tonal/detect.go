@@ -0,0 +1,111 @@
+package tonal
+
+import (
+	"math"
+
+	"penguin-tunes/pkg/dsp"
+)
+
+const (
+    analysisRate = 11025
+    frameSize    = 4096
+    hop          = 2048
+    minFreq      = 55
+    maxFreq      = 2000
+)
+
+// Krumhansl–Kessler key profiles, starting at the tonic
+var (
+    majorProfile = [12]float64{6.35, 2.23, 3.48, 2.33, 4.38, 4.09, 2.52, 5.19, 2.39, 3.66, 2.29, 2.88}
+    minorProfile = [12]float64{6.33, 2.68, 3.52, 5.38, 2.60, 3.53, 2.54, 4.75, 3.98, 2.69, 3.34, 3.17}
+)
+
+// Detect estimates the key of mono samples at rate and returns it with a
+// confidence from 0 to 1, which is low when another key fits almost as well.
+// ok is false for silence or clips too short to judge.
+func Detect(samples []float32, rate int) (k Key, confidence float64, ok bool) {
+    chroma := Chroma(samples, rate)
+    var total float64
+    for _, v := range chroma {
+        total += v
+    }
+    if total == 0 {
+        return Key{}, 0, false
+    }
+    best, second := math.Inf(-1), math.Inf(-1)
+    for tonic := 0; tonic < 12; tonic++ {
+        for _, minor := range []bool{false, true} {
+            profile := majorProfile
+            if minor {
+                profile = minorProfile
+            }
+            var rotated [12]float64
+            for i := range rotated {
+                rotated[(tonic+i)%12] = profile[i]
+            }
+            r := correlation(chroma, rotated)
+            if r > best {
+                best, second = r, best
+                k = Key{Tonic: tonic, Minor: minor}
+            } else if r > second {
+                second = r
+            }
+        }
+    }
+    if best <= 0 {
+        return k, 0, true
+    }
+    return k, math.Max(0, math.Min(1, (best-second)/(1-second))), true
+}
+
+// Chroma sums the spectral energy of mono samples at rate into the 12 pitch
+// classes, starting at C; every frame is normalized so loud passages don't dominate
+func Chroma(samples []float32, rate int) [12]float64 {
+    x := dsp.Resample(samples, rate, analysisRate)
+    window := dsp.Hamming(frameSize)
+    lo := int(math.Ceil(minFreq * frameSize / float64(analysisRate)))
+    hi := int(math.Floor(maxFreq * frameSize / float64(analysisRate)))
+    classes := make([]int, hi+1)
+    for i := lo; i <= hi; i++ {
+        f := float64(i) * analysisRate / frameSize
+        midi := 69 + 12*math.Log2(f/440)
+        classes[i] = (int(math.Round(midi))%12 + 12) % 12
+    }
+    var chroma [12]float64
+    for start := 0; start+frameSize <= len(x); start += hop {
+        power := dsp.PowerSpectrum(x[start:start+frameSize], window)
+        var frame [12]float64
+        var sum float64
+        for i := lo; i <= hi; i++ {
+            m := math.Sqrt(power[i])
+            frame[classes[i]] += m
+            sum += m
+        }
+        if sum < 1e-3 {
+            continue
+        }
+        for c := range chroma {
+            chroma[c] += frame[c] / sum
+        }
+    }
+    return chroma
+}
+
+// correlation is Pearson's r between a and b
+func correlation(a, b [12]float64) float64 {
+    var ma, mb float64
+    for i := range a {
+        ma += a[i] / 12
+        mb += b[i] / 12
+    }
+    var cov, va, vb float64
+    for i := range a {
+        cov += (a[i] - ma) * (b[i] - mb)
+        va += (a[i] - ma) * (a[i] - ma)
+        vb += (b[i] - mb) * (b[i] - mb)
+    }
+    if va == 0 || vb == 0 {
+        return 0
+    }
+    return cov / math.Sqrt(va*vb)
+}
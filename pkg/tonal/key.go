@@ -0,0 +1,94 @@
+package tonal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Key is a musical key; Tonic is the pitch class, 0 for C up to 11 for B
+type Key struct {
+    Tonic int
+    Minor bool
+}
+
+// names follow the spelling DJ software commonly shows
+var (
+    majorNames = [12]string{"C", "Db", "D", "Eb", "E", "F", "F#", "G", "Ab", "A", "Bb", "B"}
+    minorNames = [12]string{"Cm", "C#m", "Dm", "Ebm", "Em", "Fm", "F#m", "Gm", "G#m", "Am", "Bbm", "Bm"}
+)
+
+// String returns the key as written in tags, like "Am" or "F#"
+func (k Key) String() string {
+    if k.Minor {
+        return minorNames[k.Tonic]
+    }
+    return majorNames[k.Tonic]
+}
+
+// wheel returns the key's position on the circle of fifths, 0 for C major and A minor
+func (k Key) wheel() int {
+    major := k.Tonic
+    if k.Minor {
+        major = (k.Tonic + 3) % 12
+    }
+    return major * 7 % 12
+}
+
+// Camelot returns the key's Camelot wheel code, like "8A" for A minor
+func (k Key) Camelot() string {
+    suffix := "B"
+    if k.Minor {
+        suffix = "A"
+    }
+    return strconv.Itoa((k.wheel()+7)%12+1) + suffix
+}
+
+var pitchClasses = map[string]int{"c": 0, "d": 2, "e": 4, "f": 5, "g": 7, "a": 9, "b": 11}
+
+// ParseKey reads a key written as a name ("Am", "F# major", "Bbmin"), a
+// Camelot code ("8A") or an Open Key code ("1m")
+func ParseKey(s string) (Key, error) {
+    in := strings.ToLower(strings.TrimSpace(s))
+    if in == "" {
+        return Key{}, fmt.Errorf("empty key")
+    }
+    if n, err := strconv.Atoi(strings.TrimRight(in, "abdm")); err == nil && n >= 1 && n <= 12 && len(in) == len(strconv.Itoa(n))+1 {
+        var major int
+        var minor bool
+        switch in[len(in)-1] {
+        case 'a', 'b':
+            major = (n - 8 + 12) * 7 % 12 // 7 is its own inverse mod 12
+            minor = in[len(in)-1] == 'a'
+        case 'd', 'm':
+            major = (n - 1) * 7 % 12
+            minor = in[len(in)-1] == 'm'
+        }
+        if minor {
+            return Key{Tonic: (major + 9) % 12, Minor: true}, nil
+        }
+        return Key{Tonic: major}, nil
+    }
+    tonic, ok := pitchClasses[in[:1]]
+    if !ok {
+        return Key{}, fmt.Errorf("unknown key %q", s)
+    }
+    rest := in[1:]
+    switch {
+    case strings.HasPrefix(rest, "#"), strings.HasPrefix(rest, "♯"):
+        tonic++
+        rest = strings.TrimPrefix(strings.TrimPrefix(rest, "#"), "♯")
+    case strings.HasPrefix(rest, "b"), strings.HasPrefix(rest, "♭"):
+        tonic--
+        rest = strings.TrimPrefix(strings.TrimPrefix(rest, "b"), "♭")
+    }
+    k := Key{Tonic: (tonic + 12) % 12}
+    switch strings.TrimSpace(rest) {
+    case "", "maj", "major":
+    case "m", "min", "minor":
+        k.Minor = true
+    default:
+        return Key{}, fmt.Errorf("unknown key %q", s)
+    }
+    return k, nil
+}
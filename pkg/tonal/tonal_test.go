@@ -0,0 +1,80 @@
+package tonal
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseKey(t *testing.T) {
+    cases := []struct {
+        in      string
+        want    string
+        camelot string
+    }{
+        {"Am", "Am", "8A"},
+        {"C", "C", "8B"},
+        {"F# major", "F#", "2B"},
+        {"Gb", "F#", "2B"},
+        {"bbmin", "Bbm", "3A"},
+        {"Ebm", "Ebm", "2A"},
+        {"8A", "Am", "8A"},
+        {"12B", "E", "12B"},
+        {"1A", "G#m", "1A"},
+        {"1m", "Am", "8A"},
+        {"2d", "G", "9B"},
+        {"B", "B", "1B"},
+    }
+    for _, c := range cases {
+        k, err := ParseKey(c.in)
+        if err != nil {
+            t.Fatalf("ParseKey(%q): %v", c.in, err)
+        }
+        if k.String() != c.want || k.Camelot() != c.camelot {
+            t.Fatalf("ParseKey(%q) = %s (%s), want %s (%s)", c.in, k, k.Camelot(), c.want, c.camelot)
+        }
+    }
+    for _, bad := range []string{"", "H", "13A", "o", "Cx"} {
+        if _, err := ParseKey(bad); err == nil {
+            t.Fatalf("ParseKey(%q) accepted", bad)
+        }
+    }
+}
+
+// progression renders chords given as MIDI note lists, one per second, with a few harmonics
+func progression(rate int, chords ...[]int) []float32 {
+    out := make([]float32, len(chords)*rate)
+    for c, notes := range chords {
+        for i := 0; i < rate; i++ {
+            var v float64
+            for _, n := range notes {
+                f := 440 * math.Pow(2, float64(n-69)/12)
+                for h := 1.0; h <= 3; h++ {
+                    v += math.Sin(2*math.Pi*f*h*float64(i)/float64(rate)) / h
+                }
+            }
+            out[c*rate+i] = float32(v / 8)
+        }
+    }
+    return out
+}
+
+func TestDetect(t *testing.T) {
+    // I–IV–V–I in C major and i–iv–V–i in A minor
+    cMajor := progression(22050, []int{60, 64, 67}, []int{65, 69, 72}, []int{67, 71, 74}, []int{60, 64, 67})
+    aMinor := progression(22050, []int{57, 60, 64}, []int{62, 65, 69}, []int{64, 68, 71}, []int{57, 60, 64})
+    for _, c := range []struct {
+        x    []float32
+        want string
+    }{{cMajor, "C"}, {aMinor, "Am"}} {
+        k, conf, ok := Detect(c.x, 22050)
+        if !ok || k.String() != c.want {
+            t.Fatalf("Detect = %s, %v, want %s", k, ok, c.want)
+        }
+        if conf <= 0 {
+            t.Fatalf("confidence for %s = %v", c.want, conf)
+        }
+    }
+    if _, _, ok := Detect(make([]float32, 22050), 22050); ok {
+        t.Fatalf("Detect found a key in silence")
+    }
+}
@@ -0,0 +1,171 @@
+package waveform
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	cfg "penguin-tunes/pkg/config"
+)
+
+// Cache files start with a fixed header: magic, version, the stamp of the
+// audio file they were made from and the bucket count. Each bucket follows as
+// a signed byte pair (min, max) scaled by 127.
+const (
+    magic      = "PTWF"
+    version    = 1
+    headerSize = 4 + 4 + 8 + 8 + 4
+    ext        = ".peaks"
+)
+
+// Stamp identifies the state of an audio file; peaks made from a file with a different stamp are stale
+type Stamp struct {
+    Size    int64
+    ModTime int64
+}
+
+// StampOf stats path
+func StampOf(path string) (Stamp, error) {
+    fi, err := os.Stat(path)
+    if err != nil {
+        return Stamp{}, err
+    }
+    return Stamp{Size: fi.Size(), ModTime: fi.ModTime().UnixNano()}, nil
+}
+
+// Cache stores one peak file per track ID in a directory
+type Cache struct {
+    dir      string
+    readOnly bool
+}
+
+// NewCache creates a cache in dir
+func NewCache(dir string) *Cache {
+    return &Cache{dir: dir}
+}
+
+// NewCacheAtBase creates a cache in baseDir/waveforms
+func NewCacheAtBase(baseDir string) *Cache {
+    return NewCache(filepath.Join(baseDir, "waveforms"))
+}
+
+func (c *Cache) file(id string) string {
+    return filepath.Join(c.dir, id+ext)
+}
+
+// readHeader returns the stamp and bucket count of a cache file
+func readHeader(r io.Reader) (Stamp, int, error) {
+    var h [headerSize]byte
+    if _, err := io.ReadFull(r, h[:]); err != nil {
+        return Stamp{}, 0, err
+    }
+    if string(h[:4]) != magic || h[4] != version {
+        return Stamp{}, 0, fmt.Errorf("not a waveform cache file")
+    }
+    st := Stamp{
+        Size:    int64(binary.LittleEndian.Uint64(h[8:])),
+        ModTime: int64(binary.LittleEndian.Uint64(h[16:])),
+    }
+    return st, int(binary.LittleEndian.Uint32(h[24:])), nil
+}
+
+// Fresh reports whether peaks for id exist and were made from a file with stamp st
+func (c *Cache) Fresh(id string, st Stamp) bool {
+    f, err := os.Open(c.file(id))
+    if err != nil {
+        return false
+    }
+    defer f.Close()
+    got, _, err := readHeader(f)
+    return err == nil && got == st
+}
+
+// Get returns the peaks stored for id, or nil when there are none or they are stale
+func (c *Cache) Get(id string, st Stamp) (*Peaks, error) {
+    f, err := os.Open(c.file(id))
+    if errors.Is(err, os.ErrNotExist) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    got, n, err := readHeader(f)
+    if err != nil {
+        return nil, fmt.Errorf("read waveform %s: %w", id, err)
+    }
+    if got != st {
+        return nil, nil
+    }
+    data := make([]byte, 2*n)
+    if _, err := io.ReadFull(f, data); err != nil {
+        return nil, fmt.Errorf("read waveform %s: %w", id, err)
+    }
+    p := &Peaks{Min: make([]float32, n), Max: make([]float32, n)}
+    for i := 0; i < n; i++ {
+        p.Min[i] = float32(int8(data[2*i])) / 127
+        p.Max[i] = float32(int8(data[2*i+1])) / 127
+    }
+    return p, nil
+}
+
+// SetReadOnly makes Put and Prune fail with cfg.ErrReadOnly, for processes
+// that don't hold the data dir lock; call it before using the cache
+func (c *Cache) SetReadOnly(ro bool) {
+    c.readOnly = ro
+}
+
+// Put stores p for id, made from a file with stamp st
+func (c *Cache) Put(id string, st Stamp, p Peaks) error {
+    if c.readOnly {
+        return fmt.Errorf("put waveform: %w", cfg.ErrReadOnly)
+    }
+    if err := os.MkdirAll(c.dir, 0o755); err != nil {
+        return fmt.Errorf("mkdir waveforms: %w", err)
+    }
+    b := make([]byte, headerSize, headerSize+2*p.Len())
+    copy(b, magic)
+    b[4] = version
+    binary.LittleEndian.PutUint64(b[8:], uint64(st.Size))
+    binary.LittleEndian.PutUint64(b[16:], uint64(st.ModTime))
+    binary.LittleEndian.PutUint32(b[24:], uint32(p.Len()))
+    for i := range p.Min {
+        b = append(b, byte(quantize(p.Min[i])), byte(quantize(p.Max[i])))
+    }
+    if err := cfg.WriteFileAtomic(c.file(id), b); err != nil {
+        return fmt.Errorf("write waveform: %w", err)
+    }
+    return nil
+}
+
+// Prune removes the peaks of tracks not in keep
+func (c *Cache) Prune(keep map[string]bool) error {
+    if c.readOnly {
+        return fmt.Errorf("prune waveforms: %w", cfg.ErrReadOnly)
+    }
+    entries, err := os.ReadDir(c.dir)
+    if errors.Is(err, os.ErrNotExist) {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    for _, e := range entries {
+        id, ok := strings.CutSuffix(e.Name(), ext)
+        if ok && !keep[id] {
+            if err := os.Remove(filepath.Join(c.dir, e.Name())); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+func quantize(v float32) int8 {
+    return int8(math.Round(math.Max(-1, math.Min(1, float64(v))) * 127))
+}
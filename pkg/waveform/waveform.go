@@ -0,0 +1,76 @@
+package waveform
+
+import "math"
+
+// DefaultBuckets is the number of min/max pairs stored per track
+const DefaultBuckets = 1000
+
+// blocksPerSecond is the resolution peaks are collected at before they are bucketed
+const blocksPerSecond = 100
+
+// Peaks holds the lowest and highest sample of each bucket, in [-1, 1]
+type Peaks struct {
+    Min []float32 `json:"min"`
+    Max []float32 `json:"max"`
+}
+
+// Len returns the number of buckets
+func (p Peaks) Len() int { return len(p.Min) }
+
+// Builder collects peaks from streamed samples without knowing the length in advance
+type Builder struct {
+    per      int
+    n        int
+    lo, hi   float32
+    min, max []float32
+}
+
+// NewBuilder starts collecting peaks of mono audio at rate
+func NewBuilder(rate int) *Builder {
+    return &Builder{per: max(1, rate/blocksPerSecond)}
+}
+
+// Write adds the next samples
+func (b *Builder) Write(samples []float32) {
+    for _, v := range samples {
+        if b.n == 0 {
+            b.lo, b.hi = v, v
+        } else {
+            b.lo, b.hi = min(b.lo, v), max(b.hi, v)
+        }
+        b.n++
+        if b.n == b.per {
+            b.flush()
+        }
+    }
+}
+
+func (b *Builder) flush() {
+    b.min = append(b.min, b.lo)
+    b.max = append(b.max, b.hi)
+    b.n = 0
+}
+
+// Peaks returns the collected peaks in buckets, or fewer for very short audio
+func (b *Builder) Peaks(buckets int) Peaks {
+    if b.n > 0 {
+        b.flush()
+    }
+    n := len(b.min)
+    buckets = min(buckets, n)
+    p := Peaks{Min: make([]float32, buckets), Max: make([]float32, buckets)}
+    for j := 0; j < buckets; j++ {
+        from, to := j*n/buckets, (j+1)*n/buckets
+        lo, hi := b.min[from], b.max[from]
+        for i := from + 1; i < to; i++ {
+            lo, hi = min(lo, b.min[i]), max(hi, b.max[i])
+        }
+        p.Min[j] = clamp(lo)
+        p.Max[j] = clamp(hi)
+    }
+    return p
+}
+
+func clamp(v float32) float32 {
+    return float32(math.Max(-1, math.Min(1, float64(v))))
+}
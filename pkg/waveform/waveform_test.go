@@ -0,0 +1,79 @@
+package waveform
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuilderPeaks(t *testing.T) {
+    rate := 8000
+    b := NewBuilder(rate)
+    // 10 s rising from silence to full scale, written in odd-sized chunks
+    samples := make([]float32, 10*rate)
+    for i := range samples {
+        amp := float64(i) / float64(len(samples))
+        samples[i] = float32(amp * math.Sin(float64(i)))
+    }
+    for i := 0; i < len(samples); i += 333 {
+        b.Write(samples[i:min(i+333, len(samples))])
+    }
+    p := b.Peaks(100)
+    if p.Len() != 100 {
+        t.Fatalf("got %d buckets, want 100", p.Len())
+    }
+    for j := 0; j < p.Len(); j++ {
+        want := float32(j+1) / 100
+        if p.Max[j] > want || p.Max[j] < want-0.02 || p.Min[j] < -want || p.Min[j] > -want+0.02 {
+            t.Fatalf("bucket %d = [%v, %v], want about ±%v", j, p.Min[j], p.Max[j], want)
+        }
+    }
+
+    short := NewBuilder(rate)
+    short.Write(samples[:rate/10])
+    if n := short.Peaks(DefaultBuckets).Len(); n != 10 {
+        t.Fatalf("0.1 s gave %d buckets, want 10", n)
+    }
+}
+
+func TestCache(t *testing.T) {
+    base := t.TempDir()
+    c := NewCacheAtBase(base)
+    st := Stamp{Size: 1234, ModTime: 5678}
+    if p, err := c.Get("a", st); p != nil || err != nil {
+        t.Fatalf("Get before Put = %v, %v", p, err)
+    }
+    in := Peaks{Min: []float32{-1, -0.5, 0}, Max: []float32{1, 0.5, 0.25}}
+    if err := c.Put("a", st, in); err != nil {
+        t.Fatalf("Put: %v", err)
+    }
+    if !c.Fresh("a", st) || c.Fresh("a", Stamp{Size: 1234, ModTime: 9999}) {
+        t.Fatalf("Fresh does not follow the stamp")
+    }
+    p, err := c.Get("a", st)
+    if err != nil || p == nil || p.Len() != 3 {
+        t.Fatalf("Get = %v, %v", p, err)
+    }
+    for i := range in.Min {
+        if math.Abs(float64(p.Min[i]-in.Min[i])) > 0.01 || math.Abs(float64(p.Max[i]-in.Max[i])) > 0.01 {
+            t.Fatalf("bucket %d = [%v, %v], want [%v, %v]", i, p.Min[i], p.Max[i], in.Min[i], in.Max[i])
+        }
+    }
+    if p, err := c.Get("a", Stamp{Size: 1}); p != nil || err != nil {
+        t.Fatalf("Get with a changed file = %v, %v", p, err)
+    }
+
+    if err := c.Put("b", st, in); err != nil {
+        t.Fatalf("Put: %v", err)
+    }
+    if err := c.Prune(map[string]bool{"b": true}); err != nil {
+        t.Fatalf("Prune: %v", err)
+    }
+    if _, err := os.Stat(filepath.Join(base, "waveforms", "a.peaks")); !os.IsNotExist(err) {
+        t.Fatalf("pruned entry still exists: %v", err)
+    }
+    if !c.Fresh("b", st) {
+        t.Fatalf("kept entry was pruned")
+    }
+}